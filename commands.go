@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"jatistore/internal/config"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+	"jatistore/internal/signing"
+)
+
+// runCommand runs a command given on the command line instead of the server
+func runCommand(userService *services.UserService, inventoryService *services.InventoryService, args []string) error {
+	switch args[0] {
+	case "create-admin":
+		return createAdmin(userService, args[1:])
+	case "reconcile":
+		return reconcile(inventoryService, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, the commands are create-admin, generate-key and reconcile", args[0])
+	}
+}
+
+// createAdmin creates an admin account. The password can be passed in
+// ADMIN_PASSWORD instead of on the command line to keep it out of the shell
+// history.
+func createAdmin(userService *services.UserService, args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := flags.String("username", "", "admin username")
+	email := flags.String("email", "", "admin email")
+	password := flags.String("password", os.Getenv("ADMIN_PASSWORD"), "admin password, defaults to ADMIN_PASSWORD")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" || *email == "" || *password == "" {
+		flags.Usage()
+		return fmt.Errorf("create-admin needs -username, -email and -password")
+	}
+
+	user, err := userService.CreateAdmin(&models.RegisterRequest{
+		Username: *username,
+		Email:    *email,
+		Password: *password,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create admin: %w", err)
+	}
+
+	log.Printf("Created admin %s (%s)", user.Username, user.ID)
+	return nil
+}
+
+// reconcile checks every inventory quantity against the stock ledger and
+// lists those that differ. With -rebuild they are replaced by the ledger's.
+// Mismatches left in place make it fail, so that it can be run on a schedule.
+func reconcile(inventoryService *services.InventoryService, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	rebuild := flags.Bool("rebuild", false, "replace mismatched quantities by the ledger's")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := inventoryService.Reconcile(*rebuild, nil)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile inventory: %w", err)
+	}
+
+	for _, mismatch := range report.Mismatches {
+		log.Printf("Product %s at %s: inventory has %d, ledger has %d", mismatch.ProductID, mismatch.Location, mismatch.Quantity, mismatch.LedgerQuantity)
+	}
+
+	switch {
+	case len(report.Mismatches) == 0:
+		log.Printf("Checked %d stock levels, all match the ledger", report.Checked)
+	case report.Rebuilt:
+		log.Printf("Checked %d stock levels, rebuilt %d from the ledger", report.Checked, len(report.Mismatches))
+	default:
+		return fmt.Errorf("checked %d stock levels, %d do not match the ledger; run reconcile -rebuild to replace them by the ledger's", report.Checked, len(report.Mismatches))
+	}
+
+	return nil
+}
+
+// generateKey writes a new JWT signing key to JWT_KEYS_DIR. The key is named
+// after the current time unless -kid is given, so that rotated keys sort in
+// the order they were made.
+func generateKey(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("generate-key", flag.ContinueOnError)
+	alg := flags.String("alg", signing.AlgEdDSA, "signing algorithm, EdDSA or RS256")
+	dir := flags.String("dir", cfg.JWTKeysDir, "key directory, defaults to JWT_KEYS_DIR")
+	kid := flags.String("kid", time.Now().Format("20060102-150405"), "key ID, defaults to the current time")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		flags.Usage()
+		return fmt.Errorf("generate-key needs -dir or JWT_KEYS_DIR")
+	}
+
+	path, err := signing.GenerateKeyFile(*dir, *alg, *kid)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	log.Printf("Wrote %s key %s to %s. It is published in the JWKS from the next start; sign with it by setting JWT_SIGNING_KEY=%s", *alg, *kid, path, *kid)
+	return nil
+}
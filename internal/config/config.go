@@ -3,6 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"jatistore/internal/money"
 )
 
 type Config struct {
@@ -15,6 +20,118 @@ type Config struct {
 	Port        string
 	Environment string
 	BaseURL     string
+
+	// SalesLocation is the inventory location completed orders draw stock
+	// from. When empty, the location holding the most stock is used.
+	SalesLocation string
+	// AllowBackorder lets orders complete when stock is insufficient,
+	// driving the inventory quantity below zero instead of rejecting.
+	AllowBackorder bool
+	// StockAlertEmails are emailed when stock crosses a reorder level.
+	// Without any, stock alerts are only logged.
+	StockAlertEmails []string
+	// StockAlertInterval is how often reorder levels are checked besides
+	// whenever stock changes
+	StockAlertInterval time.Duration
+	// ReplenishmentSalesDays is how many days of sales replenishment
+	// suggestions average daily sales over, and ReplenishmentCoverDays how
+	// many days of sales replenished stock should last
+	ReplenishmentSalesDays int
+	ReplenishmentCoverDays int
+	// RefundApprovalThreshold is the largest refund a cashier may issue
+	// without a manager
+	RefundApprovalThreshold money.Amount
+	// DiscountApprovalThreshold is the largest manual discount on an order
+	// a cashier may give without a manager
+	DiscountApprovalThreshold money.Amount
+	// ApprovalTokenTTL is how long a supervisor's approval token can be used
+	ApprovalTokenTTL time.Duration
+	// Currency is the ISO 4217 code of the store currency, which decides
+	// how computed amounts are rounded
+	Currency string
+	// PricesIncludeTax is true when product prices already contain tax
+	PricesIncludeTax bool
+
+	// AllowPublicRegistration lets anyone register an account with the
+	// "user" role. Staff accounts are always created through invites.
+	AllowPublicRegistration bool
+	// SetupToken creates the first admin account through POST /auth/setup.
+	// A random token is generated and logged when it is empty.
+	SetupToken string
+	// InviteTTL is how long a staff invite can be redeemed for
+	InviteTTL time.Duration
+	// JWTKeysDir holds the PEM private keys that sign and verify tokens,
+	// one "<kid>.pem" file per key
+	JWTKeysDir string
+	// JWTSigningKey is the kid of the key in JWTKeysDir that signs tokens.
+	// It may be empty when the directory holds a single key.
+	JWTSigningKey string
+	// JWTSecret signs tokens with HS256 when JWTKeysDir is empty
+	JWTSecret string
+	// JWTIssuer is the iss claim of issued tokens
+	JWTIssuer string
+	// AccessTokenTTL is how long a JWT access token is accepted
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is how long a refresh token can be exchanged for a
+	// new access token
+	RefreshTokenTTL time.Duration
+	// TOTPIssuer names accounts in authenticator apps
+	TOTPIssuer string
+	// TwoFactorChallengeTTL is how long the second step of a two-factor
+	// login can be completed for
+	TwoFactorChallengeTTL time.Duration
+	// LoginMaxFailures is how many failed logins lock a username out
+	LoginMaxFailures int
+	// LoginIPMaxFailures is how many failed logins, for any usernames,
+	// lock a client IP out
+	LoginIPMaxFailures int
+	// PINMaxFailures is how many wrong supervisor PINs lock out approvals
+	// by one supervisor's PIN
+	PINMaxFailures int
+	// LoginLockoutDuration is how long a lockout lasts
+	LoginLockoutDuration time.Duration
+	// LoginBackoffBase is the wait after the first failed login, doubled
+	// by each further failure up to LoginBackoffMax
+	LoginBackoffBase time.Duration
+	LoginBackoffMax  time.Duration
+	// LoginFailureWindow is how long failed logins are counted for
+	LoginFailureWindow time.Duration
+	// ProxyHeader is the header a reverse proxy puts the client IP in, such
+	// as X-Real-IP. It is only read from requests that come from one of
+	// TrustedProxies, so that clients cannot pick their own IP.
+	ProxyHeader string
+	// TrustedProxies are the IPs or CIDR ranges of the reverse proxies
+	// allowed to set ProxyHeader
+	TrustedProxies []string
+	// PasswordResetTTL is how long an emailed password reset token works
+	PasswordResetTTL time.Duration
+	// PasswordResetURL is the front end page that sets a new password; the
+	// reset token is added as its token query parameter
+	PasswordResetURL string
+
+	// Mailer is how emails are sent: "smtp", "log" to write them to the
+	// server log, or "file" to append them to MailFile
+	Mailer   string
+	MailFile string
+	// MailFrom is the sender address of every email
+	MailFrom     string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// Store profile printed on receipts
+	StoreName    string
+	StoreAddress string
+	StorePhone   string
+	StoreTaxID   string
+	// ReceiptFooter is printed at the bottom of every receipt
+	ReceiptFooter string
+	// ReceiptWidth is the default receipt paper width in millimetres, 58 or 80
+	ReceiptWidth int
+	// ReceiptTemplate is the path of an HTML template that replaces the
+	// built-in HTML receipt
+	ReceiptTemplate string
 }
 
 func New() *Config {
@@ -27,6 +144,59 @@ func New() *Config {
 		Port:        getEnv("PORT", "8080"),
 		Environment: getEnv("ENVIRONMENT", "development"),
 		BaseURL:     getEnv("BASE_URL", ""),
+
+		SalesLocation:  getEnv("SALES_LOCATION", ""),
+		AllowBackorder: getEnvBool("ALLOW_BACKORDER", false),
+
+		StockAlertEmails:       getEnvList("STOCK_ALERT_EMAILS"),
+		StockAlertInterval:     getEnvDuration("STOCK_ALERT_INTERVAL", 5*time.Minute),
+		ReplenishmentSalesDays: getEnvInt("REPLENISHMENT_SALES_DAYS", 30),
+		ReplenishmentCoverDays: getEnvInt("REPLENISHMENT_COVER_DAYS", 14),
+
+		RefundApprovalThreshold:   getEnvAmount("REFUND_APPROVAL_THRESHOLD", money.Zero),
+		DiscountApprovalThreshold: getEnvAmount("DISCOUNT_APPROVAL_THRESHOLD", money.Zero),
+		ApprovalTokenTTL:          getEnvDuration("APPROVAL_TOKEN_TTL", 5*time.Minute),
+		Currency:                  getEnv("CURRENCY", "IDR"),
+		PricesIncludeTax:          getEnvBool("PRICES_INCLUDE_TAX", false),
+
+		AllowPublicRegistration: getEnvBool("ALLOW_PUBLIC_REGISTRATION", false),
+		SetupToken:              getEnv("SETUP_TOKEN", ""),
+		InviteTTL:               getEnvDuration("INVITE_TTL", 72*time.Hour),
+		JWTKeysDir:              getEnv("JWT_KEYS_DIR", ""),
+		JWTSigningKey:           getEnv("JWT_SIGNING_KEY", ""),
+		JWTSecret:               getEnv("JWT_SECRET", ""),
+		JWTIssuer:               getEnv("JWT_ISSUER", "jatistore"),
+		AccessTokenTTL:          getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:         getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		TOTPIssuer:              getEnv("TOTP_ISSUER", "JatiStore"),
+		TwoFactorChallengeTTL:   getEnvDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute),
+		LoginMaxFailures:        getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:      getEnvInt("LOGIN_IP_MAX_FAILURES", 50),
+		PINMaxFailures:          getEnvInt("PIN_MAX_FAILURES", 5),
+		LoginLockoutDuration:    getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginBackoffBase:        getEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginBackoffMax:         getEnvDuration("LOGIN_BACKOFF_MAX", time.Minute),
+		LoginFailureWindow:      getEnvDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+		ProxyHeader:             getEnv("PROXY_HEADER", ""),
+		TrustedProxies:          getEnvList("TRUSTED_PROXIES"),
+		PasswordResetTTL:        getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+		PasswordResetURL:        getEnv("PASSWORD_RESET_URL", ""),
+
+		Mailer:       getEnv("MAILER", "log"),
+		MailFile:     getEnv("MAIL_FILE", "mail.log"),
+		MailFrom:     getEnv("MAIL_FROM", "JatiStore <noreply@localhost>"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		StoreName:       getEnv("STORE_NAME", "JatiStore"),
+		StoreAddress:    getEnv("STORE_ADDRESS", ""),
+		StorePhone:      getEnv("STORE_PHONE", ""),
+		StoreTaxID:      getEnv("STORE_TAX_ID", ""),
+		ReceiptFooter:   getEnv("RECEIPT_FOOTER", "Thank you for shopping with us"),
+		ReceiptWidth:    getEnvInt("RECEIPT_WIDTH", 80),
+		ReceiptTemplate: getEnv("RECEIPT_TEMPLATE", ""),
 	}
 	cfg.DatabaseURL = cfg.buildDatabaseURL()
 	return cfg
@@ -49,3 +219,50 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAmount(key string, defaultValue money.Amount) money.Amount {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := money.Parse(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList splits a comma-separated variable into its non-empty values
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
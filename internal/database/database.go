@@ -7,7 +7,7 @@ import (
 	"log"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DB struct {
@@ -41,17 +41,83 @@ func (db *DB) Close() error {
 	return db.DB.Close()
 }
 
+// Listen subscribes to a PostgreSQL notification channel over a connection
+// of its own, reopened whenever it drops. The returned channel receives a
+// value when a notification arrives and when the connection has been
+// reopened, as notifications sent while it was down are lost. Values do not
+// queue up: notifications arriving together may be received as one.
+func Listen(databaseURL, channel string) (<-chan struct{}, error) {
+	listener := pq.NewListener(databaseURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Database listener on %s: %v", channel, err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+
+	signals := make(chan struct{}, 1)
+	go func() {
+		for range listener.Notify {
+			select {
+			case signals <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return signals, nil
+}
+
+// WithTx runs fn inside a database transaction. The transaction is committed
+// when fn returns nil and rolled back otherwise.
+func (db *DB) WithTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // CreateTables creates all necessary tables for the application
 func (db *DB) CreateTables() error {
 	queries := []string{
 		// Enable UUID extension
 		`CREATE EXTENSION IF NOT EXISTS "pgcrypto"`,
 
+		// Tax classes table
+		`CREATE TABLE IF NOT EXISTS tax_classes (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL UNIQUE,
+			description TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Tax rates table
+		`CREATE TABLE IF NOT EXISTS tax_rates (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			tax_class_id UUID NOT NULL REFERENCES tax_classes(id) ON DELETE CASCADE,
+			rate DECIMAL(7,4) NOT NULL CHECK (rate >= 0),
+			effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+			effective_to TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			CHECK (effective_to IS NULL OR effective_to > effective_from)
+		)`,
+
 		// Categories table
 		`CREATE TABLE IF NOT EXISTS categories (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			name VARCHAR(255) NOT NULL UNIQUE,
 			description TEXT,
+			tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)`,
@@ -65,6 +131,16 @@ func (db *DB) CreateTables() error {
 			barcode_number VARCHAR(100) UNIQUE,
 			category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
 			price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
+			tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Locations table
+		`CREATE TABLE IF NOT EXISTS locations (
+			name VARCHAR(255) PRIMARY KEY,
+			description TEXT,
+			is_active BOOLEAN NOT NULL DEFAULT true,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)`,
@@ -84,10 +160,12 @@ func (db *DB) CreateTables() error {
 		`CREATE TABLE IF NOT EXISTS inventory_transactions (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
-			type VARCHAR(50) NOT NULL CHECK (type IN ('in', 'out', 'adjustment')),
+			type VARCHAR(50) NOT NULL CHECK (type IN ('initial', 'in', 'out', 'adjustment')),
 			quantity INTEGER NOT NULL,
 			reason VARCHAR(255) NOT NULL,
 			reference VARCHAR(255),
+			location VARCHAR(255),
+			seq BIGSERIAL,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)`,
 
@@ -107,13 +185,17 @@ func (db *DB) CreateTables() error {
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			order_number VARCHAR(50) NOT NULL UNIQUE,
 			customer_id UUID REFERENCES customers(id) ON DELETE SET NULL,
-			status VARCHAR(50) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'cancelled')),
+			status VARCHAR(50) NOT NULL DEFAULT 'pending' CHECK (status IN ('draft', 'pending', 'on_hold', 'completed', 'cancelled', 'partially_refunded', 'refunded')),
 			subtotal DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (subtotal >= 0),
 			tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (tax_amount >= 0),
 			discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (discount_amount >= 0),
 			total_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (total_amount >= 0),
 			payment_status VARCHAR(50) NOT NULL DEFAULT 'pending' CHECK (payment_status IN ('pending', 'paid', 'refunded')),
+			prices_include_tax BOOLEAN NOT NULL DEFAULT false,
+			tax_overridden BOOLEAN NOT NULL DEFAULT false,
 			notes TEXT,
+			created_by UUID,
+			approved_by UUID,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)`,
@@ -127,6 +209,10 @@ func (db *DB) CreateTables() error {
 			unit_price DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0),
 			discount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (discount >= 0),
 			total_price DECIMAL(10,2) NOT NULL CHECK (total_price >= 0),
+			tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL,
+			tax_rate DECIMAL(7,4) NOT NULL DEFAULT 0,
+			tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (tax_amount >= 0),
+			price_overridden BOOLEAN NOT NULL DEFAULT false,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)`,
 
@@ -134,10 +220,16 @@ func (db *DB) CreateTables() error {
 		`CREATE TABLE IF NOT EXISTS payments (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
-			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+			amount DECIMAL(10,2) NOT NULL CHECK (amount <> 0),
 			payment_method VARCHAR(50) NOT NULL CHECK (payment_method IN ('cash', 'card', 'transfer', 'digital_wallet')),
 			reference VARCHAR(255),
 			status VARCHAR(50) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed', 'refunded')),
+			refund_of UUID REFERENCES payments(id) ON DELETE SET NULL,
+			refund_id UUID,
+			tendered_amount DECIMAL(10,2),
+			change_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (change_amount >= 0),
+			created_by UUID,
+			approved_by UUID,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)`,
@@ -147,29 +239,534 @@ func (db *DB) CreateTables() error {
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
 			receipt_number VARCHAR(50) NOT NULL UNIQUE,
+			type VARCHAR(20) NOT NULL DEFAULT 'sale' CHECK (type IN ('sale', 'credit_note')),
+			refund_id UUID,
 			total_amount DECIMAL(10,2) NOT NULL CHECK (total_amount >= 0),
 			tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (tax_amount >= 0),
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)`,
 
+		// Receipt tax breakdown table
+		`CREATE TABLE IF NOT EXISTS receipt_taxes (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			receipt_id UUID NOT NULL REFERENCES receipts(id) ON DELETE CASCADE,
+			tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL,
+			name VARCHAR(255) NOT NULL,
+			rate DECIMAL(7,4) NOT NULL,
+			taxable_amount DECIMAL(10,2) NOT NULL,
+			tax_amount DECIMAL(10,2) NOT NULL
+		)`,
+
+		// Refunds table
+		`CREATE TABLE IF NOT EXISTS refunds (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+			reason TEXT,
+			restock_location VARCHAR(255),
+			created_by UUID,
+			approved_by UUID,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Refund items table
+		`CREATE TABLE IF NOT EXISTS refund_items (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			refund_id UUID NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+			order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Promotions table
+		`CREATE TABLE IF NOT EXISTS promotions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL,
+			description TEXT,
+			campaign VARCHAR(255),
+			type VARCHAR(50) NOT NULL CHECK (type IN ('percentage', 'fixed', 'buy_x_get_y', 'bundle', 'spend_threshold')),
+			percent DECIMAL(7,4) NOT NULL DEFAULT 0 CHECK (percent >= 0 AND percent <= 100),
+			amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+			buy_quantity INTEGER NOT NULL DEFAULT 0 CHECK (buy_quantity >= 0),
+			get_quantity INTEGER NOT NULL DEFAULT 0 CHECK (get_quantity >= 0),
+			min_spend DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_spend >= 0),
+			product_id UUID REFERENCES products(id) ON DELETE CASCADE,
+			category_id UUID REFERENCES categories(id) ON DELETE CASCADE,
+			customer_id UUID REFERENCES customers(id) ON DELETE CASCADE,
+			starts_at TIMESTAMP WITH TIME ZONE,
+			ends_at TIMESTAMP WITH TIME ZONE,
+			priority INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Order promotions table
+		`CREATE TABLE IF NOT EXISTS order_promotions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			promotion_id UUID NOT NULL REFERENCES promotions(id) ON DELETE RESTRICT,
+			order_item_id UUID REFERENCES order_items(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			campaign VARCHAR(255),
+			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Coupons table
+		`CREATE TABLE IF NOT EXISTS coupons (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			code VARCHAR(50) UNIQUE NOT NULL,
+			description TEXT,
+			campaign VARCHAR(255),
+			type VARCHAR(50) NOT NULL CHECK (type IN ('percentage', 'fixed', 'buy_x_get_y', 'bundle')),
+			percent DECIMAL(7,4) NOT NULL DEFAULT 0 CHECK (percent >= 0 AND percent <= 100),
+			amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+			buy_quantity INTEGER NOT NULL DEFAULT 0 CHECK (buy_quantity >= 0),
+			get_quantity INTEGER NOT NULL DEFAULT 0 CHECK (get_quantity >= 0),
+			product_id UUID REFERENCES products(id) ON DELETE CASCADE,
+			category_id UUID REFERENCES categories(id) ON DELETE CASCADE,
+			min_basket DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_basket >= 0),
+			max_uses INTEGER CHECK (max_uses > 0),
+			max_uses_per_customer INTEGER CHECK (max_uses_per_customer > 0),
+			times_used INTEGER NOT NULL DEFAULT 0 CHECK (times_used >= 0 AND (max_uses IS NULL OR times_used <= max_uses)),
+			starts_at TIMESTAMP WITH TIME ZONE,
+			ends_at TIMESTAMP WITH TIME ZONE,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Coupon redemptions table
+		`CREATE TABLE IF NOT EXISTS coupon_redemptions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			coupon_id UUID NOT NULL REFERENCES coupons(id) ON DELETE RESTRICT,
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			customer_id UUID REFERENCES customers(id) ON DELETE SET NULL,
+			code VARCHAR(50) NOT NULL,
+			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+			reversed_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(coupon_id, order_id)
+		)`,
+
+		// Order status history table
+		`CREATE TABLE IF NOT EXISTS order_status_history (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			from_status VARCHAR(50),
+			to_status VARCHAR(50) NOT NULL,
+			changed_by UUID,
+			approved_by UUID,
+			reason TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Roles table
+		`CREATE TABLE IF NOT EXISTS roles (
+			name VARCHAR(50) PRIMARY KEY,
+			description TEXT NOT NULL DEFAULT '',
+			is_system BOOLEAN NOT NULL DEFAULT false,
+			require_two_factor BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Role permissions table
+		`CREATE TABLE IF NOT EXISTS role_permissions (
+			role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+			permission VARCHAR(100) NOT NULL,
+			PRIMARY KEY (role, permission)
+		)`,
+
 		// Users table
 		`CREATE TABLE IF NOT EXISTS users (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			username VARCHAR(50) UNIQUE NOT NULL,
 			email VARCHAR(255) UNIQUE NOT NULL,
 			password VARCHAR(255) NOT NULL,
-			role VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (role IN ('admin', 'user', 'cashier')),
+			role VARCHAR(50) NOT NULL DEFAULT 'user',
 			is_active BOOLEAN NOT NULL DEFAULT true,
+			pin_hash VARCHAR(255),
+			totp_secret VARCHAR(64),
+			totp_enabled BOOLEAN NOT NULL DEFAULT false,
+			totp_last_step BIGINT NOT NULL DEFAULT 0,
 			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
 			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 		)`,
 
+		// User invites table
+		`CREATE TABLE IF NOT EXISTS user_invites (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			email VARCHAR(255) NOT NULL,
+			role VARCHAR(50) NOT NULL,
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			accepted_at TIMESTAMP WITH TIME ZONE,
+			user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Refresh tokens table
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			family_id UUID NOT NULL,
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			access_jti UUID NOT NULL,
+			access_expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Revoked access tokens table
+		`CREATE TABLE IF NOT EXISTS revoked_tokens (
+			jti UUID PRIMARY KEY,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			revoked_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Approval tokens table
+		`CREATE TABLE IF NOT EXISTS approval_tokens (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			approver_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			permissions TEXT[] NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			used_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Two-factor recovery codes table
+		`CREATE TABLE IF NOT EXISTS recovery_codes (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash VARCHAR(64) NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Two-factor login challenges table
+		`CREATE TABLE IF NOT EXISTS login_challenges (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Login throttles table
+		`CREATE TABLE IF NOT EXISTS login_throttles (
+			scope VARCHAR(20) NOT NULL CHECK (scope IN ('username', 'ip')),
+			key VARCHAR(255) NOT NULL,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			blocked_until TIMESTAMP WITH TIME ZONE,
+			locked_out BOOLEAN NOT NULL DEFAULT false,
+			PRIMARY KEY (scope, key)
+		)`,
+
+		// Security events table
+		`CREATE TABLE IF NOT EXISTS security_events (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			type VARCHAR(50) NOT NULL,
+			user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			username VARCHAR(255),
+			ip_address VARCHAR(64),
+			details TEXT,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Password reset tokens table
+		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			token_hash VARCHAR(64) NOT NULL UNIQUE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// API keys table
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL,
+			prefix VARCHAR(20) NOT NULL,
+			key_hash VARCHAR(64) NOT NULL UNIQUE,
+			permissions TEXT[] NOT NULL,
+			allowed_ips TEXT[] NOT NULL DEFAULT '{}',
+			expires_at TIMESTAMP WITH TIME ZONE,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			last_used_ip VARCHAR(64),
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Audit log table. before, after and changes are JSON rather than
+		// JSONB so that they are stored exactly as hashed.
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			actor_id UUID,
+			api_key_id UUID,
+			actor_name VARCHAR(255) NOT NULL,
+			action VARCHAR(20) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+			entity_type VARCHAR(50) NOT NULL,
+			entity_id VARCHAR(100) NOT NULL,
+			before JSON,
+			after JSON,
+			changes JSON,
+			prev_hash VARCHAR(64) NOT NULL,
+			hash VARCHAR(64) NOT NULL UNIQUE
+		)`,
+
+		// Stock transfers table
+		`CREATE TABLE IF NOT EXISTS stock_transfers (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			transfer_number VARCHAR(50) NOT NULL UNIQUE,
+			from_location VARCHAR(255) NOT NULL REFERENCES locations(name),
+			to_location VARCHAR(255) NOT NULL REFERENCES locations(name),
+			status VARCHAR(20) NOT NULL DEFAULT 'shipped' CHECK (status IN ('shipped', 'received', 'cancelled')),
+			notes TEXT,
+			created_by UUID,
+			received_by UUID,
+			shipped_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			received_at TIMESTAMP WITH TIME ZONE,
+			cancelled_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			CHECK (from_location <> to_location)
+		)`,
+
+		// Stock transfer items table
+		`CREATE TABLE IF NOT EXISTS stock_transfer_items (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			transfer_id UUID NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			UNIQUE(transfer_id, product_id)
+		)`,
+
+		// Reorder levels table: when stock of a product at a location is low
+		// and how much to reorder, with the alert state last notified
+		`CREATE TABLE IF NOT EXISTS reorder_levels (
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			location VARCHAR(255) NOT NULL REFERENCES locations(name) ON DELETE CASCADE,
+			min_quantity INTEGER NOT NULL CHECK (min_quantity >= 0),
+			max_quantity INTEGER NOT NULL,
+			reorder_quantity INTEGER NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0),
+			alert_state VARCHAR(20) NOT NULL DEFAULT 'ok' CHECK (alert_state IN ('ok', 'low', 'out', 'over')),
+			alerted_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (product_id, location),
+			CHECK (max_quantity >= min_quantity)
+		)`,
+
+		// Suppliers table
+		`CREATE TABLE IF NOT EXISTS suppliers (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL UNIQUE,
+			contact_name VARCHAR(255),
+			email VARCHAR(255),
+			phone VARCHAR(50),
+			address TEXT,
+			notes TEXT,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Purchase orders table: stock ordered from a supplier for a location
+		`CREATE TABLE IF NOT EXISTS purchase_orders (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			po_number VARCHAR(50) NOT NULL UNIQUE,
+			supplier_id UUID NOT NULL REFERENCES suppliers(id),
+			location VARCHAR(255) NOT NULL REFERENCES locations(name),
+			status VARCHAR(30) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'sent', 'partially_received', 'received', 'closed')),
+			expected_at TIMESTAMP WITH TIME ZONE,
+			notes TEXT,
+			created_by UUID,
+			sent_at TIMESTAMP WITH TIME ZONE,
+			received_at TIMESTAMP WITH TIME ZONE,
+			closed_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Purchase order lines table: quantity ordered and received and the
+		// expected cost of each product
+		`CREATE TABLE IF NOT EXISTS purchase_order_lines (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			purchase_order_id UUID NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			received_quantity INTEGER NOT NULL DEFAULT 0 CHECK (received_quantity >= 0),
+			unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+			expected_at TIMESTAMP WITH TIME ZONE,
+			UNIQUE(purchase_order_id, product_id)
+		)`,
+
+		// Supplier costs table: what each supplier charged for a product,
+		// one row per product per goods receipt
+		`CREATE TABLE IF NOT EXISTS supplier_costs (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE CASCADE,
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			purchase_order_line_id UUID REFERENCES purchase_order_lines(id) ON DELETE SET NULL,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+			received_by UUID,
+			received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Shifts table
+		`CREATE TABLE IF NOT EXISTS shifts (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+			register VARCHAR(100),
+			status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed')),
+			opening_float DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (opening_float >= 0),
+			expected_cash DECIMAL(10,2),
+			counted_cash DECIMAL(10,2) CHECK (counted_cash >= 0),
+			variance DECIMAL(10,2),
+			notes TEXT,
+			opened_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			closed_at TIMESTAMP WITH TIME ZONE
+		)`,
+
+		// Cash movements table
+		`CREATE TABLE IF NOT EXISTS cash_movements (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			shift_id UUID NOT NULL REFERENCES shifts(id) ON DELETE CASCADE,
+			type VARCHAR(20) NOT NULL CHECK (type IN ('cash_in', 'cash_out')),
+			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+			reason TEXT NOT NULL,
+			created_by UUID,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Shift tender counts table
+		`CREATE TABLE IF NOT EXISTS shift_tender_counts (
+			shift_id UUID NOT NULL REFERENCES shifts(id) ON DELETE CASCADE,
+			payment_method VARCHAR(50) NOT NULL,
+			expected DECIMAL(10,2) NOT NULL,
+			counted DECIMAL(10,2) NOT NULL,
+			PRIMARY KEY (shift_id, payment_method)
+		)`,
+
+		// Order stock deduction: ledger rows remember their location
+		`ALTER TABLE inventory_transactions ADD COLUMN IF NOT EXISTS location VARCHAR(255)`,
+
+		// Order lifecycle states
+		`ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check`,
+		`ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('draft', 'pending', 'on_hold', 'completed', 'cancelled', 'partially_refunded', 'refunded'))`,
+
+		// Refunds: negative payments linked to the refunded payment, credit
+		// note receipts and the manager role that approves large refunds
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS refund_of UUID REFERENCES payments(id) ON DELETE SET NULL`,
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS refund_id UUID`,
+		`ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_amount_check`,
+		`ALTER TABLE payments ADD CONSTRAINT payments_amount_check CHECK (amount <> 0)`,
+		`ALTER TABLE receipts ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'sale' CHECK (type IN ('sale', 'credit_note'))`,
+		`ALTER TABLE receipts ADD COLUMN IF NOT EXISTS refund_id UUID`,
+		`ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check`,
+
+		// Tax engine: tax classes on products and categories, per-line tax
+		// on order items and the pricing mode of each order
+		`ALTER TABLE categories ADD COLUMN IF NOT EXISTS tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS prices_include_tax BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_overridden BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(7,4) NOT NULL DEFAULT 0`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (tax_amount >= 0)`,
+
+		// Split tender: what was handed over for each payment and the change
+		// given back. Older payments have no tendered amount and read it as
+		// the amount applied.
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS tendered_amount DECIMAL(10,2)`,
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS change_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (change_amount >= 0)`,
+
+		// Shifts: every payment is taken on the cashier's open shift
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS shift_id UUID REFERENCES shifts(id) ON DELETE SET NULL`,
+
+		// Rendered receipts: the cashier printed on a receipt and how often
+		// it has been printed, so that reprints are marked as copies
+		`ALTER TABLE receipts ADD COLUMN IF NOT EXISTS cashier_id UUID REFERENCES users(id) ON DELETE SET NULL`,
+		`ALTER TABLE receipts ADD COLUMN IF NOT EXISTS print_count INTEGER NOT NULL DEFAULT 0 CHECK (print_count >= 0)`,
+
+		// Permissions: roles are rows of the roles table instead of a fixed
+		// list, so users and invites can have custom roles
+		`ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(50)`,
+		`ALTER TABLE user_invites DROP CONSTRAINT IF EXISTS user_invites_role_check`,
+		`ALTER TABLE user_invites ALTER COLUMN role TYPE VARCHAR(50)`,
+
+		// Manager approvals: staff PINs, and who placed and who approved
+		// orders, status changes, payments and refunds
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS pin_hash VARCHAR(255)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS created_by UUID`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS approved_by UUID`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS price_overridden BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE order_status_history ADD COLUMN IF NOT EXISTS approved_by UUID`,
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS created_by UUID`,
+		`ALTER TABLE payments ADD COLUMN IF NOT EXISTS approved_by UUID`,
+		`ALTER TABLE refunds ADD COLUMN IF NOT EXISTS approved_by UUID`,
+
+		// Two-factor authentication: TOTP secrets and the roles that
+		// require them
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE roles ADD COLUMN IF NOT EXISTS require_two_factor BOOLEAN NOT NULL DEFAULT false`,
+
+		// Locations: every location stock was kept at so far becomes a
+		// location row, and inventory and ledger rows must name one
+		`INSERT INTO locations (name) SELECT DISTINCT location FROM inventory ON CONFLICT (name) DO NOTHING`,
+		`INSERT INTO locations (name) SELECT DISTINCT location FROM inventory_transactions WHERE location IS NOT NULL ON CONFLICT (name) DO NOTHING`,
+		`ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_location_fkey`,
+		`ALTER TABLE inventory ADD CONSTRAINT inventory_location_fkey FOREIGN KEY (location) REFERENCES locations(name)`,
+		`ALTER TABLE inventory_transactions DROP CONSTRAINT IF EXISTS inventory_transactions_location_fkey`,
+		`ALTER TABLE inventory_transactions ADD CONSTRAINT inventory_transactions_location_fkey FOREIGN KEY (location) REFERENCES locations(name)`,
+
+		// Stock ledger: opening balances are "initial" movements, rows are
+		// numbered in the order they were written, and stock that has no
+		// ledger rows at its location yet gets its opening balance
+		`ALTER TABLE inventory_transactions DROP CONSTRAINT IF EXISTS inventory_transactions_type_check`,
+		`ALTER TABLE inventory_transactions ADD CONSTRAINT inventory_transactions_type_check CHECK (type IN ('initial', 'in', 'out', 'adjustment'))`,
+		`ALTER TABLE inventory_transactions ADD COLUMN IF NOT EXISTS seq BIGSERIAL`,
+		`INSERT INTO inventory_transactions (product_id, type, quantity, reason, location, created_at)
+		SELECT i.product_id, 'initial', i.quantity, 'Opening balance', i.location, i.created_at
+		FROM inventory i
+		WHERE NOT EXISTS (
+			SELECT 1 FROM inventory_transactions it
+			WHERE it.product_id = i.product_id AND it.location = i.location
+		)`,
+
+		// Goods received against a purchase order reference its line in the
+		// stock ledger
+		`ALTER TABLE inventory_transactions ADD COLUMN IF NOT EXISTS purchase_order_line_id UUID REFERENCES purchase_order_lines(id)`,
+
+		// Supervisor PIN throttling: wrong PINs are counted per approver
+		// along with failed logins
+		`ALTER TABLE login_throttles DROP CONSTRAINT IF EXISTS login_throttles_scope_check`,
+		`ALTER TABLE login_throttles ADD CONSTRAINT login_throttles_scope_check CHECK (scope IN ('username', 'ip', 'pin'))`,
+
 		// Indexes
 		`CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_products_sku ON products(sku)`,
 		`CREATE INDEX IF NOT EXISTS idx_inventory_product_id ON inventory(product_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_inventory_transactions_product_id ON inventory_transactions(product_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_inventory_transactions_created_at ON inventory_transactions(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_transactions_reference ON inventory_transactions(reference)`,
 		`CREATE INDEX IF NOT EXISTS idx_customers_email ON customers(email)`,
 		`CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status)`,
@@ -177,17 +774,65 @@ func (db *DB) CreateTables() error {
 		`CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders(created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_order_items_product_id ON order_items(product_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_promotions_is_active ON promotions(is_active)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_promotions_order_id ON order_promotions(order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_promotions_promotion_id ON order_promotions(promotion_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_order_id ON coupon_redemptions(order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_coupon_customer ON coupon_redemptions(coupon_id, customer_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open_user_id ON shifts(user_id) WHERE status = 'open'`,
+		`CREATE INDEX IF NOT EXISTS idx_cash_movements_shift_id ON cash_movements(shift_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_payments_shift_id ON payments(shift_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_payments_refund_of ON payments(refund_of)`,
 		`CREATE INDEX IF NOT EXISTS idx_receipts_order_id ON receipts(order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_receipt_taxes_receipt_id ON receipt_taxes(receipt_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_tax_rates_tax_class_id ON tax_rates(tax_class_id, effective_from)`,
+		`CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_refund_items_refund_id ON refund_items(refund_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_refund_items_order_item_id ON refund_items(order_item_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_username ON users(username)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_role ON users(role)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_is_active ON users(is_active)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_invites_email ON user_invites(email)`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_approval_tokens_expires_at ON approval_tokens(expires_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_approved_by ON orders(approved_by)`,
+		`CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_login_challenges_expires_at ON login_challenges(expires_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure_at ON login_throttles(last_failure_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_security_events_created_at ON security_events(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_api_keys_created_at ON api_keys(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_occurred_at ON audit_log(occurred_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_location ON inventory(location)`,
+		`CREATE INDEX IF NOT EXISTS idx_stock_transfers_status ON stock_transfers(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_stock_transfers_shipped_at ON stock_transfers(shipped_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_stock_transfer_items_transfer_id ON stock_transfer_items(transfer_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_transactions_product_location ON inventory_transactions(product_id, location, seq)`,
+		`CREATE INDEX IF NOT EXISTS idx_reorder_levels_location ON reorder_levels(location)`,
+		`CREATE INDEX IF NOT EXISTS idx_purchase_orders_supplier_id ON purchase_orders(supplier_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_purchase_orders_status ON purchase_orders(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_purchase_order_id ON purchase_order_lines(purchase_order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_product_id ON purchase_order_lines(product_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_costs_supplier_product ON supplier_costs(supplier_id, product_id, received_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_transactions_purchase_order_line_id ON inventory_transactions(purchase_order_line_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_transactions_reference ON inventory_transactions(reference)`,
 
-		// Sequences for order and receipt numbers
+		// Sequences for order, receipt, transfer and purchase order numbers
 		`CREATE SEQUENCE IF NOT EXISTS order_number_seq START 1000`,
 		`CREATE SEQUENCE IF NOT EXISTS receipt_number_seq START 1000`,
+		`CREATE SEQUENCE IF NOT EXISTS credit_note_number_seq START 1000`,
+		`CREATE SEQUENCE IF NOT EXISTS transfer_number_seq START 1000`,
+		`CREATE SEQUENCE IF NOT EXISTS purchase_order_number_seq START 1000`,
 
 		// Functions for generating order and receipt numbers
 		`CREATE OR REPLACE FUNCTION generate_order_number()
@@ -201,7 +846,11 @@ func (db *DB) CreateTables() error {
 		`CREATE OR REPLACE FUNCTION generate_receipt_number()
 		RETURNS TRIGGER AS $$
 		BEGIN
-			NEW.receipt_number := 'RCP-' || nextval('receipt_number_seq');
+			IF NEW.type = 'credit_note' THEN
+				NEW.receipt_number := 'CN-' || nextval('credit_note_number_seq');
+			ELSE
+				NEW.receipt_number := 'RCP-' || nextval('receipt_number_seq');
+			END IF;
 			RETURN NEW;
 		END;
 		$$ LANGUAGE plpgsql`,
@@ -215,6 +864,36 @@ func (db *DB) CreateTables() error {
 		END;
 		$$ language 'plpgsql'`,
 
+		// Function that keeps the audit log append-only
+		`CREATE OR REPLACE FUNCTION prevent_audit_log_change()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_log is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+
+		// Function that keeps the stock ledger append-only. Deleting a product
+		// still deletes its ledger rows through the foreign key cascade.
+		`CREATE OR REPLACE FUNCTION prevent_inventory_transaction_change()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' AND pg_trigger_depth() > 1 THEN
+				RETURN OLD;
+			END IF;
+			RAISE EXCEPTION 'inventory_transactions is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+
+		// Function that tells listeners, such as the stock alert evaluator,
+		// that stock or reorder levels have changed
+		`CREATE OR REPLACE FUNCTION notify_stock_changed()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			PERFORM pg_notify('stock_changed', '');
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql`,
+
 		// Triggers for automatic number generation
 		`DROP TRIGGER IF EXISTS trigger_generate_order_number ON orders`,
 		`CREATE TRIGGER trigger_generate_order_number
@@ -236,6 +915,45 @@ func (db *DB) CreateTables() error {
 			BEFORE UPDATE ON users 
 			FOR EACH ROW 
 			EXECUTE FUNCTION update_updated_at_column()`,
+
+		// Triggers that refuse changes to the audit log
+		`DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log`,
+		`CREATE TRIGGER audit_log_append_only
+			BEFORE UPDATE OR DELETE ON audit_log
+			FOR EACH ROW
+			EXECUTE FUNCTION prevent_audit_log_change()`,
+
+		`DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log`,
+		`CREATE TRIGGER audit_log_no_truncate
+			BEFORE TRUNCATE ON audit_log
+			FOR EACH STATEMENT
+			EXECUTE FUNCTION prevent_audit_log_change()`,
+
+		// Triggers that refuse changes to the stock ledger
+		`DROP TRIGGER IF EXISTS inventory_transactions_append_only ON inventory_transactions`,
+		`CREATE TRIGGER inventory_transactions_append_only
+			BEFORE UPDATE OR DELETE ON inventory_transactions
+			FOR EACH ROW
+			EXECUTE FUNCTION prevent_inventory_transaction_change()`,
+
+		`DROP TRIGGER IF EXISTS inventory_transactions_no_truncate ON inventory_transactions`,
+		`CREATE TRIGGER inventory_transactions_no_truncate
+			BEFORE TRUNCATE ON inventory_transactions
+			FOR EACH STATEMENT
+			EXECUTE FUNCTION prevent_inventory_transaction_change()`,
+
+		// Triggers that announce stock and reorder level changes
+		`DROP TRIGGER IF EXISTS inventory_notify_stock_changed ON inventory`,
+		`CREATE TRIGGER inventory_notify_stock_changed
+			AFTER INSERT OR UPDATE OF quantity ON inventory
+			FOR EACH STATEMENT
+			EXECUTE FUNCTION notify_stock_changed()`,
+
+		`DROP TRIGGER IF EXISTS reorder_levels_notify_stock_changed ON reorder_levels`,
+		`CREATE TRIGGER reorder_levels_notify_stock_changed
+			AFTER INSERT OR UPDATE OF min_quantity, max_quantity ON reorder_levels
+			FOR EACH STATEMENT
+			EXECUTE FUNCTION notify_stock_changed()`,
 	}
 
 	for _, query := range queries {
@@ -247,3 +965,40 @@ func (db *DB) CreateTables() error {
 	log.Println("Database tables created successfully")
 	return nil
 }
+
+// SetStockFloor keeps the inventory_quantity_check constraint in place unless
+// backorders are allowed, in which case stock may be stored below zero. The
+// services refuse to oversell either way; the constraint is the backstop.
+// Turning backorders off again fails while some stock is still negative.
+func (db *DB) SetStockFloor(allowBackorder bool) error {
+	if allowBackorder {
+		if _, err := db.Exec(`ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_quantity_check`); err != nil {
+			return fmt.Errorf("failed to drop inventory quantity check: %w", err)
+		}
+		return nil
+	}
+
+	var exists bool
+	err := db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'inventory'::regclass AND conname = 'inventory_quantity_check')`,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look up inventory quantity check: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	var negative int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM inventory WHERE quantity < 0`).Scan(&negative); err != nil {
+		return fmt.Errorf("failed to count negative stock: %w", err)
+	}
+	if negative > 0 {
+		return fmt.Errorf("%d inventory rows are below zero; adjust them or set ALLOW_BACKORDER=true", negative)
+	}
+
+	if _, err := db.Exec(`ALTER TABLE inventory ADD CONSTRAINT inventory_quantity_check CHECK (quantity >= 0)`); err != nil {
+		return fmt.Errorf("failed to add inventory quantity check: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+-- Migration: Deduct inventory when orders are completed
+-- Description: Records the location on inventory transactions. Stock keeps
+-- its non-negative check; the server drops inventory_quantity_check at startup
+-- only when ALLOW_BACKORDER=true
+
+ALTER TABLE inventory_transactions ADD COLUMN IF NOT EXISTS location VARCHAR(255);
+
+-- Create index on reference for looking up movements of an order
+CREATE INDEX IF NOT EXISTS idx_inventory_transactions_reference ON inventory_transactions(reference);
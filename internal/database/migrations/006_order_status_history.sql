@@ -0,0 +1,20 @@
+-- Migration: Order lifecycle state machine
+-- Description: Adds the draft, on_hold, partially_refunded and refunded order
+-- states and an audit table of every status transition
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+    CHECK (status IN ('draft', 'pending', 'on_hold', 'completed', 'cancelled', 'partially_refunded', 'refunded'));
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(50),
+    to_status VARCHAR(50) NOT NULL,
+    changed_by UUID,
+    reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index on order_id for listing an order's history
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id);
@@ -0,0 +1,55 @@
+-- Migration: Refunds and returns
+-- Description: Adds refunds with per-line quantities, negative payment rows
+-- linked to the refunded payment, credit note receipts and the manager role
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    reason TEXT,
+    restock_location VARCHAR(255),
+    created_by UUID,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    refund_id UUID NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+    order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Refund payments are negative rows pointing at the payment they refund
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refund_of UUID REFERENCES payments(id) ON DELETE SET NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refund_id UUID;
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_amount_check;
+ALTER TABLE payments ADD CONSTRAINT payments_amount_check CHECK (amount <> 0);
+
+-- Credit notes are receipts of type credit_note numbered CN-xxxx
+ALTER TABLE receipts ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'sale' CHECK (type IN ('sale', 'credit_note'));
+ALTER TABLE receipts ADD COLUMN IF NOT EXISTS refund_id UUID;
+
+CREATE SEQUENCE IF NOT EXISTS credit_note_number_seq START 1000;
+
+CREATE OR REPLACE FUNCTION generate_receipt_number()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.type = 'credit_note' THEN
+        NEW.receipt_number := 'CN-' || nextval('credit_note_number_seq');
+    ELSE
+        NEW.receipt_number := 'RCP-' || nextval('receipt_number_seq');
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Managers approve refunds above the cashier threshold
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'manager', 'user', 'cashier'));
+
+CREATE INDEX IF NOT EXISTS idx_payments_refund_of ON payments(refund_of);
+CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX IF NOT EXISTS idx_refund_items_refund_id ON refund_items(refund_id);
+CREATE INDEX IF NOT EXISTS idx_refund_items_order_item_id ON refund_items(order_item_id);
@@ -0,0 +1,47 @@
+-- Migration: Server-side tax engine
+-- Description: Adds tax classes with effective-dated rates, assigns them to
+-- products and categories and stores the computed tax per order line and per
+-- receipt
+
+CREATE TABLE IF NOT EXISTS tax_classes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tax_class_id UUID NOT NULL REFERENCES tax_classes(id) ON DELETE CASCADE,
+    rate DECIMAL(7,4) NOT NULL CHECK (rate >= 0),
+    effective_from TIMESTAMPTZ NOT NULL,
+    effective_to TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK (effective_to IS NULL OR effective_to > effective_from)
+);
+
+-- A product's own class wins over its category's class
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL;
+
+-- Orders remember how they were priced; tax is stored per line
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS prices_include_tax BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_overridden BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(7,4) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (tax_amount >= 0);
+
+-- Tax breakdown printed on receipts and credit notes, one row per rate
+CREATE TABLE IF NOT EXISTS receipt_taxes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    receipt_id UUID NOT NULL REFERENCES receipts(id) ON DELETE CASCADE,
+    tax_class_id UUID REFERENCES tax_classes(id) ON DELETE SET NULL,
+    name VARCHAR(255) NOT NULL,
+    rate DECIMAL(7,4) NOT NULL,
+    taxable_amount DECIMAL(10,2) NOT NULL,
+    tax_amount DECIMAL(10,2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_receipt_taxes_receipt_id ON receipt_taxes(receipt_id);
+CREATE INDEX IF NOT EXISTS idx_tax_rates_tax_class_id ON tax_rates(tax_class_id, effective_from);
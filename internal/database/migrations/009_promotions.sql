@@ -0,0 +1,42 @@
+-- Migration: Promotions
+-- Description: Adds automatic promotion rules and records the discount each
+-- applied promotion gave on an order
+
+CREATE TABLE IF NOT EXISTS promotions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    campaign VARCHAR(255),
+    type VARCHAR(50) NOT NULL CHECK (type IN ('percentage', 'fixed', 'buy_x_get_y', 'bundle', 'spend_threshold')),
+    percent DECIMAL(7,4) NOT NULL DEFAULT 0 CHECK (percent >= 0 AND percent <= 100),
+    amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    buy_quantity INTEGER NOT NULL DEFAULT 0 CHECK (buy_quantity >= 0),
+    get_quantity INTEGER NOT NULL DEFAULT 0 CHECK (get_quantity >= 0),
+    min_spend DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_spend >= 0),
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE,
+    category_id UUID REFERENCES categories(id) ON DELETE CASCADE,
+    customer_id UUID REFERENCES customers(id) ON DELETE CASCADE,
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    priority INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One row per promotion and order line (order_item_id is NULL for
+-- order-level discounts) so discount cost can be attributed per campaign
+CREATE TABLE IF NOT EXISTS order_promotions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    promotion_id UUID NOT NULL REFERENCES promotions(id) ON DELETE RESTRICT,
+    order_item_id UUID REFERENCES order_items(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    campaign VARCHAR(255),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotions_is_active ON promotions(is_active);
+CREATE INDEX IF NOT EXISTS idx_order_promotions_order_id ON order_promotions(order_id);
+CREATE INDEX IF NOT EXISTS idx_order_promotions_promotion_id ON order_promotions(promotion_id);
@@ -0,0 +1,43 @@
+-- Migration: Coupons
+-- Description: Adds coupon codes with usage limits and records every
+-- redemption so it can be reversed when the order is cancelled or refunded
+
+CREATE TABLE IF NOT EXISTS coupons (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(50) UNIQUE NOT NULL,
+    description TEXT,
+    campaign VARCHAR(255),
+    type VARCHAR(50) NOT NULL CHECK (type IN ('percentage', 'fixed', 'buy_x_get_y', 'bundle')),
+    percent DECIMAL(7,4) NOT NULL DEFAULT 0 CHECK (percent >= 0 AND percent <= 100),
+    amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    buy_quantity INTEGER NOT NULL DEFAULT 0 CHECK (buy_quantity >= 0),
+    get_quantity INTEGER NOT NULL DEFAULT 0 CHECK (get_quantity >= 0),
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE,
+    category_id UUID REFERENCES categories(id) ON DELETE CASCADE,
+    min_basket DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_basket >= 0),
+    max_uses INTEGER CHECK (max_uses > 0),
+    max_uses_per_customer INTEGER CHECK (max_uses_per_customer > 0),
+    -- times_used can never pass max_uses, even if a redemption slips past the
+    -- row lock taken by the order service
+    times_used INTEGER NOT NULL DEFAULT 0 CHECK (times_used >= 0 AND (max_uses IS NULL OR times_used <= max_uses)),
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    coupon_id UUID NOT NULL REFERENCES coupons(id) ON DELETE RESTRICT,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    customer_id UUID REFERENCES customers(id) ON DELETE SET NULL,
+    code VARCHAR(50) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    reversed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(coupon_id, order_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_order_id ON coupon_redemptions(order_id);
+CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_coupon_customer ON coupon_redemptions(coupon_id, customer_id);
@@ -0,0 +1,9 @@
+-- Migration: Split tender
+-- Description: Records what was handed over for each payment next to the
+-- amount applied to the order, and the change given back for cash
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS tendered_amount DECIMAL(10,2);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS change_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (change_amount >= 0);
+
+-- Payments made before this migration were applied in full
+UPDATE payments SET tendered_amount = amount WHERE tendered_amount IS NULL;
@@ -0,0 +1,43 @@
+-- Migration: Cash drawer shifts
+-- Description: Adds register shifts with their opening float, cash movements
+-- and counted tenders, and links every payment to the shift it was taken on
+
+CREATE TABLE IF NOT EXISTS shifts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    register VARCHAR(100),
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed')),
+    opening_float DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (opening_float >= 0),
+    expected_cash DECIMAL(10,2),
+    counted_cash DECIMAL(10,2) CHECK (counted_cash >= 0),
+    variance DECIMAL(10,2),
+    notes TEXT,
+    opened_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMPTZ
+);
+
+-- A cashier can only have one open shift at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open_user_id ON shifts(user_id) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS cash_movements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    shift_id UUID NOT NULL REFERENCES shifts(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('cash_in', 'cash_out')),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL,
+    created_by UUID,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shift_tender_counts (
+    shift_id UUID NOT NULL REFERENCES shifts(id) ON DELETE CASCADE,
+    payment_method VARCHAR(50) NOT NULL,
+    expected DECIMAL(10,2) NOT NULL,
+    counted DECIMAL(10,2) NOT NULL,
+    PRIMARY KEY (shift_id, payment_method)
+);
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS shift_id UUID REFERENCES shifts(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_cash_movements_shift_id ON cash_movements(shift_id);
+CREATE INDEX IF NOT EXISTS idx_payments_shift_id ON payments(shift_id);
@@ -0,0 +1,6 @@
+-- Migration: Rendered receipts
+-- Description: Records the cashier of every receipt and how many times it has
+-- been printed, so that reprints can be marked as copies
+
+ALTER TABLE receipts ADD COLUMN IF NOT EXISTS cashier_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE receipts ADD COLUMN IF NOT EXISTS print_count INTEGER NOT NULL DEFAULT 0 CHECK (print_count >= 0);
@@ -0,0 +1,23 @@
+-- Migration: Roles and permissions
+-- Description: Routes check named permissions instead of fixed roles. Roles
+-- become editable sets of permissions, so users and invites may have custom
+-- roles. The system roles and their permissions are seeded at startup.
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    is_system BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(50);
+ALTER TABLE user_invites DROP CONSTRAINT IF EXISTS user_invites_role_check;
+ALTER TABLE user_invites ALTER COLUMN role TYPE VARCHAR(50);
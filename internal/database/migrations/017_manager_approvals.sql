@@ -0,0 +1,37 @@
+-- Migration: Manager approvals
+-- Description: Staff get an approval PIN, separate from their password.
+-- Voids, large discounts, price and tax overrides and large refunds can be
+-- approved by a supervisor's PIN or a single-use approval token, and both
+-- the user and the approver are recorded.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pin_hash VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS approval_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    approver_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permissions TEXT[] NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    used_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS created_by UUID;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS approved_by UUID;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS price_overridden BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE order_status_history ADD COLUMN IF NOT EXISTS approved_by UUID;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS created_by UUID;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS approved_by UUID;
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS approved_by UUID;
+
+CREATE INDEX IF NOT EXISTS idx_approval_tokens_expires_at ON approval_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_orders_approved_by ON orders(approved_by);
+
+-- The manager role was seeded before these permissions existed. Give it
+-- them once; roles are editable, so this is not repeated at startup.
+INSERT INTO role_permissions (role, permission)
+SELECT 'manager', permission
+FROM unnest(ARRAY['order.void', 'order.discount_approve', 'order.override_price']) AS permission
+WHERE EXISTS (SELECT 1 FROM roles WHERE name = 'manager')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,30 @@
+-- Migration: Login throttling and account lockout
+-- Description: Failed logins are counted per username and per client IP.
+-- Each failure delays the next attempt exponentially, and too many failures
+-- lock the username or IP out for a while. Lockouts and unlocks are
+-- recorded as security events.
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('username', 'ip')),
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    blocked_until TIMESTAMPTZ,
+    locked_out BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE TABLE IF NOT EXISTS security_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type VARCHAR(50) NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    username VARCHAR(255),
+    ip_address VARCHAR(64),
+    details TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure_at ON login_throttles(last_failure_at);
+CREATE INDEX IF NOT EXISTS idx_security_events_created_at ON security_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id);
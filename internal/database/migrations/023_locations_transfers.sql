@@ -0,0 +1,55 @@
+-- Migration: Locations and stock transfers
+-- Description: Locations such as the shop floor, the back room and a
+-- warehouse become rows of their own instead of free text, and every
+-- inventory and ledger row must name one. Every location stock was kept at
+-- so far is created. Stock transfers move stock between locations as an
+-- "out" at the source when shipped and an "in" at the destination when
+-- received, both referencing the transfer number.
+
+CREATE TABLE IF NOT EXISTS locations (
+    name VARCHAR(255) PRIMARY KEY,
+    description TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO locations (name) SELECT DISTINCT location FROM inventory ON CONFLICT (name) DO NOTHING;
+INSERT INTO locations (name) SELECT DISTINCT location FROM inventory_transactions WHERE location IS NOT NULL ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_location_fkey;
+ALTER TABLE inventory ADD CONSTRAINT inventory_location_fkey FOREIGN KEY (location) REFERENCES locations(name);
+ALTER TABLE inventory_transactions DROP CONSTRAINT IF EXISTS inventory_transactions_location_fkey;
+ALTER TABLE inventory_transactions ADD CONSTRAINT inventory_transactions_location_fkey FOREIGN KEY (location) REFERENCES locations(name);
+
+CREATE SEQUENCE IF NOT EXISTS transfer_number_seq START 1000;
+
+CREATE TABLE IF NOT EXISTS stock_transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transfer_number VARCHAR(50) NOT NULL UNIQUE,
+    from_location VARCHAR(255) NOT NULL REFERENCES locations(name),
+    to_location VARCHAR(255) NOT NULL REFERENCES locations(name),
+    status VARCHAR(20) NOT NULL DEFAULT 'shipped' CHECK (status IN ('shipped', 'received', 'cancelled')),
+    notes TEXT,
+    created_by UUID,
+    received_by UUID,
+    shipped_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    received_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_location <> to_location)
+);
+
+CREATE TABLE IF NOT EXISTS stock_transfer_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transfer_id UUID NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    UNIQUE(transfer_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_location ON inventory(location);
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_status ON stock_transfers(status);
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_shipped_at ON stock_transfers(shipped_at);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_items_transfer_id ON stock_transfer_items(transfer_id);
@@ -0,0 +1,48 @@
+-- Migration: Stock ledger as the source of truth
+-- Description: Every change to inventory.quantity is written to
+-- inventory_transactions in the same transaction, so the stock of a product
+-- at a location can be worked out from the ledger at any point in time.
+-- Opening balances are "initial" movements; like "adjustment" movements they
+-- set the stock to their quantity, while "in" and "out" move it. seq numbers
+-- the rows in the order they were written. Stock with no ledger rows at its
+-- location gets its opening balance, and the ledger becomes append-only.
+-- Ledger rows from before locations existed have no location and are left
+-- out of the balances.
+
+ALTER TABLE inventory_transactions DROP CONSTRAINT IF EXISTS inventory_transactions_type_check;
+ALTER TABLE inventory_transactions ADD CONSTRAINT inventory_transactions_type_check CHECK (type IN ('initial', 'in', 'out', 'adjustment'));
+ALTER TABLE inventory_transactions ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+INSERT INTO inventory_transactions (product_id, type, quantity, reason, location, created_at)
+SELECT i.product_id, 'initial', i.quantity, 'Opening balance', i.location, i.created_at
+FROM inventory i
+WHERE NOT EXISTS (
+    SELECT 1 FROM inventory_transactions it
+    WHERE it.product_id = i.product_id AND it.location = i.location
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_transactions_product_location ON inventory_transactions(product_id, location, seq);
+
+-- Deleting a product still deletes its ledger rows through the foreign key
+-- cascade, which runs from a trigger of its own
+CREATE OR REPLACE FUNCTION prevent_inventory_transaction_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND pg_trigger_depth() > 1 THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'inventory_transactions is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS inventory_transactions_append_only ON inventory_transactions;
+CREATE TRIGGER inventory_transactions_append_only
+    BEFORE UPDATE OR DELETE ON inventory_transactions
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_inventory_transaction_change();
+
+DROP TRIGGER IF EXISTS inventory_transactions_no_truncate ON inventory_transactions;
+CREATE TRIGGER inventory_transactions_no_truncate
+    BEFORE TRUNCATE ON inventory_transactions
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION prevent_inventory_transaction_change();
@@ -0,0 +1,43 @@
+-- Migration: Reorder levels and stock alerts
+-- Description: Each product can have a minimum and maximum stock level and
+-- a reorder quantity per location. Stock at or below the minimum is low, and
+-- replenishing brings it back up to the maximum. alert_state is the state
+-- last notified, so that an alert goes out once when stock crosses a level.
+-- Changes to stock and to reorder levels are announced on the stock_changed
+-- notification channel, which the stock alert evaluator listens on.
+
+CREATE TABLE IF NOT EXISTS reorder_levels (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    location VARCHAR(255) NOT NULL REFERENCES locations(name) ON DELETE CASCADE,
+    min_quantity INTEGER NOT NULL CHECK (min_quantity >= 0),
+    max_quantity INTEGER NOT NULL,
+    reorder_quantity INTEGER NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0),
+    alert_state VARCHAR(20) NOT NULL DEFAULT 'ok' CHECK (alert_state IN ('ok', 'low', 'out', 'over')),
+    alerted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (product_id, location),
+    CHECK (max_quantity >= min_quantity)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reorder_levels_location ON reorder_levels(location);
+
+CREATE OR REPLACE FUNCTION notify_stock_changed()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('stock_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS inventory_notify_stock_changed ON inventory;
+CREATE TRIGGER inventory_notify_stock_changed
+    AFTER INSERT OR UPDATE OF quantity ON inventory
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_stock_changed();
+
+DROP TRIGGER IF EXISTS reorder_levels_notify_stock_changed ON reorder_levels;
+CREATE TRIGGER reorder_levels_notify_stock_changed
+    AFTER INSERT OR UPDATE OF min_quantity, max_quantity ON reorder_levels
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_stock_changed();
@@ -0,0 +1,77 @@
+-- Migration: Suppliers and purchase orders
+-- Description: Stock is ordered from suppliers on purchase orders, which go
+-- from draft to sent and are received, in part or in full, before being
+-- closed. Receiving goods records an "in" movement per line that references
+-- the purchase order line, and what the supplier charged in the supplier's
+-- cost history. Quantities still to be received count as on order in
+-- replenishment suggestions.
+
+CREATE TABLE IF NOT EXISTS suppliers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL UNIQUE,
+    contact_name VARCHAR(255),
+    email VARCHAR(255),
+    phone VARCHAR(50),
+    address TEXT,
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE SEQUENCE IF NOT EXISTS purchase_order_number_seq START 1000;
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    po_number VARCHAR(50) NOT NULL UNIQUE,
+    supplier_id UUID NOT NULL REFERENCES suppliers(id),
+    location VARCHAR(255) NOT NULL REFERENCES locations(name),
+    status VARCHAR(30) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'sent', 'partially_received', 'received', 'closed')),
+    expected_at TIMESTAMPTZ,
+    notes TEXT,
+    created_by UUID,
+    sent_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    purchase_order_id UUID NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    received_quantity INTEGER NOT NULL DEFAULT 0 CHECK (received_quantity >= 0),
+    unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+    expected_at TIMESTAMPTZ,
+    UNIQUE(purchase_order_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS supplier_costs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    purchase_order_line_id UUID REFERENCES purchase_order_lines(id) ON DELETE SET NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+    received_by UUID,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE inventory_transactions ADD COLUMN IF NOT EXISTS purchase_order_line_id UUID REFERENCES purchase_order_lines(id);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_supplier_id ON purchase_orders(supplier_id);
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_status ON purchase_orders(status);
+CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_purchase_order_id ON purchase_order_lines(purchase_order_id);
+CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_product_id ON purchase_order_lines(product_id);
+CREATE INDEX IF NOT EXISTS idx_supplier_costs_supplier_product ON supplier_costs(supplier_id, product_id, received_at);
+CREATE INDEX IF NOT EXISTS idx_inventory_transactions_purchase_order_line_id ON inventory_transactions(purchase_order_line_id);
+
+-- The manager role was seeded before these permissions existed. Give it
+-- them once; roles are editable, so this is not repeated at startup.
+INSERT INTO role_permissions (role, permission)
+SELECT 'manager', permission
+FROM unnest(ARRAY['purchase.read', 'purchase.manage', 'purchase.receive']) AS permission
+WHERE EXISTS (SELECT 1 FROM roles WHERE name = 'manager')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,8 @@
+-- Migration: Supervisor PIN throttling
+-- Description: Wrong supervisor PINs given to approve an action are counted
+-- per approver in login_throttles, like failed logins. Too many lock out
+-- approvals by that supervisor's PIN for a while; approval tokens still
+-- work.
+
+ALTER TABLE login_throttles DROP CONSTRAINT IF EXISTS login_throttles_scope_check;
+ALTER TABLE login_throttles ADD CONSTRAINT login_throttles_scope_check CHECK (scope IN ('username', 'ip', 'pin'));
@@ -0,0 +1,6 @@
+-- Migration: Refund restock
+-- Description: A refund that leaves an order fully refunded returns its items
+-- to the locations the order's stock was taken from, found by the order
+-- number its "out" transactions reference.
+
+CREATE INDEX IF NOT EXISTS idx_inventory_transactions_reference ON inventory_transactions(reference);
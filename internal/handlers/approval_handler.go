@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ApprovalHandler struct {
+	approvalService *services.ApprovalService
+}
+
+func NewApprovalHandler(approvalService *services.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{
+		approvalService: approvalService,
+	}
+}
+
+// CreateApprovalToken godoc
+// @Summary Create an approval token
+// @Description Create a single-use token that approves one action needing the given permissions, such as order.void or order.refund_approve, for a cashier who lacks them. The current user must have every permission. The token is only returned once and expires after APPROVAL_TOKEN_TTL.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param approval body models.CreateApprovalTokenRequest true "Permissions to approve"
+// @Success 201 {object} models.APIResponse{data=models.ApprovalToken}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/approvals [post]
+func (h *ApprovalHandler) CreateApprovalToken(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(http.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	var req models.CreateApprovalTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if len(req.Permissions) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "At least one permission is required",
+		})
+	}
+
+	token, err := h.approvalService.CreateToken(currentUser, &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrUnknownPermission):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrInvalidApproval):
+			status = http.StatusForbidden
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Approval token created successfully",
+		Data:    token,
+	})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// GetAuditLog godoc
+// @Summary List audit log entries
+// @Description Get the latest creates, updates and deletes of products, categories, inventory, customers, orders, payments, refunds and users, newest first, with who made them and the entity before and after (requires audit.read)
+// @Tags audit
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param entity_type query string false "Only this kind of entity, such as product or order"
+// @Param entity_id query string false "Only this entity"
+// @Param actor_id query string false "Only changes made by this user"
+// @Param api_key_id query string false "Only changes made with this API key"
+// @Param action query string false "Only this action: create, update or delete"
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Param limit query int false "Maximum number of entries (default 100, at most 1000)"
+// @Success 200 {object} models.APIResponse{data=[]models.AuditEntry}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /audit [get]
+func (h *AuditHandler) GetAuditLog(c *fiber.Ctx) error {
+	filter := models.AuditFilter{
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Action:     c.Query("action"),
+		Limit:      defaultAuditLimit,
+	}
+
+	if value := c.Query("actor_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid actor ID",
+			})
+		}
+		filter.ActorID = &id
+	}
+
+	if value := c.Query("api_key_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid API key ID",
+			})
+		}
+		filter.APIKeyID = &id
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid from date, expected YYYY-MM-DD",
+			})
+		}
+		filter.From = &from
+	}
+
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid to date, expected YYYY-MM-DD",
+			})
+		}
+		// The last day is included
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit < 1 || filter.Limit > maxAuditLimit {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid limit",
+			})
+		}
+	}
+
+	entries, err := h.auditService.GetEntries(filter)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidAuditFilter) {
+			status = http.StatusBadRequest
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Audit log retrieved successfully",
+		Data:    entries,
+	})
+}
+
+// VerifyAuditLog godoc
+// @Summary Verify the audit log
+// @Description Walk the audit log's hash chain from the first entry and report whether any entry was changed or removed, and if so the first one that was (requires audit.read)
+// @Tags audit
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=models.AuditVerification}
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /audit/verify [get]
+func (h *AuditHandler) VerifyAuditLog(c *fiber.Ctx) error {
+	result, err := h.auditService.Verify()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	message := "Audit log is intact"
+	if !result.Valid {
+		message = "Audit log has been tampered with"
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
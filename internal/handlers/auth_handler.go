@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+
 	"jatistore/internal/middleware"
 	"jatistore/internal/models"
 	"jatistore/internal/services"
@@ -21,16 +23,15 @@ func NewAuthHandler(userService *services.UserService) *AuthHandler {
 
 // Register handles user registration
 // @Summary Register a new user
-// @Description Register a new user account
+// @Description Register a "user" account. Public registration is disabled unless ALLOW_PUBLIC_REGISTRATION is set; staff accounts are created through invites.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer token"  // <-- required header
 // @Param user body models.RegisterRequest true "User registration data"
 // @Success 201 {object} models.APIResponse{data=models.User}
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 409 {object} models.APIResponse
-// @Security BearerAuth
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req models.RegisterRequest
@@ -78,27 +79,9 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Role == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
-			Success: false,
-			Error:   "Role is required",
-		})
-	}
-
-	if req.Role != "admin" && req.Role != "user" && req.Role != "cashier" {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
-			Success: false,
-			Error:   "Role must be admin, user, or cashier",
-		})
-	}
-
 	user, err := h.userService.Register(&req)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		if err.Error() == "username already exists" || err.Error() == "email already exists" {
-			status = fiber.StatusConflict
-		}
-		return c.Status(status).JSON(models.APIResponse{
+		return c.Status(accountErrorStatus(err)).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -113,14 +96,16 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 // Login handles user authentication
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return JWT token. Users with two-factor authentication, or whose role requires it, get a two_factor_token instead, to complete the login with POST /auth/login/2fa.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param credentials body models.LoginRequest true "Login credentials"
 // @Success 200 {object} models.APIResponse{data=models.LoginResponse}
+// @Success 202 {object} models.APIResponse{data=models.TwoFactorChallenge}
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req models.LoginRequest
@@ -147,14 +132,22 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
-	response, err := h.userService.Login(&req)
+	response, challenge, err := h.userService.Login(&req, c.IP())
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+		return c.Status(loginErrorStatus(c, err)).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
 	}
 
+	if challenge != nil {
+		return c.Status(fiber.StatusAccepted).JSON(models.APIResponse{
+			Success: true,
+			Message: "Two-factor authentication required",
+			Data:    challenge,
+		})
+	}
+
 	return c.JSON(models.APIResponse{
 		Success: true,
 		Message: "Login successful",
@@ -162,6 +155,390 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	})
 }
 
+// Refresh exchanges a refresh token for a new access token
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once; using one again revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param refresh body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} models.APIResponse{data=models.LoginResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Refresh token is required",
+		})
+	}
+
+	response, err := h.userService.Refresh(&req)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) ||
+			errors.Is(err, services.ErrTwoFactorRequired) {
+			status = fiber.StatusUnauthorized
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Token refreshed successfully",
+		Data:    response,
+	})
+}
+
+// GetJWKS serves the public keys tokens are signed with
+// @Summary JSON Web Key Set
+// @Description Get the public keys that verify jatistore access tokens, as a JSON Web Key Set. Tokens name their key in the kid header. Empty when tokens are signed with a shared JWT_SECRET.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} signing.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) GetJWKS(c *fiber.Ctx) error {
+	// Verifiers may cache the keys for a while; a new key is published
+	// well before it signs tokens
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.userService.JWKS())
+}
+
+// Setup creates the first admin account
+// @Summary Create the first admin
+// @Description Create the first admin account with the one-time setup token from SETUP_TOKEN or the server log. Only works while no admin exists.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param setup body models.SetupRequest true "Setup token and admin account"
+// @Success 201 {object} models.APIResponse{data=models.User}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/setup [post]
+func (h *AuthHandler) Setup(c *fiber.Ctx) error {
+	var req models.SetupRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.SetupToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Setup token is required",
+		})
+	}
+
+	if msg := validateNewAccount(req.Username, req.Password); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   msg,
+		})
+	}
+
+	if req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Email is required",
+		})
+	}
+
+	user, err := h.userService.Setup(&req)
+	if err != nil {
+		return c.Status(accountErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Admin account created successfully",
+		Data:    user,
+	})
+}
+
+// CreateInvite invites a staff member (requires user.manage)
+// @Summary Invite a staff member
+// @Description Issue a time-limited invite for a staff account with the given role (requires user.manage). The token is only shown in this response; the staff member redeems it at POST /auth/invites/accept.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param invite body models.CreateInviteRequest true "Invite data"
+// @Success 201 {object} models.APIResponse{data=models.Invite}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/invites [post]
+func (h *AuthHandler) CreateInvite(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	var req models.CreateInviteRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Email is required",
+		})
+	}
+
+	if req.Role == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Role is required",
+		})
+	}
+
+	invite, err := h.userService.CreateInvite(&req, currentUser)
+	if err != nil {
+		return c.Status(accountErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Invite created successfully",
+		Data:    invite,
+	})
+}
+
+// GetInvites lists invites (requires user.manage)
+// @Summary Get all invites
+// @Description Get all staff invites, pending and accepted (requires user.manage)
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=[]models.Invite}
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /auth/invites [get]
+func (h *AuthHandler) GetInvites(c *fiber.Ctx) error {
+	invites, err := h.userService.GetInvites()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Failed to retrieve invites",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    invites,
+	})
+}
+
+// RevokeInvite revokes a pending invite (requires user.manage)
+// @Summary Revoke an invite
+// @Description Revoke an invite that has not been accepted (requires user.manage)
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invite ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /auth/invites/{id} [delete]
+func (h *AuthHandler) RevokeInvite(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid invite ID",
+		})
+	}
+
+	if err := h.userService.RevokeInvite(id); err != nil {
+		status := fiber.StatusInternalServerError
+		if err.Error() == "invite not found" {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Invite revoked successfully",
+	})
+}
+
+// AcceptInvite redeems an invite
+// @Summary Accept an invite
+// @Description Redeem an invite token to create the invited staff account with your own username and password
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param invite body models.AcceptInviteRequest true "Invite token and account"
+// @Success 201 {object} models.APIResponse{data=models.User}
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/invites/accept [post]
+func (h *AuthHandler) AcceptInvite(c *fiber.Ctx) error {
+	var req models.AcceptInviteRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invite token is required",
+		})
+	}
+
+	if msg := validateNewAccount(req.Username, req.Password); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   msg,
+		})
+	}
+
+	user, err := h.userService.AcceptInvite(&req)
+	if err != nil {
+		return c.Status(accountErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Account created successfully",
+		Data:    user,
+	})
+}
+
+// ForgotPassword emails a password reset token
+// @Summary Request a password reset
+// @Description Email a single-use password reset token to the account with this email. The response is the same whether or not there is such an account. Each request replaces the tokens sent before, and tokens expire after PASSWORD_RESET_TTL.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Account email"
+// @Success 202 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req models.ForgotPasswordRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Email is required",
+		})
+	}
+
+	if err := h.userService.ForgotPassword(&req); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(models.APIResponse{
+		Success: true,
+		Message: "If an account has this email, a password reset email is on its way",
+	})
+}
+
+// ResetPassword sets a new password with a reset token
+// @Summary Reset password
+// @Description Set a new password with the token from a password reset email. The new password must follow the password policy. The token works once, and every session of the account is logged out.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req models.ResetPasswordRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Reset token is required",
+		})
+	}
+
+	if req.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "New password is required",
+		})
+	}
+
+	if err := h.userService.ResetPassword(&req); err != nil {
+		return c.Status(accountErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Password reset successfully",
+	})
+}
+
 // GetProfile retrieves the current user's profile
 // @Summary Get user profile
 // @Description Get the current authenticated user's profile
@@ -189,7 +566,7 @@ func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 
 // UpdateProfile updates the current user's profile
 // @Summary Update user profile
-// @Description Update the current authenticated user's profile
+// @Description Update the current authenticated user's username and email. The role and is_active fields are ignored; they are changed by users with user.manage.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -240,21 +617,12 @@ func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Role == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
-			Success: false,
-			Error:   "Role is required",
-		})
-	}
-
-	if req.Role != "admin" && req.Role != "user" && req.Role != "cashier" {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
-			Success: false,
-			Error:   "Role must be admin, user, or cashier",
-		})
-	}
+	// The role and active flag of an account are managed by admins, not
+	// through the profile
+	req.Role = currentUser.Role
+	req.IsActive = currentUser.IsActive
 
-	user, err := h.userService.UpdateUser(currentUser.ID, &req)
+	user, err := h.userService.UpdateUser(currentUser.ID, &req, currentUser)
 	if err != nil {
 		status := fiber.StatusInternalServerError
 		if err.Error() == "username already exists" || err.Error() == "email already exists" {
@@ -339,9 +707,125 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 	})
 }
 
-// GetAllUsers retrieves all users (admin only)
+// SetPIN sets the current user's approval PIN
+// @Summary Set approval PIN
+// @Description Set the 4 to 8 digit PIN the current user approves actions at the till with, such as voids and large refunds, without logging in there
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param pin body models.SetPINRequest true "PIN data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /auth/pin [put]
+func (h *AuthHandler) SetPIN(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	var req models.SetPINRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	// Basic validation
+	if req.CurrentPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Current password is required",
+		})
+	}
+
+	err := h.userService.SetPIN(currentUser.ID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "PIN set successfully",
+	})
+}
+
+// Logout ends the current session
+// @Summary Logout
+// @Description Revoke the current access token and the refresh tokens of its session
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	claims := middleware.GetCurrentUserClaims(c)
+	if claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	if err := h.userService.Logout(claims); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Logged out successfully",
+	})
+}
+
+// LogoutAll ends every session of the current user
+// @Summary Logout all sessions
+// @Description Revoke every access and refresh token of the current user, on every device
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	if err := h.userService.LogoutAll(currentUser.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Logged out of all sessions successfully",
+	})
+}
+
+// GetAllUsers retrieves all users (requires user.manage)
 // @Summary Get all users
-// @Description Get all users in the system (admin only)
+// @Description Get all users in the system (requires user.manage)
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -365,9 +849,9 @@ func (h *AuthHandler) GetAllUsers(c *fiber.Ctx) error {
 	})
 }
 
-// GetUserByID retrieves a user by ID (admin only)
+// GetUserByID retrieves a user by ID (requires user.manage)
 // @Summary Get user by ID
-// @Description Get a specific user by ID (admin only)
+// @Description Get a specific user by ID (requires user.manage)
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -403,9 +887,9 @@ func (h *AuthHandler) GetUserByID(c *fiber.Ctx) error {
 	})
 }
 
-// UpdateUser updates a user (admin only)
+// UpdateUser updates a user (requires user.manage)
 // @Summary Update user
-// @Description Update a specific user (admin only)
+// @Description Update a specific user (requires user.manage)
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -467,18 +951,15 @@ func (h *AuthHandler) UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.Role != "admin" && req.Role != "user" && req.Role != "cashier" {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
-			Success: false,
-			Error:   "Role must be admin, user, or cashier",
-		})
-	}
-
-	user, err := h.userService.UpdateUser(id, &req)
+	user, err := h.userService.UpdateUser(id, &req, middleware.GetCurrentUser(c))
 	if err != nil {
 		status := fiber.StatusInternalServerError
 		if err.Error() == "user not found" {
 			status = fiber.StatusNotFound
+		} else if errors.Is(err, services.ErrInvalidRole) {
+			status = fiber.StatusBadRequest
+		} else if errors.Is(err, services.ErrRoleNotGrantable) {
+			status = fiber.StatusForbidden
 		} else if err.Error() == "username already exists" || err.Error() == "email already exists" {
 			status = fiber.StatusConflict
 		}
@@ -495,9 +976,9 @@ func (h *AuthHandler) UpdateUser(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteUser deletes a user (admin only)
+// DeleteUser deletes a user (requires user.manage)
 // @Summary Delete user
-// @Description Delete a specific user (admin only)
+// @Description Delete a specific user (requires user.manage)
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -519,7 +1000,7 @@ func (h *AuthHandler) DeleteUser(c *fiber.Ctx) error {
 		})
 	}
 
-	err = h.userService.DeleteUser(id)
+	err = h.userService.DeleteUser(id, middleware.GetCurrentUser(c))
 	if err != nil {
 		status := fiber.StatusInternalServerError
 		if err.Error() == "user not found" {
@@ -536,3 +1017,70 @@ func (h *AuthHandler) DeleteUser(c *fiber.Ctx) error {
 		Message: "User deleted successfully",
 	})
 }
+
+// RevokeUserSessions ends every session of a user
+// @Summary Revoke user sessions
+// @Description Revoke every access and refresh token of a specific user, logging them out on every device (requires user.manage)
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/users/{id}/sessions [delete]
+func (h *AuthHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	if err := h.userService.LogoutAll(id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "User sessions revoked successfully",
+	})
+}
+
+// validateNewAccount checks the username and password of a new account,
+// returning a message for the first one that is missing or invalid. The
+// password policy itself is enforced when the account is stored.
+func validateNewAccount(username, password string) string {
+	switch {
+	case username == "":
+		return "Username is required"
+	case len(username) < 3 || len(username) > 50:
+		return "Username must be between 3 and 50 characters"
+	case password == "":
+		return "Password is required"
+	}
+	return ""
+}
+
+// accountErrorStatus maps errors from creating accounts to HTTP status codes
+func accountErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrRegistrationDisabled), errors.Is(err, services.ErrRoleNotAllowed),
+		errors.Is(err, services.ErrInvalidSetupToken), errors.Is(err, services.ErrSetupUnavailable),
+		errors.Is(err, services.ErrRoleNotGrantable):
+		return fiber.StatusForbidden
+	case errors.Is(err, services.ErrInvalidInvite), errors.Is(err, services.ErrInvalidRole),
+		errors.Is(err, services.ErrInvalidPassword), errors.Is(err, services.ErrInvalidPasswordReset):
+		return fiber.StatusBadRequest
+	case err.Error() == "username already exists" || err.Error() == "email already exists":
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
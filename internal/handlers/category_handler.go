@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"jatistore/internal/middleware"
 	"jatistore/internal/models"
 	"jatistore/internal/services"
 
@@ -48,7 +49,7 @@ func (h *CategoryHandler) CreateCategory(c *fiber.Ctx) error {
 		})
 	}
 
-	category, err := h.categoryService.CreateCategory(&req)
+	category, err := h.categoryService.CreateCategory(&req, middleware.GetCurrentUser(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
@@ -165,7 +166,7 @@ func (h *CategoryHandler) UpdateCategory(c *fiber.Ctx) error {
 		})
 	}
 
-	category, err := h.categoryService.UpdateCategory(id, &req)
+	category, err := h.categoryService.UpdateCategory(id, &req, middleware.GetCurrentUser(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
@@ -202,7 +203,7 @@ func (h *CategoryHandler) DeleteCategory(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.categoryService.DeleteCategory(id)
+	err := h.categoryService.DeleteCategory(id, middleware.GetCurrentUser(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
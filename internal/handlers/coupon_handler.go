@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CouponHandler struct {
+	couponService *services.CouponService
+}
+
+func NewCouponHandler(couponService *services.CouponService) *CouponHandler {
+	return &CouponHandler{
+		couponService: couponService,
+	}
+}
+
+// CreateCoupon godoc
+// @Summary Create a coupon
+// @Description Create a coupon code with a discount, a validity window, usage limits and a minimum basket
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param coupon body models.CreateCouponRequest true "Coupon data"
+// @Success 201 {object} models.APIResponse{data=models.Coupon}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /coupons [post]
+func (h *CouponHandler) CreateCoupon(c *fiber.Ctx) error {
+	var req models.CreateCouponRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Code == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Coupon code is required",
+		})
+	}
+
+	coupon, err := h.couponService.CreateCoupon(&req)
+	if err != nil {
+		return c.Status(couponErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Coupon created successfully",
+		Data:    coupon,
+	})
+}
+
+// GetAllCoupons godoc
+// @Summary Get all coupons
+// @Description Get a list of all coupons with how often they have been used
+// @Tags coupons
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.Coupon}
+// @Failure 500 {object} models.APIResponse
+// @Router /coupons [get]
+func (h *CouponHandler) GetAllCoupons(c *fiber.Ctx) error {
+	coupons, err := h.couponService.GetAllCoupons()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    coupons,
+	})
+}
+
+// GetCoupon godoc
+// @Summary Get a coupon
+// @Description Get a coupon by ID
+// @Tags coupons
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Coupon ID"
+// @Success 200 {object} models.APIResponse{data=models.Coupon}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /coupons/{id} [get]
+func (h *CouponHandler) GetCoupon(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid coupon ID",
+		})
+	}
+
+	coupon, err := h.couponService.GetCoupon(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    coupon,
+	})
+}
+
+// UpdateCoupon godoc
+// @Summary Update a coupon
+// @Description Update a coupon. Orders already placed keep the discount they were given and their uses still count.
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Coupon ID"
+// @Param coupon body models.UpdateCouponRequest true "Coupon data"
+// @Success 200 {object} models.APIResponse{data=models.Coupon}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /coupons/{id} [put]
+func (h *CouponHandler) UpdateCoupon(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid coupon ID",
+		})
+	}
+
+	var req models.UpdateCouponRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Code == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Coupon code is required",
+		})
+	}
+
+	coupon, err := h.couponService.UpdateCoupon(id, &req)
+	if err != nil {
+		return c.Status(couponErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Coupon updated successfully",
+		Data:    coupon,
+	})
+}
+
+// DeleteCoupon godoc
+// @Summary Delete a coupon
+// @Description Delete a coupon that has never been redeemed. Redeemed coupons have to be deactivated instead.
+// @Tags coupons
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Coupon ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /coupons/{id} [delete]
+func (h *CouponHandler) DeleteCoupon(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid coupon ID",
+		})
+	}
+
+	if err := h.couponService.DeleteCoupon(id); err != nil {
+		return c.Status(couponErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Coupon deleted successfully",
+	})
+}
+
+func couponErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidCoupon):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrCouponInUse):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"jatistore/internal/middleware"
 	"jatistore/internal/models"
 	"jatistore/internal/services"
 
@@ -61,7 +62,7 @@ func (h *CustomerHandler) CreateCustomer(c *fiber.Ctx) error {
 		})
 	}
 
-	customer, err := h.customerService.CreateCustomer(&req)
+	customer, err := h.customerService.CreateCustomer(&req, middleware.GetCurrentUser(c))
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
@@ -190,7 +191,7 @@ func (h *CustomerHandler) UpdateCustomer(c *fiber.Ctx) error {
 		})
 	}
 
-	customer, err := h.customerService.UpdateCustomer(id, &req)
+	customer, err := h.customerService.UpdateCustomer(id, &req, middleware.GetCurrentUser(c))
 	if err != nil {
 		if err.Error() == errCustomerNotFound {
 			return c.Status(http.StatusNotFound).JSON(models.APIResponse{
@@ -233,7 +234,7 @@ func (h *CustomerHandler) DeleteCustomer(c *fiber.Ctx) error {
 		})
 	}
 
-	err = h.customerService.DeleteCustomer(id)
+	err = h.customerService.DeleteCustomer(id, middleware.GetCurrentUser(c))
 	if err != nil {
 		if err.Error() == errCustomerNotFound {
 			return c.Status(http.StatusNotFound).JSON(models.APIResponse{
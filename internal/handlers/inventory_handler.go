@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"jatistore/internal/middleware"
 	"jatistore/internal/models"
 	"jatistore/internal/services"
 
@@ -19,7 +20,7 @@ func NewInventoryHandler(inventoryService *services.InventoryService) *Inventory
 
 // CreateInventory creates a new inventory record
 // @Summary Create a new inventory record
-// @Description Create a new inventory record with the provided data
+// @Description Stock a product at a location it is not stocked at yet. The quantity is recorded in the stock ledger as its opening balance.
 // @Tags Inventory
 // @Accept json
 // @Produce json
@@ -28,6 +29,7 @@ func NewInventoryHandler(inventoryService *services.InventoryService) *Inventory
 // @Param inventory body models.CreateInventoryRequest true "Inventory data"
 // @Success 201 {object} models.APIResponse{data=models.Inventory}
 // @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /inventory [post]
 func (h *InventoryHandler) CreateInventory(c *fiber.Ctx) error {
@@ -62,9 +64,9 @@ func (h *InventoryHandler) CreateInventory(c *fiber.Ctx) error {
 		})
 	}
 
-	inventory, err := h.inventoryService.CreateInventory(&req)
+	inventory, err := h.inventoryService.CreateInventory(&req, middleware.GetCurrentUser(c))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -141,7 +143,7 @@ func (h *InventoryHandler) GetAllInventory(c *fiber.Ctx) error {
 
 // UpdateInventory updates an existing inventory record
 // @Summary Update an inventory record
-// @Description Update an inventory record with the provided data
+// @Description Set the stock of an inventory record to a counted quantity, recorded in the stock ledger as an adjustment. The location cannot be changed; transfer the stock instead.
 // @Tags Inventory
 // @Accept json
 // @Produce json
@@ -186,9 +188,9 @@ func (h *InventoryHandler) UpdateInventory(c *fiber.Ctx) error {
 		})
 	}
 
-	inventory, err := h.inventoryService.UpdateInventory(id, &req)
+	inventory, err := h.inventoryService.UpdateInventory(id, &req, middleware.GetCurrentUser(c))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -203,7 +205,7 @@ func (h *InventoryHandler) UpdateInventory(c *fiber.Ctx) error {
 
 // DeleteInventory deletes an inventory record
 // @Summary Delete an inventory record
-// @Description Delete an inventory record by its ID
+// @Description Delete an inventory record holding no stock. Its stock ledger is kept.
 // @Tags Inventory
 // @Accept json
 // @Produce json
@@ -212,6 +214,7 @@ func (h *InventoryHandler) UpdateInventory(c *fiber.Ctx) error {
 // @Param id path string true "Inventory ID"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /inventory/{id} [delete]
 func (h *InventoryHandler) DeleteInventory(c *fiber.Ctx) error {
@@ -223,9 +226,9 @@ func (h *InventoryHandler) DeleteInventory(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.inventoryService.DeleteInventory(id)
+	err := h.inventoryService.DeleteInventory(id, middleware.GetCurrentUser(c))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -239,7 +242,7 @@ func (h *InventoryHandler) DeleteInventory(c *fiber.Ctx) error {
 
 // AdjustStock adjusts inventory stock levels
 // @Summary Adjust inventory stock
-// @Description Adjust inventory stock levels (in/out/adjustment)
+// @Description Adjust inventory stock levels (in/out/adjustment) at a location. The location may be left out for a product stocked at a single location.
 // @Tags Inventory
 // @Accept json
 // @Produce json
@@ -248,6 +251,7 @@ func (h *InventoryHandler) DeleteInventory(c *fiber.Ctx) error {
 // @Param adjustment body models.AdjustStockRequest true "Stock adjustment data"
 // @Success 200 {object} models.APIResponse{data=models.InventoryTransaction}
 // @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /inventory/adjust [post]
 func (h *InventoryHandler) AdjustStock(c *fiber.Ctx) error {
@@ -282,9 +286,9 @@ func (h *InventoryHandler) AdjustStock(c *fiber.Ctx) error {
 		})
 	}
 
-	transaction, err := h.inventoryService.AdjustStock(&req)
+	transaction, err := h.inventoryService.AdjustStock(&req, middleware.GetCurrentUser(c))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
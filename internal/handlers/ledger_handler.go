@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// GetStockOnHand godoc
+// @Summary Get stock on hand
+// @Description Get the stock of each product at each location worked out from the stock ledger, now or as it stood at a past time
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param as_of query string false "RFC 3339 time to get the stock at, such as 2024-01-31T23:59:59Z; defaults to now"
+// @Param product_id query string false "Only this product"
+// @Param location query string false "Only this location"
+// @Success 200 {object} models.APIResponse{data=[]models.StockLevel}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/on-hand [get]
+func (h *InventoryHandler) GetStockOnHand(c *fiber.Ctx) error {
+	var asOf *time.Time
+	if value := c.Query("as_of"); value != "" {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid as_of time, expected RFC 3339 such as 2024-01-31T23:59:59Z",
+			})
+		}
+		asOf = &at
+	}
+
+	productID := c.Query("product_id")
+	if productID != "" {
+		if _, err := uuid.Parse(productID); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid product ID",
+			})
+		}
+	}
+
+	levels, err := h.inventoryService.GetStockOnHand(asOf, productID, c.Query("location"))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Stock on hand retrieved successfully",
+		Data:    levels,
+	})
+}
+
+// GetReconciliation godoc
+// @Summary Reconcile inventory with the stock ledger
+// @Description Check every inventory quantity against the stock ledger and report those that differ, without changing anything
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=models.ReconciliationReport}
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/reconcile [get]
+func (h *InventoryHandler) GetReconciliation(c *fiber.Ctx) error {
+	report, err := h.inventoryService.Reconcile(false, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Inventory reconciled successfully",
+		Data:    report,
+	})
+}
+
+// RebuildInventory godoc
+// @Summary Rebuild inventory from the stock ledger
+// @Description Check every inventory quantity against the stock ledger and replace those that differ by the ledger's. Stock cannot be moved while this runs. Each replaced quantity is recorded in the audit log.
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=models.ReconciliationReport}
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/reconcile [post]
+func (h *InventoryHandler) RebuildInventory(c *fiber.Ctx) error {
+	report, err := h.inventoryService.Reconcile(true, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Inventory rebuilt from the stock ledger successfully",
+		Data:    report,
+	})
+}
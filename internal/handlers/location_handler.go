@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const errLocationNotFound = "location not found"
+
+type LocationHandler struct {
+	locationService *services.LocationService
+}
+
+func NewLocationHandler(locationService *services.LocationService) *LocationHandler {
+	return &LocationHandler{
+		locationService: locationService,
+	}
+}
+
+// GetAllLocations godoc
+// @Summary List locations
+// @Description Get every location stock can be kept at, active ones first
+// @Tags locations
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.Location}
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations [get]
+func (h *LocationHandler) GetAllLocations(c *fiber.Ctx) error {
+	locations, err := h.locationService.GetLocations()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Locations retrieved successfully",
+		Data:    locations,
+	})
+}
+
+// GetLocation godoc
+// @Summary Get a location
+// @Description Get a location by its name
+// @Tags locations
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param name path string true "Location name"
+// @Success 200 {object} models.APIResponse{data=models.Location}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/{name} [get]
+func (h *LocationHandler) GetLocation(c *fiber.Ctx) error {
+	location, err := h.locationService.GetLocation(c.Params("name"))
+	if err != nil {
+		return c.Status(locationErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Location retrieved successfully",
+		Data:    location,
+	})
+}
+
+// CreateLocation godoc
+// @Summary Create a location
+// @Description Create a location, such as the shop floor, the back room or a warehouse. Its name cannot be changed later.
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param location body models.CreateLocationRequest true "Location data"
+// @Success 201 {object} models.APIResponse{data=models.Location}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations [post]
+func (h *LocationHandler) CreateLocation(c *fiber.Ctx) error {
+	var req models.CreateLocationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Location name is required",
+		})
+	}
+
+	location, err := h.locationService.CreateLocation(&req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(locationErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Location created successfully",
+		Data:    location,
+	})
+}
+
+// UpdateLocation godoc
+// @Summary Update a location
+// @Description Replace the description of a location and whether it is active. Stock at an inactive location stays there, but cannot be adjusted or transferred in or out.
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param name path string true "Location name"
+// @Param location body models.UpdateLocationRequest true "Location data"
+// @Success 200 {object} models.APIResponse{data=models.Location}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/{name} [put]
+func (h *LocationHandler) UpdateLocation(c *fiber.Ctx) error {
+	var req models.UpdateLocationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	location, err := h.locationService.UpdateLocation(c.Params("name"), &req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(locationErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Location updated successfully",
+		Data:    location,
+	})
+}
+
+// DeleteLocation godoc
+// @Summary Delete a location
+// @Description Delete a location that has never held stock. Locations with stock history can be deactivated instead.
+// @Tags locations
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param name path string true "Location name"
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/{name} [delete]
+func (h *LocationHandler) DeleteLocation(c *fiber.Ctx) error {
+	if err := h.locationService.DeleteLocation(c.Params("name"), middleware.GetCurrentUser(c)); err != nil {
+		return c.Status(locationErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Location deleted successfully",
+	})
+}
+
+// locationErrorStatus maps location errors to HTTP status codes
+func locationErrorStatus(err error) int {
+	switch {
+	case err.Error() == errLocationNotFound:
+		return http.StatusNotFound
+	case err.Error() == "location already exists", errors.Is(err, services.ErrLocationInUse):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidLocationName):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	"jatistore/internal/middleware"
 	"jatistore/internal/models"
 	"jatistore/internal/services"
 
@@ -26,7 +28,7 @@ func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
 
 // CreateOrder godoc
 // @Summary Create a new order
-// @Description Create a new sales order with items
+// @Description Create a new sales order with items. Tax is computed from each product's tax class; tax_amount overrides it. Overriding tax (tax_amount) or prices (unit_price) and manual discounts above the discount approval threshold need the matching permission or a supervisor's approval (username and PIN, or an approval token). Coupons in coupon_codes are redeemed with the order.
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -35,6 +37,8 @@ func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
 // @Param order body models.CreateOrderRequest true "Order information"
 // @Success 201 {object} models.APIResponse{data=models.Order}
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /orders [post]
 func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
@@ -67,10 +71,16 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 				Error:   "Item discount cannot be negative",
 			})
 		}
+		if item.UnitPrice != nil && *item.UnitPrice < 0 {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Item unit price cannot be negative",
+			})
+		}
 		req.Items[i] = item
 	}
 
-	if req.TaxAmount < 0 {
+	if req.TaxAmount != nil && *req.TaxAmount < 0 {
 		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Tax amount cannot be negative",
@@ -84,9 +94,26 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		})
 	}
 
-	order, err := h.orderService.CreateOrder(&req)
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	order, err := h.orderService.CreateOrder(&req, user)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrApprovalRequired), errors.Is(err, services.ErrInvalidApproval):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrNoTaxRate), errors.Is(err, services.ErrCouponUsedUp):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrInvalidCoupon):
+			status = http.StatusBadRequest
+		}
+		return c.Status(status).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -168,17 +195,19 @@ func (h *OrderHandler) GetAllOrders(c *fiber.Ctx) error {
 
 // UpdateOrderStatus godoc
 // @Summary Update order status
-// @Description Update the status of an order
+// @Description Move an order to another status. Allowed transitions: draft -> pending/cancelled, pending <-> on_hold, pending/on_hold -> completed (order must be paid) or cancelled (order must not be paid). Completing an order deducts its items from inventory; a completed order is paid, so it is refunded rather than cancelled, and its items go back to stock through the refund. Cancelling an order other than a draft is a void and needs the order.void permission or a supervisor's approval. partially_refunded and refunded are set by refunds.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param Authorization header string true "Bearer token"
 // @Param id path string true "Order ID"
-// @Param status body map[string]string true "Order status"
+// @Param status body models.UpdateOrderStatusRequest true "Order status"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /orders/{id}/status [put]
 func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
@@ -190,7 +219,7 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	var req map[string]string
+	var req models.UpdateOrderStatusRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
 			Success: false,
@@ -198,15 +227,29 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	status, exists := req["status"]
-	if !exists {
+	if req.Status == "" {
 		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
 			Success: false,
 			Error:   "Status is required",
 		})
 	}
 
-	err = h.orderService.UpdateOrderStatus(id, status)
+	if !services.IsValidOrderStatus(req.Status) {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid order status",
+		})
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	err = h.orderService.UpdateOrderStatus(id, &req, user)
 	if err != nil {
 		if err.Error() == errOrderNotFound {
 			return c.Status(http.StatusNotFound).JSON(models.APIResponse{
@@ -214,6 +257,18 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 				Error:   "Order not found",
 			})
 		}
+		if errors.Is(err, services.ErrApprovalRequired) || errors.Is(err, services.ErrInvalidApproval) {
+			return c.Status(http.StatusForbidden).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrInsufficientStock) || errors.Is(err, services.ErrInvalidTransition) {
+			return c.Status(http.StatusConflict).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
 		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -226,9 +281,51 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 	})
 }
 
+// GetOrderStatusHistory godoc
+// @Summary Get order status history
+// @Description Get every status transition of an order, oldest first, with who made it and why
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.APIResponse{data=[]models.OrderStatusHistory}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /orders/{id}/history [get]
+func (h *OrderHandler) GetOrderStatusHistory(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid order ID",
+		})
+	}
+
+	history, err := h.orderService.GetOrderStatusHistory(id)
+	if err != nil {
+		if err.Error() == errOrderNotFound {
+			return c.Status(http.StatusNotFound).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Order not found",
+			})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    history,
+	})
+}
+
 // ProcessPayment godoc
 // @Summary Process payment for an order
-// @Description Process a payment for an order
+// @Description Pay for an order with a single tender (amount, payment_method) or several tenders at once (tenders). Cash may exceed what is due and the difference is returned as change; other methods may not. Only pending orders can be paid. Requires an open shift.
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -236,9 +333,10 @@ func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 // @Param Authorization header string true "Bearer token"
 // @Param id path string true "Order ID"
 // @Param payment body models.CreatePaymentRequest true "Payment information"
-// @Success 200 {object} models.APIResponse{data=models.Payment}
+// @Success 200 {object} models.APIResponse{data=models.PaymentResult}
 // @Failure 400 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /orders/{id}/payments [post]
 func (h *OrderHandler) ProcessPayment(c *fiber.Ctx) error {
@@ -259,28 +357,36 @@ func (h *OrderHandler) ProcessPayment(c *fiber.Ctx) error {
 	}
 
 	// Basic validation
-	if req.Amount <= 0 {
-		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-			Success: false,
-			Error:   "Payment amount must be greater than 0",
-		})
+	tenders := req.Tenders
+	if len(tenders) == 0 {
+		tenders = []models.TenderRequest{{Amount: req.Amount, PaymentMethod: req.PaymentMethod}}
 	}
 
-	validPaymentMethods := map[string]bool{
-		"cash":           true,
-		"card":           true,
-		"transfer":       true,
-		"digital_wallet": true,
+	for _, tender := range tenders {
+		if tender.Amount <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Payment amount must be greater than 0",
+			})
+		}
+
+		if !services.IsPaymentMethod(tender.PaymentMethod) {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid payment method",
+			})
+		}
 	}
 
-	if !validPaymentMethods[req.PaymentMethod] {
-		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(models.APIResponse{
 			Success: false,
-			Error:   "Invalid payment method",
+			Error:   "Authentication required",
 		})
 	}
 
-	payment, err := h.orderService.ProcessPayment(id, &req)
+	result, err := h.orderService.ProcessPayment(id, &req, user)
 	if err != nil {
 		if err.Error() == errOrderNotFound {
 			return c.Status(http.StatusNotFound).JSON(models.APIResponse{
@@ -288,6 +394,18 @@ func (h *OrderHandler) ProcessPayment(c *fiber.Ctx) error {
 				Error:   "Order not found",
 			})
 		}
+		if errors.Is(err, services.ErrInvalidPayment) {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrNoOpenShift) || errors.Is(err, services.ErrInvalidTransition) {
+			return c.Status(http.StatusConflict).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
 		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -297,13 +415,13 @@ func (h *OrderHandler) ProcessPayment(c *fiber.Ctx) error {
 	return c.JSON(models.APIResponse{
 		Success: true,
 		Message: "Payment processed successfully",
-		Data:    payment,
+		Data:    result,
 	})
 }
 
 // GenerateReceipt godoc
 // @Summary Generate receipt for an order
-// @Description Generate a receipt for a paid order
+// @Description Generate a receipt for a paid order with the current user as cashier. Use GET /receipts/{id} to render it.
 // @Tags orders
 // @Produce json
 // @Security BearerAuth
@@ -323,7 +441,15 @@ func (h *OrderHandler) GenerateReceipt(c *fiber.Ctx) error {
 		})
 	}
 
-	receipt, err := h.orderService.GenerateReceipt(id)
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	receipt, err := h.orderService.GenerateReceipt(id, user)
 	if err != nil {
 		if err.Error() == errOrderNotFound {
 			return c.Status(http.StatusNotFound).JSON(models.APIResponse{
@@ -344,6 +470,135 @@ func (h *OrderHandler) GenerateReceipt(c *fiber.Ctx) error {
 	})
 }
 
+// RefundOrder godoc
+// @Summary Refund an order
+// @Description Refund a completed order fully (no items) or per line with a quantity per order item. Refunds are recorded as negative payments linked to the original payments, return the items to restock_location, or when the order ends up fully refunded without one, to the locations they were taken from, and produce a credit note receipt. Cash can only be refunded by a user with an open shift. Refunds above the configured threshold need the order.refund_approve permission or a supervisor's approval.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Order ID"
+// @Param refund body models.CreateRefundRequest true "Refund information"
+// @Success 201 {object} models.APIResponse{data=models.Refund}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /orders/{id}/refunds [post]
+func (h *OrderHandler) RefundOrder(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid order ID",
+		})
+	}
+
+	var req models.CreateRefundRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	// Basic validation
+	if req.Reason == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Refund reason is required",
+		})
+	}
+
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Refund quantity must be greater than 0",
+			})
+		}
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(http.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	refund, err := h.orderService.RefundOrder(id, &req, user)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case err.Error() == errOrderNotFound:
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidRefund), errors.Is(err, services.ErrUnknownLocation),
+			errors.Is(err, services.ErrInactiveLocation):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrRefundApprovalRequired), errors.Is(err, services.ErrInvalidApproval):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrRefundExceedsPaid), errors.Is(err, services.ErrInvalidTransition),
+			errors.Is(err, services.ErrNoOpenShift):
+			status = http.StatusConflict
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Refund processed successfully",
+		Data:    refund,
+	})
+}
+
+// GetOrderRefunds godoc
+// @Summary Get refunds of an order
+// @Description Get all refunds made against an order
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.APIResponse{data=[]models.Refund}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /orders/{id}/refunds [get]
+func (h *OrderHandler) GetOrderRefunds(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid order ID",
+		})
+	}
+
+	refunds, err := h.orderService.GetOrderRefunds(id)
+	if err != nil {
+		if err.Error() == errOrderNotFound {
+			return c.Status(http.StatusNotFound).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Order not found",
+			})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    refunds,
+	})
+}
+
 // GetOrdersByCustomer godoc
 // @Summary Get orders by customer
 // @Description Get all orders for a specific customer
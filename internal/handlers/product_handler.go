@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"jatistore/internal/middleware"
 	"jatistore/internal/models"
 	"jatistore/internal/services"
 
@@ -62,7 +63,7 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	product, err := h.productService.CreateProduct(&req)
+	product, err := h.productService.CreateProduct(&req, middleware.GetCurrentUser(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
@@ -193,7 +194,7 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	product, err := h.productService.UpdateProduct(id, &req)
+	product, err := h.productService.UpdateProduct(id, &req, middleware.GetCurrentUser(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
@@ -230,7 +231,7 @@ func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.productService.DeleteProduct(id)
+	err := h.productService.DeleteProduct(id, middleware.GetCurrentUser(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
 			Success: false,
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type PromotionHandler struct {
+	promotionService *services.PromotionService
+}
+
+func NewPromotionHandler(promotionService *services.PromotionService) *PromotionHandler {
+	return &PromotionHandler{
+		promotionService: promotionService,
+	}
+}
+
+// CreatePromotion godoc
+// @Summary Create a promotion
+// @Description Create a promotion that is applied automatically to matching orders while it is active
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param promotion body models.CreatePromotionRequest true "Promotion data"
+// @Success 201 {object} models.APIResponse{data=models.Promotion}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /promotions [post]
+func (h *PromotionHandler) CreatePromotion(c *fiber.Ctx) error {
+	var req models.CreatePromotionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Promotion name is required",
+		})
+	}
+
+	promotion, err := h.promotionService.CreatePromotion(&req)
+	if err != nil {
+		return c.Status(promotionErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Promotion created successfully",
+		Data:    promotion,
+	})
+}
+
+// GetAllPromotions godoc
+// @Summary Get all promotions
+// @Description Get a list of all promotions, active or not
+// @Tags promotions
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.Promotion}
+// @Failure 500 {object} models.APIResponse
+// @Router /promotions [get]
+func (h *PromotionHandler) GetAllPromotions(c *fiber.Ctx) error {
+	promotions, err := h.promotionService.GetAllPromotions()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    promotions,
+	})
+}
+
+// GetPromotion godoc
+// @Summary Get a promotion
+// @Description Get a promotion by ID
+// @Tags promotions
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} models.APIResponse{data=models.Promotion}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /promotions/{id} [get]
+func (h *PromotionHandler) GetPromotion(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid promotion ID",
+		})
+	}
+
+	promotion, err := h.promotionService.GetPromotion(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    promotion,
+	})
+}
+
+// UpdatePromotion godoc
+// @Summary Update a promotion
+// @Description Update a promotion. Orders already placed keep the discount they were given.
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Promotion ID"
+// @Param promotion body models.UpdatePromotionRequest true "Promotion data"
+// @Success 200 {object} models.APIResponse{data=models.Promotion}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /promotions/{id} [put]
+func (h *PromotionHandler) UpdatePromotion(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid promotion ID",
+		})
+	}
+
+	var req models.UpdatePromotionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Promotion name is required",
+		})
+	}
+
+	promotion, err := h.promotionService.UpdatePromotion(id, &req)
+	if err != nil {
+		return c.Status(promotionErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Promotion updated successfully",
+		Data:    promotion,
+	})
+}
+
+// DeletePromotion godoc
+// @Summary Delete a promotion
+// @Description Delete a promotion that has never been applied. Applied promotions have to be deactivated instead.
+// @Tags promotions
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Promotion ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /promotions/{id} [delete]
+func (h *PromotionHandler) DeletePromotion(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid promotion ID",
+		})
+	}
+
+	if err := h.promotionService.DeletePromotion(id); err != nil {
+		return c.Status(promotionErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Promotion deleted successfully",
+	})
+}
+
+// GetPromotionReport godoc
+// @Summary Get promotion report
+// @Description Get the number of orders and the total discount given per promotion for orders placed between two dates, both inclusive
+// @Tags promotions
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param from query string true "First day (YYYY-MM-DD)"
+// @Param to query string true "Last day (YYYY-MM-DD)"
+// @Success 200 {object} models.APIResponse{data=[]models.PromotionReport}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /promotions/report [get]
+func (h *PromotionHandler) GetPromotionReport(c *fiber.Ctx) error {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid from date, expected YYYY-MM-DD",
+		})
+	}
+
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid to date, expected YYYY-MM-DD",
+		})
+	}
+
+	report, err := h.promotionService.GetPromotionReport(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    report,
+	})
+}
+
+func promotionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidPromotion):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrPromotionInUse):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const errPurchaseOrderNotFound = "purchase order not found"
+
+type PurchaseOrderHandler struct {
+	purchaseOrderService *services.PurchaseOrderService
+}
+
+func NewPurchaseOrderHandler(purchaseOrderService *services.PurchaseOrderService) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{
+		purchaseOrderService: purchaseOrderService,
+	}
+}
+
+// GetAllPurchaseOrders godoc
+// @Summary List purchase orders
+// @Description Get purchase orders with their lines, newest first
+// @Tags purchase-orders
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param status query string false "Only purchase orders with this status (draft, sent, partially_received, received, closed)"
+// @Param supplier_id query string false "Only purchase orders from this supplier"
+// @Success 200 {object} models.APIResponse{data=[]models.PurchaseOrder}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchase-orders [get]
+func (h *PurchaseOrderHandler) GetAllPurchaseOrders(c *fiber.Ctx) error {
+	var supplierID *uuid.UUID
+	if value := c.Query("supplier_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid supplier ID",
+			})
+		}
+		supplierID = &id
+	}
+
+	orders, err := h.purchaseOrderService.GetPurchaseOrders(c.Query("status"), supplierID)
+	if err != nil {
+		return c.Status(purchaseOrderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Purchase orders retrieved successfully",
+		Data:    orders,
+	})
+}
+
+// GetPurchaseOrder godoc
+// @Summary Get a purchase order
+// @Description Get a purchase order with its lines and how much of each has been received
+// @Tags purchase-orders
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Purchase order ID"
+// @Success 200 {object} models.APIResponse{data=models.PurchaseOrder}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchase-orders/{id} [get]
+func (h *PurchaseOrderHandler) GetPurchaseOrder(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid purchase order ID",
+		})
+	}
+
+	order, err := h.purchaseOrderService.GetPurchaseOrder(id)
+	if err != nil {
+		return c.Status(purchaseOrderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Purchase order retrieved successfully",
+		Data:    order,
+	})
+}
+
+// CreatePurchaseOrder godoc
+// @Summary Create a purchase order
+// @Description Draft a purchase order with a supplier for stock to be delivered to a location. Lines without a unit cost are expected to cost what the supplier charged last time.
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param order body models.PurchaseOrderRequest true "Purchase order data"
+// @Success 201 {object} models.APIResponse{data=models.PurchaseOrder}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchase-orders [post]
+func (h *PurchaseOrderHandler) CreatePurchaseOrder(c *fiber.Ctx) error {
+	var req models.PurchaseOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	order, err := h.purchaseOrderService.CreatePurchaseOrder(&req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(purchaseOrderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Purchase order created successfully",
+		Data:    order,
+	})
+}
+
+// UpdatePurchaseOrder godoc
+// @Summary Update a purchase order
+// @Description Replace the supplier, location, expected date, notes and lines of a draft purchase order
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Purchase order ID"
+// @Param order body models.PurchaseOrderRequest true "Purchase order data"
+// @Success 200 {object} models.APIResponse{data=models.PurchaseOrder}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchase-orders/{id} [put]
+func (h *PurchaseOrderHandler) UpdatePurchaseOrder(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid purchase order ID",
+		})
+	}
+
+	var req models.PurchaseOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	order, err := h.purchaseOrderService.UpdatePurchaseOrder(id, &req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(purchaseOrderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Purchase order updated successfully",
+		Data:    order,
+	})
+}
+
+// DeletePurchaseOrder godoc
+// @Summary Delete a purchase order
+// @Description Delete a draft purchase order. Sent purchase orders are closed instead.
+// @Tags purchase-orders
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Purchase order ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchase-orders/{id} [delete]
+func (h *PurchaseOrderHandler) DeletePurchaseOrder(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid purchase order ID",
+		})
+	}
+
+	if err := h.purchaseOrderService.DeletePurchaseOrder(id, middleware.GetCurrentUser(c)); err != nil {
+		return c.Status(purchaseOrderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Purchase order deleted successfully",
+	})
+}
+
+// SendPurchaseOrder godoc
+// @Summary Send a purchase order
+// @Description Mark a draft purchase order as sent to its supplier. It can no longer be changed, and what it has yet to deliver counts as on order in replenishment suggestions.
+// @Tags purchase-orders
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Purchase order ID"
+// @Success 200 {object} models.APIResponse{data=models.PurchaseOrder}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchase-orders/{id}/send [post]
+func (h *PurchaseOrderHandler) SendPurchaseOrder(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid purchase order ID",
+		})
+	}
+
+	order, err := h.purchaseOrderService.SendPurchaseOrder(id, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(purchaseOrderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Purchase order sent successfully",
+		Data:    order,
+	})
+}
+
+// ReceiveGoods godoc
+// @Summary Receive goods against a purchase order
+// @Description Put a delivery against a sent purchase order into stock at its location. Each line is recorded as an "in" movement referencing the purchase order line, and its unit cost is added to the supplier's cost history. Deliveries may be partial; delivering more than is outstanding on a line needs accept_over_delivery.
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Purchase order ID"
+// @Param delivery body models.ReceiveGoodsRequest true "Delivered lines"
+// @Success 200 {object} models.APIResponse{data=models.PurchaseOrder}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchase-orders/{id}/receive [post]
+func (h *PurchaseOrderHandler) ReceiveGoods(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid purchase order ID",
+		})
+	}
+
+	var req models.ReceiveGoodsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	order, err := h.purchaseOrderService.ReceiveGoods(id, &req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(purchaseOrderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Goods received successfully",
+		Data:    order,
+	})
+}
+
+// ClosePurchaseOrder godoc
+// @Summary Close a purchase order
+// @Description Close a sent purchase order, whether or not all of it has been received. Nothing more is expected against it after that.
+// @Tags purchase-orders
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Purchase order ID"
+// @Success 200 {object} models.APIResponse{data=models.PurchaseOrder}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /purchase-orders/{id}/close [post]
+func (h *PurchaseOrderHandler) ClosePurchaseOrder(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid purchase order ID",
+		})
+	}
+
+	order, err := h.purchaseOrderService.ClosePurchaseOrder(id, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(purchaseOrderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Purchase order closed successfully",
+		Data:    order,
+	})
+}
+
+// purchaseOrderErrorStatus maps purchase order errors to HTTP status codes
+func purchaseOrderErrorStatus(err error) int {
+	switch {
+	case err.Error() == errPurchaseOrderNotFound:
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrPurchaseOrderStatus), errors.Is(err, services.ErrOverDelivery):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidPurchaseOrder), errors.Is(err, services.ErrInactiveSupplier),
+		errors.Is(err, services.ErrUnknownLocation), errors.Is(err, services.ErrInactiveLocation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
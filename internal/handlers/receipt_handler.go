@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"jatistore/internal/models"
+	"jatistore/internal/printer"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const errReceiptNotFound = "receipt not found"
+
+// formatJSON asks for the receipt as data instead of a rendering
+const formatJSON = "json"
+
+// receiptContentTypes maps every receipt format to the content type it is
+// served with
+var receiptContentTypes = map[string]string{
+	formatJSON:           fiber.MIMEApplicationJSON,
+	printer.FormatText:   fiber.MIMETextPlainCharsetUTF8,
+	printer.FormatESCPOS: "application/vnd.escpos",
+	printer.FormatHTML:   fiber.MIMETextHTMLCharsetUTF8,
+	printer.FormatPDF:    "application/pdf",
+}
+
+// receiptAccepts maps the media types a client can ask for in its Accept
+// header to receipt formats, in order of preference
+var receiptAccepts = []struct {
+	mediaType string
+	format    string
+}{
+	{fiber.MIMEApplicationJSON, formatJSON},
+	{fiber.MIMETextPlain, printer.FormatText},
+	{"application/vnd.escpos", printer.FormatESCPOS},
+	{fiber.MIMEOctetStream, printer.FormatESCPOS},
+	{fiber.MIMETextHTML, printer.FormatHTML},
+	{"application/pdf", printer.FormatPDF},
+}
+
+type ReceiptHandler struct {
+	receiptService *services.ReceiptService
+}
+
+func NewReceiptHandler(receiptService *services.ReceiptService) *ReceiptHandler {
+	return &ReceiptHandler{
+		receiptService: receiptService,
+	}
+}
+
+// GetReceipt godoc
+// @Summary Get a receipt
+// @Description Get a receipt with its store header, line items, discounts, tax breakdown, tenders, change and cashier. The format is chosen with the format query parameter or the Accept header: JSON (application/json), plain text (text/plain), ESC/POS printer commands (application/vnd.escpos or application/octet-stream), HTML (text/html) or PDF (application/pdf). Getting a receipt never counts as a print; a receipt that has been printed before renders marked COPY.
+// @Tags receipts
+// @Produce json
+// @Produce plain
+// @Produce html
+// @Produce application/pdf
+// @Produce application/vnd.escpos
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Receipt ID"
+// @Param format query string false "Output format" Enums(json, text, escpos, html, pdf)
+// @Param width query int false "Paper width in millimetres, defaults to RECEIPT_WIDTH" Enums(58, 80)
+// @Success 200 {object} models.APIResponse{data=models.Receipt}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 406 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /receipts/{id} [get]
+func (h *ReceiptHandler) GetReceipt(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid receipt ID",
+		})
+	}
+
+	format, width, ferr := receiptFormat(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(models.APIResponse{
+			Success: false,
+			Error:   ferr.Message,
+		})
+	}
+
+	if format == formatJSON {
+		receipt, err := h.receiptService.GetReceipt(id)
+		if err != nil {
+			return c.Status(receiptErrorStatus(err)).JSON(models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+
+		return c.JSON(models.APIResponse{
+			Success: true,
+			Message: "Receipt retrieved successfully",
+			Data:    receipt,
+		})
+	}
+
+	output, receipt, err := h.receiptService.RenderReceipt(id, format, width)
+	if err != nil {
+		return c.Status(receiptErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return sendReceipt(c, format, receipt, output)
+}
+
+// PrintReceipt godoc
+// @Summary Print a receipt
+// @Description Render a receipt or credit note for printing and count the print. The first print is the original; every later one is marked COPY. The format is chosen as for getting a receipt, but must be one of the rendered formats: plain text, ESC/POS, HTML or PDF.
+// @Tags receipts
+// @Produce plain
+// @Produce html
+// @Produce application/pdf
+// @Produce application/vnd.escpos
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Receipt ID"
+// @Param format query string false "Output format" Enums(text, escpos, html, pdf)
+// @Param width query int false "Paper width in millimetres, defaults to RECEIPT_WIDTH" Enums(58, 80)
+// @Success 200 {file} binary
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 406 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /receipts/{id}/print [post]
+func (h *ReceiptHandler) PrintReceipt(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid receipt ID",
+		})
+	}
+
+	format, width, ferr := receiptFormat(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(models.APIResponse{
+			Success: false,
+			Error:   ferr.Message,
+		})
+	}
+	if format == formatJSON {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Receipts are printed as plain text, ESC/POS, HTML or PDF",
+		})
+	}
+
+	output, receipt, err := h.receiptService.PrintReceipt(id, format, width)
+	if err != nil {
+		return c.Status(receiptErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return sendReceipt(c, format, receipt, output)
+}
+
+// receiptFormat reads the receipt format and paper width a request asks
+// for, or the status and message to answer with when it cannot
+func receiptFormat(c *fiber.Ctx) (string, int, *fiber.Error) {
+	format := c.Query("format")
+	if format == "" {
+		format = negotiateReceiptFormat(c)
+		if format == "" {
+			return "", 0, fiber.NewError(http.StatusNotAcceptable, "Receipts are available as JSON, plain text, ESC/POS, HTML or PDF")
+		}
+	}
+	if _, ok := receiptContentTypes[format]; !ok {
+		return "", 0, fiber.NewError(http.StatusBadRequest, fmt.Sprintf("Unsupported receipt format %q", format))
+	}
+
+	width := 0
+	if value := c.Query("width"); value != "" {
+		var err error
+		if width, err = strconv.Atoi(value); err != nil {
+			return "", 0, fiber.NewError(http.StatusBadRequest, "Invalid paper width")
+		}
+	}
+
+	return format, width, nil
+}
+
+// sendReceipt answers with a rendered receipt
+func sendReceipt(c *fiber.Ctx, format string, receipt *models.Receipt, output []byte) error {
+	c.Set(fiber.HeaderContentType, receiptContentTypes[format])
+	if format == printer.FormatPDF {
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", receipt.ReceiptNumber+".pdf"))
+	}
+	return c.Send(output)
+}
+
+// negotiateReceiptFormat picks the receipt format that best matches the
+// request's Accept header, or "" when none does
+func negotiateReceiptFormat(c *fiber.Ctx) string {
+	offers := make([]string, len(receiptAccepts))
+	for i, accept := range receiptAccepts {
+		offers[i] = accept.mediaType
+	}
+
+	chosen := c.Accepts(offers...)
+	for _, accept := range receiptAccepts {
+		if accept.mediaType == chosen {
+			return accept.format
+		}
+	}
+	return ""
+}
+
+// receiptErrorStatus maps receipt errors to HTTP status codes
+func receiptErrorStatus(err error) int {
+	switch {
+	case err.Error() == errReceiptNotFound:
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrUnsupportedReceiptFormat), errors.Is(err, services.ErrUnsupportedPaperWidth):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ReorderHandler struct {
+	reorderService *services.ReorderService
+}
+
+func NewReorderHandler(reorderService *services.ReorderService) *ReorderHandler {
+	return &ReorderHandler{
+		reorderService: reorderService,
+	}
+}
+
+// GetLowStock godoc
+// @Summary List low stock
+// @Description Get the products with a reorder level whose stock at its location is at or below the minimum, by location and product name
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param location query string false "Only this location"
+// @Success 200 {object} models.APIResponse{data=[]models.ReorderStatus}
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/low-stock [get]
+func (h *ReorderHandler) GetLowStock(c *fiber.Ctx) error {
+	statuses, err := h.reorderService.GetLowStock(c.Query("location"))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Low stock retrieved successfully",
+		Data:    statuses,
+	})
+}
+
+// GetReplenishment godoc
+// @Summary Suggest replenishment
+// @Description Suggest how much of each product with a reorder level to bring into its location, from its stock, its reorder level and how fast it has sold recently. Only products that need replenishing are listed.
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param location query string false "Only this location"
+// @Param days query int false "Days of sales to average daily sales over; defaults to REPLENISHMENT_SALES_DAYS"
+// @Param cover_days query int false "Days of sales replenished stock should last; defaults to REPLENISHMENT_COVER_DAYS"
+// @Success 200 {object} models.APIResponse{data=[]models.ReplenishmentSuggestion}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/replenishment [get]
+func (h *ReorderHandler) GetReplenishment(c *fiber.Ctx) error {
+	var salesDays, coverDays int
+	if value := c.Query("days"); value != "" {
+		var err error
+		if salesDays, err = strconv.Atoi(value); err != nil || salesDays < 1 {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid days",
+			})
+		}
+	}
+	if value := c.Query("cover_days"); value != "" {
+		var err error
+		if coverDays, err = strconv.Atoi(value); err != nil || coverDays < 1 {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid cover_days",
+			})
+		}
+	}
+
+	suggestions, err := h.reorderService.GetReplenishment(c.Query("location"), salesDays, coverDays)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Replenishment suggestions retrieved successfully",
+		Data:    suggestions,
+	})
+}
+
+// GetReorderLevels godoc
+// @Summary List reorder levels
+// @Description Get the reorder levels of products at locations
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param product_id query string false "Only this product"
+// @Param location query string false "Only this location"
+// @Success 200 {object} models.APIResponse{data=[]models.ReorderLevel}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/reorder-levels [get]
+func (h *ReorderHandler) GetReorderLevels(c *fiber.Ctx) error {
+	var productID *uuid.UUID
+	if value := c.Query("product_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid product ID",
+			})
+		}
+		productID = &id
+	}
+
+	levels, err := h.reorderService.GetReorderLevels(productID, c.Query("location"))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Reorder levels retrieved successfully",
+		Data:    levels,
+	})
+}
+
+// SetReorderLevel godoc
+// @Summary Set a reorder level
+// @Description Create or replace the minimum and maximum stock of a product at a location, and the lot size it is reordered in. An alert is sent when its stock crosses them.
+// @Tags Inventory
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param product_id path string true "Product ID"
+// @Param location path string true "Location name"
+// @Param level body models.SetReorderLevelRequest true "Reorder level"
+// @Success 200 {object} models.APIResponse{data=models.ReorderLevel}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/reorder-levels/{product_id}/{location} [put]
+func (h *ReorderHandler) SetReorderLevel(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("product_id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid product ID",
+		})
+	}
+
+	var req models.SetReorderLevelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	level, err := h.reorderService.SetReorderLevel(productID, c.Params("location"), &req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(reorderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Reorder level set successfully",
+		Data:    level,
+	})
+}
+
+// DeleteReorderLevel godoc
+// @Summary Delete a reorder level
+// @Description Stop tracking the stock of a product at a location against a reorder level
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param product_id path string true "Product ID"
+// @Param location path string true "Location name"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/reorder-levels/{product_id}/{location} [delete]
+func (h *ReorderHandler) DeleteReorderLevel(c *fiber.Ctx) error {
+	productID, err := uuid.Parse(c.Params("product_id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid product ID",
+		})
+	}
+
+	if err := h.reorderService.DeleteReorderLevel(productID, c.Params("location"), middleware.GetCurrentUser(c)); err != nil {
+		return c.Status(reorderErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Reorder level deleted successfully",
+	})
+}
+
+// reorderErrorStatus maps reorder level errors to HTTP status codes
+func reorderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrReorderLevelNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidReorderLevel), errors.Is(err, services.ErrUnknownLocation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
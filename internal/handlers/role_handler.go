@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const errRoleNotFound = "role not found"
+
+type RoleHandler struct {
+	roleService *services.RoleService
+}
+
+func NewRoleHandler(roleService *services.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+// GetPermissions godoc
+// @Summary List permissions
+// @Description Get every permission that roles can grant
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.Permission}
+// @Failure 403 {object} models.APIResponse
+// @Router /permissions [get]
+func (h *RoleHandler) GetPermissions(c *fiber.Ctx) error {
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Permissions retrieved successfully",
+		Data:    h.roleService.GetPermissions(),
+	})
+}
+
+// GetAllRoles godoc
+// @Summary List roles
+// @Description Get every role with its permissions
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.Role}
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /roles [get]
+func (h *RoleHandler) GetAllRoles(c *fiber.Ctx) error {
+	roles, err := h.roleService.GetRoles()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Roles retrieved successfully",
+		Data:    roles,
+	})
+}
+
+// GetRole godoc
+// @Summary Get a role
+// @Description Get a role with its permissions
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param name path string true "Role name"
+// @Success 200 {object} models.APIResponse{data=models.Role}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /roles/{name} [get]
+func (h *RoleHandler) GetRole(c *fiber.Ctx) error {
+	role, err := h.roleService.GetRole(c.Params("name"))
+	if err != nil {
+		return c.Status(roleErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Role retrieved successfully",
+		Data:    role,
+	})
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Description Create a custom role from a set of permissions, all of which the caller must have. Users and invites can then be given the role.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param role body models.CreateRoleRequest true "Role data"
+// @Success 201 {object} models.APIResponse{data=models.Role}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /roles [post]
+func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
+	var req models.CreateRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Role name is required",
+		})
+	}
+
+	role, err := h.roleService.CreateRole(&req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(roleErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Role created successfully",
+		Data:    role,
+	})
+}
+
+// UpdateRole godoc
+// @Summary Update a role
+// @Description Replace the description and permissions of a role. Users with the role get the new permissions on their next request. The caller must have every permission of the role, before and after. The admin role cannot be changed.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param name path string true "Role name"
+// @Param role body models.UpdateRoleRequest true "Role data"
+// @Success 200 {object} models.APIResponse{data=models.Role}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /roles/{name} [put]
+func (h *RoleHandler) UpdateRole(c *fiber.Ctx) error {
+	var req models.UpdateRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	role, err := h.roleService.UpdateRole(c.Params("name"), &req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(roleErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Role updated successfully",
+		Data:    role,
+	})
+}
+
+// SetTwoFactorRequirement godoc
+// @Summary Require two-factor authentication for a role
+// @Description Require, or stop requiring, two-factor authentication for every user with a role, including admin. Users without it set it up at their next login, and their sessions cannot be refreshed until then.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param name path string true "Role name"
+// @Param requirement body models.SetTwoFactorRequirementRequest true "Whether two-factor authentication is required"
+// @Success 200 {object} models.APIResponse{data=models.Role}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /roles/{name}/two-factor [put]
+func (h *RoleHandler) SetTwoFactorRequirement(c *fiber.Ctx) error {
+	var req models.SetTwoFactorRequirementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	role, err := h.roleService.SetTwoFactorRequirement(c.Params("name"), &req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(roleErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Role updated successfully",
+		Data:    role,
+	})
+}
+
+// DeleteRole godoc
+// @Summary Delete a role
+// @Description Delete a custom role that no user or pending invite has. System roles cannot be deleted.
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param name path string true "Role name"
+// @Success 200 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /roles/{name} [delete]
+func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
+	if err := h.roleService.DeleteRole(c.Params("name"), middleware.GetCurrentUser(c)); err != nil {
+		return c.Status(roleErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Role deleted successfully",
+	})
+}
+
+// roleErrorStatus maps role errors to HTTP status codes
+func roleErrorStatus(err error) int {
+	switch {
+	case err.Error() == errRoleNotFound:
+		return http.StatusNotFound
+	case err.Error() == "role already exists", errors.Is(err, services.ErrRoleInUse):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrSystemRole), errors.Is(err, services.ErrRoleNotGrantable):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidRoleName), errors.Is(err, services.ErrUnknownPermission):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSecurityEventLimit = 100
+	maxSecurityEventLimit     = 1000
+)
+
+type SecurityHandler struct {
+	loginGuard *services.LoginGuard
+}
+
+func NewSecurityHandler(loginGuard *services.LoginGuard) *SecurityHandler {
+	return &SecurityHandler{
+		loginGuard: loginGuard,
+	}
+}
+
+// GetLockouts godoc
+// @Summary List login lockouts
+// @Description Get the usernames and client IPs that are locked out after too many failed logins, and until when (requires user.manage)
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.LoginThrottle}
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/lockouts [get]
+func (h *SecurityHandler) GetLockouts(c *fiber.Ctx) error {
+	lockouts, err := h.loginGuard.GetLockouts()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Lockouts retrieved successfully",
+		Data:    lockouts,
+	})
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user
+// @Description Lift the login lockout of a user and forget their failed logins (requires user.manage)
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/users/{id}/lockout [delete]
+func (h *SecurityHandler) UnlockUser(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(http.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	if err := h.loginGuard.UnlockUser(id, currentUser); err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			status = http.StatusNotFound
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "User unlocked successfully",
+	})
+}
+
+// UnlockIP godoc
+// @Summary Unlock a client IP
+// @Description Lift the login lockout of a client IP and forget its failed logins (requires user.manage)
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param ip path string true "Client IP"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/lockouts/ip/{ip} [delete]
+func (h *SecurityHandler) UnlockIP(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(http.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	if err := h.loginGuard.UnlockIP(c.Params("ip"), currentUser); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "IP unlocked successfully",
+	})
+}
+
+// GetSecurityEvents godoc
+// @Summary List security events
+// @Description Get the latest security events, such as lockouts after failed logins and their unlocking, newest first (requires user.manage)
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param user_id query string false "Only events of this user"
+// @Param limit query int false "Maximum number of events (default 100, at most 1000)"
+// @Success 200 {object} models.APIResponse{data=[]models.SecurityEvent}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/security-events [get]
+func (h *SecurityHandler) GetSecurityEvents(c *fiber.Ctx) error {
+	var userID *uuid.UUID
+	if value := c.Query("user_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid user ID",
+			})
+		}
+		userID = &id
+	}
+
+	limit := defaultSecurityEventLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxSecurityEventLimit {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid limit",
+			})
+		}
+	}
+
+	events, err := h.loginGuard.GetSecurityEvents(userID, limit)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Security events retrieved successfully",
+		Data:    events,
+	})
+}
+
+// loginErrorStatus maps login errors to HTTP status codes. A throttled login
+// also gets a Retry-After header.
+func loginErrorStatus(c *fiber.Ctx, err error) int {
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		return fiber.StatusTooManyRequests
+	}
+	return fiber.StatusUnauthorized
+}
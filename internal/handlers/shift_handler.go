@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type ShiftHandler struct {
+	shiftService *services.ShiftService
+}
+
+func NewShiftHandler(shiftService *services.ShiftService) *ShiftHandler {
+	return &ShiftHandler{
+		shiftService: shiftService,
+	}
+}
+
+// OpenShift godoc
+// @Summary Open a shift
+// @Description Open a cash drawer shift for the current user with the starting float. Payments can only be taken on an open shift.
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param shift body models.OpenShiftRequest true "Shift data"
+// @Success 201 {object} models.APIResponse{data=models.Shift}
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /shifts/open [post]
+func (h *ShiftHandler) OpenShift(c *fiber.Ctx) error {
+	var req models.OpenShiftRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.OpeningFloat < 0 {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Opening float cannot be negative",
+		})
+	}
+
+	shift, err := h.shiftService.OpenShift(middleware.GetCurrentUser(c), &req)
+	if err != nil {
+		return c.Status(shiftErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift opened successfully",
+		Data:    shift,
+	})
+}
+
+// CloseShift godoc
+// @Summary Close the current shift
+// @Description Close the current user's shift with the cash counted in the drawer and get its Z report. Other tenders can be counted in counted_tenders, keyed by payment method (cash, card, transfer, digital_wallet).
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param count body models.CloseShiftRequest true "Counted tenders"
+// @Success 200 {object} models.APIResponse{data=models.ShiftReport}
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /shifts/close [post]
+func (h *ShiftHandler) CloseShift(c *fiber.Ctx) error {
+	var req models.CloseShiftRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.CountedCash < 0 {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Counted cash cannot be negative",
+		})
+	}
+	for _, counted := range req.CountedTenders {
+		if counted < 0 {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Counted amounts cannot be negative",
+			})
+		}
+	}
+
+	report, err := h.shiftService.CloseShift(middleware.GetCurrentUser(c), &req)
+	if err != nil {
+		return c.Status(shiftErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Shift closed successfully",
+		Data:    report,
+	})
+}
+
+// RecordCashMovement godoc
+// @Summary Record a cash movement
+// @Description Record cash put into (cash_in) or taken out of (cash_out) the current user's drawer, such as petty cash or a safe drop
+// @Tags shifts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param movement body models.CreateCashMovementRequest true "Cash movement data"
+// @Success 201 {object} models.APIResponse{data=models.CashMovement}
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /shifts/cash-movements [post]
+func (h *ShiftHandler) RecordCashMovement(c *fiber.Ctx) error {
+	var req models.CreateCashMovementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Type != services.CashMovementIn && req.Type != services.CashMovementOut {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Type must be cash_in or cash_out",
+		})
+	}
+
+	if req.Amount <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Amount must be greater than 0",
+		})
+	}
+
+	if req.Reason == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Reason is required",
+		})
+	}
+
+	movement, err := h.shiftService.RecordCashMovement(middleware.GetCurrentUser(c), &req)
+	if err != nil {
+		return c.Status(shiftErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Cash movement recorded successfully",
+		Data:    movement,
+	})
+}
+
+// GetCurrentShift godoc
+// @Summary Get the current shift
+// @Description Get the current user's open shift
+// @Tags shifts
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=models.Shift}
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /shifts/current [get]
+func (h *ShiftHandler) GetCurrentShift(c *fiber.Ctx) error {
+	shift, err := h.shiftService.GetCurrentShift(middleware.GetCurrentUser(c))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrNoOpenShift) {
+			status = http.StatusNotFound
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    shift,
+	})
+}
+
+// GetAllShifts godoc
+// @Summary Get all shifts
+// @Description Get every shift, newest first
+// @Tags shifts
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.Shift}
+// @Failure 500 {object} models.APIResponse
+// @Router /shifts [get]
+func (h *ShiftHandler) GetAllShifts(c *fiber.Ctx) error {
+	shifts, err := h.shiftService.GetAllShifts()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    shifts,
+	})
+}
+
+// GetShift godoc
+// @Summary Get a shift
+// @Description Get a shift by ID. Cashiers can only see their own shifts.
+// @Tags shifts
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Shift ID"
+// @Success 200 {object} models.APIResponse{data=models.Shift}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /shifts/{id} [get]
+func (h *ShiftHandler) GetShift(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid shift ID",
+		})
+	}
+
+	shift, err := h.shiftService.GetShift(id, middleware.GetCurrentUser(c))
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, services.ErrShiftAccessDenied) {
+			status = http.StatusForbidden
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    shift,
+	})
+}
+
+// GetShiftReport godoc
+// @Summary Get a shift report
+// @Description Get the X report of an open shift or the Z report of a closed one: takings per tender, expected and counted cash, refunds, voids, discounts and the variance
+// @Tags shifts
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Shift ID"
+// @Success 200 {object} models.APIResponse{data=models.ShiftReport}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /shifts/{id}/report [get]
+func (h *ShiftHandler) GetShiftReport(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid shift ID",
+		})
+	}
+
+	report, err := h.shiftService.GetShiftReport(id, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(shiftErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    report,
+	})
+}
+
+func shiftErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNoOpenShift), errors.Is(err, services.ErrShiftAlreadyOpen):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrShiftAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrUnknownPaymentMethod):
+		return http.StatusBadRequest
+	case err.Error() == "failed to get shift: shift not found":
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
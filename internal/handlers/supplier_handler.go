@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const errSupplierNotFound = "supplier not found"
+
+type SupplierHandler struct {
+	supplierService *services.SupplierService
+}
+
+func NewSupplierHandler(supplierService *services.SupplierService) *SupplierHandler {
+	return &SupplierHandler{
+		supplierService: supplierService,
+	}
+}
+
+// GetAllSuppliers godoc
+// @Summary List suppliers
+// @Description Get every supplier, active ones first
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.Supplier}
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /suppliers [get]
+func (h *SupplierHandler) GetAllSuppliers(c *fiber.Ctx) error {
+	suppliers, err := h.supplierService.GetSuppliers()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Suppliers retrieved successfully",
+		Data:    suppliers,
+	})
+}
+
+// GetSupplier godoc
+// @Summary Get a supplier
+// @Description Get a supplier by ID
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Supplier ID"
+// @Success 200 {object} models.APIResponse{data=models.Supplier}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /suppliers/{id} [get]
+func (h *SupplierHandler) GetSupplier(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid supplier ID",
+		})
+	}
+
+	supplier, err := h.supplierService.GetSupplier(id)
+	if err != nil {
+		return c.Status(supplierErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Supplier retrieved successfully",
+		Data:    supplier,
+	})
+}
+
+// CreateSupplier godoc
+// @Summary Create a supplier
+// @Description Create a supplier that purchase orders can be raised with
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param supplier body models.CreateSupplierRequest true "Supplier data"
+// @Success 201 {object} models.APIResponse{data=models.Supplier}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /suppliers [post]
+func (h *SupplierHandler) CreateSupplier(c *fiber.Ctx) error {
+	var req models.CreateSupplierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	supplier, err := h.supplierService.CreateSupplier(&req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(supplierErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Supplier created successfully",
+		Data:    supplier,
+	})
+}
+
+// UpdateSupplier godoc
+// @Summary Update a supplier
+// @Description Replace the details of a supplier and whether it is active. No new purchase orders can be raised with an inactive supplier, but its open ones can still be received and closed.
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Supplier ID"
+// @Param supplier body models.UpdateSupplierRequest true "Supplier data"
+// @Success 200 {object} models.APIResponse{data=models.Supplier}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /suppliers/{id} [put]
+func (h *SupplierHandler) UpdateSupplier(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid supplier ID",
+		})
+	}
+
+	var req models.UpdateSupplierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	supplier, err := h.supplierService.UpdateSupplier(id, &req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(supplierErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Supplier updated successfully",
+		Data:    supplier,
+	})
+}
+
+// DeleteSupplier godoc
+// @Summary Delete a supplier
+// @Description Delete a supplier that has no purchase orders. Suppliers with purchase orders can be deactivated instead.
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Supplier ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /suppliers/{id} [delete]
+func (h *SupplierHandler) DeleteSupplier(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid supplier ID",
+		})
+	}
+
+	if err := h.supplierService.DeleteSupplier(id, middleware.GetCurrentUser(c)); err != nil {
+		return c.Status(supplierErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Supplier deleted successfully",
+	})
+}
+
+// GetSupplierCosts godoc
+// @Summary Get a supplier's cost history
+// @Description Get what a supplier charged per unit for the goods received from it, newest first
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Supplier ID"
+// @Param product_id query string false "Only this product"
+// @Success 200 {object} models.APIResponse{data=[]models.SupplierCost}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /suppliers/{id}/costs [get]
+func (h *SupplierHandler) GetSupplierCosts(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid supplier ID",
+		})
+	}
+
+	var productID *uuid.UUID
+	if value := c.Query("product_id"); value != "" {
+		parsed, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Invalid product ID",
+			})
+		}
+		productID = &parsed
+	}
+
+	costs, err := h.supplierService.GetSupplierCosts(id, productID)
+	if err != nil {
+		return c.Status(supplierErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Supplier costs retrieved successfully",
+		Data:    costs,
+	})
+}
+
+// supplierErrorStatus maps supplier errors to HTTP status codes
+func supplierErrorStatus(err error) int {
+	switch {
+	case err.Error() == errSupplierNotFound:
+		return http.StatusNotFound
+	case err.Error() == "supplier already exists", errors.Is(err, services.ErrSupplierInUse):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidSupplierName):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type TaxHandler struct {
+	taxService *services.TaxService
+}
+
+func NewTaxHandler(taxService *services.TaxService) *TaxHandler {
+	return &TaxHandler{
+		taxService: taxService,
+	}
+}
+
+// CreateTaxClass godoc
+// @Summary Create a tax class
+// @Description Create a tax class that products and categories can be assigned to
+// @Tags tax
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param taxClass body models.CreateTaxClassRequest true "Tax class data"
+// @Success 201 {object} models.APIResponse{data=models.TaxClass}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /tax-classes [post]
+func (h *TaxHandler) CreateTaxClass(c *fiber.Ctx) error {
+	var req models.CreateTaxClassRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Tax class name is required",
+		})
+	}
+
+	class, err := h.taxService.CreateTaxClass(&req)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Tax class created successfully",
+		Data:    class,
+	})
+}
+
+// GetAllTaxClasses godoc
+// @Summary Get all tax classes
+// @Description Get a list of all tax classes
+// @Tags tax
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.APIResponse{data=[]models.TaxClass}
+// @Failure 500 {object} models.APIResponse
+// @Router /tax-classes [get]
+func (h *TaxHandler) GetAllTaxClasses(c *fiber.Ctx) error {
+	classes, err := h.taxService.GetAllTaxClasses()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    classes,
+	})
+}
+
+// GetTaxClass godoc
+// @Summary Get a tax class
+// @Description Get a tax class with all of its rates, newest first
+// @Tags tax
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Tax class ID"
+// @Success 200 {object} models.APIResponse{data=models.TaxClass}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /tax-classes/{id} [get]
+func (h *TaxHandler) GetTaxClass(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid tax class ID",
+		})
+	}
+
+	class, err := h.taxService.GetTaxClass(id)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    class,
+	})
+}
+
+// UpdateTaxClass godoc
+// @Summary Update a tax class
+// @Description Update the name and description of a tax class
+// @Tags tax
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Tax class ID"
+// @Param taxClass body models.UpdateTaxClassRequest true "Tax class data"
+// @Success 200 {object} models.APIResponse{data=models.TaxClass}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /tax-classes/{id} [put]
+func (h *TaxHandler) UpdateTaxClass(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid tax class ID",
+		})
+	}
+
+	var req models.UpdateTaxClassRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Tax class name is required",
+		})
+	}
+
+	class, err := h.taxService.UpdateTaxClass(id, &req)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Tax class updated successfully",
+		Data:    class,
+	})
+}
+
+// DeleteTaxClass godoc
+// @Summary Delete a tax class
+// @Description Delete a tax class. Products and categories in the class become untaxed.
+// @Tags tax
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Tax class ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /tax-classes/{id} [delete]
+func (h *TaxHandler) DeleteTaxClass(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid tax class ID",
+		})
+	}
+
+	if err := h.taxService.DeleteTaxClass(id); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Tax class deleted successfully",
+	})
+}
+
+// AddTaxRate godoc
+// @Summary Add a tax rate
+// @Description Schedule a new rate for a tax class. It replaces the current rate from effective_from (default now); orders placed earlier keep their rate.
+// @Tags tax
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Tax class ID"
+// @Param rate body models.CreateTaxRateRequest true "Tax rate data"
+// @Success 201 {object} models.APIResponse{data=models.TaxRate}
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /tax-classes/{id}/rates [post]
+func (h *TaxHandler) AddTaxRate(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid tax class ID",
+		})
+	}
+
+	var req models.CreateTaxRateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Rate < 0 {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Tax rate cannot be negative",
+		})
+	}
+
+	rate, err := h.taxService.AddTaxRate(id, &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrTaxRateOverlap) {
+			status = http.StatusConflict
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Tax rate added successfully",
+		Data:    rate,
+	})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const errTransferNotFound = "transfer not found"
+
+// CreateTransfer godoc
+// @Summary Transfer stock between locations
+// @Description Ship stock from one location to another. The stock leaves the source location at once and is in transit until the transfer is received. Set receive to complete the transfer straight away.
+// @Tags Inventory
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param transfer body models.CreateTransferRequest true "Transfer data"
+// @Success 201 {object} models.APIResponse{data=models.StockTransfer}
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/transfers [post]
+func (h *InventoryHandler) CreateTransfer(c *fiber.Ctx) error {
+	var req models.CreateTransferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.FromLocation == "" || req.ToLocation == "" {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Source and destination locations are required",
+		})
+	}
+
+	transfer, err := h.inventoryService.CreateTransfer(&req, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(models.APIResponse{
+		Success: true,
+		Message: "Transfer created successfully",
+		Data:    transfer,
+	})
+}
+
+// GetAllTransfers godoc
+// @Summary List transfers
+// @Description Get stock transfers, newest first
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param status query string false "Only transfers with this status (shipped, received, cancelled)"
+// @Success 200 {object} models.APIResponse{data=[]models.StockTransfer}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/transfers [get]
+func (h *InventoryHandler) GetAllTransfers(c *fiber.Ctx) error {
+	transfers, err := h.inventoryService.GetTransfers(c.Query("status"))
+	if err != nil {
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Transfers retrieved successfully",
+		Data:    transfers,
+	})
+}
+
+// GetTransfer godoc
+// @Summary Get a transfer
+// @Description Get a stock transfer with its items
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.APIResponse{data=models.StockTransfer}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/transfers/{id} [get]
+func (h *InventoryHandler) GetTransfer(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid transfer ID",
+		})
+	}
+
+	transfer, err := h.inventoryService.GetTransfer(id)
+	if err != nil {
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Transfer retrieved successfully",
+		Data:    transfer,
+	})
+}
+
+// ReceiveTransfer godoc
+// @Summary Receive a transfer
+// @Description Put the stock of a transfer in transit into its destination location
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.APIResponse{data=models.StockTransfer}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/transfers/{id}/receive [post]
+func (h *InventoryHandler) ReceiveTransfer(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid transfer ID",
+		})
+	}
+
+	transfer, err := h.inventoryService.ReceiveTransfer(id, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Transfer received successfully",
+		Data:    transfer,
+	})
+}
+
+// CancelTransfer godoc
+// @Summary Cancel a transfer
+// @Description Put the stock of a transfer in transit back into its source location
+// @Tags Inventory
+// @Produce json
+// @Security BearerAuth
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.APIResponse{data=models.StockTransfer}
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /inventory/transfers/{id}/cancel [post]
+func (h *InventoryHandler) CancelTransfer(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid transfer ID",
+		})
+	}
+
+	transfer, err := h.inventoryService.CancelTransfer(id, middleware.GetCurrentUser(c))
+	if err != nil {
+		return c.Status(inventoryErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Transfer cancelled successfully",
+		Data:    transfer,
+	})
+}
+
+// inventoryErrorStatus maps stock movement and transfer errors to HTTP
+// status codes
+func inventoryErrorStatus(err error) int {
+	switch {
+	case err.Error() == errTransferNotFound, err.Error() == "inventory not found":
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInsufficientStock), errors.Is(err, services.ErrTransferNotInTransit),
+		errors.Is(err, services.ErrInventoryExists), errors.Is(err, services.ErrInventoryNotEmpty):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidTransfer), errors.Is(err, services.ErrInvalidAdjustment), errors.Is(err, services.ErrLocationRequired),
+		errors.Is(err, services.ErrUnknownLocation), errors.Is(err, services.ErrInactiveLocation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"errors"
+
+	"jatistore/internal/middleware"
+	"jatistore/internal/models"
+	"jatistore/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// LoginTwoFactor completes a login with its second factor
+// @Summary Complete two-factor login
+// @Description Complete a login that returned a two_factor_token with a code from the authenticator app, or an unused recovery code. A login that set up two-factor authentication returns the new recovery codes once. After 5 wrong codes the login has to start over.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param login body models.TwoFactorLoginRequest true "Two-factor login token and code"
+// @Success 200 {object} models.APIResponse{data=models.LoginResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
+// @Router /auth/login/2fa [post]
+func (h *AuthHandler) LoginTwoFactor(c *fiber.Ctx) error {
+	var req models.TwoFactorLoginRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.TwoFactorToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Two-factor token is required",
+		})
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Code or recovery code is required",
+		})
+	}
+
+	response, err := h.userService.CompleteLogin(&req, c.IP())
+	if err != nil {
+		status := twoFactorErrorStatus(err)
+		if errors.Is(err, services.ErrTooManyLoginAttempts) {
+			status = loginErrorStatus(c, err)
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Login successful",
+		Data:    response,
+	})
+}
+
+// SetupLoginTwoFactor sets up two-factor authentication during login
+// @Summary Set up two-factor authentication at login
+// @Description For a login that returned setup_required, because the user's role requires two-factor authentication: get a TOTP secret and its otpauth:// provisioning URI to show as a QR code, then complete the login with POST /auth/login/2fa and the first code from the authenticator app.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param setup body models.TwoFactorSetupRequest true "Two-factor login token"
+// @Success 200 {object} models.APIResponse{data=models.TwoFactorEnrollment}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/login/2fa/setup [post]
+func (h *AuthHandler) SetupLoginTwoFactor(c *fiber.Ctx) error {
+	var req models.TwoFactorSetupRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.TwoFactorToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Two-factor token is required",
+		})
+	}
+
+	enrollment, err := h.userService.SetupLoginTwoFactor(&req)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Scan the provisioning URI with an authenticator app",
+		Data:    enrollment,
+	})
+}
+
+// EnrollTwoFactor starts setting up two-factor authentication
+// @Summary Set up two-factor authentication
+// @Description Get a new TOTP secret and its otpauth:// provisioning URI to show as a QR code. Two-factor authentication is turned on by POST /auth/2fa/confirm with the first code from the authenticator app.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param enroll body models.EnrollTwoFactorRequest true "Current password"
+// @Success 200 {object} models.APIResponse{data=models.TwoFactorEnrollment}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/2fa/enroll [post]
+func (h *AuthHandler) EnrollTwoFactor(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	var req models.EnrollTwoFactorRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.CurrentPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Current password is required",
+		})
+	}
+
+	enrollment, err := h.userService.EnrollTwoFactor(currentUser.ID, &req)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Scan the provisioning URI with an authenticator app",
+		Data:    enrollment,
+	})
+}
+
+// ConfirmTwoFactor turns on two-factor authentication
+// @Summary Confirm two-factor authentication
+// @Description Turn on two-factor authentication with the first code from the authenticator app. Returns the recovery codes, which are only shown once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body models.TwoFactorCodeRequest true "Authenticator code"
+// @Success 200 {object} models.APIResponse{data=models.RecoveryCodesResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/2fa/confirm [post]
+func (h *AuthHandler) ConfirmTwoFactor(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	var req models.TwoFactorCodeRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Code is required",
+		})
+	}
+
+	codes, err := h.userService.ConfirmTwoFactor(currentUser.ID, &req)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Two-factor authentication enabled",
+		Data:    models.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// DisableTwoFactor turns off two-factor authentication
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication with the current password and an authenticator or recovery code. Not allowed when the user's role requires two-factor authentication.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param disable body models.DisableTwoFactorRequest true "Current password and code"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	var req models.DisableTwoFactorRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.CurrentPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Current password is required",
+		})
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Code or recovery code is required",
+		})
+	}
+
+	if err := h.userService.DisableTwoFactor(currentUser.ID, &req); err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes
+// @Summary Regenerate recovery codes
+// @Description Replace every recovery code of the current user, given a code from the authenticator app. The new codes are only shown once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body models.TwoFactorCodeRequest true "Authenticator code"
+// @Success 200 {object} models.APIResponse{data=models.RecoveryCodesResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /auth/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Authentication required",
+		})
+	}
+
+	var req models.TwoFactorCodeRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+
+	if req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Code is required",
+		})
+	}
+
+	codes, err := h.userService.RegenerateRecoveryCodes(currentUser.ID, &req)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Recovery codes regenerated",
+		Data:    models.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// ResetUserTwoFactor turns off two-factor authentication for a user
+// @Summary Reset a user's two-factor authentication
+// @Description Turn off two-factor authentication for a user who lost their authenticator and recovery codes, and log them out everywhere. If their role requires it, they set it up again at their next login. The caller must have every permission of the user's role. (requires user.manage)
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/users/{id}/2fa [delete]
+func (h *AuthHandler) ResetUserTwoFactor(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIResponse{
+			Success: false,
+			Error:   "Invalid user ID",
+		})
+	}
+
+	if err := h.userService.ResetTwoFactor(id, middleware.GetCurrentUser(c)); err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Two-factor authentication reset successfully",
+	})
+}
+
+// twoFactorErrorStatus maps two-factor authentication errors to HTTP status
+// codes
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode), errors.Is(err, services.ErrInvalidLoginChallenge):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, services.ErrTwoFactorRequired), errors.Is(err, services.ErrRoleNotGrantable):
+		return fiber.StatusForbidden
+	case errors.Is(err, services.ErrTwoFactorEnabled):
+		return fiber.StatusConflict
+	case errors.Is(err, services.ErrTwoFactorNotEnrolled), err.Error() == "current password is incorrect":
+		return fiber.StatusBadRequest
+	case err.Error() == "user not found":
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,120 @@
+// Package mailer sends the emails of the application, such as password
+// resets. The SMTP mailer delivers them; the log mailer only writes them out,
+// for development and for servers without a mail server.
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPConfig is how to reach the SMTP server
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	// From is the sender address of every email
+	From string
+}
+
+// SMTP sends emails through an SMTP server. The connection is upgraded with
+// STARTTLS when the server offers it, which is required for authentication
+// unless the server is on localhost.
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("sender address is required")
+	}
+	return &SMTP{cfg: cfg}, nil
+}
+
+// Send delivers msg to its recipient
+func (m *SMTP) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, m.cfg.Port)
+	if err := smtp.SendMail(addr, auth, m.cfg.From, []string{msg.To}, format(m.cfg.From, msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// Log writes emails to a writer instead of sending them
+type Log struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// NewLog returns a mailer that writes emails to the server log
+func NewLog(from string) *Log {
+	return &Log{w: log.Writer(), from: from}
+}
+
+// NewFile returns a mailer that appends emails to the file at path
+func NewFile(path, from string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mail file: %w", err)
+	}
+	return &Log{w: f, from: from}, nil
+}
+
+// Send writes msg out in full, separated from the previous email by a line
+func (m *Log) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := fmt.Fprintf(m.w, "----- email %s -----\r\n%s\r\n", time.Now().Format(time.RFC3339), format(m.from, msg)); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
+
+// format returns msg as an RFC 5322 message
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + headerValue(msg.To) + "\r\n")
+	b.WriteString("Subject: " + headerValue(msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue strips line breaks, so that a value cannot add headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
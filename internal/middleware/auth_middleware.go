@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"jatistore/internal/models"
@@ -10,19 +11,28 @@ import (
 	"github.com/google/uuid"
 )
 
-// AuthMiddleware handles JWT authentication
+// APIKeyHeader is the header other systems send their API key in
+const APIKeyHeader = "X-API-Key"
+
+// AuthMiddleware handles JWT and API key authentication
 type AuthMiddleware struct {
-	userService *services.UserService
+	userService   *services.UserService
+	apiKeyService *services.APIKeyService
 }
 
 // NewAuthMiddleware creates a new AuthMiddleware instance
-func NewAuthMiddleware(userService *services.UserService) *AuthMiddleware {
-	return &AuthMiddleware{userService: userService}
+func NewAuthMiddleware(userService *services.UserService, apiKeyService *services.APIKeyService) *AuthMiddleware {
+	return &AuthMiddleware{userService: userService, apiKeyService: apiKeyService}
 }
 
-// Authenticate validates JWT token and sets user context
+// Authenticate validates JWT token and sets user context. Requests with an
+// API key in the X-API-Key header are authenticated with the key instead.
 func (m *AuthMiddleware) Authenticate() fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if apiKey := c.Get(APIKeyHeader); apiKey != "" {
+			return m.authenticateAPIKey(c, apiKey)
+		}
+
 		// Get Authorization header
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -52,6 +62,22 @@ func (m *AuthMiddleware) Authenticate() fiber.Handler {
 			})
 		}
 
+		// Reject tokens revoked by logging out or by reuse of their session's
+		// refresh token
+		revoked, err := m.userService.IsTokenRevoked(claims)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Failed to check token",
+			})
+		}
+		if revoked {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Token has been revoked",
+			})
+		}
+
 		// Get user from database to ensure user still exists and is active
 		user, err := m.userService.GetUserByID(claims.UserID)
 		if err != nil {
@@ -68,6 +94,15 @@ func (m *AuthMiddleware) Authenticate() fiber.Handler {
 			})
 		}
 
+		// Load what the user's role allows
+		user.Permissions, err = m.userService.GetPermissions(user.Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIResponse{
+				Success: false,
+				Error:   "Failed to load permissions",
+			})
+		}
+
 		// Set user in context
 		c.Locals("user", user)
 		c.Locals("claims", claims)
@@ -76,11 +111,34 @@ func (m *AuthMiddleware) Authenticate() fiber.Handler {
 	}
 }
 
-// RequireRole creates middleware that requires specific role(s)
-func (m *AuthMiddleware) RequireRole(roles ...string) fiber.Handler {
+// authenticateAPIKey sets the user context for a request made with an API
+// key: a user without an ID that has the key's permissions
+func (m *AuthMiddleware) authenticateAPIKey(c *fiber.Ctx, apiKey string) error {
+	user, err := m.apiKeyService.Authenticate(apiKey, c.IP())
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidAPIKey):
+			status = fiber.StatusUnauthorized
+		case errors.Is(err, services.ErrAPIKeyIPNotAllowed):
+			status = fiber.StatusForbidden
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	c.Locals("user", user)
+
+	return c.Next()
+}
+
+// RequireUser creates middleware that turns away requests made with an API
+// key, for routes about the user's own account and for managing API keys
+func (m *AuthMiddleware) RequireUser() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get user from context (set by Authenticate middleware)
-		user := c.Locals("user").(*models.User)
+		user := GetCurrentUser(c)
 		if user == nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
 				Success: false,
@@ -88,22 +146,39 @@ func (m *AuthMiddleware) RequireRole(roles ...string) fiber.Handler {
 			})
 		}
 
-		// Check if user has required role
-		hasRole := false
-		for _, role := range roles {
-			if user.Role == role {
-				hasRole = true
-				break
-			}
+		if user.APIKeyID != nil {
+			return c.Status(fiber.StatusForbidden).JSON(models.APIResponse{
+				Success: false,
+				Error:   "API keys cannot be used here",
+			})
 		}
 
-		if !hasRole {
-			return c.Status(fiber.StatusForbidden).JSON(models.APIResponse{
+		return c.Next()
+	}
+}
+
+// RequirePermission creates middleware that requires every one of the given
+// permissions
+func (m *AuthMiddleware) RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Get user from context (set by Authenticate middleware)
+		user := GetCurrentUser(c)
+		if user == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.APIResponse{
 				Success: false,
-				Error:   "Insufficient permissions",
+				Error:   "Authentication required",
 			})
 		}
 
+		for _, permission := range permissions {
+			if !user.HasPermission(permission) {
+				return c.Status(fiber.StatusForbidden).JSON(models.APIResponse{
+					Success: false,
+					Error:   "Insufficient permissions: requires " + permission,
+				})
+			}
+		}
+
 		return c.Next()
 	}
 }
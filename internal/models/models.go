@@ -1,33 +1,61 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
+	"jatistore/internal/money"
+
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // Product represents a product in the inventory system
 type Product struct {
-	ID            uuid.UUID `json:"id" db:"id"`
-	Name          string    `json:"name" db:"name"`
-	Description   string    `json:"description" db:"description"`
-	SKU           string    `json:"sku" db:"sku"`
-	BarcodeNumber *string   `json:"barcode_number" db:"barcode_number"`
-	CategoryID    uuid.UUID `json:"category_id" db:"category_id"`
-	Price         float64   `json:"price" db:"price"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
-	Category      *Category `json:"category,omitempty"`
+	ID            uuid.UUID    `json:"id" db:"id"`
+	Name          string       `json:"name" db:"name"`
+	Description   string       `json:"description" db:"description"`
+	SKU           string       `json:"sku" db:"sku"`
+	BarcodeNumber *string      `json:"barcode_number" db:"barcode_number"`
+	CategoryID    uuid.UUID    `json:"category_id" db:"category_id"`
+	Price         money.Amount `json:"price" db:"price"`
+	TaxClassID    *uuid.UUID   `json:"tax_class_id,omitempty" db:"tax_class_id"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at" db:"updated_at"`
+	Category      *Category    `json:"category,omitempty"`
 }
 
 // Category represents a product category
 type Category struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Description string     `json:"description" db:"description"`
+	TaxClassID  *uuid.UUID `json:"tax_class_id,omitempty" db:"tax_class_id"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// TaxClass groups products that are taxed the same way, e.g. standard PPN or
+// exempt basic goods. Products without a class of their own use their
+// category's class; products with neither are not taxed.
+type TaxClass struct {
 	ID          uuid.UUID `json:"id" db:"id"`
 	Name        string    `json:"name" db:"name"`
 	Description string    `json:"description" db:"description"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	Rates       []TaxRate `json:"rates,omitempty"`
+}
+
+// TaxRate is the percentage a tax class is charged at during a period. A
+// rate without EffectiveTo stays in effect until a newer rate replaces it.
+type TaxRate struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	TaxClassID    uuid.UUID  `json:"tax_class_id" db:"tax_class_id"`
+	Rate          money.Rate `json:"rate" db:"rate"`
+	EffectiveFrom time.Time  `json:"effective_from" db:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to,omitempty" db:"effective_to"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
 }
 
 // Inventory represents inventory stock for a product
@@ -45,12 +73,195 @@ type Inventory struct {
 type InventoryTransaction struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	ProductID string    `json:"product_id" db:"product_id"` // changed from uuid.UUID to string
-	Type      string    `json:"type" db:"type"`             // "in", "out", "adjustment"
+	Type      string    `json:"type" db:"type"`             // "initial", "in", "out", "adjustment"
 	Quantity  int       `json:"quantity" db:"quantity"`
 	Reason    string    `json:"reason" db:"reason"`
 	Reference string    `json:"reference" db:"reference"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	Product   *Product  `json:"product,omitempty"`
+	Location  string    `json:"location,omitempty" db:"location"`
+	// PurchaseOrderLineID is the purchase order line goods were received
+	// against
+	PurchaseOrderLineID *uuid.UUID `json:"purchase_order_line_id,omitempty" db:"purchase_order_line_id"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	Product             *Product   `json:"product,omitempty"`
+}
+
+// StockLevel is the stock of a product at a location worked out from the
+// stock ledger
+type StockLevel struct {
+	ProductID string `json:"product_id"`
+	Location  string `json:"location"`
+	Quantity  int    `json:"quantity"`
+}
+
+// StockMismatch is a product at a location whose inventory quantity differs
+// from what its stock ledger adds up to
+type StockMismatch struct {
+	ProductID      string `json:"product_id"`
+	Location       string `json:"location"`
+	Quantity       int    `json:"quantity"`
+	LedgerQuantity int    `json:"ledger_quantity"`
+	Difference     int    `json:"difference"` // quantity - ledger_quantity
+}
+
+// ReconciliationReport is the outcome of checking every inventory quantity
+// against the stock ledger. When Rebuilt is set the mismatched quantities
+// have been replaced by the ledger's.
+type ReconciliationReport struct {
+	CheckedAt  time.Time       `json:"checked_at"`
+	Checked    int             `json:"checked"`
+	Mismatches []StockMismatch `json:"mismatches"`
+	Rebuilt    bool            `json:"rebuilt"`
+}
+
+// Location is a place where stock is kept, such as the shop floor, the back
+// room or a warehouse. Inactive locations keep their stock and history but
+// take no new stock movements.
+type Location struct {
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	IsActive    bool      `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ReorderLevel is when a product is low at a location and how much to
+// reorder. Stock at or below MinQuantity is low, and replenishing brings it
+// back up to MaxQuantity, in multiples of ReorderQuantity when it is set.
+type ReorderLevel struct {
+	ProductID       uuid.UUID `json:"product_id" db:"product_id"`
+	Location        string    `json:"location" db:"location"`
+	MinQuantity     int       `json:"min_quantity" db:"min_quantity"`
+	MaxQuantity     int       `json:"max_quantity" db:"max_quantity"`
+	ReorderQuantity int       `json:"reorder_quantity" db:"reorder_quantity"`
+	// AlertState is the state of the stock last notified: "ok", "low",
+	// "out" or "over"
+	AlertState string     `json:"alert_state" db:"alert_state"`
+	AlertedAt  *time.Time `json:"alerted_at,omitempty" db:"alerted_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ReorderStatus is the stock of a product at a location next to its reorder
+// level. State is what the stock is now: "out" at or below zero, "low" at or
+// below the minimum, "over" above the maximum and "ok" otherwise.
+type ReorderStatus struct {
+	ProductID       uuid.UUID `json:"product_id"`
+	ProductName     string    `json:"product_name"`
+	SKU             string    `json:"sku"`
+	Location        string    `json:"location"`
+	Quantity        int       `json:"quantity"`
+	MinQuantity     int       `json:"min_quantity"`
+	MaxQuantity     int       `json:"max_quantity"`
+	ReorderQuantity int       `json:"reorder_quantity"`
+	State           string    `json:"state"`
+	// AlertState is the state last notified
+	AlertState string `json:"alert_state"`
+	// OnOrder is how many units sent purchase orders for the location have
+	// yet to deliver
+	OnOrder int `json:"on_order"`
+}
+
+// ReplenishmentSuggestion is how much of a product to bring into a location,
+// worked out from its reorder level and recent sales
+type ReplenishmentSuggestion struct {
+	ReorderStatus
+	// UnitsSold is how many units completed orders sold over the sales
+	// period, and DailySales the average per day
+	UnitsSold         int     `json:"units_sold"`
+	DailySales        float64 `json:"daily_sales"`
+	SuggestedQuantity int     `json:"suggested_quantity"`
+}
+
+// StockTransfer moves stock from one location to another. Shipping takes the
+// stock out of FromLocation; it is in transit until the transfer is received
+// into ToLocation, or cancelled and put back into FromLocation.
+type StockTransfer struct {
+	ID             uuid.UUID           `json:"id" db:"id"`
+	TransferNumber string              `json:"transfer_number" db:"transfer_number"`
+	FromLocation   string              `json:"from_location" db:"from_location"`
+	ToLocation     string              `json:"to_location" db:"to_location"`
+	Status         string              `json:"status" db:"status"` // "shipped", "received", "cancelled"
+	Notes          string              `json:"notes,omitempty" db:"notes"`
+	CreatedBy      *uuid.UUID          `json:"created_by,omitempty" db:"created_by"`
+	ReceivedBy     *uuid.UUID          `json:"received_by,omitempty" db:"received_by"`
+	ShippedAt      time.Time           `json:"shipped_at" db:"shipped_at"`
+	ReceivedAt     *time.Time          `json:"received_at,omitempty" db:"received_at"`
+	CancelledAt    *time.Time          `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at" db:"updated_at"`
+	Items          []StockTransferItem `json:"items"`
+}
+
+// StockTransferItem is the quantity of one product moved by a transfer
+type StockTransferItem struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	TransferID uuid.UUID `json:"transfer_id" db:"transfer_id"`
+	ProductID  uuid.UUID `json:"product_id" db:"product_id"`
+	Quantity   int       `json:"quantity" db:"quantity"`
+}
+
+// Supplier is a business stock is bought from. Inactive suppliers keep their
+// purchase orders and cost history but take no new purchase orders.
+type Supplier struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	ContactName string    `json:"contact_name" db:"contact_name"`
+	Email       string    `json:"email" db:"email"`
+	Phone       string    `json:"phone" db:"phone"`
+	Address     string    `json:"address" db:"address"`
+	Notes       string    `json:"notes" db:"notes"`
+	IsActive    bool      `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SupplierCost is what a supplier charged per unit of a product received
+// against a purchase order
+type SupplierCost struct {
+	ID                  uuid.UUID    `json:"id" db:"id"`
+	SupplierID          uuid.UUID    `json:"supplier_id" db:"supplier_id"`
+	ProductID           uuid.UUID    `json:"product_id" db:"product_id"`
+	PurchaseOrderLineID *uuid.UUID   `json:"purchase_order_line_id,omitempty" db:"purchase_order_line_id"`
+	PONumber            string       `json:"po_number,omitempty"`
+	Quantity            int          `json:"quantity" db:"quantity"`
+	UnitCost            money.Amount `json:"unit_cost" db:"unit_cost"`
+	ReceivedBy          *uuid.UUID   `json:"received_by,omitempty" db:"received_by"`
+	ReceivedAt          time.Time    `json:"received_at" db:"received_at"`
+}
+
+// PurchaseOrder orders stock from a supplier, to be delivered to Location. It
+// is drafted, sent to the supplier and received in one or more deliveries,
+// and closed once nothing more is expected.
+type PurchaseOrder struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	PONumber   string     `json:"po_number" db:"po_number"`
+	SupplierID uuid.UUID  `json:"supplier_id" db:"supplier_id"`
+	Location   string     `json:"location" db:"location"`
+	Status     string     `json:"status" db:"status"` // "draft", "sent", "partially_received", "received", "closed"
+	ExpectedAt *time.Time `json:"expected_at,omitempty" db:"expected_at"`
+	Notes      string     `json:"notes,omitempty" db:"notes"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	SentAt     *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+	ReceivedAt *time.Time `json:"received_at,omitempty" db:"received_at"`
+	ClosedAt   *time.Time `json:"closed_at,omitempty" db:"closed_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	// ExpectedTotal is what the lines are expected to cost
+	ExpectedTotal money.Amount        `json:"expected_total"`
+	Lines         []PurchaseOrderLine `json:"lines"`
+}
+
+// PurchaseOrderLine is a product ordered on a purchase order, its expected
+// unit cost and how much of it has been received so far. ReceivedQuantity
+// can exceed Quantity when the supplier delivered more than ordered.
+type PurchaseOrderLine struct {
+	ID               uuid.UUID    `json:"id" db:"id"`
+	PurchaseOrderID  uuid.UUID    `json:"purchase_order_id" db:"purchase_order_id"`
+	ProductID        uuid.UUID    `json:"product_id" db:"product_id"`
+	Quantity         int          `json:"quantity" db:"quantity"`
+	ReceivedQuantity int          `json:"received_quantity" db:"received_quantity"`
+	UnitCost         money.Amount `json:"unit_cost" db:"unit_cost"`
+	ExpectedAt       *time.Time   `json:"expected_at,omitempty" db:"expected_at"`
 }
 
 // Customer represents a customer in the POS system
@@ -66,89 +277,444 @@ type Customer struct {
 
 // Order represents a sales order in the POS system
 type Order struct {
-	ID             uuid.UUID   `json:"id" db:"id"`
-	OrderNumber    string      `json:"order_number" db:"order_number"`
-	CustomerID     *uuid.UUID  `json:"customer_id,omitempty" db:"customer_id"`
-	Status         string      `json:"status" db:"status"` // "pending", "completed", "cancelled"
-	Subtotal       float64     `json:"subtotal" db:"subtotal"`
-	TaxAmount      float64     `json:"tax_amount" db:"tax_amount"`
-	DiscountAmount float64     `json:"discount_amount" db:"discount_amount"`
-	TotalAmount    float64     `json:"total_amount" db:"total_amount"`
-	PaymentStatus  string      `json:"payment_status" db:"payment_status"` // "pending", "paid", "refunded"
-	Notes          string      `json:"notes" db:"notes"`
-	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
-	Customer       *Customer   `json:"customer,omitempty"`
-	Items          []OrderItem `json:"items,omitempty"`
-	Payments       []Payment   `json:"payments,omitempty"`
+	ID             uuid.UUID    `json:"id" db:"id"`
+	OrderNumber    string       `json:"order_number" db:"order_number"`
+	CustomerID     *uuid.UUID   `json:"customer_id,omitempty" db:"customer_id"`
+	Status         string       `json:"status" db:"status"` // "draft", "pending", "on_hold", "completed", "cancelled", "partially_refunded", "refunded"
+	Subtotal       money.Amount `json:"subtotal" db:"subtotal"`
+	TaxAmount      money.Amount `json:"tax_amount" db:"tax_amount"`
+	DiscountAmount money.Amount `json:"discount_amount" db:"discount_amount"`
+	TotalAmount    money.Amount `json:"total_amount" db:"total_amount"`
+	PaymentStatus  string       `json:"payment_status" db:"payment_status"` // "pending", "paid", "refunded"
+	// PricesIncludeTax records whether item prices already contained tax
+	// when the order was placed
+	PricesIncludeTax bool `json:"prices_include_tax" db:"prices_include_tax"`
+	// TaxOverridden is set when TaxAmount was entered by a manager instead
+	// of computed from the tax rates
+	TaxOverridden bool   `json:"tax_overridden" db:"tax_overridden"`
+	Notes         string `json:"notes" db:"notes"`
+	// CreatedBy is the user who placed the order and ApprovedBy the
+	// supervisor who approved a discount, price or tax the user could not
+	// give alone
+	CreatedBy  *uuid.UUID  `json:"created_by,omitempty" db:"created_by"`
+	ApprovedBy *uuid.UUID  `json:"approved_by,omitempty" db:"approved_by"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at" db:"updated_at"`
+	Customer   *Customer   `json:"customer,omitempty"`
+	Items      []OrderItem `json:"items,omitempty"`
+	Payments   []Payment   `json:"payments,omitempty"`
+	// Promotions lists the promotions applied to the order and the discount
+	// each of them gave
+	Promotions []OrderPromotion `json:"promotions,omitempty"`
+	// Coupons lists the coupons redeemed on the order
+	Coupons []CouponRedemption `json:"coupons,omitempty"`
+}
+
+// OrderStatusHistory records a single order status transition
+type OrderStatusHistory struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	OrderID    uuid.UUID  `json:"order_id" db:"order_id"`
+	FromStatus string     `json:"from_status,omitempty" db:"from_status"`
+	ToStatus   string     `json:"to_status" db:"to_status"`
+	ChangedBy  *uuid.UUID `json:"changed_by,omitempty" db:"changed_by"`
+	ApprovedBy *uuid.UUID `json:"approved_by,omitempty" db:"approved_by"`
+	Reason     string     `json:"reason,omitempty" db:"reason"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 }
 
 // OrderItem represents an item in a sales order
 type OrderItem struct {
-	ID         uuid.UUID `json:"id" db:"id"`
-	OrderID    uuid.UUID `json:"order_id" db:"order_id"`
-	ProductID  uuid.UUID `json:"product_id" db:"product_id"`
-	Quantity   int       `json:"quantity" db:"quantity"`
-	UnitPrice  float64   `json:"unit_price" db:"unit_price"`
-	Discount   float64   `json:"discount" db:"discount"`
-	TotalPrice float64   `json:"total_price" db:"total_price"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	Product    *Product  `json:"product,omitempty"`
+	ID         uuid.UUID    `json:"id" db:"id"`
+	OrderID    uuid.UUID    `json:"order_id" db:"order_id"`
+	ProductID  uuid.UUID    `json:"product_id" db:"product_id"`
+	Quantity   int          `json:"quantity" db:"quantity"`
+	UnitPrice  money.Amount `json:"unit_price" db:"unit_price"`
+	Discount   money.Amount `json:"discount" db:"discount"`
+	TotalPrice money.Amount `json:"total_price" db:"total_price"`
+	TaxClassID *uuid.UUID   `json:"tax_class_id,omitempty" db:"tax_class_id"`
+	TaxRate    money.Rate   `json:"tax_rate" db:"tax_rate"`
+	TaxAmount  money.Amount `json:"tax_amount" db:"tax_amount"`
+	// PriceOverridden is set when UnitPrice was entered at the till instead
+	// of taken from the product
+	PriceOverridden bool      `json:"price_overridden" db:"price_overridden"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	Product         *Product  `json:"product,omitempty"`
 }
 
 // Payment represents a payment for an order
 type Payment struct {
-	ID            uuid.UUID `json:"id" db:"id"`
-	OrderID       uuid.UUID `json:"order_id" db:"order_id"`
-	Amount        float64   `json:"amount" db:"amount"`
-	PaymentMethod string    `json:"payment_method" db:"payment_method"` // "cash", "card", "transfer", "digital_wallet"
-	Reference     string    `json:"reference" db:"reference"`
-	Status        string    `json:"status" db:"status"` // "pending", "completed", "failed", "refunded"
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	ID      uuid.UUID `json:"id" db:"id"`
+	OrderID uuid.UUID `json:"order_id" db:"order_id"`
+	// Amount is what the payment applied to the order. For cash it can be
+	// less than TenderedAmount, the difference being returned as change.
+	Amount         money.Amount `json:"amount" db:"amount"`
+	TenderedAmount money.Amount `json:"tendered_amount" db:"tendered_amount"`
+	ChangeAmount   money.Amount `json:"change_amount" db:"change_amount"`
+	PaymentMethod  string       `json:"payment_method" db:"payment_method"` // "cash", "card", "transfer", "digital_wallet"
+	Reference      string       `json:"reference" db:"reference"`
+	Status         string       `json:"status" db:"status"` // "pending", "completed", "failed", "refunded"
+	RefundOf       *uuid.UUID   `json:"refund_of,omitempty" db:"refund_of"`
+	RefundID       *uuid.UUID   `json:"refund_id,omitempty" db:"refund_id"`
+	ShiftID        *uuid.UUID   `json:"shift_id,omitempty" db:"shift_id"`
+	CreatedBy      *uuid.UUID   `json:"created_by,omitempty" db:"created_by"`
+	ApprovedBy     *uuid.UUID   `json:"approved_by,omitempty" db:"approved_by"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// PaymentResult is the outcome of paying for an order with one or more
+// tenders
+type PaymentResult struct {
+	OrderID        uuid.UUID    `json:"order_id"`
+	Payments       []Payment    `json:"payments"`
+	AmountDue      money.Amount `json:"amount_due"`
+	TenderedAmount money.Amount `json:"tendered_amount"`
+	AppliedAmount  money.Amount `json:"applied_amount"`
+	ChangeAmount   money.Amount `json:"change_amount"`
+	// Balance is what is still to be paid after this payment
+	Balance       money.Amount `json:"balance"`
+	PaymentStatus string       `json:"payment_status"`
+}
+
+// Shift is a cashier's session at a cash drawer, from the opening float to
+// the cash counted when it is closed
+type Shift struct {
+	ID           uuid.UUID     `json:"id" db:"id"`
+	UserID       uuid.UUID     `json:"user_id" db:"user_id"`
+	Register     string        `json:"register,omitempty" db:"register"`
+	Status       string        `json:"status" db:"status"` // "open", "closed"
+	OpeningFloat money.Amount  `json:"opening_float" db:"opening_float"`
+	ExpectedCash *money.Amount `json:"expected_cash,omitempty" db:"expected_cash"`
+	CountedCash  *money.Amount `json:"counted_cash,omitempty" db:"counted_cash"`
+	Variance     *money.Amount `json:"variance,omitempty" db:"variance"`
+	Notes        string        `json:"notes,omitempty" db:"notes"`
+	OpenedAt     time.Time     `json:"opened_at" db:"opened_at"`
+	ClosedAt     *time.Time    `json:"closed_at,omitempty" db:"closed_at"`
+}
+
+// CashMovement is cash put into or taken out of the drawer during a shift
+// other than for a sale, such as petty cash or a safe drop
+type CashMovement struct {
+	ID        uuid.UUID    `json:"id" db:"id"`
+	ShiftID   uuid.UUID    `json:"shift_id" db:"shift_id"`
+	Type      string       `json:"type" db:"type"` // "cash_in", "cash_out"
+	Amount    money.Amount `json:"amount" db:"amount"`
+	Reason    string       `json:"reason" db:"reason"`
+	CreatedBy *uuid.UUID   `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
+
+// ShiftTender is the takings of a shift for one payment method. Counted and
+// Variance are only set once the shift is closed and the tender was counted.
+type ShiftTender struct {
+	PaymentMethod string        `json:"payment_method" db:"payment_method"`
+	Sales         money.Amount  `json:"sales" db:"sales"`
+	Refunds       money.Amount  `json:"refunds" db:"refunds"`
+	Expected      money.Amount  `json:"expected" db:"expected"`
+	Counted       *money.Amount `json:"counted,omitempty" db:"counted"`
+	Variance      *money.Amount `json:"variance,omitempty" db:"variance"`
+}
+
+// ShiftReport summarises a shift. An X report is taken while the shift is
+// open and leaves it open; the Z report is produced when it is closed and
+// includes the counted cash and the variance.
+type ShiftReport struct {
+	Type           string         `json:"type"` // "X", "Z"
+	Shift          Shift          `json:"shift"`
+	Tenders        []ShiftTender  `json:"tenders"`
+	Movements      []CashMovement `json:"movements"`
+	CashIn         money.Amount   `json:"cash_in"`
+	CashOut        money.Amount   `json:"cash_out"`
+	ChangeGiven    money.Amount   `json:"change_given"`
+	RefundCount    int            `json:"refund_count"`
+	RefundAmount   money.Amount   `json:"refund_amount"`
+	VoidCount      int            `json:"void_count"`
+	VoidAmount     money.Amount   `json:"void_amount"`
+	DiscountAmount money.Amount   `json:"discount_amount"`
+	ExpectedCash   money.Amount   `json:"expected_cash"`
+	CountedCash    *money.Amount  `json:"counted_cash,omitempty"`
+	Variance       *money.Amount  `json:"variance,omitempty"`
 }
 
 // Receipt represents a sales receipt
 type Receipt struct {
-	ID            uuid.UUID `json:"id" db:"id"`
-	OrderID       uuid.UUID `json:"order_id" db:"order_id"`
-	ReceiptNumber string    `json:"receipt_number" db:"receipt_number"`
-	TotalAmount   float64   `json:"total_amount" db:"total_amount"`
-	TaxAmount     float64   `json:"tax_amount" db:"tax_amount"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	Order         *Order    `json:"order,omitempty"`
+	ID            uuid.UUID    `json:"id" db:"id"`
+	OrderID       uuid.UUID    `json:"order_id" db:"order_id"`
+	ReceiptNumber string       `json:"receipt_number" db:"receipt_number"`
+	Type          string       `json:"type" db:"type"` // "sale", "credit_note"
+	RefundID      *uuid.UUID   `json:"refund_id,omitempty" db:"refund_id"`
+	TotalAmount   money.Amount `json:"total_amount" db:"total_amount"`
+	TaxAmount     money.Amount `json:"tax_amount" db:"tax_amount"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+	Order         *Order       `json:"order,omitempty"`
+	Taxes         []ReceiptTax `json:"taxes,omitempty"`
+	// Tenders are the payments made for a sale and ChangeAmount the cash
+	// given back over all of them
+	Tenders      []Payment    `json:"tenders,omitempty"`
+	ChangeAmount money.Amount `json:"change_amount"`
+	CashierID    *uuid.UUID   `json:"cashier_id,omitempty" db:"cashier_id"`
+	// PrintCount is how many times the receipt has been rendered for
+	// printing; every print after the first is a copy
+	PrintCount int `json:"print_count" db:"print_count"`
+	// Cashier, Lines and Discounts are filled in when the receipt is
+	// rendered in full
+	Cashier   string            `json:"cashier,omitempty"`
+	Lines     []ReceiptLine     `json:"lines,omitempty"`
+	Discounts []ReceiptDiscount `json:"discounts,omitempty"`
+}
+
+// ReceiptLine is one product line printed on a receipt. Amount is what the
+// line comes to after its Discount.
+type ReceiptLine struct {
+	Name      string       `json:"name"`
+	SKU       string       `json:"sku,omitempty"`
+	Quantity  int          `json:"quantity"`
+	UnitPrice money.Amount `json:"unit_price"`
+	Discount  money.Amount `json:"discount"`
+	Amount    money.Amount `json:"amount"`
+}
+
+// ReceiptDiscount is a promotion or coupon listed on a receipt with what it
+// took off the sale
+type ReceiptDiscount struct {
+	Name   string       `json:"name"`
+	Amount money.Amount `json:"amount"`
+}
+
+// ReceiptTax is one line of a receipt's tax breakdown: the amount taxed and
+// the tax charged at a single rate
+type ReceiptTax struct {
+	ID            uuid.UUID    `json:"id" db:"id"`
+	ReceiptID     uuid.UUID    `json:"receipt_id" db:"receipt_id"`
+	TaxClassID    *uuid.UUID   `json:"tax_class_id,omitempty" db:"tax_class_id"`
+	Name          string       `json:"name" db:"name"`
+	Rate          money.Rate   `json:"rate" db:"rate"`
+	TaxableAmount money.Amount `json:"taxable_amount" db:"taxable_amount"`
+	TaxAmount     money.Amount `json:"tax_amount" db:"tax_amount"`
+}
+
+// Promotion is a discount rule that is applied automatically to orders it
+// matches. Which fields are used depends on Type:
+//   - "percentage": Percent off matching lines, or off the order when the
+//     promotion is not scoped to a product or category
+//   - "fixed": Amount off each matching unit, or off the order when the
+//     promotion is not scoped to a product or category
+//   - "buy_x_get_y": for every BuyQuantity matching units bought,
+//     GetQuantity more are Percent off (free when Percent is 0), cheapest first
+//   - "bundle": every BuyQuantity matching units cost Amount together
+//   - "spend_threshold": Amount off the order, or Percent off the matching
+//     spend, once the matching spend reaches MinSpend
+type Promotion struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	Name        string       `json:"name" db:"name"`
+	Description string       `json:"description" db:"description"`
+	Campaign    string       `json:"campaign" db:"campaign"`
+	Type        string       `json:"type" db:"type"` // "percentage", "fixed", "buy_x_get_y", "bundle", "spend_threshold"
+	Percent     money.Rate   `json:"percent" db:"percent"`
+	Amount      money.Amount `json:"amount" db:"amount"`
+	BuyQuantity int          `json:"buy_quantity" db:"buy_quantity"`
+	GetQuantity int          `json:"get_quantity" db:"get_quantity"`
+	MinSpend    money.Amount `json:"min_spend" db:"min_spend"`
+	ProductID   *uuid.UUID   `json:"product_id,omitempty" db:"product_id"`
+	CategoryID  *uuid.UUID   `json:"category_id,omitempty" db:"category_id"`
+	CustomerID  *uuid.UUID   `json:"customer_id,omitempty" db:"customer_id"`
+	StartsAt    *time.Time   `json:"starts_at,omitempty" db:"starts_at"`
+	EndsAt      *time.Time   `json:"ends_at,omitempty" db:"ends_at"`
+	Priority    int          `json:"priority" db:"priority"`
+	IsActive    bool         `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// OrderPromotion records the discount a promotion gave on an order, either
+// on a single line or, without OrderItemID, on the order as a whole
+type OrderPromotion struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	OrderID     uuid.UUID    `json:"order_id" db:"order_id"`
+	PromotionID uuid.UUID    `json:"promotion_id" db:"promotion_id"`
+	OrderItemID *uuid.UUID   `json:"order_item_id,omitempty" db:"order_item_id"`
+	Name        string       `json:"name" db:"name"`
+	Campaign    string       `json:"campaign,omitempty" db:"campaign"`
+	Amount      money.Amount `json:"amount" db:"amount"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+}
+
+// Coupon is a code that gives a discount when it is entered on an order. The
+// discount is defined like a promotion's; "spend_threshold" is not used since
+// MinBasket already sets the spend a coupon needs. MaxUses and
+// MaxUsesPerCustomer are unlimited when nil.
+type Coupon struct {
+	ID                 uuid.UUID    `json:"id" db:"id"`
+	Code               string       `json:"code" db:"code"`
+	Description        string       `json:"description" db:"description"`
+	Campaign           string       `json:"campaign" db:"campaign"`
+	Type               string       `json:"type" db:"type"` // "percentage", "fixed", "buy_x_get_y", "bundle"
+	Percent            money.Rate   `json:"percent" db:"percent"`
+	Amount             money.Amount `json:"amount" db:"amount"`
+	BuyQuantity        int          `json:"buy_quantity" db:"buy_quantity"`
+	GetQuantity        int          `json:"get_quantity" db:"get_quantity"`
+	ProductID          *uuid.UUID   `json:"product_id,omitempty" db:"product_id"`
+	CategoryID         *uuid.UUID   `json:"category_id,omitempty" db:"category_id"`
+	MinBasket          money.Amount `json:"min_basket" db:"min_basket"`
+	MaxUses            *int         `json:"max_uses,omitempty" db:"max_uses"`
+	MaxUsesPerCustomer *int         `json:"max_uses_per_customer,omitempty" db:"max_uses_per_customer"`
+	TimesUsed          int          `json:"times_used" db:"times_used"`
+	StartsAt           *time.Time   `json:"starts_at,omitempty" db:"starts_at"`
+	EndsAt             *time.Time   `json:"ends_at,omitempty" db:"ends_at"`
+	IsActive           bool         `json:"is_active" db:"is_active"`
+	CreatedAt          time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// CouponRedemption records a coupon used on an order and the discount it gave.
+// Redemptions of cancelled and refunded orders are reversed and no longer
+// count towards the coupon's usage limits.
+type CouponRedemption struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	CouponID   uuid.UUID    `json:"coupon_id" db:"coupon_id"`
+	OrderID    uuid.UUID    `json:"order_id" db:"order_id"`
+	CustomerID *uuid.UUID   `json:"customer_id,omitempty" db:"customer_id"`
+	Code       string       `json:"code" db:"code"`
+	Amount     money.Amount `json:"amount" db:"amount"`
+	ReversedAt *time.Time   `json:"reversed_at,omitempty" db:"reversed_at"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+}
+
+// PromotionReport is the discount given by a promotion over a period
+type PromotionReport struct {
+	PromotionID    uuid.UUID    `json:"promotion_id"`
+	Name           string       `json:"name"`
+	Campaign       string       `json:"campaign"`
+	Orders         int          `json:"orders"`
+	DiscountAmount money.Amount `json:"discount_amount"`
+}
+
+// Refund represents money (and optionally stock) returned against a paid order
+type Refund struct {
+	ID              uuid.UUID    `json:"id" db:"id"`
+	OrderID         uuid.UUID    `json:"order_id" db:"order_id"`
+	Amount          money.Amount `json:"amount" db:"amount"`
+	Reason          string       `json:"reason" db:"reason"`
+	RestockLocation string       `json:"restock_location,omitempty" db:"restock_location"`
+	CreatedBy       *uuid.UUID   `json:"created_by,omitempty" db:"created_by"`
+	ApprovedBy      *uuid.UUID   `json:"approved_by,omitempty" db:"approved_by"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+	Items           []RefundItem `json:"items,omitempty"`
+	Payments        []Payment    `json:"payments,omitempty"`
+	CreditNote      *Receipt     `json:"credit_note,omitempty"`
+}
+
+// RefundItem represents the quantity of an order line returned in a refund
+type RefundItem struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	RefundID    uuid.UUID    `json:"refund_id" db:"refund_id"`
+	OrderItemID uuid.UUID    `json:"order_item_id" db:"order_item_id"`
+	ProductID   uuid.UUID    `json:"product_id" db:"product_id"`
+	Quantity    int          `json:"quantity" db:"quantity"`
+	Amount      money.Amount `json:"amount" db:"amount"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
 }
 
 // CreateProductRequest represents the request to create a product
 type CreateProductRequest struct {
-	Name          string  `json:"name" validate:"required"`
-	Description   string  `json:"description"`
-	SKU           string  `json:"sku"`
-	BarcodeNumber string  `json:"barcode_number"`
-	CategoryID    string  `json:"category_id" validate:"required"`
-	Price         float64 `json:"price" validate:"required,min=0"`
+	Name          string       `json:"name" validate:"required"`
+	Description   string       `json:"description"`
+	SKU           string       `json:"sku"`
+	BarcodeNumber string       `json:"barcode_number"`
+	CategoryID    string       `json:"category_id" validate:"required"`
+	Price         money.Amount `json:"price" validate:"required,min=0"`
+	TaxClassID    string       `json:"tax_class_id"`
 }
 
 // UpdateProductRequest represents the request to update a product
 type UpdateProductRequest struct {
-	Name          string  `json:"name" validate:"required"`
-	Description   string  `json:"description"`
-	SKU           string  `json:"sku"`
-	BarcodeNumber string  `json:"barcode_number"`
-	CategoryID    string  `json:"category_id" validate:"required"`
-	Price         float64 `json:"price" validate:"required,min=0"`
+	Name          string       `json:"name" validate:"required"`
+	Description   string       `json:"description"`
+	SKU           string       `json:"sku"`
+	BarcodeNumber string       `json:"barcode_number"`
+	CategoryID    string       `json:"category_id" validate:"required"`
+	Price         money.Amount `json:"price" validate:"required,min=0"`
+	TaxClassID    string       `json:"tax_class_id"`
 }
 
 // CreateCategoryRequest represents the request to create a category
 type CreateCategoryRequest struct {
 	Name        string `json:"name" validate:"required"`
 	Description string `json:"description"`
+	TaxClassID  string `json:"tax_class_id"`
 }
 
 // UpdateCategoryRequest represents the request to update a category
 type UpdateCategoryRequest struct {
 	Name        string `json:"name" validate:"required"`
 	Description string `json:"description"`
+	TaxClassID  string `json:"tax_class_id"`
+}
+
+// CreatePromotionRequest represents the request to create a promotion
+type CreatePromotionRequest struct {
+	Name        string       `json:"name" validate:"required"`
+	Description string       `json:"description"`
+	Campaign    string       `json:"campaign"`
+	Type        string       `json:"type" validate:"required,oneof=percentage fixed buy_x_get_y bundle spend_threshold"`
+	Percent     money.Rate   `json:"percent"`
+	Amount      money.Amount `json:"amount"`
+	BuyQuantity int          `json:"buy_quantity"`
+	GetQuantity int          `json:"get_quantity"`
+	MinSpend    money.Amount `json:"min_spend"`
+	ProductID   *uuid.UUID   `json:"product_id"`
+	CategoryID  *uuid.UUID   `json:"category_id"`
+	CustomerID  *uuid.UUID   `json:"customer_id"`
+	StartsAt    *time.Time   `json:"starts_at"`
+	EndsAt      *time.Time   `json:"ends_at"`
+	Priority    int          `json:"priority"`
+	IsActive    *bool        `json:"is_active"`
+}
+
+// UpdatePromotionRequest represents the request to update a promotion
+type UpdatePromotionRequest CreatePromotionRequest
+
+// CreateCouponRequest represents the request to create a coupon
+type CreateCouponRequest struct {
+	Code               string       `json:"code" validate:"required"`
+	Description        string       `json:"description"`
+	Campaign           string       `json:"campaign"`
+	Type               string       `json:"type" validate:"required,oneof=percentage fixed buy_x_get_y bundle"`
+	Percent            money.Rate   `json:"percent"`
+	Amount             money.Amount `json:"amount"`
+	BuyQuantity        int          `json:"buy_quantity"`
+	GetQuantity        int          `json:"get_quantity"`
+	ProductID          *uuid.UUID   `json:"product_id"`
+	CategoryID         *uuid.UUID   `json:"category_id"`
+	MinBasket          money.Amount `json:"min_basket"`
+	MaxUses            *int         `json:"max_uses"`
+	MaxUsesPerCustomer *int         `json:"max_uses_per_customer"`
+	StartsAt           *time.Time   `json:"starts_at"`
+	EndsAt             *time.Time   `json:"ends_at"`
+	IsActive           *bool        `json:"is_active"`
+}
+
+// UpdateCouponRequest represents the request to update a coupon
+type UpdateCouponRequest CreateCouponRequest
+
+// CreateTaxClassRequest represents the request to create a tax class
+type CreateTaxClassRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+// UpdateTaxClassRequest represents the request to update a tax class
+type UpdateTaxClassRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+// CreateTaxRateRequest represents the request to add a rate to a tax class.
+// The rate takes over from the class's current rate at EffectiveFrom, which
+// defaults to now.
+type CreateTaxRateRequest struct {
+	Rate          money.Rate `json:"rate" validate:"min=0"`
+	EffectiveFrom *time.Time `json:"effective_from"`
 }
 
 // CreateInventoryRequest represents the request to create inventory
@@ -162,6 +728,8 @@ type CreateInventoryRequest struct {
 type UpdateInventoryRequest struct {
 	Quantity int    `json:"quantity" validate:"required,min=0"`
 	Location string `json:"location" validate:"required"`
+	// Reason is recorded in the stock ledger with the new quantity
+	Reason string `json:"reason"`
 }
 
 // AdjustStockRequest represents the request to adjust stock
@@ -171,6 +739,103 @@ type AdjustStockRequest struct {
 	Type      string `json:"type" validate:"required,oneof=in out adjustment"`
 	Reason    string `json:"reason" validate:"required"`
 	Reference string `json:"reference"`
+	// Location is the location whose stock is adjusted. It may be left out
+	// for a product stocked at a single location.
+	Location string `json:"location"`
+}
+
+// CreateLocationRequest represents the request to create a location
+type CreateLocationRequest struct {
+	Name        string `json:"name" validate:"required,max=255"`
+	Description string `json:"description"`
+}
+
+// UpdateLocationRequest represents the request to update a location. The
+// name of a location cannot be changed.
+type UpdateLocationRequest struct {
+	Description string `json:"description"`
+	IsActive    bool   `json:"is_active"`
+}
+
+// CreateTransferRequest represents the request to ship stock from one
+// location to another. With Receive set the transfer is received at once,
+// for moves that are not in transit, such as from the back room to the shop
+// floor.
+type CreateTransferRequest struct {
+	FromLocation string                `json:"from_location" validate:"required"`
+	ToLocation   string                `json:"to_location" validate:"required"`
+	Notes        string                `json:"notes"`
+	Receive      bool                  `json:"receive"`
+	Items        []TransferItemRequest `json:"items" validate:"required,min=1"`
+}
+
+// TransferItemRequest represents a line in a transfer request
+type TransferItemRequest struct {
+	ProductID uuid.UUID `json:"product_id" validate:"required"`
+	Quantity  int       `json:"quantity" validate:"required,min=1"`
+}
+
+// SetReorderLevelRequest sets the reorder level of a product at a location
+type SetReorderLevelRequest struct {
+	MinQuantity     int `json:"min_quantity" validate:"min=0"`
+	MaxQuantity     int `json:"max_quantity" validate:"min=0"`
+	ReorderQuantity int `json:"reorder_quantity" validate:"min=0"`
+}
+
+// CreateSupplierRequest represents the request to create a supplier
+type CreateSupplierRequest struct {
+	Name        string `json:"name" validate:"required"`
+	ContactName string `json:"contact_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Address     string `json:"address"`
+	Notes       string `json:"notes"`
+}
+
+// UpdateSupplierRequest represents the request to update a supplier
+type UpdateSupplierRequest struct {
+	Name        string `json:"name" validate:"required"`
+	ContactName string `json:"contact_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Address     string `json:"address"`
+	Notes       string `json:"notes"`
+	IsActive    bool   `json:"is_active"`
+}
+
+// PurchaseOrderRequest represents the request to create a purchase order,
+// or to replace a draft one
+type PurchaseOrderRequest struct {
+	SupplierID uuid.UUID                  `json:"supplier_id" validate:"required"`
+	Location   string                     `json:"location" validate:"required"`
+	ExpectedAt *time.Time                 `json:"expected_at,omitempty"`
+	Notes      string                     `json:"notes"`
+	Lines      []PurchaseOrderLineRequest `json:"lines" validate:"required,min=1"`
+}
+
+// PurchaseOrderLineRequest represents a line in a purchase order request.
+// Without UnitCost the supplier's last cost for the product is expected.
+type PurchaseOrderLineRequest struct {
+	ProductID  uuid.UUID     `json:"product_id" validate:"required"`
+	Quantity   int           `json:"quantity" validate:"required,min=1"`
+	UnitCost   *money.Amount `json:"unit_cost,omitempty"`
+	ExpectedAt *time.Time    `json:"expected_at,omitempty"`
+}
+
+// ReceiveGoodsRequest represents a delivery against a purchase order.
+// Delivering more of a line than is still outstanding is refused unless
+// AcceptOverDelivery is set.
+type ReceiveGoodsRequest struct {
+	AcceptOverDelivery bool                      `json:"accept_over_delivery"`
+	Lines              []ReceiveGoodsLineRequest `json:"lines" validate:"required,min=1"`
+}
+
+// ReceiveGoodsLineRequest represents the quantity of a purchase order line
+// delivered. Without UnitCost the line's expected cost is what was charged.
+type ReceiveGoodsLineRequest struct {
+	LineID   uuid.UUID     `json:"line_id" validate:"required"`
+	Quantity int           `json:"quantity" validate:"required,min=1"`
+	UnitCost *money.Amount `json:"unit_cost,omitempty"`
 }
 
 // CreateCustomerRequest represents the request to create a customer
@@ -191,50 +856,122 @@ type UpdateCustomerRequest struct {
 
 // CreateOrderRequest represents the request to create an order
 type CreateOrderRequest struct {
-	CustomerID     *string            `json:"customer_id"`
-	Items          []OrderItemRequest `json:"items" validate:"required,min=1"`
-	TaxAmount      float64            `json:"tax_amount"`
-	DiscountAmount float64            `json:"discount_amount"`
-	Notes          string             `json:"notes"`
+	CustomerID *string            `json:"customer_id"`
+	Items      []OrderItemRequest `json:"items" validate:"required,min=1"`
+	// TaxAmount replaces the computed tax and needs the order.override_tax
+	// permission
+	TaxAmount      *money.Amount `json:"tax_amount,omitempty"`
+	DiscountAmount money.Amount  `json:"discount_amount"`
+	// CouponCodes are redeemed together with the order
+	CouponCodes []string `json:"coupon_codes"`
+	Notes       string   `json:"notes"`
+	Draft       bool     `json:"draft"`
+	// Approval is a supervisor's approval of a discount, price or tax the
+	// user may not give alone
+	Approval *ApprovalRequest `json:"approval,omitempty"`
+}
+
+// UpdateOrderStatusRequest represents the request to move an order to another status
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" validate:"required"`
+	Reason string `json:"reason"`
+	// Approval is a supervisor's approval of cancelling (voiding) the order
+	Approval *ApprovalRequest `json:"approval,omitempty"`
 }
 
 // OrderItemRequest represents an item in order creation request
 type OrderItemRequest struct {
-	ProductID uuid.UUID `json:"product_id" validate:"required"`
-	Quantity  int       `json:"quantity" validate:"required,min=1"`
-	Discount  float64   `json:"discount"`
+	ProductID uuid.UUID    `json:"product_id" validate:"required"`
+	Quantity  int          `json:"quantity" validate:"required,min=1"`
+	Discount  money.Amount `json:"discount"`
+	// UnitPrice replaces the product price and needs the
+	// order.override_price permission
+	UnitPrice *money.Amount `json:"unit_price,omitempty"`
 }
 
-// CreatePaymentRequest represents the request to create a payment
+// CreatePaymentRequest represents the request to pay for an order. A single
+// tender is given with Amount, PaymentMethod and Reference; several tenders,
+// such as part cash and part card, are given with Tenders instead.
 type CreatePaymentRequest struct {
-	OrderID       uuid.UUID `json:"order_id" validate:"required"`
-	Amount        float64   `json:"amount" validate:"required,min=0"`
-	PaymentMethod string    `json:"payment_method" validate:"required,oneof=cash card transfer digital_wallet"`
-	Reference     string    `json:"reference"`
+	OrderID       uuid.UUID       `json:"order_id"`
+	Amount        money.Amount    `json:"amount" validate:"omitempty,min=0"`
+	PaymentMethod string          `json:"payment_method" validate:"omitempty,oneof=cash card transfer digital_wallet"`
+	Reference     string          `json:"reference"`
+	Tenders       []TenderRequest `json:"tenders"`
+}
+
+// TenderRequest is one means of payment handed over for an order. For cash
+// Amount is what the customer handed over, which may be more than is due.
+type TenderRequest struct {
+	Amount        money.Amount `json:"amount" validate:"required,min=0"`
+	PaymentMethod string       `json:"payment_method" validate:"required,oneof=cash card transfer digital_wallet"`
+	Reference     string       `json:"reference"`
+}
+
+// OpenShiftRequest represents the request to open a shift
+type OpenShiftRequest struct {
+	OpeningFloat money.Amount `json:"opening_float" validate:"min=0"`
+	Register     string       `json:"register"`
+	Notes        string       `json:"notes"`
+}
+
+// CloseShiftRequest represents the request to close a shift. CountedCash is
+// the cash in the drawer; other tenders, such as the card terminal total,
+// can be given in CountedTenders to be reconciled as well.
+type CloseShiftRequest struct {
+	CountedCash    money.Amount            `json:"counted_cash" validate:"min=0"`
+	CountedTenders map[string]money.Amount `json:"counted_tenders"`
+	Notes          string                  `json:"notes"`
+}
+
+// CreateCashMovementRequest represents the request to record cash put into or
+// taken out of the drawer
+type CreateCashMovementRequest struct {
+	Type   string       `json:"type" validate:"required,oneof=cash_in cash_out"`
+	Amount money.Amount `json:"amount" validate:"required,gt=0"`
+	Reason string       `json:"reason" validate:"required"`
+}
+
+// CreateRefundRequest represents the request to refund an order. When Items is
+// empty everything still refundable on the order is refunded. Refunded items
+// go back to RestockLocation; without it, only a refund that leaves the order
+// fully refunded returns them, to where they were taken from.
+type CreateRefundRequest struct {
+	Items           []RefundItemRequest `json:"items"`
+	Reason          string              `json:"reason" validate:"required"`
+	RestockLocation string              `json:"restock_location"`
+	// Approval is a supervisor's approval of a refund above the cashier limit
+	Approval *ApprovalRequest `json:"approval,omitempty"`
+}
+
+// RefundItemRequest represents a line in a refund request
+type RefundItemRequest struct {
+	OrderItemID uuid.UUID `json:"order_item_id" validate:"required"`
+	Quantity    int       `json:"quantity" validate:"required,min=1"`
 }
 
 // SalesReport represents sales report data
 type SalesReport struct {
-	TotalSales   float64        `json:"total_sales"`
+	TotalSales   money.Amount   `json:"total_sales"`
 	TotalOrders  int            `json:"total_orders"`
-	AverageOrder float64        `json:"average_order"`
+	AverageOrder money.Amount   `json:"average_order"`
 	TopProducts  []ProductSales `json:"top_products"`
 	SalesByDate  []DailySales   `json:"sales_by_date"`
 }
 
 // ProductSales represents product sales data
 type ProductSales struct {
-	ProductID   uuid.UUID `json:"product_id"`
-	ProductName string    `json:"product_name"`
-	Quantity    int       `json:"quantity"`
-	Revenue     float64   `json:"revenue"`
+	ProductID   uuid.UUID    `json:"product_id"`
+	ProductName string       `json:"product_name"`
+	Quantity    int          `json:"quantity"`
+	Revenue     money.Amount `json:"revenue"`
 }
 
 // DailySales represents daily sales data
 type DailySales struct {
-	Date   string  `json:"date"`
-	Sales  float64 `json:"sales"`
-	Orders int     `json:"orders"`
+	Date   string       `json:"date"`
+	Sales  money.Amount `json:"sales"`
+	Orders int          `json:"orders"`
 }
 
 // APIResponse represents a standard API response
@@ -251,10 +988,74 @@ type User struct {
 	Username  string    `json:"username" db:"username"`
 	Email     string    `json:"email" db:"email"`
 	Password  string    `json:"-" db:"password"` // "-" means this field won't be included in JSON
-	Role      string    `json:"role" db:"role"`  // "admin", "user", "cashier"
+	Role      string    `json:"role" db:"role"`  // name of one of the roles
 	IsActive  bool      `json:"is_active" db:"is_active"`
+	PIN       string    `json:"-" db:"pin_hash"` // hash of the approval PIN, empty when none is set
+	HasPIN    bool      `json:"has_pin"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// TOTPSecret is the user's authenticator secret. It is set on
+	// enrollment and only used for login once TwoFactorEnabled is set by
+	// the first verified code.
+	TOTPSecret       string `json:"-" db:"totp_secret"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled" db:"totp_enabled"`
+	TOTPLastStep     int64  `json:"-" db:"totp_last_step"` // time step of the last accepted code
+	// Permissions are the permissions of the user's role, loaded for the
+	// authenticated user
+	Permissions []string `json:"permissions,omitempty"`
+	// APIKeyID is set when the request was authenticated with an API key
+	// instead of a user's token. The user then stands for the key: it has
+	// no ID and the key's permissions.
+	APIKeyID *uuid.UUID `json:"api_key_id,omitempty"`
+}
+
+// HasPermission reports whether the user's role grants permission
+func (u *User) HasPermission(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Permission is something a role can allow its users to do
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Role is a named set of permissions. System roles come with jatistore and
+// cannot be deleted; the admin role cannot be changed either.
+type Role struct {
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	IsSystem    bool      `json:"is_system" db:"is_system"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	// RequireTwoFactor makes every user with the role log in with a second
+	// factor, setting one up at their next login if they have none
+	RequireTwoFactor bool `json:"require_two_factor" db:"require_two_factor"`
+}
+
+// CreateRoleRequest represents the request to create a custom role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,max=50"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest represents the request to change a role's permissions
+type UpdateRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// SetTwoFactorRequirementRequest represents the request to require, or stop
+// requiring, two-factor authentication for a role
+type SetTwoFactorRequirementRequest struct {
+	Required bool `json:"required"`
 }
 
 // LoginRequest represents the login request
@@ -268,14 +1069,16 @@ type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
-	Role     string `json:"role" validate:"required,oneof=admin user cashier"`
+	// Role can only be "user", or empty for the same: staff accounts are
+	// created through invites
+	Role string `json:"role,omitempty"`
 }
 
 // UpdateUserRequest represents the request to update a user
 type UpdateUserRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email"`
-	Role     string `json:"role" validate:"required,oneof=admin user cashier"`
+	Role     string `json:"role" validate:"required,max=50"`
 	IsActive bool   `json:"is_active"`
 }
 
@@ -285,17 +1088,309 @@ type ChangePasswordRequest struct {
 	NewPassword     string `json:"new_password" validate:"required,min=6"`
 }
 
-// LoginResponse represents the login response with JWT token
+// SetPINRequest represents the request to set the PIN a user approves
+// actions at the till with
+type SetPINRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	PIN             string `json:"pin" validate:"required"`
+}
+
+// ApprovalRequest is a supervisor's approval of an action the requesting
+// user may not take alone. It is either the supervisor's username and PIN,
+// entered at the till, or an approval token the supervisor created
+// beforehand.
+type ApprovalRequest struct {
+	Username string `json:"username,omitempty"`
+	PIN      string `json:"pin,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// ApprovalToken is a single-use, short-lived approval of actions needing
+// Permissions, created by a supervisor for another user to present. Token
+// is only returned when it is created; just its hash is stored.
+type ApprovalToken struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	ApproverID  uuid.UUID  `json:"approver_id" db:"approver_id"`
+	Permissions []string   `json:"permissions" db:"permissions"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty" db:"used_at"`
+	UsedBy      *uuid.UUID `json:"used_by,omitempty" db:"used_by"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	Token       string     `json:"token,omitempty"`
+}
+
+// CreateApprovalTokenRequest represents the request to create an approval
+// token for the given permissions
+type CreateApprovalTokenRequest struct {
+	Permissions []string `json:"permissions" validate:"required,min=1"`
+}
+
+// SetupRequest creates the first admin account with the one-time setup token
+type SetupRequest struct {
+	SetupToken string `json:"setup_token" validate:"required"`
+	Username   string `json:"username" validate:"required,min=3,max=50"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+}
+
+// Invite lets a new staff member create their own account with the role an
+// admin chose for them. Token is only returned when the invite is created;
+// just its hash is stored.
+type Invite struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	Email      string     `json:"email" db:"email"`
+	Role       string     `json:"role" db:"role"`
+	InvitedBy  *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	UserID     *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+// CreateInviteRequest represents the request to invite a staff member
+type CreateInviteRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,max=50"`
+}
+
+// AcceptInviteRequest redeems an invite, creating the invited account with
+// the username and password the staff member chooses
+type AcceptInviteRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Password string `json:"password" validate:"required"`
+}
+
+// LoginResponse represents the login response with a short-lived JWT access
+// token and the refresh token that gets the next one
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	User             User      `json:"user"`
+	// RecoveryCodes are returned once, when two-factor authentication was
+	// set up during this login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// TwoFactorChallenge is returned by login instead of tokens when the user
+// has to give a second factor. TwoFactorToken identifies the login in the
+// second step; SetupRequired is set when the user's role requires two-factor
+// authentication but the user has not set it up yet.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	SetupRequired     bool      `json:"setup_required"`
+	TwoFactorToken    string    `json:"two_factor_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// LoginChallenge is a login waiting for its second factor. Just the hash of
+// its token is stored.
+type LoginChallenge struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Attempts  int       `json:"attempts" db:"attempts"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TwoFactorLoginRequest represents the second login step: the token from the
+// first step and an authenticator code or, instead, an unused recovery code
+type TwoFactorLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+// TwoFactorSetupRequest represents the request to set up two-factor
+// authentication during a login that requires it
+type TwoFactorSetupRequest struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required"`
+}
+
+// TwoFactorEnrollment is a new TOTP secret with its otpauth:// provisioning
+// URI, which is shown as a QR code for authenticator apps to scan
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// EnrollTwoFactorRequest represents the request to start setting up
+// two-factor authentication for the current user
+type EnrollTwoFactorRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+// TwoFactorCodeRequest represents a request confirmed with an authenticator
+// code or, where accepted, a recovery code
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// DisableTwoFactorRequest represents the request to turn off two-factor
+// authentication for the current user
+type DisableTwoFactorRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Code            string `json:"code,omitempty"`
+	RecoveryCode    string `json:"recovery_code,omitempty"`
+}
+
+// RecoveryCodesResponse holds newly generated recovery codes, which are only
+// shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// LoginThrottle counts the recent failed logins of a username or client IP.
+// Until BlockedUntil, logins from it are refused; LockedOut is set when the
+// block is a lockout rather than the delay after a single failure.
+type LoginThrottle struct {
+	Scope         string     `json:"scope" db:"scope"` // username or ip
+	Key           string     `json:"key" db:"key"`
+	Failures      int        `json:"failures" db:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" db:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until,omitempty" db:"blocked_until"`
+	LockedOut     bool       `json:"locked_out" db:"locked_out"`
+}
+
+// SecurityEvent records something that happened to the security of an
+// account, such as a lockout, with who caused it where known
+type SecurityEvent struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Type      string     `json:"type" db:"type"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	Username  string     `json:"username,omitempty" db:"username"`
+	IPAddress string     `json:"ip_address,omitempty" db:"ip_address"`
+	Details   string     `json:"details,omitempty" db:"details"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// APIKey lets another system, such as an e-commerce sync job, call the API
+// without a user account. Just the hash of the key is stored; Prefix is its
+// first characters, to tell keys apart.
+type APIKey struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Prefix      string     `json:"prefix" db:"prefix"`
+	Permissions []string   `json:"permissions" db:"permissions"`
+	AllowedIPs  []string   `json:"allowed_ips" db:"allowed_ips"` // IPs and CIDR ranges; empty allows any
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip,omitempty" db:"last_used_ip"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	Key         string     `json:"key,omitempty"` // only returned on creation
+}
+
+// CreateAPIKeyRequest represents the request to create an API key
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name" validate:"required"`
+	Permissions []string   `json:"permissions" validate:"required,min=1"`
+	AllowedIPs  []string   `json:"allowed_ips,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// AuditEntry records one change to the store's data: who made it, when, and
+// the entity before and after it. Changes holds the fields an update changed,
+// each with its value before and after. Hash covers the entry and PrevHash,
+// the hash of the entry before it, so that the log cannot be changed without
+// breaking the chain.
+type AuditEntry struct {
+	ID         int64           `json:"id" db:"id"`
+	OccurredAt time.Time       `json:"occurred_at" db:"occurred_at"`
+	ActorID    *uuid.UUID      `json:"actor_id,omitempty" db:"actor_id"`
+	APIKeyID   *uuid.UUID      `json:"api_key_id,omitempty" db:"api_key_id"`
+	ActorName  string          `json:"actor_name" db:"actor_name"`
+	Action     string          `json:"action" db:"action"` // create, update or delete
+	EntityType string          `json:"entity_type" db:"entity_type"`
+	EntityID   string          `json:"entity_id" db:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty" db:"before"`
+	After      json.RawMessage `json:"after,omitempty" db:"after"`
+	Changes    json.RawMessage `json:"changes,omitempty" db:"changes"`
+	PrevHash   string          `json:"prev_hash" db:"prev_hash"`
+	Hash       string          `json:"hash" db:"hash"`
+}
+
+// AuditFilter narrows down the audit log entries returned. Zero fields do
+// not filter.
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	ActorID    *uuid.UUID
+	APIKeyID   *uuid.UUID
+	Action     string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+}
+
+// AuditVerification is the result of checking the audit log's hash chain.
+// BrokenAt is the first entry that does not match, when there is one.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	LastHash string `json:"last_hash,omitempty"`
+}
+
+// ForgotPasswordRequest asks for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password with the token from a password
+// reset email
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// PasswordResetToken is an emailed password reset. Just the hash of the
+// token is stored, and it can be used once.
+type PasswordResetToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// RefreshRequest represents the request to exchange a refresh token for a
+// new access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshToken is one link of a session's refresh token chain. Every refresh
+// replaces the token with a new one in the same family; just the hash of the
+// token is stored. AccessJTI is the access token issued along with it, so
+// that revoking the session revokes its access tokens too.
+type RefreshToken struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	FamilyID        uuid.UUID  `json:"family_id" db:"family_id"`
+	AccessJTI       uuid.UUID  `json:"access_jti" db:"access_jti"`
+	AccessExpiresAt time.Time  `json:"access_expires_at" db:"access_expires_at"`
+	ExpiresAt       time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt          *time.Time `json:"used_at,omitempty" db:"used_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
 }
 
-// Claims represents the JWT claims
+// Claims represents the JWT claims. The token ID (jti) is checked against
+// revoked tokens, and SessionID is the refresh token family the token was
+// issued for.
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
-	Role     string    `json:"role"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	SessionID uuid.UUID `json:"sid"`
 	jwt.RegisteredClaims
 }
@@ -0,0 +1,98 @@
+package money
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Currency describes how amounts in a currency are rounded
+type Currency struct {
+	// Code is the ISO 4217 currency code
+	Code string
+	// Decimals is the number of decimal places amounts are rounded to.
+	// It can be lower than the two places amounts are stored with, e.g.
+	// rupiah totals are rounded to whole rupiah.
+	Decimals int
+}
+
+// Supported currencies
+var (
+	IDR = Currency{Code: "IDR", Decimals: 0}
+	USD = Currency{Code: "USD", Decimals: 2}
+	EUR = Currency{Code: "EUR", Decimals: 2}
+	SGD = Currency{Code: "SGD", Decimals: 2}
+	MYR = Currency{Code: "MYR", Decimals: 2}
+	JPY = Currency{Code: "JPY", Decimals: 0}
+)
+
+var currencies = map[string]Currency{
+	IDR.Code: IDR,
+	USD.Code: USD,
+	EUR.Code: EUR,
+	SGD.Code: SGD,
+	MYR.Code: MYR,
+	JPY.Code: JPY,
+}
+
+// LookupCurrency returns the currency with the given ISO 4217 code
+func LookupCurrency(code string) (Currency, error) {
+	currency, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, fmt.Errorf("unsupported currency %q", code)
+	}
+	return currency, nil
+}
+
+// Round rounds an amount half away from zero to the currency's decimal places
+func (c Currency) Round(a Amount) Amount {
+	step := c.step()
+	return Amount(divRound(int64(a), step) * step)
+}
+
+// Allocate works like Allocate but hands out whole currency units, so every
+// part is rounded to the currency. total is rounded first.
+func (c Currency) Allocate(total Amount, weights []Amount) []Amount {
+	step := c.step()
+	parts := Allocate(Amount(divRound(int64(total), step)), weights)
+	for i := range parts {
+		parts[i] *= Amount(step)
+	}
+	return parts
+}
+
+// step is the smallest amount the currency is rounded to, in minor units
+func (c Currency) step() int64 {
+	step := int64(1)
+	for i := c.Decimals; i < 2; i++ {
+		step *= 10
+	}
+	return step
+}
+
+// Format formats an amount for display with the currency's decimal places
+// and a comma between thousands, e.g. "73,500" for IDR or "1,250.50" for USD.
+// The amount is rounded to the currency first.
+func (c Currency) Format(a Amount) string {
+	v := int64(c.Round(a))
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	units := strconv.FormatInt(v/Scale, 10)
+	var grouped strings.Builder
+	for i, digit := range units {
+		if i > 0 && (len(units)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	if c.Decimals == 0 {
+		return sign + grouped.String()
+	}
+	minor := fmt.Sprintf("%02d", v%Scale)
+	return sign + grouped.String() + "." + minor[:c.Decimals]
+}
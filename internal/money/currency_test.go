@@ -0,0 +1,95 @@
+package money
+
+import "testing"
+
+func TestCurrencyRound(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		in       Amount
+		want     Amount
+	}{
+		{IDR, 0, 0},
+		{IDR, 49, 0},
+		{IDR, 50, 100},
+		{IDR, 149, 100},
+		{IDR, 150, 200},
+		{IDR, -49, 0},
+		{IDR, -50, -100},
+		{IDR, -149, -100},
+		{IDR, -150, -200},
+		{JPY, 12350, 12400},
+		{USD, 12345, 12345},
+		{USD, -1, -1},
+	}
+
+	for _, tt := range tests {
+		if got := tt.currency.Round(tt.in); got != tt.want {
+			t.Errorf("%s.Round(%d) = %d, want %d", tt.currency.Code, int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestCurrencyAllocate(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		total    Amount
+		weights  []Amount
+		want     []Amount
+	}{
+		{IDR, 1000, []Amount{1, 1, 1}, []Amount{400, 300, 300}},
+		{IDR, 1050, []Amount{1, 1, 1}, []Amount{400, 400, 300}},
+		{IDR, 1049, []Amount{1, 1, 1}, []Amount{400, 300, 300}},
+		{USD, 1000, []Amount{1, 1, 1}, []Amount{334, 333, 333}},
+	}
+
+	for _, tt := range tests {
+		got := tt.currency.Allocate(tt.total, tt.weights)
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s.Allocate(%d) = %v, want %v", tt.currency.Code, int64(tt.total), got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s.Allocate(%d) = %v, want %v", tt.currency.Code, int64(tt.total), got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCurrencyFormat(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		in       Amount
+		want     string
+	}{
+		{IDR, 7350000, "73,500"},
+		{IDR, 100000000, "1,000,000"},
+		{IDR, 149, "1"},
+		{IDR, 150, "2"},
+		{IDR, -7350000, "-73,500"},
+		{USD, 0, "0.00"},
+		{USD, 125050, "1,250.50"},
+		{USD, -125050, "-1,250.50"},
+		{USD, 99999, "999.99"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.currency.Format(tt.in); got != tt.want {
+			t.Errorf("%s.Format(%d) = %q, want %q", tt.currency.Code, int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestLookupCurrency(t *testing.T) {
+	currency, err := LookupCurrency("idr")
+	if err != nil {
+		t.Fatalf("LookupCurrency(idr): %v", err)
+	}
+	if currency != IDR {
+		t.Errorf("LookupCurrency(idr) = %v, want %v", currency, IDR)
+	}
+
+	if _, err := LookupCurrency("XYZ"); err == nil {
+		t.Error("LookupCurrency(XYZ) succeeded, want an error")
+	}
+}
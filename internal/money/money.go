@@ -0,0 +1,239 @@
+// Package money provides an exact monetary amount type used for every price,
+// total and payment in the system instead of float64.
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of minor units in one currency unit. Amounts are kept
+// in hundredths to match the DECIMAL(10,2) columns in the database.
+const Scale = 100
+
+// Amount is a monetary amount in hundredths of the store currency. It
+// marshals to and from JSON numbers and database decimals without going
+// through floating point.
+type Amount int64
+
+// Zero is the zero amount
+const Zero Amount = 0
+
+// FromMinor creates an amount from a number of minor units (hundredths)
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// FromUnits creates an amount from a whole number of currency units
+func FromUnits(units int64) Amount {
+	return Amount(units * Scale)
+}
+
+// Parse parses a decimal string such as "73500", "12.5" or "-0.75". Digits
+// beyond the second decimal place are rounded half away from zero.
+func Parse(s string) (Amount, error) {
+	v, err := parseScaled(s, Scale)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %w", err)
+	}
+	return Amount(v), nil
+}
+
+// Minor returns the amount in minor units (hundredths)
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// String formats the amount as a plain decimal with two places, e.g. "-12.50"
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/Scale, v%Scale)
+}
+
+// IsZero reports whether the amount is zero
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (a Amount) IsNegative() bool {
+	return a < 0
+}
+
+// Mul multiplies the amount by a whole quantity
+func (a Amount) Mul(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// MulRatio multiplies the amount by num/den, rounding half away from zero
+func (a Amount) MulRatio(num, den int64) Amount {
+	if den == 0 {
+		return 0
+	}
+	return Amount(divRound(int64(a)*num, den))
+}
+
+// Min returns the smaller of two amounts
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of two amounts
+func Max(a, b Amount) Amount {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Allocate splits total over the given weights in proportion to them. The
+// parts always add up to exactly total; leftover minor units go to the parts
+// with the largest remainders.
+func Allocate(total Amount, weights []Amount) []Amount {
+	parts := make([]Amount, len(weights))
+
+	var sum int64
+	for _, w := range weights {
+		sum += int64(w)
+	}
+	if sum == 0 {
+		return parts
+	}
+
+	remainders := make([]int64, len(weights))
+	var allocated Amount
+	for i, w := range weights {
+		n := int64(total) * int64(w)
+		parts[i] = Amount(n / sum)
+		remainders[i] = n % sum
+		allocated += parts[i]
+	}
+
+	for left := total - allocated; left != 0; {
+		best := 0
+		for i := range remainders {
+			if abs(remainders[i]) > abs(remainders[best]) {
+				best = i
+			}
+		}
+		step := Amount(1)
+		if left < 0 {
+			step = -1
+		}
+		parts[best] += step
+		remainders[best] = 0
+		left -= step
+	}
+
+	return parts
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimal places
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or numeric string
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = parsed
+	return nil
+}
+
+// Value stores the amount as a decimal string
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads the amount from a database decimal
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = FromUnits(v)
+		return nil
+	case float64:
+		return a.scanString(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// parseScaled parses a decimal string and returns it multiplied by scale,
+// rounded half away from zero
+func parseScaled(s string, scale int64) (int64, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if s == "" || !ok {
+		return 0, fmt.Errorf("%q is not a decimal number", s)
+	}
+
+	r.Mul(r, big.NewRat(scale, 1))
+	if !r.Num().IsInt64() || !r.Denom().IsInt64() {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+
+	return divRound(r.Num().Int64(), r.Denom().Int64()), nil
+}
+
+// divRound divides n by d rounding half away from zero
+func divRound(n, d int64) int64 {
+	if d < 0 {
+		n, d = -n, -d
+	}
+	q := n / d
+	r := n % d
+	if 2*abs(r) >= d {
+		if n < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
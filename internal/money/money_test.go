@@ -0,0 +1,162 @@
+package money
+
+import "testing"
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		n, d int64
+		want int64
+	}{
+		{0, 7, 0},
+		{4, 2, 2},
+		{5, 2, 3},
+		{3, 2, 2},
+		{7, 3, 2},
+		{8, 3, 3},
+		{1, 3, 0},
+		{-5, 2, -3},
+		{-3, 2, -2},
+		{-7, 3, -2},
+		{-8, 3, -3},
+		{-1, 3, 0},
+		{5, -2, -3},
+		{-5, -2, 3},
+	}
+
+	for _, tt := range tests {
+		if got := divRound(tt.n, tt.d); got != tt.want {
+			t.Errorf("divRound(%d, %d) = %d, want %d", tt.n, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+	}{
+		{"73500", 7350000},
+		{"12.5", 1250},
+		{"-0.75", -75},
+		{" 1.00 ", 100},
+		{"12.345", 1235},
+		{"12.344", 1234},
+		{"-12.345", -1235},
+		{"0.005", 1},
+		{"-0.005", -1},
+		{"0.004", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "1,5", "99999999999999999999"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{1250, "12.50"},
+		{-1250, "-12.50"},
+		{7350000, "73500.00"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestMulRatio(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		num, den int64
+		want     Amount
+	}{
+		{100, 1, 3, 33},
+		{200, 1, 3, 67},
+		{5, 1, 2, 3},
+		{-5, 1, 2, -3},
+		{1000, 0, 1, 0},
+		{1000, 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.MulRatio(tt.num, tt.den); got != tt.want {
+			t.Errorf("Amount(%d).MulRatio(%d, %d) = %d, want %d", int64(tt.amount), tt.num, tt.den, got, tt.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   Amount
+		weights []Amount
+		want    []Amount
+	}{
+		{"even split", 90, []Amount{1, 1, 1}, []Amount{30, 30, 30}},
+		{"remainder to the first tie", 100, []Amount{1, 1, 1}, []Amount{34, 33, 33}},
+		{"remainder to the largest remainder", 1000, []Amount{1, 2, 3}, []Amount{167, 333, 500}},
+		{"one unit over three", 1, []Amount{1, 1, 1}, []Amount{1, 0, 0}},
+		{"zero weight gets nothing", 7, []Amount{1, 0, 1}, []Amount{4, 0, 3}},
+		{"negative total", -100, []Amount{1, 1, 1}, []Amount{-34, -33, -33}},
+		{"all weights zero", 10, []Amount{0, 0}, []Amount{0, 0}},
+		{"no weights", 10, nil, []Amount{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Allocate(tt.total, tt.weights)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Allocate = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Allocate = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateSumsToTotal(t *testing.T) {
+	weights := [][]Amount{
+		{1, 1, 1},
+		{1, 2, 3, 4, 5, 6, 7},
+		{9999, 1},
+		{333, 333, 334},
+		{150000, 2575, 99},
+	}
+	totals := []Amount{1, 2, 99, 100, 101, 12345, -12345, 999999}
+
+	for _, w := range weights {
+		for _, total := range totals {
+			var sum Amount
+			for _, part := range Allocate(total, w) {
+				sum += part
+			}
+			if sum != total {
+				t.Errorf("Allocate(%d, %v) sums to %d", total, w, sum)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RateScale is the number of rate units in one percent. Rates are kept with
+// four decimal places to match the DECIMAL(7,4) columns in the database.
+const RateScale = 10000
+
+// Rate is a percentage such as a tax rate, in ten-thousandths of a percent
+type Rate int64
+
+// ParseRate parses a percentage such as "11" or "12.5"
+func ParseRate(s string) (Rate, error) {
+	v, err := parseScaled(s, RateScale)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate: %w", err)
+	}
+	return Rate(v), nil
+}
+
+// String formats the rate as a percentage without trailing zeros, e.g. "11" or "12.5"
+func (r Rate) String() string {
+	sign := ""
+	v := int64(r)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	s := fmt.Sprintf("%s%d.%04d", sign, v/RateScale, v%RateScale)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// Of returns the rate applied to amount, e.g. the tax on a net amount,
+// rounded half away from zero to the minor unit
+func (r Rate) Of(amount Amount) Amount {
+	return amount.MulRatio(int64(r), 100*RateScale)
+}
+
+// Within returns the part of a gross amount that the rate makes up when the
+// rate is already included in it, e.g. the tax inside a tax-inclusive price
+func (r Rate) Within(gross Amount) Amount {
+	return gross.MulRatio(int64(r), 100*RateScale+int64(r))
+}
+
+// MarshalJSON encodes the rate as a JSON number
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or numeric string
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+
+	*r = parsed
+	return nil
+}
+
+// Value stores the rate as a decimal string
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// Scan reads the rate from a database decimal
+func (r *Rate) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*r = 0
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*r = Rate(v * RateScale)
+		return nil
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("cannot scan %T into money.Rate", src)
+	}
+
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
@@ -0,0 +1,82 @@
+package money
+
+import "testing"
+
+func TestRateOf(t *testing.T) {
+	tests := []struct {
+		rate   Rate
+		amount Amount
+		want   Amount
+	}{
+		{110000, 10000, 1100},
+		{110000, 5, 1},
+		{110000, 4, 0},
+		{100000, 5, 1},
+		{100000, -5, -1},
+		{100000, 4, 0},
+		{0, 10000, 0},
+		{1000000, 12345, 12345},
+		{125000, 999, 125},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rate.Of(tt.amount); got != tt.want {
+			t.Errorf("Rate(%s).Of(%d) = %d, want %d", tt.rate, int64(tt.amount), got, tt.want)
+		}
+	}
+}
+
+func TestRateWithin(t *testing.T) {
+	tests := []struct {
+		rate  Rate
+		gross Amount
+		want  Amount
+	}{
+		{110000, 11100, 1100},
+		{100000, 11, 1},
+		{100000, 6, 1},
+		{100000, 5, 0},
+		{100000, -6, -1},
+		{0, 11100, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rate.Within(tt.gross); got != tt.want {
+			t.Errorf("Rate(%s).Within(%d) = %d, want %d", tt.rate, int64(tt.gross), got, tt.want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Rate
+		str  string
+	}{
+		{"11", 110000, "11"},
+		{"12.5", 125000, "12.5"},
+		{"0", 0, "0"},
+		{"100", 1000000, "100"},
+		{"0.0001", 1, "0.0001"},
+		{"0.00005", 1, "0.0001"},
+		{"-2.25", -22500, "-2.25"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if err != nil {
+			t.Errorf("ParseRate(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+		if got.String() != tt.str {
+			t.Errorf("ParseRate(%q).String() = %q, want %q", tt.in, got.String(), tt.str)
+		}
+	}
+
+	if _, err := ParseRate("eleven"); err == nil {
+		t.Error("ParseRate(eleven) succeeded, want an error")
+	}
+}
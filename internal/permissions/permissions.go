@@ -0,0 +1,124 @@
+// Package permissions names what users may do. Routes and services check
+// permissions instead of roles; roles are sets of permissions stored in the
+// database, starting out as the system roles below.
+package permissions
+
+import "jatistore/internal/models"
+
+// Permissions
+const (
+	ProductRead          = "product.read"
+	ProductWrite         = "product.write"
+	TaxManage            = "tax.manage"
+	PromotionManage      = "promotion.manage"
+	CouponManage         = "coupon.manage"
+	InventoryRead        = "inventory.read"
+	InventoryAdjust      = "inventory.adjust"
+	LocationManage       = "location.manage"
+	PurchaseRead         = "purchase.read"
+	PurchaseManage       = "purchase.manage"
+	PurchaseReceive      = "purchase.receive"
+	CustomerRead         = "customer.read"
+	CustomerWrite        = "customer.write"
+	OrderRead            = "order.read"
+	OrderWrite           = "order.write"
+	OrderVoid            = "order.void"
+	OrderRefund          = "order.refund"
+	OrderRefundApprove   = "order.refund_approve"
+	OrderDiscountApprove = "order.discount_approve"
+	OrderOverridePrice   = "order.override_price"
+	OrderOverrideTax     = "order.override_tax"
+	ShiftOperate         = "shift.operate"
+	ShiftManage          = "shift.manage"
+	UserManage           = "user.manage"
+	RoleManage           = "role.manage"
+	AuditRead            = "audit.read"
+)
+
+// All lists every permission
+var All = []models.Permission{
+	{Name: ProductRead, Description: "View products and categories"},
+	{Name: ProductWrite, Description: "Create, update and delete products and categories"},
+	{Name: TaxManage, Description: "Create and change tax classes and rates"},
+	{Name: PromotionManage, Description: "Create and change promotions and see the promotion report"},
+	{Name: CouponManage, Description: "View, create and change coupons"},
+	{Name: InventoryRead, Description: "View stock levels"},
+	{Name: InventoryAdjust, Description: "Create, change, adjust and transfer stock"},
+	{Name: LocationManage, Description: "Create, change and delete stock locations"},
+	{Name: PurchaseRead, Description: "View suppliers, purchase orders and supplier costs"},
+	{Name: PurchaseManage, Description: "Create and change suppliers and purchase orders"},
+	{Name: PurchaseReceive, Description: "Receive goods against purchase orders"},
+	{Name: CustomerRead, Description: "View and search customers"},
+	{Name: CustomerWrite, Description: "Create, update and delete customers"},
+	{Name: OrderRead, Description: "View orders, refunds and receipts"},
+	{Name: OrderWrite, Description: "Create orders, change their status, take payments and issue receipts"},
+	{Name: OrderVoid, Description: "Cancel (void) orders"},
+	{Name: OrderRefund, Description: "Refund orders up to the refund approval threshold"},
+	{Name: OrderRefundApprove, Description: "Refund orders above the refund approval threshold"},
+	{Name: OrderDiscountApprove, Description: "Give manual discounts above the discount approval threshold"},
+	{Name: OrderOverridePrice, Description: "Sell items at a price other than the product price"},
+	{Name: OrderOverrideTax, Description: "Replace the computed tax of an order"},
+	{Name: ShiftOperate, Description: "Open and close own shifts and record cash movements"},
+	{Name: ShiftManage, Description: "View and report on every shift"},
+	{Name: UserManage, Description: "Manage users, invites and sessions"},
+	{Name: RoleManage, Description: "Create and change roles"},
+	{Name: AuditRead, Description: "View and verify the audit log"},
+}
+
+// Admin is the role that always has every permission. It cannot be changed
+// or deleted, so that there is always a way back in.
+const Admin = "admin"
+
+// SystemRoles are the roles every store starts with. Apart from admin they
+// can be edited, but not deleted.
+var SystemRoles = []models.Role{
+	{
+		Name:        Admin,
+		Description: "Full access to everything",
+		Permissions: Names(),
+	},
+	{
+		Name:        "manager",
+		Description: "Store supervisor: runs the floor, approves refunds and manages promotions",
+		Permissions: []string{
+			ProductRead, ProductWrite, PromotionManage, CouponManage,
+			InventoryRead, InventoryAdjust, LocationManage,
+			PurchaseRead, PurchaseManage, PurchaseReceive, CustomerRead, CustomerWrite,
+			OrderRead, OrderWrite, OrderVoid, OrderRefund, OrderRefundApprove,
+			OrderDiscountApprove, OrderOverridePrice, OrderOverrideTax,
+			ShiftOperate, ShiftManage,
+		},
+	},
+	{
+		Name:        "cashier",
+		Description: "Takes orders and payments at the register",
+		Permissions: []string{
+			ProductRead, InventoryRead, CustomerRead, CustomerWrite,
+			OrderRead, OrderWrite, OrderRefund, ShiftOperate,
+		},
+	},
+	{
+		Name:        "user",
+		Description: "Read-only access to the catalog, stock, customers and orders",
+		Permissions: []string{ProductRead, InventoryRead, CustomerRead, OrderRead},
+	},
+}
+
+// Names returns the names of all permissions
+func Names() []string {
+	names := make([]string, len(All))
+	for i, permission := range All {
+		names[i] = permission.Name
+	}
+	return names
+}
+
+// Valid reports whether name is a permission
+func Valid(name string) bool {
+	for _, permission := range All {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
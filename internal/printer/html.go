@@ -0,0 +1,63 @@
+package printer
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+)
+
+// defaultTemplate is the HTML receipt used when no template is configured.
+// It is a good starting point for a custom template.
+//
+//go:embed templates/receipt.html
+var defaultTemplate string
+
+// htmlReceipt is what an HTML receipt template is executed with
+type htmlReceipt struct {
+	Store       Profile
+	Receipt     *models.Receipt
+	Title       string
+	Copy        bool
+	CreditNote  bool
+	Customer    string
+	TaxIncluded bool
+	// Width is the paper width in millimetres
+	Width int
+}
+
+// renderHTML executes the receipt template
+func (r *Renderer) renderHTML(receipt *models.Receipt, opts Options) ([]byte, error) {
+	data := htmlReceipt{
+		Store:       r.profile,
+		Receipt:     receipt,
+		Title:       title(receipt),
+		Copy:        opts.Copy,
+		CreditNote:  receipt.Type == "credit_note",
+		Customer:    customerName(receipt),
+		TaxIncluded: receipt.Order != nil && receipt.Order.PricesIncludeTax,
+		Width:       opts.Width,
+	}
+
+	var buf bytes.Buffer
+	if err := r.template.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render receipt: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// templateFuncs are the functions available to receipt templates
+func (r *Renderer) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"money":  r.currency.Format,
+		"negate": func(a money.Amount) money.Amount { return -a },
+		"gross":  func(l models.ReceiptLine) money.Amount { return l.Amount + l.Discount },
+		"tax":    taxName,
+		"tender": tenderName,
+		"date":   func(t time.Time) string { return t.Format("2006-01-02 15:04") },
+	}
+}
@@ -0,0 +1,176 @@
+// Package printer renders receipts for printing: as plain text for 58mm and
+// 80mm paper, as raw ESC/POS bytes for thermal printers, as HTML and as PDF.
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+)
+
+// Output formats
+const (
+	FormatText   = "text"
+	FormatESCPOS = "escpos"
+	FormatHTML   = "html"
+	FormatPDF    = "pdf"
+)
+
+// Supported paper widths in millimetres
+const (
+	Width58 = 58
+	Width80 = 80
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported receipt format")
+	ErrUnsupportedWidth  = errors.New("unsupported paper width")
+)
+
+// Profile is the store information printed on every receipt
+type Profile struct {
+	Name    string
+	Address string
+	Phone   string
+	TaxID   string
+	Footer  string
+	// Width is the default paper width in millimetres, 58 or 80
+	Width int
+}
+
+// Options control a single rendering of a receipt
+type Options struct {
+	// Width is the paper width in millimetres; the profile's width is used
+	// when it is zero
+	Width int
+	// Copy marks the receipt as a reprint
+	Copy bool
+}
+
+// Renderer renders receipts with a store profile. Amounts are formatted in
+// the store currency.
+type Renderer struct {
+	profile  Profile
+	currency money.Currency
+	template *template.Template
+}
+
+// NewRenderer creates a Renderer. templatePath names an html/template file
+// that replaces the built-in HTML receipt; the built-in one is used when it
+// is empty.
+func NewRenderer(profile Profile, currency money.Currency, templatePath string) (*Renderer, error) {
+	if profile.Width == 0 {
+		profile.Width = Width80
+	}
+	if _, err := columns(profile.Width); err != nil {
+		return nil, err
+	}
+
+	source := defaultTemplate
+	if templatePath != "" {
+		content, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read receipt template: %w", err)
+		}
+		source = string(content)
+	}
+
+	r := &Renderer{profile: profile, currency: currency}
+	tmpl, err := template.New("receipt").Funcs(r.templateFuncs()).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse receipt template: %w", err)
+	}
+	r.template = tmpl
+
+	return r, nil
+}
+
+// Validate checks that a receipt can be rendered in format on paper of the
+// given width, zero meaning the profile's width
+func (r *Renderer) Validate(format string, width int) error {
+	switch format {
+	case FormatText, FormatESCPOS, FormatHTML, FormatPDF:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+	if width == 0 {
+		return nil
+	}
+	_, err := columns(width)
+	return err
+}
+
+// Render renders a fully loaded receipt in the given format
+func (r *Renderer) Render(receipt *models.Receipt, format string, opts Options) ([]byte, error) {
+	if err := r.Validate(format, opts.Width); err != nil {
+		return nil, err
+	}
+	if opts.Width == 0 {
+		opts.Width = r.profile.Width
+	}
+	cols, _ := columns(opts.Width)
+
+	switch format {
+	case FormatText:
+		return renderText(r.layout(receipt, opts, cols), cols), nil
+	case FormatESCPOS:
+		return renderESCPOS(r.layout(receipt, opts, cols)), nil
+	case FormatHTML:
+		return r.renderHTML(receipt, opts)
+	default:
+		return renderPDF(r.layout(receipt, opts, cols), cols, opts.Width), nil
+	}
+}
+
+// columns returns the number of characters that fit on a line of paper of
+// the given width in the printer's standard font
+func columns(width int) (int, error) {
+	switch width {
+	case Width58:
+		return 32, nil
+	case Width80:
+		return 48, nil
+	default:
+		return 0, fmt.Errorf("%w: %dmm, use %d or %d", ErrUnsupportedWidth, width, Width58, Width80)
+	}
+}
+
+// title is the heading of a receipt of the given type
+func title(receipt *models.Receipt) string {
+	if receipt.Type == "credit_note" {
+		return "CREDIT NOTE"
+	}
+	return "RECEIPT"
+}
+
+// tenderName turns a payment method such as "digital_wallet" into a label
+func tenderName(method string) string {
+	name := strings.ReplaceAll(method, "_", " ")
+	if name == "" {
+		return "Payment"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// taxName labels a line of the tax breakdown with its rate, noting when the
+// tax is already contained in the prices
+func taxName(tax models.ReceiptTax, included bool) string {
+	name := fmt.Sprintf("%s %s%%", tax.Name, tax.Rate)
+	if included {
+		name += " incl."
+	}
+	return name
+}
+
+// customerName is the name of the customer of a receipt's order, if any
+func customerName(receipt *models.Receipt) string {
+	if receipt.Order == nil || receipt.Order.CustomerID == nil || receipt.Order.Customer == nil {
+		return ""
+	}
+	return receipt.Order.Customer.Name
+}
@@ -0,0 +1,249 @@
+package printer
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"jatistore/internal/models"
+)
+
+// line is one line of a receipt laid out for a fixed number of columns
+type line struct {
+	text   string
+	center bool
+	bold   bool
+}
+
+// layout lays out a receipt as the lines printed on paper cols characters
+// wide. The text, ESC/POS and PDF formats all print this layout.
+func (r *Renderer) layout(receipt *models.Receipt, opts Options, cols int) []line {
+	var lines []line
+	add := func(l ...line) { lines = append(lines, l...) }
+	rule := line{text: strings.Repeat("-", cols)}
+	creditNote := receipt.Type == "credit_note"
+	included := receipt.Order != nil && receipt.Order.PricesIncludeTax
+
+	// Store header
+	if r.profile.Name != "" {
+		add(centered(r.profile.Name, cols, true)...)
+	}
+	add(centered(r.profile.Address, cols, false)...)
+	if r.profile.Phone != "" {
+		add(centered("Tel: "+r.profile.Phone, cols, false)...)
+	}
+	if r.profile.TaxID != "" {
+		add(centered("Tax ID: "+r.profile.TaxID, cols, false)...)
+	}
+	if opts.Copy {
+		add(line{text: "*** COPY ***", center: true, bold: true})
+	}
+	add(rule)
+
+	add(line{text: title(receipt), center: true, bold: true})
+	add(row("No", receipt.ReceiptNumber, cols)...)
+	if receipt.Order != nil {
+		add(row("Order", receipt.Order.OrderNumber, cols)...)
+	}
+	add(row("Date", receipt.CreatedAt.Format("2006-01-02 15:04"), cols)...)
+	if receipt.Cashier != "" {
+		add(row("Cashier", receipt.Cashier, cols)...)
+	}
+	if customer := customerName(receipt); customer != "" {
+		add(row("Customer", customer, cols)...)
+	}
+	add(rule)
+
+	// Line items
+	for _, item := range receipt.Lines {
+		add(wrapped(item.Name, cols)...)
+		// Credit notes print what was refunded for the line, which may
+		// include its tax and share of the order discount
+		qty := "  " + strconv.Itoa(item.Quantity) + " x " + r.currency.Format(item.UnitPrice)
+		if creditNote {
+			qty = "  Qty " + strconv.Itoa(item.Quantity)
+		}
+		add(row(qty, r.currency.Format(item.Amount+item.Discount), cols)...)
+		if item.Discount > 0 {
+			add(row("  Discount", r.currency.Format(-item.Discount), cols)...)
+		}
+	}
+	add(rule)
+
+	// Totals
+	if !creditNote && receipt.Order != nil {
+		add(row("Subtotal", r.currency.Format(receipt.Order.Subtotal), cols)...)
+		if receipt.Order.DiscountAmount > 0 {
+			add(row("Order discount", r.currency.Format(-receipt.Order.DiscountAmount), cols)...)
+		}
+	}
+	for _, tax := range receipt.Taxes {
+		add(row(taxName(tax, included), r.currency.Format(tax.TaxAmount), cols)...)
+	}
+	for _, l := range row("TOTAL", r.currency.Format(receipt.TotalAmount), cols) {
+		l.bold = true
+		add(l)
+	}
+
+	// Tenders and change
+	if len(receipt.Tenders) > 0 {
+		add(rule)
+		for _, tender := range receipt.Tenders {
+			if creditNote {
+				add(row("Refund to "+tenderName(tender.PaymentMethod), r.currency.Format(-tender.Amount), cols)...)
+				continue
+			}
+			add(row(tenderName(tender.PaymentMethod), r.currency.Format(tender.TenderedAmount), cols)...)
+		}
+		if receipt.ChangeAmount > 0 {
+			add(row("Change", r.currency.Format(receipt.ChangeAmount), cols)...)
+		}
+	}
+
+	// Promotions and coupons
+	if len(receipt.Discounts) > 0 {
+		add(rule)
+		add(line{text: "You saved"})
+		for _, discount := range receipt.Discounts {
+			add(row("  "+discount.Name, r.currency.Format(discount.Amount), cols)...)
+		}
+	}
+
+	if r.profile.Footer != "" {
+		add(rule)
+		for _, paragraph := range strings.Split(r.profile.Footer, "\n") {
+			add(centered(paragraph, cols, false)...)
+		}
+	}
+	if opts.Copy {
+		add(line{text: "*** COPY ***", center: true, bold: true})
+	}
+
+	return lines
+}
+
+// renderText prints a layout as plain text
+func renderText(lines []line, cols int) []byte {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(strings.TrimRight(pad(l, cols), " "))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// ESC/POS commands
+const (
+	escInit        = "\x1b@"
+	escAlignLeft   = "\x1ba\x00"
+	escAlignCenter = "\x1ba\x01"
+	escBoldOn      = "\x1bE\x01"
+	escBoldOff     = "\x1bE\x00"
+	escFeedAndCut  = "\x1bd\x03\x1dV\x42\x00"
+)
+
+// renderESCPOS prints a layout as ESC/POS commands for a thermal printer,
+// ending with a paper cut. Characters outside ASCII are replaced since the
+// printer's code page is unknown.
+func renderESCPOS(lines []line) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(escInit)
+	for _, l := range lines {
+		if l.center {
+			buf.WriteString(escAlignCenter)
+		} else {
+			buf.WriteString(escAlignLeft)
+		}
+		if l.bold {
+			buf.WriteString(escBoldOn)
+		}
+		buf.WriteString(ascii(l.text))
+		if l.bold {
+			buf.WriteString(escBoldOff)
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(escAlignLeft)
+	buf.WriteString(escFeedAndCut)
+	return buf.Bytes()
+}
+
+// row lays out a label on the left and a value on the right. A label that
+// leaves no room for the value gets lines of its own.
+func row(label, value string, cols int) []line {
+	space := cols - utf8.RuneCountInString(label) - utf8.RuneCountInString(value)
+	if space >= 1 {
+		return []line{{text: label + strings.Repeat(" ", space) + value}}
+	}
+
+	lines := wrapped(label, cols)
+	width := utf8.RuneCountInString(value)
+	if width > cols {
+		return append(lines, wrapped(value, cols)...)
+	}
+	return append(lines, line{text: strings.Repeat(" ", cols-width) + value})
+}
+
+// centered wraps text to cols and centers every line
+func centered(text string, cols int, bold bool) []line {
+	lines := wrapped(text, cols)
+	for i := range lines {
+		lines[i].center = true
+		lines[i].bold = bold
+	}
+	return lines
+}
+
+// wrapped breaks text into lines of at most cols characters at spaces,
+// breaking words that are longer than a line
+func wrapped(text string, cols int) []line {
+	var lines []line
+	var current []rune
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for len(runes) > cols {
+			if len(current) > 0 {
+				lines = append(lines, line{text: string(current)})
+				current = nil
+			}
+			lines = append(lines, line{text: string(runes[:cols])})
+			runes = runes[cols:]
+		}
+		if len(current) > 0 && len(current)+1+len(runes) > cols {
+			lines = append(lines, line{text: string(current)})
+			current = nil
+		}
+		if len(current) > 0 {
+			current = append(current, ' ')
+		}
+		current = append(current, runes...)
+	}
+	if len(current) > 0 {
+		lines = append(lines, line{text: string(current)})
+	}
+	return lines
+}
+
+// pad returns the text of a line as it appears on paper cols characters
+// wide, with centered lines indented
+func pad(l line, cols int) string {
+	if !l.center {
+		return l.text
+	}
+	indent := (cols - utf8.RuneCountInString(l.text)) / 2
+	if indent <= 0 {
+		return l.text
+	}
+	return strings.Repeat(" ", indent) + l.text
+}
+
+// ascii replaces every character outside printable ASCII with '?'
+func ascii(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return '?'
+		}
+		return r
+	}, text)
+}
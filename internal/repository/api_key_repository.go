@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// apiKeyTouchInterval is how often the last use of an API key is written,
+// so that a busy integration does not update its key on every request
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyRepository struct {
+	db *database.DB
+}
+
+func NewAPIKeyRepository(db *database.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, name, prefix, permissions, allowed_ips, expires_at, last_used_at, COALESCE(last_used_ip, ''), created_by, revoked_at, created_at`
+
+// Create stores an API key under the hash of the key
+func (r *APIKeyRepository) Create(key *models.APIKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (id, name, prefix, key_hash, permissions, allowed_ips, expires_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(query,
+		key.ID,
+		key.Name,
+		key.Prefix,
+		keyHash,
+		pq.Array(key.Permissions),
+		pq.Array(key.AllowedIPs),
+		key.ExpiresAt,
+		key.CreatedBy,
+		key.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+// GetAll returns every API key, newest first
+func (r *APIKeyRepository) GetAll() ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, nil
+}
+
+// GetByHash returns the API key with the given hash, or nil when there is
+// none
+func (r *APIKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	key, err := scanAPIKey(r.db.QueryRow(query, keyHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+// Revoke stops an API key from being accepted. The key is kept, so that what
+// was done with it can still be traced to it.
+func (r *APIKeyRepository) Revoke(id uuid.UUID) (*models.APIKey, error) {
+	query := `
+		UPDATE api_keys SET revoked_at = $1
+		WHERE id = $2 AND revoked_at IS NULL
+		RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(r.db.QueryRow(query, time.Now(), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("API key not found")
+		}
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	return key, nil
+}
+
+// Touch records that an API key was used from ip, at most once per
+// apiKeyTouchInterval
+func (r *APIKeyRepository) Touch(id uuid.UUID, ip string) error {
+	query := `
+		UPDATE api_keys SET last_used_at = $1, last_used_ip = $2
+		WHERE id = $3 AND (last_used_at IS NULL OR last_used_at < $4 OR last_used_ip IS DISTINCT FROM $2)
+	`
+
+	now := time.Now()
+	if _, err := r.db.Exec(query, now, ip, id, now.Add(-apiKeyTouchInterval)); err != nil {
+		return fmt.Errorf("failed to record API key use: %w", err)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row scanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Permissions),
+		pq.Array(&key.AllowedIPs),
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.LastUsedIP,
+		&key.CreatedBy,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	if key.AllowedIPs == nil {
+		key.AllowedIPs = []string{}
+	}
+
+	return key, nil
+}
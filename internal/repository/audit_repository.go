@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// auditGenesisHash is the previous hash of the first audit log entry
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// AuditRepository stores the audit log. Entries are only ever appended, each
+// chained to the one before it by hash.
+type AuditRepository struct {
+	db *database.DB
+}
+
+func NewAuditRepository(db *database.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+const auditColumns = `id, occurred_at, actor_id, api_key_id, actor_name, action, entity_type, entity_id, before, after, changes, prev_hash, hash`
+
+// auditLockKey is the advisory lock key taken while appending to the audit
+// log
+const auditLockKey = 7238302
+
+// AppendTx adds an entry to the end of the audit log inside tx, so that it is
+// only kept if the change it records is. Appends are serialised by an
+// advisory lock held until tx ends, so that entries are chained one after
+// the other; callers append last to hold it as briefly as possible.
+func (r *AuditRepository) AppendTx(tx *sql.Tx, entry *models.AuditEntry) error {
+	// Readers are not blocked, only other appends
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+
+	prevHash := auditGenesisHash
+	err := tx.QueryRow(`SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get last audit log entry: %w", err)
+	}
+
+	// The database keeps microseconds, so that is what gets hashed
+	entry.OccurredAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.PrevHash = prevHash
+	entry.Hash, err = auditEntryHash(entry)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_log (occurred_at, actor_id, api_key_id, actor_name, action, entity_type, entity_id, before, after, changes, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`
+
+	err = tx.QueryRow(query,
+		entry.OccurredAt,
+		entry.ActorID,
+		entry.APIKeyID,
+		entry.ActorName,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		jsonValue(entry.Before),
+		jsonValue(entry.After),
+		jsonValue(entry.Changes),
+		entry.PrevHash,
+		entry.Hash,
+	).Scan(&entry.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to append audit log entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetEntries returns the audit log entries matching filter, newest first
+func (r *AuditRepository) GetEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EntityType != "" {
+		where("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		where("entity_id = $%d", filter.EntityID)
+	}
+	if filter.ActorID != nil {
+		where("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.APIKeyID != nil {
+		where("api_key_id = $%d", *filter.APIKeyID)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.From != nil {
+		where("occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("occurred_at < $%d", *filter.To)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+
+	return entries, nil
+}
+
+// Verify walks the whole audit log from the first entry, checking that each
+// entry links to the one before it and still has the hash it was stored with
+func (r *AuditRepository) Verify() (*models.AuditVerification, error) {
+	rows, err := r.db.Query(`SELECT ` + auditColumns + ` FROM audit_log ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.AuditVerification{Valid: true}
+	prevHash := auditGenesisHash
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log entry: %w", err)
+		}
+		result.Entries++
+
+		hash, err := auditEntryHash(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case entry.PrevHash != prevHash:
+			result.Reason = "entry does not link to the entry before it"
+		case entry.Hash != hash:
+			result.Reason = "entry does not match its hash"
+		}
+		if result.Reason != "" {
+			result.Valid = false
+			result.BrokenAt = &entry.ID
+			return result, nil
+		}
+
+		prevHash = entry.Hash
+		result.LastHash = entry.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return result, nil
+}
+
+// auditEntryHash returns the SHA-256 of an entry's contents and the hash of
+// the entry before it. The ID is left out: it comes from a sequence, which
+// has gaps, and the order of entries is kept by the chain itself.
+func auditEntryHash(entry *models.AuditEntry) (string, error) {
+	content, err := json.Marshal(struct {
+		PrevHash   string          `json:"prev_hash"`
+		OccurredAt string          `json:"occurred_at"`
+		ActorID    *uuid.UUID      `json:"actor_id"`
+		APIKeyID   *uuid.UUID      `json:"api_key_id"`
+		ActorName  string          `json:"actor_name"`
+		Action     string          `json:"action"`
+		EntityType string          `json:"entity_type"`
+		EntityID   string          `json:"entity_id"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		Changes    json.RawMessage `json:"changes"`
+	}{
+		PrevHash:   entry.PrevHash,
+		OccurredAt: entry.OccurredAt.UTC().Format(time.RFC3339Nano),
+		ActorID:    entry.ActorID,
+		APIKeyID:   entry.APIKeyID,
+		ActorName:  entry.ActorName,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Before:     entry.Before,
+		After:      entry.After,
+		Changes:    entry.Changes,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash audit log entry: %w", err)
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// jsonValue stores an empty JSON document as NULL
+func jsonValue(doc json.RawMessage) interface{} {
+	if len(doc) == 0 {
+		return nil
+	}
+	return string(doc)
+}
+
+func scanAuditEntry(row scanner) (*models.AuditEntry, error) {
+	entry := &models.AuditEntry{}
+	var before, after, changes sql.NullString
+
+	err := row.Scan(
+		&entry.ID,
+		&entry.OccurredAt,
+		&entry.ActorID,
+		&entry.APIKeyID,
+		&entry.ActorName,
+		&entry.Action,
+		&entry.EntityType,
+		&entry.EntityID,
+		&before,
+		&after,
+		&changes,
+		&entry.PrevHash,
+		&entry.Hash,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	if before.Valid {
+		entry.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		entry.After = json.RawMessage(after.String)
+	}
+	if changes.Valid {
+		entry.Changes = json.RawMessage(changes.String)
+	}
+
+	return entry, nil
+}
@@ -19,10 +19,17 @@ func NewCategoryRepository(db *database.DB) *CategoryRepository {
 	return &CategoryRepository{db: db}
 }
 
-func (r *CategoryRepository) Create(category *models.Category) error {
+// WithTx runs fn inside a database transaction, so that category changes can
+// be audited together with them
+func (r *CategoryRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// CreateTx inserts a category inside tx
+func (r *CategoryRepository) CreateTx(tx *sql.Tx, category *models.Category) error {
 	query := `
-		INSERT INTO categories (id, name, description, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO categories (id, name, description, tax_class_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
 
 	now := time.Now()
@@ -30,10 +37,11 @@ func (r *CategoryRepository) Create(category *models.Category) error {
 	category.CreatedAt = now
 	category.UpdatedAt = now
 
-	_, err := r.db.Exec(query,
+	_, err := tx.Exec(query,
 		category.ID,
 		category.Name,
 		category.Description,
+		category.TaxClassID,
 		category.CreatedAt,
 		category.UpdatedAt,
 	)
@@ -46,18 +54,27 @@ func (r *CategoryRepository) Create(category *models.Category) error {
 }
 
 func (r *CategoryRepository) GetByID(id uuid.UUID) (*models.Category, error) {
+	return r.getByID(r.db, id, "")
+}
+
+// Lock locks a category for the rest of tx and returns it
+func (r *CategoryRepository) Lock(tx *sql.Tx, id uuid.UUID) (*models.Category, error) {
+	return r.getByID(tx, id, " FOR UPDATE")
+}
+
+func (r *CategoryRepository) getByID(q querier, id uuid.UUID, lock string) (*models.Category, error) {
 	query := `
-		SELECT id, name, description, created_at, updated_at
+		SELECT id, name, description, tax_class_id, created_at, updated_at
 		FROM categories
-		WHERE id = $1
-	`
+		WHERE id = $1` + lock
 
 	category := &models.Category{}
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := q.QueryRow(query, id).Scan(
 		&category.ID,
 		&category.Name,
 		&category.Description,
+		&category.TaxClassID,
 		&category.CreatedAt,
 		&category.UpdatedAt,
 	)
@@ -74,7 +91,7 @@ func (r *CategoryRepository) GetByID(id uuid.UUID) (*models.Category, error) {
 
 func (r *CategoryRepository) GetAll() ([]*models.Category, error) {
 	query := `
-		SELECT id, name, description, created_at, updated_at
+		SELECT id, name, description, tax_class_id, created_at, updated_at
 		FROM categories
 		ORDER BY name ASC
 	`
@@ -93,6 +110,7 @@ func (r *CategoryRepository) GetAll() ([]*models.Category, error) {
 			&category.ID,
 			&category.Name,
 			&category.Description,
+			&category.TaxClassID,
 			&category.CreatedAt,
 			&category.UpdatedAt,
 		)
@@ -107,18 +125,20 @@ func (r *CategoryRepository) GetAll() ([]*models.Category, error) {
 	return categories, nil
 }
 
-func (r *CategoryRepository) Update(category *models.Category) error {
+// UpdateTx saves a category inside tx
+func (r *CategoryRepository) UpdateTx(tx *sql.Tx, category *models.Category) error {
 	query := `
 		UPDATE categories 
-		SET name = $1, description = $2, updated_at = $3
-		WHERE id = $4
+		SET name = $1, description = $2, tax_class_id = $3, updated_at = $4
+		WHERE id = $5
 	`
 
 	category.UpdatedAt = time.Now()
 
-	result, err := r.db.Exec(query,
+	result, err := tx.Exec(query,
 		category.Name,
 		category.Description,
+		category.TaxClassID,
 		category.UpdatedAt,
 		category.ID,
 	)
@@ -139,10 +159,11 @@ func (r *CategoryRepository) Update(category *models.Category) error {
 	return nil
 }
 
-func (r *CategoryRepository) Delete(id uuid.UUID) error {
+// DeleteTx deletes a category inside tx
+func (r *CategoryRepository) DeleteTx(tx *sql.Tx, id uuid.UUID) error {
 	query := `DELETE FROM categories WHERE id = $1`
 
-	result, err := r.db.Exec(query, id)
+	result, err := tx.Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete category: %w", err)
 	}
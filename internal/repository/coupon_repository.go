@@ -0,0 +1,372 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrCouponUsedUp is returned when redeeming a coupon that has reached its
+// usage limit
+var ErrCouponUsedUp = errors.New("coupon usage limit reached")
+
+type CouponRepository struct {
+	db *database.DB
+}
+
+func NewCouponRepository(db *database.DB) *CouponRepository {
+	return &CouponRepository{db: db}
+}
+
+const couponColumns = `id, code, COALESCE(description, ''), COALESCE(campaign, ''), type, percent, amount, buy_quantity, get_quantity,
+		product_id, category_id, min_basket, max_uses, max_uses_per_customer, times_used, starts_at, ends_at, is_active, created_at, updated_at`
+
+func (r *CouponRepository) Create(coupon *models.Coupon) error {
+	query := `
+		INSERT INTO coupons (id, code, description, campaign, type, percent, amount, buy_quantity, get_quantity,
+			product_id, category_id, min_basket, max_uses, max_uses_per_customer, starts_at, ends_at, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+	`
+
+	now := time.Now()
+	coupon.ID = uuid.New()
+	coupon.TimesUsed = 0
+	coupon.CreatedAt = now
+	coupon.UpdatedAt = now
+
+	_, err := r.db.Exec(query,
+		coupon.ID,
+		coupon.Code,
+		coupon.Description,
+		coupon.Campaign,
+		coupon.Type,
+		coupon.Percent,
+		coupon.Amount,
+		coupon.BuyQuantity,
+		coupon.GetQuantity,
+		coupon.ProductID,
+		coupon.CategoryID,
+		coupon.MinBasket,
+		coupon.MaxUses,
+		coupon.MaxUsesPerCustomer,
+		coupon.StartsAt,
+		coupon.EndsAt,
+		coupon.IsActive,
+		coupon.CreatedAt,
+		coupon.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create coupon: %w", err)
+	}
+
+	return nil
+}
+
+func (r *CouponRepository) GetByID(id uuid.UUID) (*models.Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE id = $1`
+
+	coupon, err := scanCoupon(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("coupon not found")
+		}
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+// GetByCode returns the coupon with the given code, or nil when there is none
+func (r *CouponRepository) GetByCode(code string) (*models.Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE code = $1`
+
+	coupon, err := scanCoupon(r.db.QueryRow(query, code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+func (r *CouponRepository) GetAll() ([]*models.Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coupons: %w", err)
+	}
+	defer rows.Close()
+
+	var coupons []*models.Coupon
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan coupon: %w", err)
+		}
+		coupons = append(coupons, coupon)
+	}
+
+	return coupons, nil
+}
+
+func (r *CouponRepository) Update(coupon *models.Coupon) error {
+	query := `
+		UPDATE coupons
+		SET code = $1, description = $2, campaign = $3, type = $4, percent = $5, amount = $6, buy_quantity = $7, get_quantity = $8,
+			product_id = $9, category_id = $10, min_basket = $11, max_uses = $12, max_uses_per_customer = $13, starts_at = $14,
+			ends_at = $15, is_active = $16, updated_at = $17
+		WHERE id = $18
+	`
+
+	coupon.UpdatedAt = time.Now()
+
+	result, err := r.db.Exec(query,
+		coupon.Code,
+		coupon.Description,
+		coupon.Campaign,
+		coupon.Type,
+		coupon.Percent,
+		coupon.Amount,
+		coupon.BuyQuantity,
+		coupon.GetQuantity,
+		coupon.ProductID,
+		coupon.CategoryID,
+		coupon.MinBasket,
+		coupon.MaxUses,
+		coupon.MaxUsesPerCustomer,
+		coupon.StartsAt,
+		coupon.EndsAt,
+		coupon.IsActive,
+		coupon.UpdatedAt,
+		coupon.ID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update coupon: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("coupon not found")
+	}
+
+	return nil
+}
+
+func (r *CouponRepository) Delete(id uuid.UUID) error {
+	query := `DELETE FROM coupons WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete coupon: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("coupon not found")
+	}
+
+	return nil
+}
+
+// IsUsed reports whether a coupon has ever been redeemed, reversed or not
+func (r *CouponRepository) IsUsed(id uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM coupon_redemptions WHERE coupon_id = $1)`
+
+	var used bool
+	if err := r.db.QueryRow(query, id).Scan(&used); err != nil {
+		return false, fmt.Errorf("failed to check coupon usage: %w", err)
+	}
+
+	return used, nil
+}
+
+// Lock reads a coupon and locks its row until tx ends, so that concurrent
+// redemptions of the same coupon are checked one after another
+func (r *CouponRepository) Lock(tx *sql.Tx, id uuid.UUID) (*models.Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE id = $1 FOR UPDATE`
+
+	coupon, err := scanCoupon(tx.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("coupon not found")
+		}
+		return nil, fmt.Errorf("failed to lock coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+// CountCustomerRedemptionsTx returns how many times a customer has redeemed a
+// coupon, not counting reversed redemptions
+func (r *CouponRepository) CountCustomerRedemptionsTx(tx *sql.Tx, couponID, customerID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = $1 AND customer_id = $2 AND reversed_at IS NULL`
+
+	var count int
+	if err := tx.QueryRow(query, couponID, customerID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count coupon redemptions: %w", err)
+	}
+
+	return count, nil
+}
+
+// RedeemTx records a redemption and counts it against the coupon inside tx.
+// The count is only raised while it is below the coupon's limit, so the
+// limit holds even without the row lock.
+func (r *CouponRepository) RedeemTx(tx *sql.Tx, redemption *models.CouponRedemption) error {
+	result, err := tx.Exec(`
+		UPDATE coupons
+		SET times_used = times_used + 1
+		WHERE id = $1 AND (max_uses IS NULL OR times_used < max_uses)
+	`, redemption.CouponID)
+	if err != nil {
+		return fmt.Errorf("failed to redeem coupon: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrCouponUsedUp, redemption.Code)
+	}
+
+	query := `
+		INSERT INTO coupon_redemptions (id, coupon_id, order_id, customer_id, code, amount, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	redemption.ID = uuid.New()
+	redemption.CreatedAt = time.Now()
+
+	_, err = tx.Exec(query,
+		redemption.ID,
+		redemption.CouponID,
+		redemption.OrderID,
+		redemption.CustomerID,
+		redemption.Code,
+		redemption.Amount,
+		redemption.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create coupon redemption: %w", err)
+	}
+
+	return nil
+}
+
+// ReverseByOrderIDTx reverses every redemption of an order that has not been
+// reversed yet and gives the uses back to their coupons
+func (r *CouponRepository) ReverseByOrderIDTx(tx *sql.Tx, orderID uuid.UUID) error {
+	query := `
+		WITH reversed AS (
+			UPDATE coupon_redemptions
+			SET reversed_at = $2
+			WHERE order_id = $1 AND reversed_at IS NULL
+			RETURNING coupon_id
+		)
+		UPDATE coupons c
+		SET times_used = c.times_used - r.uses
+		FROM (SELECT coupon_id, COUNT(*) AS uses FROM reversed GROUP BY coupon_id) r
+		WHERE c.id = r.coupon_id
+	`
+
+	if _, err := tx.Exec(query, orderID, time.Now()); err != nil {
+		return fmt.Errorf("failed to reverse coupon redemptions: %w", err)
+	}
+
+	return nil
+}
+
+// GetByOrderID returns the coupons redeemed on an order
+func (r *CouponRepository) GetByOrderID(orderID uuid.UUID) ([]models.CouponRedemption, error) {
+	query := `
+		SELECT id, coupon_id, order_id, customer_id, code, amount, reversed_at, created_at
+		FROM coupon_redemptions
+		WHERE order_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coupon redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	var redemptions []models.CouponRedemption
+	for rows.Next() {
+		var redemption models.CouponRedemption
+
+		err := rows.Scan(
+			&redemption.ID,
+			&redemption.CouponID,
+			&redemption.OrderID,
+			&redemption.CustomerID,
+			&redemption.Code,
+			&redemption.Amount,
+			&redemption.ReversedAt,
+			&redemption.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan coupon redemption: %w", err)
+		}
+
+		redemptions = append(redemptions, redemption)
+	}
+
+	return redemptions, nil
+}
+
+func scanCoupon(row scanner) (*models.Coupon, error) {
+	coupon := &models.Coupon{}
+
+	err := row.Scan(
+		&coupon.ID,
+		&coupon.Code,
+		&coupon.Description,
+		&coupon.Campaign,
+		&coupon.Type,
+		&coupon.Percent,
+		&coupon.Amount,
+		&coupon.BuyQuantity,
+		&coupon.GetQuantity,
+		&coupon.ProductID,
+		&coupon.CategoryID,
+		&coupon.MinBasket,
+		&coupon.MaxUses,
+		&coupon.MaxUsesPerCustomer,
+		&coupon.TimesUsed,
+		&coupon.StartsAt,
+		&coupon.EndsAt,
+		&coupon.IsActive,
+		&coupon.CreatedAt,
+		&coupon.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return coupon, nil
+}
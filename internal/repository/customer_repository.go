@@ -19,7 +19,14 @@ func NewCustomerRepository(db *database.DB) *CustomerRepository {
 	return &CustomerRepository{db: db}
 }
 
-func (r *CustomerRepository) Create(customer *models.Customer) error {
+// WithTx runs fn inside a database transaction, so that customer changes can
+// be audited together with them
+func (r *CustomerRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// CreateTx inserts a customer inside tx
+func (r *CustomerRepository) CreateTx(tx *sql.Tx, customer *models.Customer) error {
 	query := `
 		INSERT INTO customers (id, name, email, phone, address, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
@@ -30,7 +37,7 @@ func (r *CustomerRepository) Create(customer *models.Customer) error {
 	customer.CreatedAt = now
 	customer.UpdatedAt = now
 
-	_, err := r.db.Exec(query,
+	_, err := tx.Exec(query,
 		customer.ID,
 		customer.Name,
 		customer.Email,
@@ -48,10 +55,19 @@ func (r *CustomerRepository) Create(customer *models.Customer) error {
 }
 
 func (r *CustomerRepository) GetByID(id uuid.UUID) (*models.Customer, error) {
-	query := `SELECT * FROM customers WHERE id = $1`
+	return r.getByID(r.db, id, "")
+}
+
+// Lock locks a customer for the rest of tx and returns it
+func (r *CustomerRepository) Lock(tx *sql.Tx, id uuid.UUID) (*models.Customer, error) {
+	return r.getByID(tx, id, " FOR UPDATE")
+}
+
+func (r *CustomerRepository) getByID(q querier, id uuid.UUID, lock string) (*models.Customer, error) {
+	query := `SELECT * FROM customers WHERE id = $1` + lock
 
 	var customer models.Customer
-	err := r.db.QueryRow(query, id).Scan(
+	err := q.QueryRow(query, id).Scan(
 		&customer.ID,
 		&customer.Name,
 		&customer.Email,
@@ -127,7 +143,8 @@ func (r *CustomerRepository) GetAll() ([]models.Customer, error) {
 	return customers, nil
 }
 
-func (r *CustomerRepository) Update(customer *models.Customer) error {
+// UpdateTx saves a customer inside tx
+func (r *CustomerRepository) UpdateTx(tx *sql.Tx, customer *models.Customer) error {
 	query := `
 		UPDATE customers 
 		SET name = $1, email = $2, phone = $3, address = $4, updated_at = $5
@@ -136,7 +153,7 @@ func (r *CustomerRepository) Update(customer *models.Customer) error {
 
 	customer.UpdatedAt = time.Now()
 
-	result, err := r.db.Exec(query,
+	result, err := tx.Exec(query,
 		customer.Name,
 		customer.Email,
 		customer.Phone,
@@ -161,10 +178,11 @@ func (r *CustomerRepository) Update(customer *models.Customer) error {
 	return nil
 }
 
-func (r *CustomerRepository) Delete(id uuid.UUID) error {
+// DeleteTx deletes a customer inside tx
+func (r *CustomerRepository) DeleteTx(tx *sql.Tx, id uuid.UUID) error {
 	query := `DELETE FROM customers WHERE id = $1`
 
-	result, err := r.db.Exec(query, id)
+	result, err := tx.Exec(query, id)
 	if err != nil {
 		return err
 	}
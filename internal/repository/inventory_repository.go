@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,6 +12,14 @@ import (
 	"github.com/google/uuid"
 )
 
+// ErrInsufficientStock is returned when a stock movement would take a
+// location below zero and backorders are not allowed
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrUnknownLocation is returned for a stock movement at a location that does
+// not exist
+var ErrUnknownLocation = errors.New("unknown location")
+
 type InventoryRepository struct {
 	db *database.DB
 }
@@ -19,31 +28,9 @@ func NewInventoryRepository(db *database.DB) *InventoryRepository {
 	return &InventoryRepository{db: db}
 }
 
-func (r *InventoryRepository) Create(inventory *models.Inventory) error {
-	query := `
-		INSERT INTO inventory (id, product_id, quantity, location, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`
-
-	now := time.Now()
-	inventory.ID = uuid.New()
-	inventory.CreatedAt = now
-	inventory.UpdatedAt = now
-
-	_, err := r.db.Exec(query,
-		inventory.ID,
-		inventory.ProductID,
-		inventory.Quantity,
-		inventory.Location,
-		inventory.CreatedAt,
-		inventory.UpdatedAt,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to create inventory: %w", err)
-	}
-
-	return nil
+// WithTx runs fn inside a database transaction
+func (r *InventoryRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
 }
 
 func (r *InventoryRepository) GetByID(id uuid.UUID) (*models.Inventory, error) {
@@ -134,42 +121,11 @@ func (r *InventoryRepository) GetAll() ([]*models.Inventory, error) {
 	return inventories, nil
 }
 
-func (r *InventoryRepository) Update(inventory *models.Inventory) error {
-	query := `
-		UPDATE inventory 
-		SET quantity = $1, location = $2, updated_at = $3
-		WHERE id = $4
-	`
-
-	inventory.UpdatedAt = time.Now()
-
-	result, err := r.db.Exec(query,
-		inventory.Quantity,
-		inventory.Location,
-		inventory.UpdatedAt,
-		inventory.ID,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to update inventory: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("inventory not found")
-	}
-
-	return nil
-}
-
-func (r *InventoryRepository) Delete(id uuid.UUID) error {
+// Delete deletes an inventory row inside tx. Its ledger rows are kept.
+func (r *InventoryRepository) Delete(tx *sql.Tx, id uuid.UUID) error {
 	query := `DELETE FROM inventory WHERE id = $1`
 
-	result, err := r.db.Exec(query, id)
+	result, err := tx.Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete inventory: %w", err)
 	}
@@ -235,36 +191,9 @@ func (r *InventoryRepository) GetByProductID(productID uuid.UUID) ([]*models.Inv
 	return inventories, nil
 }
 
-func (r *InventoryRepository) CreateTransaction(transaction *models.InventoryTransaction) error {
-	query := `
-		INSERT INTO inventory_transactions (id, product_id, type, quantity, reason, reference, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`
-
-	now := time.Now()
-	transaction.ID = uuid.New()
-	transaction.CreatedAt = now
-
-	_, err := r.db.Exec(query,
-		transaction.ID,
-		transaction.ProductID,
-		transaction.Type,
-		transaction.Quantity,
-		transaction.Reason,
-		transaction.Reference,
-		transaction.CreatedAt,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to create inventory transaction: %w", err)
-	}
-
-	return nil
-}
-
 func (r *InventoryRepository) GetTransactionsByProductID(productID uuid.UUID) ([]*models.InventoryTransaction, error) {
 	query := `
-		SELECT it.id, it.product_id, it.type, it.quantity, it.reason, it.reference, it.created_at,
+		SELECT it.id, it.product_id, it.type, it.quantity, it.reason, it.reference, COALESCE(it.location, ''), it.purchase_order_line_id, it.created_at,
 		       p.id, p.name, p.description, p.sku, p.category_id, p.price, p.created_at, p.updated_at
 		FROM inventory_transactions it
 		LEFT JOIN products p ON it.product_id = p.id
@@ -290,6 +219,8 @@ func (r *InventoryRepository) GetTransactionsByProductID(productID uuid.UUID) ([
 			&transaction.Quantity,
 			&transaction.Reason,
 			&transaction.Reference,
+			&transaction.Location,
+			&transaction.PurchaseOrderLineID,
 			&transaction.CreatedAt,
 			&product.ID,
 			&product.Name,
@@ -361,48 +292,124 @@ func (r *InventoryRepository) GetByProductIDString(productID string) ([]*models.
 	return inventories, nil
 }
 
-func (r *InventoryRepository) CreateTransactionString(transaction *models.InventoryTransaction) error {
-	query := `
-		INSERT INTO inventory_transactions (id, product_id, type, quantity, reason, reference, created_at)
-		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
-	`
+// DeductStock lowers the stock of a product inside tx and records an "out"
+// transaction for it. When location is empty the location holding the most
+// stock is used. With allowNegative, a product that has never been stocked
+// there, or anywhere when location is empty, is backordered at location or
+// the default location. The inventory row is locked for the rest of the
+// transaction.
+func (r *InventoryRepository) DeductStock(tx *sql.Tx, productID, location string, quantity int, allowNegative bool, reason, reference string) (*models.InventoryTransaction, error) {
+	if location != "" {
+		if err := r.checkLocation(tx, location); err != nil {
+			return nil, err
+		}
+	}
 
-	_, err := r.db.Exec(query,
-		transaction.ProductID,
-		transaction.Type,
-		transaction.Quantity,
-		transaction.Reason,
-		transaction.Reference,
-	)
+	lockQuery := `
+		SELECT location, quantity FROM inventory
+		WHERE product_id = $1 AND ($2 = '' OR location = $2)
+		ORDER BY quantity DESC, location ASC
+		LIMIT 1
+		FOR UPDATE
+	`
 
+	var current int
+	err := tx.QueryRow(lockQuery, productID, location).Scan(&location, &current)
 	if err != nil {
-		return fmt.Errorf("failed to create inventory transaction: %w", err)
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to lock inventory: %w", err)
+		}
+		if !allowNegative {
+			return nil, fmt.Errorf("%w: no inventory found for product %s", ErrInsufficientStock, productID)
+		}
+		// Backorders against a location that has never been stocked
+		if location == "" {
+			if location, err = r.defaultLocation(tx); err != nil {
+				return nil, err
+			}
+		}
+		inventory, _, err := r.LockOrCreate(tx, productID, location)
+		if err != nil {
+			return nil, err
+		}
+		current = inventory.Quantity
 	}
 
-	return nil
+	if current < quantity && !allowNegative {
+		return nil, fmt.Errorf("%w: product %s has %d at %s, trying to remove %d", ErrInsufficientStock, productID, current, location, quantity)
+	}
+
+	if err := r.changeQuantity(tx, productID, location, -quantity); err != nil {
+		return nil, err
+	}
+
+	transaction := &models.InventoryTransaction{
+		ProductID: productID,
+		Type:      "out",
+		Quantity:  quantity,
+		Reason:    reason,
+		Reference: reference,
+		Location:  location,
+	}
+
+	if err := r.createTransaction(tx, transaction); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// ReturnStock raises the stock of a product at a location inside tx and
+// records an "in" transaction for it
+func (r *InventoryRepository) ReturnStock(tx *sql.Tx, productID, location string, quantity int, reason, reference string) (*models.InventoryTransaction, error) {
+	if err := r.checkLocation(tx, location); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.insertEmpty(tx, productID, location); err != nil {
+		return nil, err
+	}
+
+	if err := r.changeQuantity(tx, productID, location, quantity); err != nil {
+		return nil, err
+	}
+
+	transaction := &models.InventoryTransaction{
+		ProductID: productID,
+		Type:      "in",
+		Quantity:  quantity,
+		Reason:    reason,
+		Reference: reference,
+		Location:  location,
+	}
+
+	if err := r.createTransaction(tx, transaction); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
 }
 
-func (r *InventoryRepository) GetTransactionsByProductIDString(productID string) ([]*models.InventoryTransaction, error) {
+// GetDeductionsTx returns the "out" transactions recorded with reason for
+// reference, such as the stock taken for an order, in the order they were
+// written
+func (r *InventoryRepository) GetDeductionsTx(tx *sql.Tx, reason, reference string) ([]models.InventoryTransaction, error) {
 	query := `
-		SELECT it.id, it.product_id, it.type, it.quantity, it.reason, it.reference, it.created_at,
-		       p.id, p.name, p.description, p.sku, p.category_id, p.price, p.created_at, p.updated_at
-		FROM inventory_transactions it
-		LEFT JOIN products p ON it.product_id = p.id
-		WHERE it.product_id = $1
-		ORDER BY it.created_at DESC
+		SELECT id, product_id, type, quantity, reason, reference, COALESCE(location, ''), purchase_order_line_id, created_at
+		FROM inventory_transactions
+		WHERE type = 'out' AND reason = $1 AND reference = $2
+		ORDER BY seq ASC
 	`
 
-	rows, err := r.db.Query(query, productID)
+	rows, err := tx.Query(query, reason, reference)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query inventory transactions: %w", err)
 	}
 	defer rows.Close()
 
-	var transactions []*models.InventoryTransaction
+	var transactions []models.InventoryTransaction
 	for rows.Next() {
-		transaction := &models.InventoryTransaction{}
-		var product models.Product
-
+		var transaction models.InventoryTransaction
 		err := rows.Scan(
 			&transaction.ID,
 			&transaction.ProductID,
@@ -410,24 +417,325 @@ func (r *InventoryRepository) GetTransactionsByProductIDString(productID string)
 			&transaction.Quantity,
 			&transaction.Reason,
 			&transaction.Reference,
+			&transaction.Location,
+			&transaction.PurchaseOrderLineID,
 			&transaction.CreatedAt,
-			&product.ID,
-			&product.Name,
-			&product.Description,
-			&product.SKU,
-			&product.CategoryID,
-			&product.Price,
-			&product.CreatedAt,
-			&product.UpdatedAt,
 		)
-
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan inventory transaction: %w", err)
 		}
-
-		transaction.Product = &product
 		transactions = append(transactions, transaction)
 	}
 
-	return transactions, nil
+	return transactions, rows.Err()
+}
+
+// Lock locks the inventory row of a product at a location for the rest of tx
+// and returns it. It returns nil when the product has never been stocked
+// there.
+func (r *InventoryRepository) Lock(tx *sql.Tx, productID, location string) (*models.Inventory, error) {
+	query := `
+		SELECT id, product_id, quantity, location, created_at, updated_at
+		FROM inventory
+		WHERE product_id = $1 AND location = $2
+		FOR UPDATE
+	`
+
+	inventory := &models.Inventory{}
+	err := tx.QueryRow(query, productID, location).Scan(
+		&inventory.ID,
+		&inventory.ProductID,
+		&inventory.Quantity,
+		&inventory.Location,
+		&inventory.CreatedAt,
+		&inventory.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock inventory: %w", err)
+	}
+
+	return inventory, nil
+}
+
+// LockOrCreate locks the inventory row of a product at a location for the
+// rest of tx, first creating it empty when the product has never been
+// stocked there. created reports whether it was.
+func (r *InventoryRepository) LockOrCreate(tx *sql.Tx, productID, location string) (inventory *models.Inventory, created bool, err error) {
+	if err := r.checkLocation(tx, location); err != nil {
+		return nil, false, err
+	}
+
+	// A concurrent transaction creating the same row makes this one wait
+	// for it, and then find the row already there
+	if created, err = r.insertEmpty(tx, productID, location); err != nil {
+		return nil, false, err
+	}
+
+	if inventory, err = r.Lock(tx, productID, location); err != nil {
+		return nil, false, err
+	}
+	if inventory == nil {
+		return nil, false, fmt.Errorf("inventory not found")
+	}
+
+	return inventory, created, nil
+}
+
+// Move changes the quantity of an inventory row locked in tx by delta and
+// records transaction for it in the ledger, so that the two are only ever
+// kept together
+func (r *InventoryRepository) Move(tx *sql.Tx, inventory *models.Inventory, delta int, transaction *models.InventoryTransaction) error {
+	query := `
+		UPDATE inventory
+		SET quantity = quantity + $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING quantity, updated_at
+	`
+
+	err := tx.QueryRow(query, delta, inventory.ID).Scan(&inventory.Quantity, &inventory.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("inventory not found")
+		}
+		return fmt.Errorf("failed to update inventory: %w", err)
+	}
+
+	transaction.ProductID = inventory.ProductID
+	transaction.Location = inventory.Location
+
+	return r.createTransaction(tx, transaction)
+}
+
+// ledgerLevels works out the stock of each product at each location from the
+// ledger, replaying its rows in the order they were written: "initial" and
+// "adjustment" rows set the stock to their quantity, "in" and "out" rows move
+// it. Only rows written up to $1 count unless it is null, and $2 and $3
+// narrow it to a product and a location unless they are empty. Rows from
+// before stock had locations are left out.
+const ledgerLevels = `
+	SELECT product_id, location,
+	       SUM(CASE WHEN type = 'out' THEN -quantity ELSE quantity END) AS quantity
+	FROM (
+		SELECT product_id, location, type, quantity, seq,
+		       MAX(CASE WHEN type IN ('initial', 'adjustment') THEN seq END)
+		           OVER (PARTITION BY product_id, location) AS set_seq
+		FROM inventory_transactions
+		WHERE location IS NOT NULL
+		  AND ($1::timestamptz IS NULL OR created_at <= $1)
+		  AND ($2 = '' OR product_id = NULLIF($2, '')::uuid)
+		  AND ($3 = '' OR location = $3)
+	) ledger
+	WHERE set_seq IS NULL OR seq >= set_seq
+	GROUP BY product_id, location
+`
+
+// GetLevels returns the stock of each product at each location according to
+// the ledger as it stood at the given time, or now when it is nil. productID
+// and location narrow it down unless they are empty.
+func (r *InventoryRepository) GetLevels(at *time.Time, productID, location string) ([]models.StockLevel, error) {
+	query := ledgerLevels + ` ORDER BY location ASC, product_id ASC`
+
+	rows, err := r.db.Query(query, at, productID, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []models.StockLevel{}
+	for rows.Next() {
+		var level models.StockLevel
+		if err := rows.Scan(&level.ProductID, &level.Location, &level.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan stock level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, rows.Err()
+}
+
+// GetMismatches compares every inventory quantity with the ledger. It returns
+// how many products at locations it checked and those whose quantity differs.
+// A product at a location missing from either side counts as zero there.
+func (r *InventoryRepository) GetMismatches() (int, []models.StockMismatch, error) {
+	return r.getMismatches(r.db)
+}
+
+// GetMismatchesTx is GetMismatches inside tx
+func (r *InventoryRepository) GetMismatchesTx(tx *sql.Tx) (int, []models.StockMismatch, error) {
+	return r.getMismatches(tx)
+}
+
+func (r *InventoryRepository) getMismatches(q querier) (int, []models.StockMismatch, error) {
+	query := `
+		WITH ledger AS (` + ledgerLevels + `)
+		SELECT COALESCE(i.product_id, l.product_id), COALESCE(i.location, l.location),
+		       COALESCE(i.quantity, 0), COALESCE(l.quantity, 0)
+		FROM inventory i
+		FULL JOIN ledger l ON l.product_id = i.product_id AND l.location = i.location
+		ORDER BY 2, 1
+	`
+
+	rows, err := q.Query(query, nil, "", "")
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reconcile inventory: %w", err)
+	}
+	defer rows.Close()
+
+	checked := 0
+	mismatches := []models.StockMismatch{}
+	for rows.Next() {
+		var mismatch models.StockMismatch
+		if err := rows.Scan(&mismatch.ProductID, &mismatch.Location, &mismatch.Quantity, &mismatch.LedgerQuantity); err != nil {
+			return 0, nil, fmt.Errorf("failed to scan stock level: %w", err)
+		}
+
+		checked++
+		if mismatch.Quantity != mismatch.LedgerQuantity {
+			mismatch.Difference = mismatch.Quantity - mismatch.LedgerQuantity
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+
+	return checked, mismatches, rows.Err()
+}
+
+// LockAll keeps every other transaction from moving stock until tx ends. It
+// waits for those moving stock already to finish.
+func (r *InventoryRepository) LockAll(tx *sql.Tx) error {
+	if _, err := tx.Exec(`LOCK TABLE inventory IN EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("failed to lock inventory: %w", err)
+	}
+
+	return nil
+}
+
+// SetQuantity sets the quantity of an inventory row locked in tx without
+// writing to the ledger. It is only for rebuilding quantities from the
+// ledger; every other change goes through Move.
+func (r *InventoryRepository) SetQuantity(tx *sql.Tx, inventory *models.Inventory, quantity int) error {
+	query := `
+		UPDATE inventory
+		SET quantity = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING quantity, updated_at
+	`
+
+	err := tx.QueryRow(query, quantity, inventory.ID).Scan(&inventory.Quantity, &inventory.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("inventory not found")
+		}
+		return fmt.Errorf("failed to update inventory: %w", err)
+	}
+
+	return nil
+}
+
+// checkLocation returns ErrUnknownLocation unless the location exists
+func (r *InventoryRepository) checkLocation(q querier, location string) error {
+	query := `SELECT EXISTS (SELECT 1 FROM locations WHERE name = $1)`
+
+	var exists bool
+	if err := q.QueryRow(query, location).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check location: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w %q", ErrUnknownLocation, location)
+	}
+
+	return nil
+}
+
+// defaultLocation returns the location stock is backordered at when none is
+// given: the oldest active location
+func (r *InventoryRepository) defaultLocation(q querier) (string, error) {
+	query := `SELECT name FROM locations WHERE is_active ORDER BY created_at ASC, name ASC LIMIT 1`
+
+	var location string
+	if err := q.QueryRow(query).Scan(&location); err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("%w: there are no active locations", ErrUnknownLocation)
+		}
+		return "", fmt.Errorf("failed to get default location: %w", err)
+	}
+
+	return location, nil
+}
+
+// insertEmpty creates an empty inventory row for a product at a location
+// unless there is one already, and reports whether it did
+func (r *InventoryRepository) insertEmpty(q querier, productID, location string) (bool, error) {
+	query := `
+		INSERT INTO inventory (id, product_id, quantity, location, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, 0, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (product_id, location) DO NOTHING
+	`
+
+	result, err := q.Exec(query, productID, location)
+	if err != nil {
+		return false, fmt.Errorf("failed to create inventory: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *InventoryRepository) changeQuantity(q querier, productID, location string, delta int) error {
+	query := `
+		UPDATE inventory
+		SET quantity = quantity + $1, updated_at = CURRENT_TIMESTAMP
+		WHERE product_id = $2 AND location = $3
+	`
+
+	result, err := q.Exec(query, delta, productID, location)
+	if err != nil {
+		return fmt.Errorf("failed to update inventory: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("inventory not found")
+	}
+
+	return nil
+}
+
+func (r *InventoryRepository) createTransaction(q querier, transaction *models.InventoryTransaction) error {
+	query := `
+		INSERT INTO inventory_transactions (id, product_id, type, quantity, reason, reference, location, purchase_order_line_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9)
+	`
+
+	transaction.ID = uuid.New()
+	transaction.CreatedAt = time.Now()
+
+	_, err := q.Exec(query,
+		transaction.ID,
+		transaction.ProductID,
+		transaction.Type,
+		transaction.Quantity,
+		transaction.Reason,
+		transaction.Reference,
+		transaction.Location,
+		transaction.PurchaseOrderLineID,
+		transaction.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create inventory transaction: %w", err)
+	}
+
+	return nil
 }
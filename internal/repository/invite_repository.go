@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type InviteRepository struct {
+	db *database.DB
+}
+
+func NewInviteRepository(db *database.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+const inviteColumns = `id, email, role, invited_by, expires_at, accepted_at, user_id, created_at`
+
+// WithTx runs fn inside a database transaction
+func (r *InviteRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// Create stores an invite under the hash of its token
+func (r *InviteRepository) Create(invite *models.Invite, tokenHash string) error {
+	query := `
+		INSERT INTO user_invites (id, email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	invite.ID = uuid.New()
+	invite.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(query,
+		invite.ID,
+		invite.Email,
+		invite.Role,
+		tokenHash,
+		invite.InvitedBy,
+		invite.ExpiresAt,
+		invite.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	return nil
+}
+
+// GetAll returns every invite, newest first
+func (r *InviteRepository) GetAll() ([]models.Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM user_invites ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []models.Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invite: %w", err)
+		}
+		invites = append(invites, *invite)
+	}
+
+	return invites, nil
+}
+
+// LockByTokenHash locks the invite with the given token hash for the rest of
+// tx. It returns nil when there is no such invite.
+func (r *InviteRepository) LockByTokenHash(tx *sql.Tx, tokenHash string) (*models.Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM user_invites WHERE token_hash = $1 FOR UPDATE`
+
+	invite, err := scanInvite(tx.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+// AcceptTx marks an invite as redeemed by the user created with it
+func (r *InviteRepository) AcceptTx(tx *sql.Tx, invite *models.Invite, userID uuid.UUID) error {
+	query := `UPDATE user_invites SET accepted_at = $1, user_id = $2 WHERE id = $3`
+
+	now := time.Now()
+	if _, err := tx.Exec(query, now, userID, invite.ID); err != nil {
+		return fmt.Errorf("failed to accept invite: %w", err)
+	}
+
+	invite.AcceptedAt = &now
+	invite.UserID = &userID
+	return nil
+}
+
+// DeletePendingByEmail removes the invites for an email that have not been
+// accepted yet, so that only the newest invite can be used
+func (r *InviteRepository) DeletePendingByEmail(email string) error {
+	query := `DELETE FROM user_invites WHERE LOWER(email) = LOWER($1) AND accepted_at IS NULL`
+
+	if _, err := r.db.Exec(query, email); err != nil {
+		return fmt.Errorf("failed to delete pending invites: %w", err)
+	}
+
+	return nil
+}
+
+// Delete revokes an invite that has not been accepted yet
+func (r *InviteRepository) Delete(id uuid.UUID) error {
+	query := `DELETE FROM user_invites WHERE id = $1 AND accepted_at IS NULL`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete invite: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("invite not found")
+	}
+
+	return nil
+}
+
+func scanInvite(row scanner) (*models.Invite, error) {
+	invite := &models.Invite{}
+
+	err := row.Scan(
+		&invite.ID,
+		&invite.Email,
+		&invite.Role,
+		&invite.InvitedBy,
+		&invite.ExpiresAt,
+		&invite.AcceptedAt,
+		&invite.UserID,
+		&invite.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
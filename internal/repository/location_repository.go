@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+)
+
+type LocationRepository struct {
+	db *database.DB
+}
+
+func NewLocationRepository(db *database.DB) *LocationRepository {
+	return &LocationRepository{db: db}
+}
+
+// WithTx runs fn inside a database transaction, so that location changes can
+// be audited together with them
+func (r *LocationRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+const locationColumns = `name, COALESCE(description, ''), is_active, created_at, updated_at`
+
+// CreateTx inserts an active location inside tx
+func (r *LocationRepository) CreateTx(tx *sql.Tx, location *models.Location) error {
+	query := `
+		INSERT INTO locations (name, description, is_active, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), true, $3, $4)
+	`
+
+	now := time.Now()
+	location.IsActive = true
+	location.CreatedAt = now
+	location.UpdatedAt = now
+
+	_, err := tx.Exec(query, location.Name, location.Description, location.CreatedAt, location.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create location: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateTx replaces the description of a location and whether it is active
+// inside tx
+func (r *LocationRepository) UpdateTx(tx *sql.Tx, location *models.Location) error {
+	query := `UPDATE locations SET description = NULLIF($1, ''), is_active = $2, updated_at = $3 WHERE name = $4`
+
+	location.UpdatedAt = time.Now()
+
+	result, err := tx.Exec(query, location.Description, location.IsActive, location.UpdatedAt, location.Name)
+	if err != nil {
+		return fmt.Errorf("failed to update location: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("location not found")
+	}
+
+	return nil
+}
+
+// DeleteTx deletes a location inside tx
+func (r *LocationRepository) DeleteTx(tx *sql.Tx, name string) error {
+	query := `DELETE FROM locations WHERE name = $1`
+
+	result, err := tx.Exec(query, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete location: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("location not found")
+	}
+
+	return nil
+}
+
+func (r *LocationRepository) GetByName(name string) (*models.Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM locations WHERE name = $1`
+
+	location, err := scanLocation(r.db.QueryRow(query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("location not found")
+		}
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+
+	return location, nil
+}
+
+// Lock locks a location for the rest of tx and returns it. Stock cannot be
+// put at it until tx ends.
+func (r *LocationRepository) Lock(tx *sql.Tx, name string) (*models.Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM locations WHERE name = $1 FOR UPDATE`
+
+	location, err := scanLocation(tx.QueryRow(query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("location not found")
+		}
+		return nil, fmt.Errorf("failed to lock location: %w", err)
+	}
+
+	return location, nil
+}
+
+// GetAll returns every location, active ones first
+func (r *LocationRepository) GetAll() ([]models.Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM locations ORDER BY is_active DESC, name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query locations: %w", err)
+	}
+	defer rows.Close()
+
+	locations := []models.Location{}
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan location: %w", err)
+		}
+		locations = append(locations, *location)
+	}
+
+	return locations, nil
+}
+
+// Exists reports whether there is a location with the given name
+func (r *LocationRepository) Exists(name string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM locations WHERE name = $1)`
+
+	var exists bool
+	if err := r.db.QueryRow(query, name).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check location: %w", err)
+	}
+
+	return exists, nil
+}
+
+// CountUsesTx returns how many inventory rows, ledger entries, transfers
+// and purchase orders name a location inside tx
+func (r *LocationRepository) CountUsesTx(tx *sql.Tx, name string) (int, error) {
+	query := `
+		SELECT (SELECT COUNT(*) FROM inventory WHERE location = $1)
+			+ (SELECT COUNT(*) FROM inventory_transactions WHERE location = $1)
+			+ (SELECT COUNT(*) FROM stock_transfers WHERE from_location = $1 OR to_location = $1)
+			+ (SELECT COUNT(*) FROM purchase_orders WHERE location = $1)
+	`
+
+	var count int
+	if err := tx.QueryRow(query, name).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count location uses: %w", err)
+	}
+
+	return count, nil
+}
+
+func scanLocation(row scanner) (*models.Location, error) {
+	location := &models.Location{}
+
+	err := row.Scan(
+		&location.Name,
+		&location.Description,
+		&location.IsActive,
+		&location.CreatedAt,
+		&location.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return location, nil
+}
@@ -20,16 +20,18 @@ func NewOrderRepository(db *database.DB) *OrderRepository {
 }
 
 func (r *OrderRepository) Create(order *models.Order) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
+	return r.db.WithTx(func(tx *sql.Tx) error {
+		return r.CreateTx(tx, order)
+	})
+}
 
+// CreateTx inserts the order and its items inside tx
+func (r *OrderRepository) CreateTx(tx *sql.Tx, order *models.Order) error {
 	// Insert order
 	orderQuery := `
-		INSERT INTO orders (id, order_number, customer_id, status, subtotal, tax_amount, discount_amount, total_amount, payment_status, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO orders (id, order_number, customer_id, status, subtotal, tax_amount, discount_amount, total_amount, payment_status, prices_include_tax, tax_overridden, notes,
+			created_by, approved_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`
 
 	now := time.Now()
@@ -37,7 +39,7 @@ func (r *OrderRepository) Create(order *models.Order) error {
 	order.CreatedAt = now
 	order.UpdatedAt = now
 
-	_, err = tx.Exec(orderQuery,
+	_, err := tx.Exec(orderQuery,
 		order.ID,
 		order.OrderNumber,
 		order.CustomerID,
@@ -47,7 +49,11 @@ func (r *OrderRepository) Create(order *models.Order) error {
 		order.DiscountAmount,
 		order.TotalAmount,
 		order.PaymentStatus,
+		order.PricesIncludeTax,
+		order.TaxOverridden,
 		order.Notes,
+		order.CreatedBy,
+		order.ApprovedBy,
 		order.CreatedAt,
 		order.UpdatedAt,
 	)
@@ -60,8 +66,8 @@ func (r *OrderRepository) Create(order *models.Order) error {
 	for i := range order.Items {
 		item := &order.Items[i]
 		itemQuery := `
-			INSERT INTO order_items (id, order_id, product_id, quantity, unit_price, discount, total_price, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			INSERT INTO order_items (id, order_id, product_id, quantity, unit_price, discount, total_price, tax_class_id, tax_rate, tax_amount, price_overridden, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		`
 
 		item.ID = uuid.New()
@@ -76,6 +82,10 @@ func (r *OrderRepository) Create(order *models.Order) error {
 			item.UnitPrice,
 			item.Discount,
 			item.TotalPrice,
+			item.TaxClassID,
+			item.TaxRate,
+			item.TaxAmount,
+			item.PriceOverridden,
 			item.CreatedAt,
 		)
 
@@ -84,13 +94,14 @@ func (r *OrderRepository) Create(order *models.Order) error {
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 func (r *OrderRepository) GetByID(id uuid.UUID) (*models.Order, error) {
 	// Get order with customer
 	orderQuery := `
-		SELECT o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.notes, o.created_at, o.updated_at,
+		SELECT o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.prices_include_tax, o.tax_overridden, o.notes,
+		       o.created_by, o.approved_by, o.created_at, o.updated_at,
 		       c.id, c.name, c.email, c.phone, c.address, c.created_at, c.updated_at
 		FROM orders o
 		LEFT JOIN customers c ON o.customer_id = c.id
@@ -110,7 +121,11 @@ func (r *OrderRepository) GetByID(id uuid.UUID) (*models.Order, error) {
 		&order.DiscountAmount,
 		&order.TotalAmount,
 		&order.PaymentStatus,
+		&order.PricesIncludeTax,
+		&order.TaxOverridden,
 		&order.Notes,
+		&order.CreatedBy,
+		&order.ApprovedBy,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 		&customer.ID,
@@ -133,7 +148,7 @@ func (r *OrderRepository) GetByID(id uuid.UUID) (*models.Order, error) {
 
 	// Get order items
 	itemsQuery := `
-		SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.unit_price, oi.discount, oi.total_price, oi.created_at,
+		SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.unit_price, oi.discount, oi.total_price, oi.tax_class_id, oi.tax_rate, oi.tax_amount, oi.price_overridden, oi.created_at,
 		       p.id, p.name, p.description, p.sku, p.category_id, p.price, p.created_at, p.updated_at
 		FROM order_items oi
 		LEFT JOIN products p ON oi.product_id = p.id
@@ -159,6 +174,10 @@ func (r *OrderRepository) GetByID(id uuid.UUID) (*models.Order, error) {
 			&item.UnitPrice,
 			&item.Discount,
 			&item.TotalPrice,
+			&item.TaxClassID,
+			&item.TaxRate,
+			&item.TaxAmount,
+			&item.PriceOverridden,
 			&item.CreatedAt,
 			&product.ID,
 			&product.Name,
@@ -185,7 +204,8 @@ func (r *OrderRepository) GetByID(id uuid.UUID) (*models.Order, error) {
 
 func (r *OrderRepository) GetAll() ([]models.Order, error) {
 	query := `
-		SELECT o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.notes, o.created_at, o.updated_at,
+		SELECT o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.prices_include_tax, o.tax_overridden, o.notes,
+		       o.created_by, o.approved_by, o.created_at, o.updated_at,
 		       c.id, c.name, c.email, c.phone, c.address, c.created_at, c.updated_at
 		FROM orders o
 		LEFT JOIN customers c ON o.customer_id = c.id
@@ -213,7 +233,11 @@ func (r *OrderRepository) GetAll() ([]models.Order, error) {
 			&order.DiscountAmount,
 			&order.TotalAmount,
 			&order.PaymentStatus,
+			&order.PricesIncludeTax,
+			&order.TaxOverridden,
 			&order.Notes,
+			&order.CreatedBy,
+			&order.ApprovedBy,
 			&order.CreatedAt,
 			&order.UpdatedAt,
 			&customer.ID,
@@ -236,10 +260,47 @@ func (r *OrderRepository) GetAll() ([]models.Order, error) {
 	return orders, nil
 }
 
+// WithTx runs fn inside a database transaction
+func (r *OrderRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// Lock locks the order row for the rest of tx and returns its current
+// number, status, payment status and total. Items are not loaded.
+func (r *OrderRepository) Lock(tx *sql.Tx, id uuid.UUID) (*models.Order, error) {
+	query := `SELECT id, order_number, status, payment_status, total_amount FROM orders WHERE id = $1 FOR UPDATE`
+
+	var order models.Order
+	err := tx.QueryRow(query, id).Scan(
+		&order.ID,
+		&order.OrderNumber,
+		&order.Status,
+		&order.PaymentStatus,
+		&order.TotalAmount,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	return &order, nil
+}
+
 func (r *OrderRepository) UpdateStatus(id uuid.UUID, status string) error {
+	return r.updateStatus(r.db, id, status)
+}
+
+// UpdateStatusTx updates the order status inside tx
+func (r *OrderRepository) UpdateStatusTx(tx *sql.Tx, id uuid.UUID, status string) error {
+	return r.updateStatus(tx, id, status)
+}
+
+func (r *OrderRepository) updateStatus(q querier, id uuid.UUID, status string) error {
 	query := `UPDATE orders SET status = $1, updated_at = $2 WHERE id = $3`
 
-	result, err := r.db.Exec(query, status, time.Now(), id)
+	result, err := q.Exec(query, status, time.Now(), id)
 	if err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
@@ -257,9 +318,18 @@ func (r *OrderRepository) UpdateStatus(id uuid.UUID, status string) error {
 }
 
 func (r *OrderRepository) UpdatePaymentStatus(id uuid.UUID, paymentStatus string) error {
+	return r.updatePaymentStatus(r.db, id, paymentStatus)
+}
+
+// UpdatePaymentStatusTx updates the order payment status inside tx
+func (r *OrderRepository) UpdatePaymentStatusTx(tx *sql.Tx, id uuid.UUID, paymentStatus string) error {
+	return r.updatePaymentStatus(tx, id, paymentStatus)
+}
+
+func (r *OrderRepository) updatePaymentStatus(q querier, id uuid.UUID, paymentStatus string) error {
 	query := `UPDATE orders SET payment_status = $1, updated_at = $2 WHERE id = $3`
 
-	result, err := r.db.Exec(query, paymentStatus, time.Now(), id)
+	result, err := q.Exec(query, paymentStatus, time.Now(), id)
 	if err != nil {
 		return fmt.Errorf("failed to update payment status: %w", err)
 	}
@@ -278,7 +348,8 @@ func (r *OrderRepository) UpdatePaymentStatus(id uuid.UUID, paymentStatus string
 
 func (r *OrderRepository) GetByCustomerID(customerID uuid.UUID) ([]models.Order, error) {
 	query := `
-		SELECT o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.notes, o.created_at, o.updated_at,
+		SELECT o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.prices_include_tax, o.tax_overridden, o.notes,
+		       o.created_by, o.approved_by, o.created_at, o.updated_at,
 		       c.id, c.name, c.email, c.phone, c.address, c.created_at, c.updated_at
 		FROM orders o
 		LEFT JOIN customers c ON o.customer_id = c.id
@@ -307,7 +378,11 @@ func (r *OrderRepository) GetByCustomerID(customerID uuid.UUID) ([]models.Order,
 			&order.DiscountAmount,
 			&order.TotalAmount,
 			&order.PaymentStatus,
+			&order.PricesIncludeTax,
+			&order.TaxOverridden,
 			&order.Notes,
+			&order.CreatedBy,
+			&order.ApprovedBy,
 			&order.CreatedAt,
 			&order.UpdatedAt,
 			&customer.ID,
@@ -329,3 +404,71 @@ func (r *OrderRepository) GetByCustomerID(customerID uuid.UUID) ([]models.Order,
 
 	return orders, nil
 }
+
+// AddStatusHistory records a status transition inside tx
+func (r *OrderRepository) AddStatusHistory(tx *sql.Tx, history *models.OrderStatusHistory) error {
+	query := `
+		INSERT INTO order_status_history (id, order_id, from_status, to_status, changed_by, approved_by, reason, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+	`
+
+	history.ID = uuid.New()
+	history.CreatedAt = time.Now()
+
+	_, err := tx.Exec(query,
+		history.ID,
+		history.OrderID,
+		history.FromStatus,
+		history.ToStatus,
+		history.ChangedBy,
+		history.ApprovedBy,
+		history.Reason,
+		history.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create order status history: %w", err)
+	}
+
+	return nil
+}
+
+// GetStatusHistory returns the status transitions of an order, oldest first
+func (r *OrderRepository) GetStatusHistory(orderID uuid.UUID) ([]models.OrderStatusHistory, error) {
+	query := `
+		SELECT id, order_id, COALESCE(from_status, ''), to_status, changed_by, approved_by, COALESCE(reason, ''), created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.OrderStatusHistory
+	for rows.Next() {
+		var entry models.OrderStatusHistory
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.OrderID,
+			&entry.FromStatus,
+			&entry.ToStatus,
+			&entry.ChangedBy,
+			&entry.ApprovedBy,
+			&entry.Reason,
+			&entry.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order status history: %w", err)
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"jatistore/internal/database"
 	"jatistore/internal/models"
+	"jatistore/internal/money"
 
 	"github.com/google/uuid"
 )
@@ -19,24 +20,47 @@ func NewPaymentRepository(db *database.DB) *PaymentRepository {
 	return &PaymentRepository{db: db}
 }
 
+const paymentColumns = `id, order_id, amount, COALESCE(tendered_amount, amount), change_amount, payment_method, reference, status, refund_of, refund_id,
+		shift_id, created_by, approved_by, created_at, updated_at`
+
 func (r *PaymentRepository) Create(payment *models.Payment) error {
+	return r.create(r.db, payment)
+}
+
+// CreateTx inserts a payment inside tx
+func (r *PaymentRepository) CreateTx(tx *sql.Tx, payment *models.Payment) error {
+	return r.create(tx, payment)
+}
+
+func (r *PaymentRepository) create(q querier, payment *models.Payment) error {
 	query := `
-		INSERT INTO payments (id, order_id, amount, payment_method, reference, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO payments (id, order_id, amount, tendered_amount, change_amount, payment_method, reference, status, refund_of, refund_id,
+			shift_id, created_by, approved_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	`
 
 	now := time.Now()
 	payment.ID = uuid.New()
+	if payment.TenderedAmount == 0 {
+		payment.TenderedAmount = payment.Amount
+	}
 	payment.CreatedAt = now
 	payment.UpdatedAt = now
 
-	_, err := r.db.Exec(query,
+	_, err := q.Exec(query,
 		payment.ID,
 		payment.OrderID,
 		payment.Amount,
+		payment.TenderedAmount,
+		payment.ChangeAmount,
 		payment.PaymentMethod,
 		payment.Reference,
 		payment.Status,
+		payment.RefundOf,
+		payment.RefundID,
+		payment.ShiftID,
+		payment.CreatedBy,
+		payment.ApprovedBy,
 		payment.CreatedAt,
 		payment.UpdatedAt,
 	)
@@ -49,20 +73,9 @@ func (r *PaymentRepository) Create(payment *models.Payment) error {
 }
 
 func (r *PaymentRepository) GetByID(id uuid.UUID) (*models.Payment, error) {
-	query := `SELECT * FROM payments WHERE id = $1`
-
-	var payment models.Payment
-	err := r.db.QueryRow(query, id).Scan(
-		&payment.ID,
-		&payment.OrderID,
-		&payment.Amount,
-		&payment.PaymentMethod,
-		&payment.Reference,
-		&payment.Status,
-		&payment.CreatedAt,
-		&payment.UpdatedAt,
-	)
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE id = $1`
 
+	payment, err := scanPayment(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("payment not found")
@@ -70,11 +83,11 @@ func (r *PaymentRepository) GetByID(id uuid.UUID) (*models.Payment, error) {
 		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
 
-	return &payment, nil
+	return payment, nil
 }
 
 func (r *PaymentRepository) GetByOrderID(orderID uuid.UUID) ([]models.Payment, error) {
-	query := `SELECT * FROM payments WHERE order_id = $1 ORDER BY created_at DESC`
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 ORDER BY created_at DESC`
 
 	rows, err := r.db.Query(query, orderID)
 	if err != nil {
@@ -84,23 +97,12 @@ func (r *PaymentRepository) GetByOrderID(orderID uuid.UUID) ([]models.Payment, e
 
 	var payments []models.Payment
 	for rows.Next() {
-		var payment models.Payment
-		err := rows.Scan(
-			&payment.ID,
-			&payment.OrderID,
-			&payment.Amount,
-			&payment.PaymentMethod,
-			&payment.Reference,
-			&payment.Status,
-			&payment.CreatedAt,
-			&payment.UpdatedAt,
-		)
-
+		payment, err := scanPayment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan payment: %w", err)
 		}
 
-		payments = append(payments, payment)
+		payments = append(payments, *payment)
 	}
 
 	return payments, nil
@@ -126,14 +128,87 @@ func (r *PaymentRepository) UpdateStatus(id uuid.UUID, status string) error {
 	return nil
 }
 
-func (r *PaymentRepository) GetTotalPaidByOrderID(orderID uuid.UUID) (float64, error) {
-	query := `SELECT COALESCE(SUM(amount), 0) FROM payments WHERE order_id = $1 AND status = 'completed'`
+// GetTotalPaidByOrderID returns the net amount paid for an order: completed
+// payments less the (negative) refund payments made against them
+func (r *PaymentRepository) GetTotalPaidByOrderID(orderID uuid.UUID) (money.Amount, error) {
+	return r.getTotalPaid(r.db, orderID)
+}
+
+// GetTotalPaidByOrderIDTx returns the net amount paid for an order inside tx
+func (r *PaymentRepository) GetTotalPaidByOrderIDTx(tx *sql.Tx, orderID uuid.UUID) (money.Amount, error) {
+	return r.getTotalPaid(tx, orderID)
+}
+
+func (r *PaymentRepository) getTotalPaid(q querier, orderID uuid.UUID) (money.Amount, error) {
+	query := `SELECT COALESCE(SUM(amount), 0) FROM payments WHERE order_id = $1 AND status IN ('completed', 'refunded')`
 
-	var total float64
-	err := r.db.QueryRow(query, orderID).Scan(&total)
+	var total money.Amount
+	err := q.QueryRow(query, orderID).Scan(&total)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get total paid: %w", err)
 	}
 
 	return total, nil
 }
+
+// GetRefundableByOrderID returns the completed payments of an order, newest
+// first, with Amount reduced by whatever has already been refunded against
+// each of them. Payments that are fully refunded are omitted.
+func (r *PaymentRepository) GetRefundableByOrderID(tx *sql.Tx, orderID uuid.UUID) ([]models.Payment, error) {
+	query := `
+		SELECT p.id, p.order_id, p.amount + COALESCE(SUM(rp.amount), 0), COALESCE(p.tendered_amount, p.amount), p.change_amount,
+			p.payment_method, p.reference, p.status, p.refund_of, p.refund_id, p.shift_id, p.created_by, p.approved_by, p.created_at, p.updated_at
+		FROM payments p
+		LEFT JOIN payments rp ON rp.refund_of = p.id
+		WHERE p.order_id = $1 AND p.status = 'completed' AND p.amount > 0
+		GROUP BY p.id
+		HAVING p.amount + COALESCE(SUM(rp.amount), 0) > 0
+		ORDER BY p.created_at DESC
+	`
+
+	rows, err := tx.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refundable payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+
+		payments = append(payments, *payment)
+	}
+
+	return payments, rows.Err()
+}
+
+func scanPayment(row scanner) (*models.Payment, error) {
+	payment := &models.Payment{}
+
+	err := row.Scan(
+		&payment.ID,
+		&payment.OrderID,
+		&payment.Amount,
+		&payment.TenderedAmount,
+		&payment.ChangeAmount,
+		&payment.PaymentMethod,
+		&payment.Reference,
+		&payment.Status,
+		&payment.RefundOf,
+		&payment.RefundID,
+		&payment.ShiftID,
+		&payment.CreatedBy,
+		&payment.ApprovedBy,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
@@ -19,10 +19,17 @@ func NewProductRepository(db *database.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) Create(product *models.Product) error {
+// WithTx runs fn inside a database transaction, so that product changes can
+// be audited together with them
+func (r *ProductRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// CreateTx inserts a product inside tx
+func (r *ProductRepository) CreateTx(tx *sql.Tx, product *models.Product) error {
 	query := `
-		INSERT INTO products (id, name, description, sku, barcode_number, category_id, price, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO products (id, name, description, sku, barcode_number, category_id, price, tax_class_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 
 	now := time.Now()
@@ -35,7 +42,7 @@ func (r *ProductRepository) Create(product *models.Product) error {
 		barcodeNumber = *product.BarcodeNumber
 	}
 
-	_, err := r.db.Exec(query,
+	_, err := tx.Exec(query,
 		product.ID,
 		product.Name,
 		product.Description,
@@ -43,6 +50,7 @@ func (r *ProductRepository) Create(product *models.Product) error {
 		barcodeNumber,
 		product.CategoryID,
 		product.Price,
+		product.TaxClassID,
 		product.CreatedAt,
 		product.UpdatedAt,
 	)
@@ -55,19 +63,27 @@ func (r *ProductRepository) Create(product *models.Product) error {
 }
 
 func (r *ProductRepository) GetByID(id uuid.UUID) (*models.Product, error) {
+	return r.getByID(r.db, id, "")
+}
+
+// Lock locks a product for the rest of tx and returns it with its category
+func (r *ProductRepository) Lock(tx *sql.Tx, id uuid.UUID) (*models.Product, error) {
+	return r.getByID(tx, id, " FOR UPDATE OF p")
+}
+
+func (r *ProductRepository) getByID(q querier, id uuid.UUID, lock string) (*models.Product, error) {
 	query := `
-		SELECT p.id, p.name, p.description, p.sku, p.barcode_number, p.category_id, p.price, p.created_at, p.updated_at,
+		SELECT p.id, p.name, p.description, p.sku, p.barcode_number, p.category_id, p.price, p.tax_class_id, p.created_at, p.updated_at,
 		       c.id, c.name, c.description, c.created_at, c.updated_at
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.id
-		WHERE p.id = $1
-	`
+		WHERE p.id = $1` + lock
 
 	product := &models.Product{}
 	var category models.Category
 	var barcodeNumber sql.NullString
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := q.QueryRow(query, id).Scan(
 		&product.ID,
 		&product.Name,
 		&product.Description,
@@ -75,6 +91,7 @@ func (r *ProductRepository) GetByID(id uuid.UUID) (*models.Product, error) {
 		&barcodeNumber,
 		&product.CategoryID,
 		&product.Price,
+		&product.TaxClassID,
 		&product.CreatedAt,
 		&product.UpdatedAt,
 		&category.ID,
@@ -101,7 +118,7 @@ func (r *ProductRepository) GetByID(id uuid.UUID) (*models.Product, error) {
 
 func (r *ProductRepository) GetAll() ([]*models.Product, error) {
 	query := `
-		SELECT p.id, p.name, p.description, p.sku, p.barcode_number, p.category_id, p.price, p.created_at, p.updated_at,
+		SELECT p.id, p.name, p.description, p.sku, p.barcode_number, p.category_id, p.price, p.tax_class_id, p.created_at, p.updated_at,
 		       c.id, c.name, c.description, c.created_at, c.updated_at
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.id
@@ -128,6 +145,7 @@ func (r *ProductRepository) GetAll() ([]*models.Product, error) {
 			&barcodeNumber,
 			&product.CategoryID,
 			&product.Price,
+			&product.TaxClassID,
 			&product.CreatedAt,
 			&product.UpdatedAt,
 			&category.ID,
@@ -152,11 +170,12 @@ func (r *ProductRepository) GetAll() ([]*models.Product, error) {
 	return products, nil
 }
 
-func (r *ProductRepository) Update(product *models.Product) error {
+// UpdateTx saves a product inside tx
+func (r *ProductRepository) UpdateTx(tx *sql.Tx, product *models.Product) error {
 	query := `
 		UPDATE products 
-		SET name = $1, description = $2, sku = $3, barcode_number = $4, category_id = $5, price = $6, updated_at = $7
-		WHERE id = $8
+		SET name = $1, description = $2, sku = $3, barcode_number = $4, category_id = $5, price = $6, tax_class_id = $7, updated_at = $8
+		WHERE id = $9
 	`
 
 	product.UpdatedAt = time.Now()
@@ -166,13 +185,14 @@ func (r *ProductRepository) Update(product *models.Product) error {
 		barcodeNumber = *product.BarcodeNumber
 	}
 
-	result, err := r.db.Exec(query,
+	result, err := tx.Exec(query,
 		product.Name,
 		product.Description,
 		product.SKU,
 		barcodeNumber,
 		product.CategoryID,
 		product.Price,
+		product.TaxClassID,
 		product.UpdatedAt,
 		product.ID,
 	)
@@ -193,10 +213,11 @@ func (r *ProductRepository) Update(product *models.Product) error {
 	return nil
 }
 
-func (r *ProductRepository) Delete(id uuid.UUID) error {
+// DeleteTx deletes a product inside tx
+func (r *ProductRepository) DeleteTx(tx *sql.Tx, id uuid.UUID) error {
 	query := `DELETE FROM products WHERE id = $1`
 
-	result, err := r.db.Exec(query, id)
+	result, err := tx.Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete product: %w", err)
 	}
@@ -215,7 +236,7 @@ func (r *ProductRepository) Delete(id uuid.UUID) error {
 
 func (r *ProductRepository) GetBySKU(sku string) (*models.Product, error) {
 	query := `
-		SELECT p.id, p.name, p.description, p.sku, p.barcode_number, p.category_id, p.price, p.created_at, p.updated_at,
+		SELECT p.id, p.name, p.description, p.sku, p.barcode_number, p.category_id, p.price, p.tax_class_id, p.created_at, p.updated_at,
 		       c.id, c.name, c.description, c.created_at, c.updated_at
 		FROM products p
 		LEFT JOIN categories c ON p.category_id = c.id
@@ -234,6 +255,7 @@ func (r *ProductRepository) GetBySKU(sku string) (*models.Product, error) {
 		&barcodeNumber,
 		&product.CategoryID,
 		&product.Price,
+		&product.TaxClassID,
 		&product.CreatedAt,
 		&product.UpdatedAt,
 		&category.ID,
@@ -0,0 +1,344 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type PromotionRepository struct {
+	db *database.DB
+}
+
+func NewPromotionRepository(db *database.DB) *PromotionRepository {
+	return &PromotionRepository{db: db}
+}
+
+const promotionColumns = `id, name, COALESCE(description, ''), COALESCE(campaign, ''), type, percent, amount, buy_quantity, get_quantity, min_spend,
+		product_id, category_id, customer_id, starts_at, ends_at, priority, is_active, created_at, updated_at`
+
+func (r *PromotionRepository) Create(promotion *models.Promotion) error {
+	query := `
+		INSERT INTO promotions (id, name, description, campaign, type, percent, amount, buy_quantity, get_quantity, min_spend,
+			product_id, category_id, customer_id, starts_at, ends_at, priority, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+	`
+
+	now := time.Now()
+	promotion.ID = uuid.New()
+	promotion.CreatedAt = now
+	promotion.UpdatedAt = now
+
+	_, err := r.db.Exec(query,
+		promotion.ID,
+		promotion.Name,
+		promotion.Description,
+		promotion.Campaign,
+		promotion.Type,
+		promotion.Percent,
+		promotion.Amount,
+		promotion.BuyQuantity,
+		promotion.GetQuantity,
+		promotion.MinSpend,
+		promotion.ProductID,
+		promotion.CategoryID,
+		promotion.CustomerID,
+		promotion.StartsAt,
+		promotion.EndsAt,
+		promotion.Priority,
+		promotion.IsActive,
+		promotion.CreatedAt,
+		promotion.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create promotion: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PromotionRepository) GetByID(id uuid.UUID) (*models.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE id = $1`
+
+	promotion, err := scanPromotion(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("promotion not found")
+		}
+		return nil, fmt.Errorf("failed to get promotion: %w", err)
+	}
+
+	return promotion, nil
+}
+
+func (r *PromotionRepository) GetAll() ([]*models.Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions ORDER BY priority DESC, created_at DESC`
+
+	return r.query(query)
+}
+
+// GetActive returns the active promotions whose date window contains at, in
+// the order they are applied: highest priority first, oldest first
+func (r *PromotionRepository) GetActive(at time.Time) ([]*models.Promotion, error) {
+	query := `
+		SELECT ` + promotionColumns + `
+		FROM promotions
+		WHERE is_active = true
+		  AND (starts_at IS NULL OR starts_at <= $1)
+		  AND (ends_at IS NULL OR ends_at > $1)
+		ORDER BY priority DESC, created_at ASC
+	`
+
+	return r.query(query, at)
+}
+
+func (r *PromotionRepository) Update(promotion *models.Promotion) error {
+	query := `
+		UPDATE promotions
+		SET name = $1, description = $2, campaign = $3, type = $4, percent = $5, amount = $6, buy_quantity = $7, get_quantity = $8,
+			min_spend = $9, product_id = $10, category_id = $11, customer_id = $12, starts_at = $13, ends_at = $14, priority = $15,
+			is_active = $16, updated_at = $17
+		WHERE id = $18
+	`
+
+	promotion.UpdatedAt = time.Now()
+
+	result, err := r.db.Exec(query,
+		promotion.Name,
+		promotion.Description,
+		promotion.Campaign,
+		promotion.Type,
+		promotion.Percent,
+		promotion.Amount,
+		promotion.BuyQuantity,
+		promotion.GetQuantity,
+		promotion.MinSpend,
+		promotion.ProductID,
+		promotion.CategoryID,
+		promotion.CustomerID,
+		promotion.StartsAt,
+		promotion.EndsAt,
+		promotion.Priority,
+		promotion.IsActive,
+		promotion.UpdatedAt,
+		promotion.ID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update promotion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("promotion not found")
+	}
+
+	return nil
+}
+
+func (r *PromotionRepository) Delete(id uuid.UUID) error {
+	query := `DELETE FROM promotions WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete promotion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("promotion not found")
+	}
+
+	return nil
+}
+
+// IsUsed reports whether a promotion has been applied to any order
+func (r *PromotionRepository) IsUsed(id uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM order_promotions WHERE promotion_id = $1)`
+
+	var used bool
+	if err := r.db.QueryRow(query, id).Scan(&used); err != nil {
+		return false, fmt.Errorf("failed to check promotion usage: %w", err)
+	}
+
+	return used, nil
+}
+
+// CreateOrderPromotionsTx records the promotions applied to an order inside tx
+func (r *PromotionRepository) CreateOrderPromotionsTx(tx *sql.Tx, applied []models.OrderPromotion) error {
+	query := `
+		INSERT INTO order_promotions (id, order_id, promotion_id, order_item_id, name, campaign, amount, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+	`
+
+	now := time.Now()
+	for i := range applied {
+		promotion := &applied[i]
+		promotion.ID = uuid.New()
+		promotion.CreatedAt = now
+
+		_, err := tx.Exec(query,
+			promotion.ID,
+			promotion.OrderID,
+			promotion.PromotionID,
+			promotion.OrderItemID,
+			promotion.Name,
+			promotion.Campaign,
+			promotion.Amount,
+			promotion.CreatedAt,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to create order promotion: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetByOrderID returns the promotions applied to an order
+func (r *PromotionRepository) GetByOrderID(orderID uuid.UUID) ([]models.OrderPromotion, error) {
+	query := `
+		SELECT id, order_id, promotion_id, order_item_id, name, COALESCE(campaign, ''), amount, created_at
+		FROM order_promotions
+		WHERE order_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order promotions: %w", err)
+	}
+	defer rows.Close()
+
+	var applied []models.OrderPromotion
+	for rows.Next() {
+		var promotion models.OrderPromotion
+
+		err := rows.Scan(
+			&promotion.ID,
+			&promotion.OrderID,
+			&promotion.PromotionID,
+			&promotion.OrderItemID,
+			&promotion.Name,
+			&promotion.Campaign,
+			&promotion.Amount,
+			&promotion.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order promotion: %w", err)
+		}
+
+		applied = append(applied, promotion)
+	}
+
+	return applied, nil
+}
+
+// GetReport returns the discount given per promotion on orders placed in
+// [from, to). Draft and cancelled orders are left out.
+func (r *PromotionRepository) GetReport(from, to time.Time) ([]models.PromotionReport, error) {
+	query := `
+		SELECT p.id, p.name, COALESCE(p.campaign, ''), COUNT(DISTINCT op.order_id), SUM(op.amount)
+		FROM order_promotions op
+		JOIN promotions p ON op.promotion_id = p.id
+		JOIN orders o ON op.order_id = o.id
+		WHERE o.created_at >= $1 AND o.created_at < $2
+		  AND o.status NOT IN ('draft', 'cancelled')
+		GROUP BY p.id, p.name, p.campaign
+		ORDER BY SUM(op.amount) DESC
+	`
+
+	rows, err := r.db.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promotion report: %w", err)
+	}
+	defer rows.Close()
+
+	var report []models.PromotionReport
+	for rows.Next() {
+		var line models.PromotionReport
+
+		err := rows.Scan(
+			&line.PromotionID,
+			&line.Name,
+			&line.Campaign,
+			&line.Orders,
+			&line.DiscountAmount,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promotion report: %w", err)
+		}
+
+		report = append(report, line)
+	}
+
+	return report, nil
+}
+
+func (r *PromotionRepository) query(query string, args ...interface{}) ([]*models.Promotion, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promotions: %w", err)
+	}
+	defer rows.Close()
+
+	var promotions []*models.Promotion
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promotion: %w", err)
+		}
+		promotions = append(promotions, promotion)
+	}
+
+	return promotions, nil
+}
+
+func scanPromotion(row scanner) (*models.Promotion, error) {
+	promotion := &models.Promotion{}
+
+	err := row.Scan(
+		&promotion.ID,
+		&promotion.Name,
+		&promotion.Description,
+		&promotion.Campaign,
+		&promotion.Type,
+		&promotion.Percent,
+		&promotion.Amount,
+		&promotion.BuyQuantity,
+		&promotion.GetQuantity,
+		&promotion.MinSpend,
+		&promotion.ProductID,
+		&promotion.CategoryID,
+		&promotion.CustomerID,
+		&promotion.StartsAt,
+		&promotion.EndsAt,
+		&promotion.Priority,
+		&promotion.IsActive,
+		&promotion.CreatedAt,
+		&promotion.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return promotion, nil
+}
@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+
+	"github.com/google/uuid"
+)
+
+type PurchaseOrderRepository struct {
+	db *database.DB
+}
+
+func NewPurchaseOrderRepository(db *database.DB) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: db}
+}
+
+// WithTx runs fn inside a database transaction, so that purchase order
+// changes can be made together with the stock they move
+func (r *PurchaseOrderRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+const purchaseOrderColumns = `id, po_number, supplier_id, location, status, expected_at, COALESCE(notes, ''), created_by, sent_at, received_at, closed_at, created_at, updated_at`
+
+// CreateTx inserts a draft purchase order and its lines inside tx. The PO
+// number is taken from its own sequence.
+func (r *PurchaseOrderRepository) CreateTx(tx *sql.Tx, order *models.PurchaseOrder) error {
+	query := `
+		INSERT INTO purchase_orders (id, po_number, supplier_id, location, status, expected_at, notes, created_by, created_at, updated_at)
+		VALUES ($1, 'PO-' || nextval('purchase_order_number_seq'), $2, $3, 'draft', $4, NULLIF($5, ''), $6, $7, $7)
+		RETURNING po_number
+	`
+
+	now := time.Now()
+	order.ID = uuid.New()
+	order.Status = "draft"
+	order.CreatedAt = now
+	order.UpdatedAt = now
+
+	err := tx.QueryRow(query,
+		order.ID,
+		order.SupplierID,
+		order.Location,
+		order.ExpectedAt,
+		order.Notes,
+		order.CreatedBy,
+		now,
+	).Scan(&order.PONumber)
+	if err != nil {
+		return fmt.Errorf("failed to create purchase order: %w", err)
+	}
+
+	return r.insertLines(tx, order)
+}
+
+// UpdateTx replaces the supplier, location, expected date, notes and lines
+// of a draft purchase order inside tx
+func (r *PurchaseOrderRepository) UpdateTx(tx *sql.Tx, order *models.PurchaseOrder) error {
+	query := `
+		UPDATE purchase_orders
+		SET supplier_id = $1, location = $2, expected_at = $3, notes = NULLIF($4, ''), updated_at = $5
+		WHERE id = $6 AND status = 'draft'
+	`
+
+	order.UpdatedAt = time.Now()
+
+	result, err := tx.Exec(query,
+		order.SupplierID,
+		order.Location,
+		order.ExpectedAt,
+		order.Notes,
+		order.UpdatedAt,
+		order.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update purchase order: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("purchase order not found")
+	}
+
+	if _, err := tx.Exec(`DELETE FROM purchase_order_lines WHERE purchase_order_id = $1`, order.ID); err != nil {
+		return fmt.Errorf("failed to replace purchase order lines: %w", err)
+	}
+
+	return r.insertLines(tx, order)
+}
+
+// Lock locks a purchase order for the rest of tx and returns it with its
+// lines
+func (r *PurchaseOrderRepository) Lock(tx *sql.Tx, id uuid.UUID) (*models.PurchaseOrder, error) {
+	query := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders WHERE id = $1 FOR UPDATE`
+
+	order, err := scanPurchaseOrder(tx.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("purchase order not found")
+		}
+		return nil, fmt.Errorf("failed to lock purchase order: %w", err)
+	}
+
+	if err := r.loadLines(tx, order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// UpdateStatusTx saves the status of a purchase order and when it was sent,
+// received and closed
+func (r *PurchaseOrderRepository) UpdateStatusTx(tx *sql.Tx, order *models.PurchaseOrder) error {
+	query := `
+		UPDATE purchase_orders
+		SET status = $1, sent_at = $2, received_at = $3, closed_at = $4, updated_at = $5
+		WHERE id = $6
+	`
+
+	order.UpdatedAt = time.Now()
+
+	result, err := tx.Exec(query,
+		order.Status,
+		order.SentAt,
+		order.ReceivedAt,
+		order.ClosedAt,
+		order.UpdatedAt,
+		order.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update purchase order: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("purchase order not found")
+	}
+
+	return nil
+}
+
+// AddReceivedTx adds quantity to what has been received of a purchase order
+// line inside tx
+func (r *PurchaseOrderRepository) AddReceivedTx(tx *sql.Tx, line *models.PurchaseOrderLine, quantity int) error {
+	query := `
+		UPDATE purchase_order_lines
+		SET received_quantity = received_quantity + $1
+		WHERE id = $2
+		RETURNING received_quantity
+	`
+
+	if err := tx.QueryRow(query, quantity, line.ID).Scan(&line.ReceivedQuantity); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("purchase order line not found")
+		}
+		return fmt.Errorf("failed to update purchase order line: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PurchaseOrderRepository) GetByID(id uuid.UUID) (*models.PurchaseOrder, error) {
+	query := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders WHERE id = $1`
+
+	order, err := scanPurchaseOrder(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("purchase order not found")
+		}
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+
+	if err := r.loadLines(r.db, order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// GetAll returns the purchase orders with their lines, newest first,
+// optionally only those with the given status or from the given supplier
+func (r *PurchaseOrderRepository) GetAll(status string, supplierID *uuid.UUID) ([]models.PurchaseOrder, error) {
+	query := `
+		SELECT ` + purchaseOrderColumns + `
+		FROM purchase_orders
+		WHERE ($1 = '' OR status = $1) AND ($2::uuid IS NULL OR supplier_id = $2)
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(query, status, supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchase orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.PurchaseOrder{}
+	for rows.Next() {
+		order, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase order: %w", err)
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read purchase orders: %w", err)
+	}
+
+	for i := range orders {
+		if err := r.loadLines(r.db, &orders[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return orders, nil
+}
+
+// DeleteTx deletes a draft purchase order and its lines inside tx
+func (r *PurchaseOrderRepository) DeleteTx(tx *sql.Tx, id uuid.UUID) error {
+	query := `DELETE FROM purchase_orders WHERE id = $1 AND status = 'draft'`
+
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete purchase order: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("purchase order not found")
+	}
+
+	return nil
+}
+
+func (r *PurchaseOrderRepository) insertLines(tx *sql.Tx, order *models.PurchaseOrder) error {
+	query := `
+		INSERT INTO purchase_order_lines (id, purchase_order_id, product_id, quantity, received_quantity, unit_cost, expected_at)
+		VALUES ($1, $2, $3, $4, 0, $5, $6)
+	`
+
+	for i := range order.Lines {
+		line := &order.Lines[i]
+		line.ID = uuid.New()
+		line.PurchaseOrderID = order.ID
+		line.ReceivedQuantity = 0
+
+		_, err := tx.Exec(query, line.ID, line.PurchaseOrderID, line.ProductID, line.Quantity, line.UnitCost, line.ExpectedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create purchase order line: %w", err)
+		}
+	}
+
+	order.ExpectedTotal = purchaseOrderTotal(order.Lines)
+	return nil
+}
+
+// loadLines reads the lines of a purchase order in product order and adds
+// up what they are expected to cost
+func (r *PurchaseOrderRepository) loadLines(q querier, order *models.PurchaseOrder) error {
+	query := `
+		SELECT id, purchase_order_id, product_id, quantity, received_quantity, unit_cost, expected_at
+		FROM purchase_order_lines
+		WHERE purchase_order_id = $1
+		ORDER BY product_id
+	`
+
+	rows, err := q.Query(query, order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to query purchase order lines: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []models.PurchaseOrderLine{}
+	for rows.Next() {
+		var line models.PurchaseOrderLine
+		err := rows.Scan(
+			&line.ID,
+			&line.PurchaseOrderID,
+			&line.ProductID,
+			&line.Quantity,
+			&line.ReceivedQuantity,
+			&line.UnitCost,
+			&line.ExpectedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan purchase order line: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read purchase order lines: %w", err)
+	}
+
+	order.Lines = lines
+	order.ExpectedTotal = purchaseOrderTotal(lines)
+	return nil
+}
+
+func purchaseOrderTotal(lines []models.PurchaseOrderLine) money.Amount {
+	total := money.Zero
+	for _, line := range lines {
+		total += line.UnitCost.Mul(line.Quantity)
+	}
+	return total
+}
+
+func scanPurchaseOrder(row scanner) (*models.PurchaseOrder, error) {
+	order := &models.PurchaseOrder{}
+
+	err := row.Scan(
+		&order.ID,
+		&order.PONumber,
+		&order.SupplierID,
+		&order.Location,
+		&order.Status,
+		&order.ExpectedAt,
+		&order.Notes,
+		&order.CreatedBy,
+		&order.SentAt,
+		&order.ReceivedAt,
+		&order.ClosedAt,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
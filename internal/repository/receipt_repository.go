@@ -20,63 +20,143 @@ func NewReceiptRepository(db *database.DB) *ReceiptRepository {
 }
 
 func (r *ReceiptRepository) Create(receipt *models.Receipt) error {
+	return r.db.WithTx(func(tx *sql.Tx) error {
+		return r.create(tx, receipt)
+	})
+}
+
+// CreateTx inserts a receipt and its tax breakdown inside tx
+func (r *ReceiptRepository) CreateTx(tx *sql.Tx, receipt *models.Receipt) error {
+	return r.create(tx, receipt)
+}
+
+func (r *ReceiptRepository) create(q querier, receipt *models.Receipt) error {
 	query := `
-		INSERT INTO receipts (id, order_id, receipt_number, total_amount, tax_amount, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO receipts (id, order_id, receipt_number, type, refund_id, total_amount, tax_amount, cashier_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING receipt_number
 	`
 
 	receipt.ID = uuid.New()
 	receipt.CreatedAt = time.Now()
+	if receipt.Type == "" {
+		receipt.Type = "sale"
+	}
 
-	_, err := r.db.Exec(query,
+	err := q.QueryRow(query,
 		receipt.ID,
 		receipt.OrderID,
 		receipt.ReceiptNumber,
+		receipt.Type,
+		receipt.RefundID,
 		receipt.TotalAmount,
 		receipt.TaxAmount,
+		receipt.CashierID,
 		receipt.CreatedAt,
-	)
+	).Scan(&receipt.ReceiptNumber)
 
 	if err != nil {
 		return fmt.Errorf("failed to create receipt: %w", err)
 	}
 
+	for i := range receipt.Taxes {
+		tax := &receipt.Taxes[i]
+		taxQuery := `
+			INSERT INTO receipt_taxes (id, receipt_id, tax_class_id, name, rate, taxable_amount, tax_amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`
+
+		tax.ID = uuid.New()
+		tax.ReceiptID = receipt.ID
+
+		_, err = q.Exec(taxQuery,
+			tax.ID,
+			tax.ReceiptID,
+			tax.TaxClassID,
+			tax.Name,
+			tax.Rate,
+			tax.TaxableAmount,
+			tax.TaxAmount,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to create receipt tax: %w", err)
+		}
+	}
+
 	return nil
 }
 
+const receiptColumns = `r.id, r.order_id, r.receipt_number, r.type, r.refund_id, r.total_amount, r.tax_amount, r.cashier_id, r.print_count, r.created_at,
+		       o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.prices_include_tax, o.tax_overridden, o.notes, o.created_at, o.updated_at`
+
 func (r *ReceiptRepository) GetByID(id uuid.UUID) (*models.Receipt, error) {
 	query := `
-		SELECT r.id, r.order_id, r.receipt_number, r.total_amount, r.tax_amount, r.created_at,
-		       o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.notes, o.created_at, o.updated_at
+		SELECT ` + receiptColumns + `
 		FROM receipts r
 		LEFT JOIN orders o ON r.order_id = o.id
 		WHERE r.id = $1
 	`
 
-	var receipt models.Receipt
-	var order models.Order
+	return r.get(query, id)
+}
 
-	err := r.db.QueryRow(query, id).Scan(
-		&receipt.ID,
-		&receipt.OrderID,
-		&receipt.ReceiptNumber,
-		&receipt.TotalAmount,
-		&receipt.TaxAmount,
-		&receipt.CreatedAt,
-		&order.ID,
-		&order.OrderNumber,
-		&order.CustomerID,
-		&order.Status,
-		&order.Subtotal,
-		&order.TaxAmount,
-		&order.DiscountAmount,
-		&order.TotalAmount,
-		&order.PaymentStatus,
-		&order.Notes,
-		&order.CreatedAt,
-		&order.UpdatedAt,
-	)
+func (r *ReceiptRepository) GetByOrderID(orderID uuid.UUID) (*models.Receipt, error) {
+	query := `
+		SELECT ` + receiptColumns + `
+		FROM receipts r
+		LEFT JOIN orders o ON r.order_id = o.id
+		WHERE r.order_id = $1 AND r.type = 'sale'
+	`
+
+	return r.get(query, orderID)
+}
+
+func (r *ReceiptRepository) GetAll() ([]models.Receipt, error) {
+	query := `
+		SELECT ` + receiptColumns + `
+		FROM receipts r
+		LEFT JOIN orders o ON r.order_id = o.id
+		ORDER BY r.created_at DESC
+	`
 
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	var receipts []models.Receipt
+	for rows.Next() {
+		receipt, err := scanReceipt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan receipt: %w", err)
+		}
+		receipts = append(receipts, *receipt)
+	}
+
+	return receipts, nil
+}
+
+// MarkPrinted counts one more print of a receipt and returns how many times
+// it has been printed, this one included
+func (r *ReceiptRepository) MarkPrinted(id uuid.UUID) (int, error) {
+	query := `UPDATE receipts SET print_count = print_count + 1 WHERE id = $1 RETURNING print_count`
+
+	var count int
+	if err := r.db.QueryRow(query, id).Scan(&count); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("receipt not found")
+		}
+		return 0, fmt.Errorf("failed to mark receipt printed: %w", err)
+	}
+
+	return count, nil
+}
+
+// get returns the single receipt selected by query with its tax breakdown
+func (r *ReceiptRepository) get(query string, args ...interface{}) (*models.Receipt, error) {
+	receipt, err := scanReceipt(r.db.QueryRow(query, args...))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("receipt not found")
@@ -84,28 +164,66 @@ func (r *ReceiptRepository) GetByID(id uuid.UUID) (*models.Receipt, error) {
 		return nil, fmt.Errorf("failed to get receipt: %w", err)
 	}
 
-	receipt.Order = &order
-	return &receipt, nil
+	if receipt.Taxes, err = r.getTaxes(receipt.ID); err != nil {
+		return nil, err
+	}
+
+	return receipt, nil
 }
 
-func (r *ReceiptRepository) GetByOrderID(orderID uuid.UUID) (*models.Receipt, error) {
+// getTaxes returns the tax breakdown of a receipt
+func (r *ReceiptRepository) getTaxes(receiptID uuid.UUID) ([]models.ReceiptTax, error) {
 	query := `
-		SELECT r.id, r.order_id, r.receipt_number, r.total_amount, r.tax_amount, r.created_at,
-		       o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.notes, o.created_at, o.updated_at
-		FROM receipts r
-		LEFT JOIN orders o ON r.order_id = o.id
-		WHERE r.order_id = $1
+		SELECT id, receipt_id, tax_class_id, name, rate, taxable_amount, tax_amount
+		FROM receipt_taxes
+		WHERE receipt_id = $1
+		ORDER BY rate DESC, name ASC
 	`
 
+	rows, err := r.db.Query(query, receiptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipt taxes: %w", err)
+	}
+	defer rows.Close()
+
+	var taxes []models.ReceiptTax
+	for rows.Next() {
+		var tax models.ReceiptTax
+
+		err := rows.Scan(
+			&tax.ID,
+			&tax.ReceiptID,
+			&tax.TaxClassID,
+			&tax.Name,
+			&tax.Rate,
+			&tax.TaxableAmount,
+			&tax.TaxAmount,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan receipt tax: %w", err)
+		}
+
+		taxes = append(taxes, tax)
+	}
+
+	return taxes, nil
+}
+
+func scanReceipt(row scanner) (*models.Receipt, error) {
 	var receipt models.Receipt
 	var order models.Order
 
-	err := r.db.QueryRow(query, orderID).Scan(
+	err := row.Scan(
 		&receipt.ID,
 		&receipt.OrderID,
 		&receipt.ReceiptNumber,
+		&receipt.Type,
+		&receipt.RefundID,
 		&receipt.TotalAmount,
 		&receipt.TaxAmount,
+		&receipt.CashierID,
+		&receipt.PrintCount,
 		&receipt.CreatedAt,
 		&order.ID,
 		&order.OrderNumber,
@@ -116,70 +234,17 @@ func (r *ReceiptRepository) GetByOrderID(orderID uuid.UUID) (*models.Receipt, er
 		&order.DiscountAmount,
 		&order.TotalAmount,
 		&order.PaymentStatus,
+		&order.PricesIncludeTax,
+		&order.TaxOverridden,
 		&order.Notes,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 	)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("receipt not found")
-		}
-		return nil, fmt.Errorf("failed to get receipt: %w", err)
+		return nil, err
 	}
 
 	receipt.Order = &order
 	return &receipt, nil
 }
-
-func (r *ReceiptRepository) GetAll() ([]models.Receipt, error) {
-	query := `
-		SELECT r.id, r.order_id, r.receipt_number, r.total_amount, r.tax_amount, r.created_at,
-		       o.id, o.order_number, o.customer_id, o.status, o.subtotal, o.tax_amount, o.discount_amount, o.total_amount, o.payment_status, o.notes, o.created_at, o.updated_at
-		FROM receipts r
-		LEFT JOIN orders o ON r.order_id = o.id
-		ORDER BY r.created_at DESC
-	`
-
-	rows, err := r.db.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query receipts: %w", err)
-	}
-	defer rows.Close()
-
-	var receipts []models.Receipt
-	for rows.Next() {
-		var receipt models.Receipt
-		var order models.Order
-
-		err := rows.Scan(
-			&receipt.ID,
-			&receipt.OrderID,
-			&receipt.ReceiptNumber,
-			&receipt.TotalAmount,
-			&receipt.TaxAmount,
-			&receipt.CreatedAt,
-			&order.ID,
-			&order.OrderNumber,
-			&order.CustomerID,
-			&order.Status,
-			&order.Subtotal,
-			&order.TaxAmount,
-			&order.DiscountAmount,
-			&order.TotalAmount,
-			&order.PaymentStatus,
-			&order.Notes,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan receipt: %w", err)
-		}
-
-		receipt.Order = &order
-		receipts = append(receipts, receipt)
-	}
-
-	return receipts, nil
-}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type RefundRepository struct {
+	db *database.DB
+}
+
+func NewRefundRepository(db *database.DB) *RefundRepository {
+	return &RefundRepository{db: db}
+}
+
+// CreateTx inserts a refund and its items inside tx
+func (r *RefundRepository) CreateTx(tx *sql.Tx, refund *models.Refund) error {
+	refundQuery := `
+		INSERT INTO refunds (id, order_id, amount, reason, restock_location, created_by, approved_by, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+	`
+
+	now := time.Now()
+	refund.ID = uuid.New()
+	refund.CreatedAt = now
+
+	_, err := tx.Exec(refundQuery,
+		refund.ID,
+		refund.OrderID,
+		refund.Amount,
+		refund.Reason,
+		refund.RestockLocation,
+		refund.CreatedBy,
+		refund.ApprovedBy,
+		refund.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create refund: %w", err)
+	}
+
+	for i := range refund.Items {
+		item := &refund.Items[i]
+		itemQuery := `
+			INSERT INTO refund_items (id, refund_id, order_item_id, quantity, amount, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`
+
+		item.ID = uuid.New()
+		item.RefundID = refund.ID
+		item.CreatedAt = now
+
+		_, err = tx.Exec(itemQuery,
+			item.ID,
+			item.RefundID,
+			item.OrderItemID,
+			item.Quantity,
+			item.Amount,
+			item.CreatedAt,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to create refund item: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetRefundedByOrderID returns, per order item, the quantity and amount already
+// refunded. Quantity and Amount of each returned item hold the running totals.
+func (r *RefundRepository) GetRefundedByOrderID(tx *sql.Tx, orderID uuid.UUID) (map[uuid.UUID]models.RefundItem, error) {
+	query := `
+		SELECT ri.order_item_id, SUM(ri.quantity), SUM(ri.amount)
+		FROM refund_items ri
+		JOIN refunds rf ON ri.refund_id = rf.id
+		WHERE rf.order_id = $1
+		GROUP BY ri.order_item_id
+	`
+
+	rows, err := tx.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refunded items: %w", err)
+	}
+	defer rows.Close()
+
+	refunded := make(map[uuid.UUID]models.RefundItem)
+	for rows.Next() {
+		var item models.RefundItem
+		if err := rows.Scan(&item.OrderItemID, &item.Quantity, &item.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan refunded item: %w", err)
+		}
+		refunded[item.OrderItemID] = item
+	}
+
+	return refunded, nil
+}
+
+// GetByOrderID returns the refunds of an order with their items, newest first
+func (r *RefundRepository) GetByOrderID(orderID uuid.UUID) ([]models.Refund, error) {
+	query := `
+		SELECT id, order_id, amount, COALESCE(reason, ''), COALESCE(restock_location, ''), created_by, approved_by, created_at
+		FROM refunds
+		WHERE order_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []models.Refund
+	for rows.Next() {
+		var refund models.Refund
+
+		err := rows.Scan(
+			&refund.ID,
+			&refund.OrderID,
+			&refund.Amount,
+			&refund.Reason,
+			&refund.RestockLocation,
+			&refund.CreatedBy,
+			&refund.ApprovedBy,
+			&refund.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+
+		refunds = append(refunds, refund)
+	}
+
+	for i := range refunds {
+		items, err := r.getItems(refunds[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		refunds[i].Items = items
+	}
+
+	return refunds, nil
+}
+
+func (r *RefundRepository) getItems(refundID uuid.UUID) ([]models.RefundItem, error) {
+	query := `
+		SELECT ri.id, ri.refund_id, ri.order_item_id, oi.product_id, ri.quantity, ri.amount, ri.created_at
+		FROM refund_items ri
+		JOIN order_items oi ON ri.order_item_id = oi.id
+		WHERE ri.refund_id = $1
+	`
+
+	rows, err := r.db.Query(query, refundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refund items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.RefundItem
+	for rows.Next() {
+		var item models.RefundItem
+
+		err := rows.Scan(
+			&item.ID,
+			&item.RefundID,
+			&item.OrderItemID,
+			&item.ProductID,
+			&item.Quantity,
+			&item.Amount,
+			&item.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund item: %w", err)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
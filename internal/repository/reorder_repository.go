@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrReorderLevelNotFound is returned when a product has no reorder level at
+// a location
+var ErrReorderLevelNotFound = errors.New("reorder level not found")
+
+type ReorderRepository struct {
+	db *database.DB
+}
+
+func NewReorderRepository(db *database.DB) *ReorderRepository {
+	return &ReorderRepository{db: db}
+}
+
+// WithTx runs fn inside a database transaction, so that reorder level
+// changes can be audited together with them
+func (r *ReorderRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+const reorderLevelColumns = `product_id, location, min_quantity, max_quantity, reorder_quantity, alert_state, alerted_at, created_at, updated_at`
+
+// reorderStatusColumns select the stock of r.product_id at r.location next to
+// its reorder level, from reorder_levels r joined with products p and,
+// optionally, inventory i. on_order is what sent purchase orders for the
+// location have yet to deliver.
+const reorderStatusColumns = `
+	r.product_id, p.name, COALESCE(p.sku, '') AS sku, r.location, COALESCE(i.quantity, 0) AS quantity,
+	r.min_quantity, r.max_quantity, r.reorder_quantity,
+	CASE
+		WHEN COALESCE(i.quantity, 0) <= 0 THEN 'out'
+		WHEN COALESCE(i.quantity, 0) <= r.min_quantity THEN 'low'
+		WHEN COALESCE(i.quantity, 0) > r.max_quantity THEN 'over'
+		ELSE 'ok'
+	END AS state,
+	r.alert_state,
+	COALESCE((
+		SELECT SUM(GREATEST(l.quantity - l.received_quantity, 0))
+		FROM purchase_order_lines l
+		JOIN purchase_orders po ON po.id = l.purchase_order_id
+		WHERE l.product_id = r.product_id AND po.location = r.location
+		  AND po.status IN ('sent', 'partially_received')
+	), 0) AS on_order`
+
+const reorderStatusFrom = `
+	FROM reorder_levels r
+	JOIN products p ON p.id = r.product_id
+	LEFT JOIN inventory i ON i.product_id = r.product_id AND i.location = r.location`
+
+// SetTx creates or replaces the reorder level of a product at a location
+// inside tx
+func (r *ReorderRepository) SetTx(tx *sql.Tx, level *models.ReorderLevel) error {
+	query := `
+		INSERT INTO reorder_levels (product_id, location, min_quantity, max_quantity, reorder_quantity, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (product_id, location) DO UPDATE
+		SET min_quantity = EXCLUDED.min_quantity,
+		    max_quantity = EXCLUDED.max_quantity,
+		    reorder_quantity = EXCLUDED.reorder_quantity,
+		    updated_at = EXCLUDED.updated_at
+		RETURNING ` + reorderLevelColumns
+
+	err := scanReorderLevel(tx.QueryRow(query,
+		level.ProductID,
+		level.Location,
+		level.MinQuantity,
+		level.MaxQuantity,
+		level.ReorderQuantity,
+		time.Now(),
+	), level)
+	if err != nil {
+		return fmt.Errorf("failed to set reorder level: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ReorderRepository) Get(productID uuid.UUID, location string) (*models.ReorderLevel, error) {
+	return r.get(r.db, productID, location, "")
+}
+
+// GetTx gets a reorder level inside tx, locking it until the transaction ends
+func (r *ReorderRepository) GetTx(tx *sql.Tx, productID uuid.UUID, location string) (*models.ReorderLevel, error) {
+	return r.get(tx, productID, location, " FOR UPDATE")
+}
+
+func (r *ReorderRepository) get(q querier, productID uuid.UUID, location, lock string) (*models.ReorderLevel, error) {
+	query := `SELECT ` + reorderLevelColumns + ` FROM reorder_levels WHERE product_id = $1 AND location = $2` + lock
+
+	level := &models.ReorderLevel{}
+	if err := scanReorderLevel(q.QueryRow(query, productID, location), level); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReorderLevelNotFound
+		}
+		return nil, fmt.Errorf("failed to get reorder level: %w", err)
+	}
+
+	return level, nil
+}
+
+// GetAll returns the reorder levels, optionally only those of a product or
+// at a location
+func (r *ReorderRepository) GetAll(productID *uuid.UUID, location string) ([]models.ReorderLevel, error) {
+	query := `
+		SELECT ` + reorderLevelColumns + `
+		FROM reorder_levels
+		WHERE ($1::uuid IS NULL OR product_id = $1) AND ($2 = '' OR location = $2)
+		ORDER BY location ASC, product_id ASC
+	`
+
+	rows, err := r.db.Query(query, productID, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reorder levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []models.ReorderLevel{}
+	for rows.Next() {
+		var level models.ReorderLevel
+		if err := scanReorderLevel(rows, &level); err != nil {
+			return nil, fmt.Errorf("failed to scan reorder level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, rows.Err()
+}
+
+// DeleteTx deletes the reorder level of a product at a location inside tx
+func (r *ReorderRepository) DeleteTx(tx *sql.Tx, productID uuid.UUID, location string) error {
+	query := `DELETE FROM reorder_levels WHERE product_id = $1 AND location = $2`
+
+	result, err := tx.Exec(query, productID, location)
+	if err != nil {
+		return fmt.Errorf("failed to delete reorder level: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrReorderLevelNotFound
+	}
+
+	return nil
+}
+
+// GetStatuses returns the stock of every product with a reorder level next
+// to it, optionally only at a location, ordered by location and product name
+func (r *ReorderRepository) GetStatuses(location string) ([]models.ReorderStatus, error) {
+	query := `SELECT ` + reorderStatusColumns + reorderStatusFrom + `
+		WHERE $1 = '' OR r.location = $1
+		ORDER BY r.location ASC, p.name ASC
+	`
+
+	return r.queryStatuses(query, location)
+}
+
+// GetAlertChanges returns the stock whose state differs from the state last
+// notified
+func (r *ReorderRepository) GetAlertChanges() ([]models.ReorderStatus, error) {
+	query := `SELECT * FROM (SELECT ` + reorderStatusColumns + reorderStatusFrom + `) s
+		WHERE s.state <> s.alert_state
+	`
+
+	return r.queryStatuses(query)
+}
+
+// SetAlertState records that the state of a product at a location has been
+// notified. It reports false when another caller recorded a change from
+// the same state first, so that each change is notified once.
+func (r *ReorderRepository) SetAlertState(productID uuid.UUID, location, from, to string) (bool, error) {
+	query := `
+		UPDATE reorder_levels
+		SET alert_state = $1, alerted_at = CURRENT_TIMESTAMP
+		WHERE product_id = $2 AND location = $3 AND alert_state = $4
+	`
+
+	result, err := r.db.Exec(query, to, productID, location, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to set alert state: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// GetUnitsSold returns how many units of each product orders completed since
+// the given time sold, leaving out orders cancelled since
+func (r *ReorderRepository) GetUnitsSold(since time.Time) (map[uuid.UUID]int, error) {
+	query := `
+		SELECT oi.product_id, SUM(oi.quantity)
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE o.status IN ('completed', 'partially_refunded', 'refunded')
+		  AND EXISTS (
+			SELECT 1 FROM order_status_history h
+			WHERE h.order_id = o.id AND h.to_status = 'completed' AND h.created_at >= $1
+		  )
+		GROUP BY oi.product_id
+	`
+
+	rows, err := r.db.Query(query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query units sold: %w", err)
+	}
+	defer rows.Close()
+
+	sold := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var productID uuid.UUID
+		var units int
+		if err := rows.Scan(&productID, &units); err != nil {
+			return nil, fmt.Errorf("failed to scan units sold: %w", err)
+		}
+		sold[productID] = units
+	}
+
+	return sold, rows.Err()
+}
+
+func (r *ReorderRepository) queryStatuses(query string, args ...interface{}) ([]models.ReorderStatus, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reorder statuses: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := []models.ReorderStatus{}
+	for rows.Next() {
+		var status models.ReorderStatus
+		err := rows.Scan(
+			&status.ProductID,
+			&status.ProductName,
+			&status.SKU,
+			&status.Location,
+			&status.Quantity,
+			&status.MinQuantity,
+			&status.MaxQuantity,
+			&status.ReorderQuantity,
+			&status.State,
+			&status.AlertState,
+			&status.OnOrder,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reorder status: %w", err)
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, rows.Err()
+}
+
+func scanReorderLevel(row scanner, level *models.ReorderLevel) error {
+	return row.Scan(
+		&level.ProductID,
+		&level.Location,
+		&level.MinQuantity,
+		&level.MaxQuantity,
+		&level.ReorderQuantity,
+		&level.AlertState,
+		&level.AlertedAt,
+		&level.CreatedAt,
+		&level.UpdatedAt,
+	)
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+// querier is satisfied by both *database.DB and *sql.Tx so that repository
+// methods can run either standalone or as part of a larger transaction
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows so that a single helper
+// can scan a row from either
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
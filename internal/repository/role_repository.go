@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+)
+
+type RoleRepository struct {
+	db *database.DB
+}
+
+func NewRoleRepository(db *database.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// WithTx runs fn inside a database transaction, so that role changes can be
+// audited together with them
+func (r *RoleRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+const roleColumns = `name, description, is_system, require_two_factor, created_at, updated_at`
+
+// SeedSystemRole creates a system role with its default permissions unless
+// it exists already. With replace set, the role's permissions are reset to
+// the defaults even when it exists.
+func (r *RoleRepository) SeedSystemRole(role models.Role, replace bool) error {
+	return r.db.WithTx(func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO roles (name, description, is_system, created_at, updated_at)
+			VALUES ($1, $2, true, $3, $3)
+			ON CONFLICT (name) DO UPDATE SET is_system = true
+			RETURNING (xmax = 0)
+		`
+
+		// xmax is zero for a row this statement inserted
+		var created bool
+		if err := tx.QueryRow(query, role.Name, role.Description, time.Now()).Scan(&created); err != nil {
+			return fmt.Errorf("failed to seed role %s: %w", role.Name, err)
+		}
+
+		if !created && !replace {
+			return nil
+		}
+		return r.setPermissions(tx, role.Name, role.Permissions)
+	})
+}
+
+// CreateTx creates a custom role with its permissions inside tx
+func (r *RoleRepository) CreateTx(tx *sql.Tx, role *models.Role) error {
+	query := `
+		INSERT INTO roles (name, description, is_system, created_at, updated_at)
+		VALUES ($1, $2, false, $3, $4)
+	`
+
+	now := time.Now()
+	role.IsSystem = false
+	role.CreatedAt = now
+	role.UpdatedAt = now
+
+	if _, err := tx.Exec(query, role.Name, role.Description, role.CreatedAt, role.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+	return r.setPermissions(tx, role.Name, role.Permissions)
+}
+
+// UpdateTx replaces the description and permissions of a role inside tx
+func (r *RoleRepository) UpdateTx(tx *sql.Tx, role *models.Role) error {
+	query := `UPDATE roles SET description = $1, updated_at = $2 WHERE name = $3`
+
+	role.UpdatedAt = time.Now()
+
+	result, err := tx.Exec(query, role.Description, role.UpdatedAt, role.Name)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("role not found")
+	}
+
+	return r.setPermissions(tx, role.Name, role.Permissions)
+}
+
+// DeleteTx deletes a custom role inside tx. System roles are never deleted.
+func (r *RoleRepository) DeleteTx(tx *sql.Tx, name string) error {
+	query := `DELETE FROM roles WHERE name = $1 AND NOT is_system`
+
+	result, err := tx.Exec(query, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("role not found")
+	}
+
+	return nil
+}
+
+// GetByName returns a role with its permissions
+func (r *RoleRepository) GetByName(name string) (*models.Role, error) {
+	return r.getByName(r.db, name, "")
+}
+
+// Lock locks a role for the rest of tx and returns it with its permissions
+func (r *RoleRepository) Lock(tx *sql.Tx, name string) (*models.Role, error) {
+	return r.getByName(tx, name, " FOR UPDATE")
+}
+
+func (r *RoleRepository) getByName(q querier, name, lock string) (*models.Role, error) {
+	query := `SELECT ` + roleColumns + ` FROM roles WHERE name = $1` + lock
+
+	role, err := scanRole(q.QueryRow(query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("role not found")
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	if role.Permissions, err = r.getPermissions(q, name); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// GetAll returns every role with its permissions, system roles first
+func (r *RoleRepository) GetAll() ([]models.Role, error) {
+	query := `SELECT ` + roleColumns + ` FROM roles ORDER BY is_system DESC, name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, *role)
+	}
+
+	permissions, err := r.getAllPermissions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range roles {
+		roles[i].Permissions = permissions[roles[i].Name]
+		if roles[i].Permissions == nil {
+			roles[i].Permissions = []string{}
+		}
+	}
+
+	return roles, nil
+}
+
+// SetRequireTwoFactorTx sets whether users with a role must log in with a
+// second factor inside tx
+func (r *RoleRepository) SetRequireTwoFactorTx(tx *sql.Tx, role *models.Role, required bool) error {
+	query := `UPDATE roles SET require_two_factor = $1, updated_at = $2 WHERE name = $3`
+
+	now := time.Now()
+	result, err := tx.Exec(query, required, now, role.Name)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("role not found")
+	}
+
+	role.RequireTwoFactor = required
+	role.UpdatedAt = now
+	return nil
+}
+
+// RequiresTwoFactor reports whether users with a role must log in with a
+// second factor
+func (r *RoleRepository) RequiresTwoFactor(role string) (bool, error) {
+	query := `SELECT COALESCE((SELECT require_two_factor FROM roles WHERE name = $1), false)`
+
+	var required bool
+	if err := r.db.QueryRow(query, role).Scan(&required); err != nil {
+		return false, fmt.Errorf("failed to check role: %w", err)
+	}
+
+	return required, nil
+}
+
+// Exists reports whether there is a role with the given name
+func (r *RoleRepository) Exists(name string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`
+
+	var exists bool
+	if err := r.db.QueryRow(query, name).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check role: %w", err)
+	}
+
+	return exists, nil
+}
+
+// CountMembersTx returns how many users and pending invites have a role,
+// inside tx
+func (r *RoleRepository) CountMembersTx(tx *sql.Tx, name string) (int, error) {
+	query := `
+		SELECT (SELECT COUNT(*) FROM users WHERE role = $1)
+			+ (SELECT COUNT(*) FROM user_invites WHERE role = $1 AND accepted_at IS NULL)
+	`
+
+	var count int
+	if err := tx.QueryRow(query, name).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count role members: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetPermissions returns the permissions of a role, sorted by name
+func (r *RoleRepository) GetPermissions(role string) ([]string, error) {
+	return r.getPermissions(r.db, role)
+}
+
+func (r *RoleRepository) getPermissions(q querier, role string) ([]string, error) {
+	query := `SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission`
+
+	rows, err := q.Query(query, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, fmt.Errorf("failed to scan role permission: %w", err)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, nil
+}
+
+// getAllPermissions returns the permissions of every role by role name
+func (r *RoleRepository) getAllPermissions() (map[string][]string, error) {
+	query := `SELECT role, permission FROM role_permissions ORDER BY role, permission`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := make(map[string][]string)
+	for rows.Next() {
+		var role, permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, fmt.Errorf("failed to scan role permission: %w", err)
+		}
+		permissions[role] = append(permissions[role], permission)
+	}
+
+	return permissions, nil
+}
+
+// setPermissions replaces the permissions of a role inside tx
+func (r *RoleRepository) setPermissions(tx *sql.Tx, role string, permissions []string) error {
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role = $1`, role); err != nil {
+		return fmt.Errorf("failed to clear role permissions: %w", err)
+	}
+
+	for _, permission := range permissions {
+		_, err := tx.Exec(`
+			INSERT INTO role_permissions (role, permission)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, role, permission)
+		if err != nil {
+			return fmt.Errorf("failed to add role permission: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func scanRole(row scanner) (*models.Role, error) {
+	role := &models.Role{}
+
+	err := row.Scan(
+		&role.Name,
+		&role.Description,
+		&role.IsSystem,
+		&role.RequireTwoFactor,
+		&role.CreatedAt,
+		&role.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
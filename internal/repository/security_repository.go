@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SecurityRepository stores login throttles and security events
+type SecurityRepository struct {
+	db *database.DB
+}
+
+func NewSecurityRepository(db *database.DB) *SecurityRepository {
+	return &SecurityRepository{db: db}
+}
+
+const loginThrottleColumns = `scope, key, failures, last_failure_at, blocked_until, locked_out`
+
+const securityEventColumns = `id, type, user_id, COALESCE(username, ''), COALESCE(ip_address, ''), COALESCE(details, ''), created_by, created_at`
+
+// GetThrottle returns the throttle of a username or IP, or nil when it has
+// no failed logins
+func (r *SecurityRepository) GetThrottle(scope, key string) (*models.LoginThrottle, error) {
+	query := `SELECT ` + loginThrottleColumns + ` FROM login_throttles WHERE scope = $1 AND key = $2`
+
+	throttle, err := scanLoginThrottle(r.db.QueryRow(query, scope, key))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+
+	return throttle, nil
+}
+
+// Attempt counts a login attempt for a username or IP before its
+// credentials are checked and returns its attempts so far, or ok false when
+// it is blocked until later or has max attempts counted already. Attempts
+// before windowStart are forgotten first, and once a lockout is over one
+// attempt is let through, which locks it out again if it fails. Counting
+// happens in one statement, so parallel logins cannot all get in before the
+// first failure is recorded.
+func (r *SecurityRepository) Attempt(scope, key string, at, windowStart time.Time, max int) (failures int, ok bool, err error) {
+	query := `
+		INSERT INTO login_throttles (scope, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure_at < $4 THEN 1
+				WHEN login_throttles.locked_out THEN $5
+				ELSE login_throttles.failures + 1
+			END,
+			locked_out = false,
+			last_failure_at = $3
+		WHERE (login_throttles.blocked_until IS NULL OR login_throttles.blocked_until <= $3)
+			AND (login_throttles.last_failure_at < $4 OR login_throttles.locked_out OR login_throttles.failures < $5)
+		RETURNING failures
+	`
+
+	err = r.db.QueryRow(query, scope, key, at, windowStart, max).Scan(&failures)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to count login attempt: %w", err)
+	}
+
+	return failures, true, nil
+}
+
+// Release takes back a counted attempt of a username or IP that turned out
+// not to be a failure
+func (r *SecurityRepository) Release(scope, key string) error {
+	query := `UPDATE login_throttles SET failures = GREATEST(failures - 1, 0) WHERE scope = $1 AND key = $2`
+
+	if _, err := r.db.Exec(query, scope, key); err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// Block refuses logins for a username or IP until the given time. A block
+// that lasts longer already is kept, so that parallel failures cannot cut
+// a lockout short.
+func (r *SecurityRepository) Block(scope, key string, until time.Time, lockedOut bool) error {
+	query := `
+		UPDATE login_throttles SET
+			blocked_until = CASE WHEN blocked_until > $1 THEN blocked_until ELSE $1 END,
+			locked_out = CASE WHEN blocked_until > $1 THEN locked_out ELSE $2 END
+		WHERE scope = $3 AND key = $4
+	`
+
+	if _, err := r.db.Exec(query, until, lockedOut, scope, key); err != nil {
+		return fmt.Errorf("failed to block logins: %w", err)
+	}
+
+	return nil
+}
+
+// ResetThrottle forgets the failed logins of a username or IP. It reports
+// whether it was locked out.
+func (r *SecurityRepository) ResetThrottle(scope, key string) (bool, error) {
+	query := `
+		DELETE FROM login_throttles WHERE scope = $1 AND key = $2
+		RETURNING locked_out AND blocked_until > NOW()
+	`
+
+	var locked bool
+	err := r.db.QueryRow(query, scope, key).Scan(&locked)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+
+	return locked, nil
+}
+
+// GetLockouts returns the usernames and IPs that are locked out now
+func (r *SecurityRepository) GetLockouts() ([]models.LoginThrottle, error) {
+	query := `
+		SELECT ` + loginThrottleColumns + ` FROM login_throttles
+		WHERE locked_out AND blocked_until > $1
+		ORDER BY blocked_until DESC
+	`
+
+	rows, err := r.db.Query(query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lockouts: %w", err)
+	}
+	defer rows.Close()
+
+	lockouts := []models.LoginThrottle{}
+	for rows.Next() {
+		throttle, err := scanLoginThrottle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lockout: %w", err)
+		}
+		lockouts = append(lockouts, *throttle)
+	}
+
+	return lockouts, nil
+}
+
+// DeleteStaleThrottles removes throttles that block nothing and whose
+// failures are all before windowStart
+func (r *SecurityRepository) DeleteStaleThrottles(windowStart time.Time) error {
+	query := `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < $1 AND (blocked_until IS NULL OR blocked_until < $2)
+	`
+
+	if _, err := r.db.Exec(query, windowStart, time.Now()); err != nil {
+		return fmt.Errorf("failed to delete stale login throttles: %w", err)
+	}
+
+	return nil
+}
+
+// CreateEvent records a security event
+func (r *SecurityRepository) CreateEvent(event *models.SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (id, type, user_id, username, ip_address, details, created_by, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8)
+	`
+
+	event.ID = uuid.New()
+	event.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(query,
+		event.ID,
+		event.Type,
+		event.UserID,
+		event.Username,
+		event.IPAddress,
+		event.Details,
+		event.CreatedBy,
+		event.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvents returns the latest security events, newest first, optionally
+// only those of one user
+func (r *SecurityRepository) GetEvents(userID *uuid.UUID, limit int) ([]models.SecurityEvent, error) {
+	query := `
+		SELECT ` + securityEventColumns + ` FROM security_events
+		WHERE $1::uuid IS NULL OR user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query security events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.SecurityEvent{}
+	for rows.Next() {
+		event := models.SecurityEvent{}
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.UserID,
+			&event.Username,
+			&event.IPAddress,
+			&event.Details,
+			&event.CreatedBy,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan security event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func scanLoginThrottle(row scanner) (*models.LoginThrottle, error) {
+	throttle := &models.LoginThrottle{}
+
+	err := row.Scan(
+		&throttle.Scope,
+		&throttle.Key,
+		&throttle.Failures,
+		&throttle.LastFailureAt,
+		&throttle.BlockedUntil,
+		&throttle.LockedOut,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return throttle, nil
+}
@@ -0,0 +1,393 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+
+	"github.com/google/uuid"
+)
+
+type ShiftRepository struct {
+	db *database.DB
+}
+
+func NewShiftRepository(db *database.DB) *ShiftRepository {
+	return &ShiftRepository{db: db}
+}
+
+const shiftColumns = `id, user_id, COALESCE(register, ''), status, opening_float, expected_cash, counted_cash, variance,
+		COALESCE(notes, ''), opened_at, closed_at`
+
+// WithTx runs fn inside a database transaction
+func (r *ShiftRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// CreateTx inserts a shift inside tx
+func (r *ShiftRepository) CreateTx(tx *sql.Tx, shift *models.Shift) error {
+	query := `
+		INSERT INTO shifts (id, user_id, register, status, opening_float, notes, opened_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), $7)
+	`
+
+	shift.ID = uuid.New()
+	shift.OpenedAt = time.Now()
+
+	_, err := tx.Exec(query,
+		shift.ID,
+		shift.UserID,
+		shift.Register,
+		shift.Status,
+		shift.OpeningFloat,
+		shift.Notes,
+		shift.OpenedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create shift: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ShiftRepository) GetByID(id uuid.UUID) (*models.Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts WHERE id = $1`
+
+	shift, err := scanShift(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("shift not found")
+		}
+		return nil, fmt.Errorf("failed to get shift: %w", err)
+	}
+
+	return shift, nil
+}
+
+func (r *ShiftRepository) GetAll() ([]*models.Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts ORDER BY opened_at DESC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shifts: %w", err)
+	}
+	defer rows.Close()
+
+	var shifts []*models.Shift
+	for rows.Next() {
+		shift, err := scanShift(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shift: %w", err)
+		}
+		shifts = append(shifts, shift)
+	}
+
+	return shifts, nil
+}
+
+// GetOpenByUserID returns the open shift of a user, or nil when there is none
+func (r *ShiftRepository) GetOpenByUserID(userID uuid.UUID) (*models.Shift, error) {
+	return r.getOpenByUserID(r.db, userID, "")
+}
+
+// GetOpenByUserIDTx returns the open shift of a user, or nil when there is
+// none, and keeps it from being closed until tx ends. Any number of
+// transactions can hold the shift this way at the same time.
+func (r *ShiftRepository) GetOpenByUserIDTx(tx *sql.Tx, userID uuid.UUID) (*models.Shift, error) {
+	return r.getOpenByUserID(tx, userID, "FOR SHARE")
+}
+
+// LockOpenByUserID returns the open shift of a user, or nil when there is
+// none, and locks it for the rest of tx. It waits for transactions holding
+// the shift through GetOpenByUserIDTx to finish first.
+func (r *ShiftRepository) LockOpenByUserID(tx *sql.Tx, userID uuid.UUID) (*models.Shift, error) {
+	return r.getOpenByUserID(tx, userID, "FOR UPDATE")
+}
+
+func (r *ShiftRepository) getOpenByUserID(q querier, userID uuid.UUID, lock string) (*models.Shift, error) {
+	query := `SELECT ` + shiftColumns + ` FROM shifts WHERE user_id = $1 AND status = 'open' ` + lock
+
+	shift, err := scanShift(q.QueryRow(query, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get open shift: %w", err)
+	}
+
+	return shift, nil
+}
+
+// CloseTx records the counted cash of a shift and closes it inside tx
+func (r *ShiftRepository) CloseTx(tx *sql.Tx, shift *models.Shift) error {
+	query := `
+		UPDATE shifts
+		SET status = 'closed', expected_cash = $1, counted_cash = $2, variance = $3, notes = NULLIF($4, ''), closed_at = $5
+		WHERE id = $6 AND status = 'open'
+	`
+
+	closedAt := time.Now()
+
+	result, err := tx.Exec(query,
+		shift.ExpectedCash,
+		shift.CountedCash,
+		shift.Variance,
+		shift.Notes,
+		closedAt,
+		shift.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close shift: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("shift is not open")
+	}
+
+	shift.Status = "closed"
+	shift.ClosedAt = &closedAt
+
+	return nil
+}
+
+// CreateMovementTx records cash put into or taken out of a drawer inside tx
+func (r *ShiftRepository) CreateMovementTx(tx *sql.Tx, movement *models.CashMovement) error {
+	query := `
+		INSERT INTO cash_movements (id, shift_id, type, amount, reason, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	movement.ID = uuid.New()
+	movement.CreatedAt = time.Now()
+
+	_, err := tx.Exec(query,
+		movement.ID,
+		movement.ShiftID,
+		movement.Type,
+		movement.Amount,
+		movement.Reason,
+		movement.CreatedBy,
+		movement.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create cash movement: %w", err)
+	}
+
+	return nil
+}
+
+// GetMovements returns the cash movements of a shift, oldest first
+func (r *ShiftRepository) GetMovements(tx *sql.Tx, shiftID uuid.UUID) ([]models.CashMovement, error) {
+	query := `
+		SELECT id, shift_id, type, amount, reason, created_by, created_at
+		FROM cash_movements
+		WHERE shift_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := tx.Query(query, shiftID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cash movements: %w", err)
+	}
+	defer rows.Close()
+
+	var movements []models.CashMovement
+	for rows.Next() {
+		var movement models.CashMovement
+
+		err := rows.Scan(
+			&movement.ID,
+			&movement.ShiftID,
+			&movement.Type,
+			&movement.Amount,
+			&movement.Reason,
+			&movement.CreatedBy,
+			&movement.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cash movement: %w", err)
+		}
+
+		movements = append(movements, movement)
+	}
+
+	return movements, nil
+}
+
+// GetTenderTotals returns the sales and refunds taken on a shift per payment
+// method, together with the change given on cash sales
+func (r *ShiftRepository) GetTenderTotals(tx *sql.Tx, shiftID uuid.UUID) ([]models.ShiftTender, money.Amount, error) {
+	query := `
+		SELECT payment_method,
+			COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0),
+			COALESCE(SUM(change_amount), 0)
+		FROM payments
+		WHERE shift_id = $1 AND status IN ('completed', 'refunded')
+		GROUP BY payment_method
+		ORDER BY payment_method
+	`
+
+	rows, err := tx.Query(query, shiftID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query shift tenders: %w", err)
+	}
+	defer rows.Close()
+
+	var tenders []models.ShiftTender
+	var change money.Amount
+	for rows.Next() {
+		var tender models.ShiftTender
+		var tenderChange money.Amount
+
+		if err := rows.Scan(&tender.PaymentMethod, &tender.Sales, &tender.Refunds, &tenderChange); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan shift tender: %w", err)
+		}
+
+		tenders = append(tenders, tender)
+		change += tenderChange
+	}
+
+	return tenders, change, nil
+}
+
+// GetRefundTotals returns the number of refunds paid out on a shift and
+// their total
+func (r *ShiftRepository) GetRefundTotals(tx *sql.Tx, shiftID uuid.UUID) (int, money.Amount, error) {
+	query := `
+		SELECT COUNT(DISTINCT refund_id), COALESCE(-SUM(amount), 0)
+		FROM payments
+		WHERE shift_id = $1 AND refund_id IS NOT NULL
+	`
+
+	var count int
+	var total money.Amount
+	if err := tx.QueryRow(query, shiftID).Scan(&count, &total); err != nil {
+		return 0, 0, fmt.Errorf("failed to get shift refunds: %w", err)
+	}
+
+	return count, total, nil
+}
+
+// GetVoidTotals returns the number and total of the orders the shift's
+// cashier cancelled while the shift was open
+func (r *ShiftRepository) GetVoidTotals(tx *sql.Tx, shift *models.Shift) (int, money.Amount, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(o.total_amount), 0)
+		FROM order_status_history h
+		JOIN orders o ON h.order_id = o.id
+		WHERE h.to_status = 'cancelled'
+		  AND h.changed_by = $1
+		  AND h.created_at >= $2
+		  AND ($3::timestamptz IS NULL OR h.created_at <= $3)
+	`
+
+	var count int
+	var total money.Amount
+	if err := tx.QueryRow(query, shift.UserID, shift.OpenedAt, shift.ClosedAt).Scan(&count, &total); err != nil {
+		return 0, 0, fmt.Errorf("failed to get shift voids: %w", err)
+	}
+
+	return count, total, nil
+}
+
+// GetDiscountTotal returns the order and line discounts of the orders paid
+// for on a shift
+func (r *ShiftRepository) GetDiscountTotal(tx *sql.Tx, shiftID uuid.UUID) (money.Amount, error) {
+	query := `
+		SELECT COALESCE(SUM(o.discount_amount + COALESCE(i.discount, 0)), 0)
+		FROM orders o
+		LEFT JOIN (SELECT order_id, SUM(discount) AS discount FROM order_items GROUP BY order_id) i ON i.order_id = o.id
+		WHERE o.id IN (SELECT order_id FROM payments WHERE shift_id = $1 AND amount > 0)
+	`
+
+	var total money.Amount
+	if err := tx.QueryRow(query, shiftID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to get shift discounts: %w", err)
+	}
+
+	return total, nil
+}
+
+// CreateTenderCountsTx records what was expected and counted per payment
+// method when a shift is closed
+func (r *ShiftRepository) CreateTenderCountsTx(tx *sql.Tx, shiftID uuid.UUID, tenders []models.ShiftTender) error {
+	query := `
+		INSERT INTO shift_tender_counts (shift_id, payment_method, expected, counted)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	for _, tender := range tenders {
+		if tender.Counted == nil {
+			continue
+		}
+
+		if _, err := tx.Exec(query, shiftID, tender.PaymentMethod, tender.Expected, *tender.Counted); err != nil {
+			return fmt.Errorf("failed to create shift tender count: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetTenderCounts returns what was counted per payment method when a shift
+// was closed
+func (r *ShiftRepository) GetTenderCounts(tx *sql.Tx, shiftID uuid.UUID) (map[string]money.Amount, error) {
+	query := `SELECT payment_method, counted FROM shift_tender_counts WHERE shift_id = $1`
+
+	rows, err := tx.Query(query, shiftID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shift tender counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]money.Amount)
+	for rows.Next() {
+		var method string
+		var counted money.Amount
+
+		if err := rows.Scan(&method, &counted); err != nil {
+			return nil, fmt.Errorf("failed to scan shift tender count: %w", err)
+		}
+
+		counts[method] = counted
+	}
+
+	return counts, nil
+}
+
+func scanShift(row scanner) (*models.Shift, error) {
+	shift := &models.Shift{}
+
+	err := row.Scan(
+		&shift.ID,
+		&shift.UserID,
+		&shift.Register,
+		&shift.Status,
+		&shift.OpeningFloat,
+		&shift.ExpectedCash,
+		&shift.CountedCash,
+		&shift.Variance,
+		&shift.Notes,
+		&shift.OpenedAt,
+		&shift.ClosedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return shift, nil
+}
@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+
+	"github.com/google/uuid"
+)
+
+type SupplierRepository struct {
+	db *database.DB
+}
+
+func NewSupplierRepository(db *database.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+// WithTx runs fn inside a database transaction, so that supplier changes can
+// be audited together with them
+func (r *SupplierRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+const supplierColumns = `id, name, COALESCE(contact_name, ''), COALESCE(email, ''), COALESCE(phone, ''), COALESCE(address, ''), COALESCE(notes, ''), is_active, created_at, updated_at`
+
+// CreateTx inserts an active supplier inside tx
+func (r *SupplierRepository) CreateTx(tx *sql.Tx, supplier *models.Supplier) error {
+	query := `
+		INSERT INTO suppliers (id, name, contact_name, email, phone, address, notes, is_active, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), true, $8, $9)
+	`
+
+	now := time.Now()
+	supplier.ID = uuid.New()
+	supplier.IsActive = true
+	supplier.CreatedAt = now
+	supplier.UpdatedAt = now
+
+	_, err := tx.Exec(query,
+		supplier.ID,
+		supplier.Name,
+		supplier.ContactName,
+		supplier.Email,
+		supplier.Phone,
+		supplier.Address,
+		supplier.Notes,
+		supplier.CreatedAt,
+		supplier.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create supplier: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SupplierRepository) GetByID(id uuid.UUID) (*models.Supplier, error) {
+	query := `SELECT ` + supplierColumns + ` FROM suppliers WHERE id = $1`
+
+	supplier, err := scanSupplier(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("supplier not found")
+		}
+		return nil, fmt.Errorf("failed to get supplier: %w", err)
+	}
+
+	return supplier, nil
+}
+
+// Lock locks a supplier for the rest of tx and returns it. Purchase orders
+// cannot be raised with it until tx ends.
+func (r *SupplierRepository) Lock(tx *sql.Tx, id uuid.UUID) (*models.Supplier, error) {
+	query := `SELECT ` + supplierColumns + ` FROM suppliers WHERE id = $1 FOR UPDATE`
+
+	supplier, err := scanSupplier(tx.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("supplier not found")
+		}
+		return nil, fmt.Errorf("failed to lock supplier: %w", err)
+	}
+
+	return supplier, nil
+}
+
+// GetAll returns every supplier, active ones first, by name
+func (r *SupplierRepository) GetAll() ([]models.Supplier, error) {
+	query := `SELECT ` + supplierColumns + ` FROM suppliers ORDER BY is_active DESC, name ASC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query suppliers: %w", err)
+	}
+	defer rows.Close()
+
+	suppliers := []models.Supplier{}
+	for rows.Next() {
+		supplier, err := scanSupplier(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan supplier: %w", err)
+		}
+		suppliers = append(suppliers, *supplier)
+	}
+
+	return suppliers, rows.Err()
+}
+
+// ExistsByName reports whether a supplier other than exceptID has the given
+// name
+func (r *SupplierRepository) ExistsByName(name string, exceptID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM suppliers WHERE LOWER(name) = LOWER($1) AND id <> $2)`
+
+	var exists bool
+	if err := r.db.QueryRow(query, name, exceptID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check supplier: %w", err)
+	}
+
+	return exists, nil
+}
+
+// UpdateTx saves a supplier inside tx
+func (r *SupplierRepository) UpdateTx(tx *sql.Tx, supplier *models.Supplier) error {
+	query := `
+		UPDATE suppliers
+		SET name = $1, contact_name = NULLIF($2, ''), email = NULLIF($3, ''), phone = NULLIF($4, ''),
+		    address = NULLIF($5, ''), notes = NULLIF($6, ''), is_active = $7, updated_at = $8
+		WHERE id = $9
+	`
+
+	supplier.UpdatedAt = time.Now()
+
+	result, err := tx.Exec(query,
+		supplier.Name,
+		supplier.ContactName,
+		supplier.Email,
+		supplier.Phone,
+		supplier.Address,
+		supplier.Notes,
+		supplier.IsActive,
+		supplier.UpdatedAt,
+		supplier.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update supplier: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("supplier not found")
+	}
+
+	return nil
+}
+
+// DeleteTx deletes a supplier inside tx
+func (r *SupplierRepository) DeleteTx(tx *sql.Tx, id uuid.UUID) error {
+	query := `DELETE FROM suppliers WHERE id = $1`
+
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete supplier: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("supplier not found")
+	}
+
+	return nil
+}
+
+// CountPurchaseOrdersTx returns how many purchase orders a supplier has
+// inside tx
+func (r *SupplierRepository) CountPurchaseOrdersTx(tx *sql.Tx, id uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM purchase_orders WHERE supplier_id = $1`
+
+	var count int
+	if err := tx.QueryRow(query, id).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count purchase orders: %w", err)
+	}
+
+	return count, nil
+}
+
+// AddCostTx records what a supplier charged for a product inside tx
+func (r *SupplierRepository) AddCostTx(tx *sql.Tx, cost *models.SupplierCost) error {
+	query := `
+		INSERT INTO supplier_costs (id, supplier_id, product_id, purchase_order_line_id, quantity, unit_cost, received_by, received_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	cost.ID = uuid.New()
+	if cost.ReceivedAt.IsZero() {
+		cost.ReceivedAt = time.Now()
+	}
+
+	_, err := tx.Exec(query,
+		cost.ID,
+		cost.SupplierID,
+		cost.ProductID,
+		cost.PurchaseOrderLineID,
+		cost.Quantity,
+		cost.UnitCost,
+		cost.ReceivedBy,
+		cost.ReceivedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record supplier cost: %w", err)
+	}
+
+	return nil
+}
+
+// GetCosts returns what a supplier charged, newest first, optionally only
+// for one product
+func (r *SupplierRepository) GetCosts(supplierID uuid.UUID, productID *uuid.UUID) ([]models.SupplierCost, error) {
+	query := `
+		SELECT c.id, c.supplier_id, c.product_id, c.purchase_order_line_id, COALESCE(po.po_number, ''),
+		       c.quantity, c.unit_cost, c.received_by, c.received_at
+		FROM supplier_costs c
+		LEFT JOIN purchase_order_lines l ON l.id = c.purchase_order_line_id
+		LEFT JOIN purchase_orders po ON po.id = l.purchase_order_id
+		WHERE c.supplier_id = $1 AND ($2::uuid IS NULL OR c.product_id = $2)
+		ORDER BY c.received_at DESC
+	`
+
+	rows, err := r.db.Query(query, supplierID, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query supplier costs: %w", err)
+	}
+	defer rows.Close()
+
+	costs := []models.SupplierCost{}
+	for rows.Next() {
+		var cost models.SupplierCost
+		err := rows.Scan(
+			&cost.ID,
+			&cost.SupplierID,
+			&cost.ProductID,
+			&cost.PurchaseOrderLineID,
+			&cost.PONumber,
+			&cost.Quantity,
+			&cost.UnitCost,
+			&cost.ReceivedBy,
+			&cost.ReceivedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan supplier cost: %w", err)
+		}
+		costs = append(costs, cost)
+	}
+
+	return costs, rows.Err()
+}
+
+// GetLastCost returns what a supplier charged for a product the last time
+// it was received. ok is false when it never has been.
+func (r *SupplierRepository) GetLastCost(supplierID, productID uuid.UUID) (cost money.Amount, ok bool, err error) {
+	query := `
+		SELECT unit_cost FROM supplier_costs
+		WHERE supplier_id = $1 AND product_id = $2
+		ORDER BY received_at DESC
+		LIMIT 1
+	`
+
+	if err := r.db.QueryRow(query, supplierID, productID).Scan(&cost); err != nil {
+		if err == sql.ErrNoRows {
+			return money.Zero, false, nil
+		}
+		return money.Zero, false, fmt.Errorf("failed to get supplier cost: %w", err)
+	}
+
+	return cost, true, nil
+}
+
+func scanSupplier(row scanner) (*models.Supplier, error) {
+	supplier := &models.Supplier{}
+
+	err := row.Scan(
+		&supplier.ID,
+		&supplier.Name,
+		&supplier.ContactName,
+		&supplier.Email,
+		&supplier.Phone,
+		&supplier.Address,
+		&supplier.Notes,
+		&supplier.IsActive,
+		&supplier.CreatedAt,
+		&supplier.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return supplier, nil
+}
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type TaxRepository struct {
+	db *database.DB
+}
+
+func NewTaxRepository(db *database.DB) *TaxRepository {
+	return &TaxRepository{db: db}
+}
+
+func (r *TaxRepository) CreateClass(class *models.TaxClass) error {
+	query := `
+		INSERT INTO tax_classes (id, name, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	now := time.Now()
+	class.ID = uuid.New()
+	class.CreatedAt = now
+	class.UpdatedAt = now
+
+	_, err := r.db.Exec(query,
+		class.ID,
+		class.Name,
+		class.Description,
+		class.CreatedAt,
+		class.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create tax class: %w", err)
+	}
+
+	return nil
+}
+
+// GetClassByID returns a tax class with all of its rates, newest first
+func (r *TaxRepository) GetClassByID(id uuid.UUID) (*models.TaxClass, error) {
+	query := `
+		SELECT id, name, COALESCE(description, ''), created_at, updated_at
+		FROM tax_classes
+		WHERE id = $1
+	`
+
+	class := &models.TaxClass{}
+
+	err := r.db.QueryRow(query, id).Scan(
+		&class.ID,
+		&class.Name,
+		&class.Description,
+		&class.CreatedAt,
+		&class.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("tax class not found")
+		}
+		return nil, fmt.Errorf("failed to get tax class: %w", err)
+	}
+
+	rates, err := r.GetRates(id)
+	if err != nil {
+		return nil, err
+	}
+	class.Rates = rates
+
+	return class, nil
+}
+
+func (r *TaxRepository) GetAllClasses() ([]*models.TaxClass, error) {
+	query := `
+		SELECT id, name, COALESCE(description, ''), created_at, updated_at
+		FROM tax_classes
+		ORDER BY name ASC
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tax classes: %w", err)
+	}
+	defer rows.Close()
+
+	var classes []*models.TaxClass
+	for rows.Next() {
+		class := &models.TaxClass{}
+
+		err := rows.Scan(
+			&class.ID,
+			&class.Name,
+			&class.Description,
+			&class.CreatedAt,
+			&class.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tax class: %w", err)
+		}
+
+		classes = append(classes, class)
+	}
+
+	return classes, nil
+}
+
+func (r *TaxRepository) UpdateClass(class *models.TaxClass) error {
+	query := `
+		UPDATE tax_classes
+		SET name = $1, description = $2, updated_at = $3
+		WHERE id = $4
+	`
+
+	class.UpdatedAt = time.Now()
+
+	result, err := r.db.Exec(query,
+		class.Name,
+		class.Description,
+		class.UpdatedAt,
+		class.ID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update tax class: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("tax class not found")
+	}
+
+	return nil
+}
+
+func (r *TaxRepository) DeleteClass(id uuid.UUID) error {
+	query := `DELETE FROM tax_classes WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tax class: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("tax class not found")
+	}
+
+	return nil
+}
+
+// WithTx runs fn inside a database transaction
+func (r *TaxRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// GetLatestRateTx locks and returns the rate of a class with the latest
+// effective date, or nil when the class has no rates yet
+func (r *TaxRepository) GetLatestRateTx(tx *sql.Tx, classID uuid.UUID) (*models.TaxRate, error) {
+	query := `
+		SELECT id, tax_class_id, rate, effective_from, effective_to, created_at
+		FROM tax_rates
+		WHERE tax_class_id = $1
+		ORDER BY effective_from DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	rate := &models.TaxRate{}
+	err := tx.QueryRow(query, classID).Scan(
+		&rate.ID,
+		&rate.TaxClassID,
+		&rate.Rate,
+		&rate.EffectiveFrom,
+		&rate.EffectiveTo,
+		&rate.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest tax rate: %w", err)
+	}
+
+	return rate, nil
+}
+
+// EndRateTx sets the date a rate stops being in effect
+func (r *TaxRepository) EndRateTx(tx *sql.Tx, id uuid.UUID, effectiveTo time.Time) error {
+	query := `UPDATE tax_rates SET effective_to = $1 WHERE id = $2`
+
+	if _, err := tx.Exec(query, effectiveTo, id); err != nil {
+		return fmt.Errorf("failed to end tax rate: %w", err)
+	}
+
+	return nil
+}
+
+// CreateRateTx inserts a tax rate inside tx
+func (r *TaxRepository) CreateRateTx(tx *sql.Tx, rate *models.TaxRate) error {
+	query := `
+		INSERT INTO tax_rates (id, tax_class_id, rate, effective_from, effective_to, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	rate.ID = uuid.New()
+	rate.CreatedAt = time.Now()
+
+	_, err := tx.Exec(query,
+		rate.ID,
+		rate.TaxClassID,
+		rate.Rate,
+		rate.EffectiveFrom,
+		rate.EffectiveTo,
+		rate.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create tax rate: %w", err)
+	}
+
+	return nil
+}
+
+// GetRates returns every rate of a class, newest first
+func (r *TaxRepository) GetRates(classID uuid.UUID) ([]models.TaxRate, error) {
+	query := `
+		SELECT id, tax_class_id, rate, effective_from, effective_to, created_at
+		FROM tax_rates
+		WHERE tax_class_id = $1
+		ORDER BY effective_from DESC
+	`
+
+	rows, err := r.db.Query(query, classID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tax rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []models.TaxRate
+	for rows.Next() {
+		var rate models.TaxRate
+
+		err := rows.Scan(
+			&rate.ID,
+			&rate.TaxClassID,
+			&rate.Rate,
+			&rate.EffectiveFrom,
+			&rate.EffectiveTo,
+			&rate.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tax rate: %w", err)
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+// GetProductTaxClass returns the tax class that applies to a product, which
+// is the product's own class or else its category's, together with the rate
+// in effect at the given time as its only rate. It returns nil when the
+// product has no tax class, and a class without rates when none is in effect.
+func (r *TaxRepository) GetProductTaxClass(productID uuid.UUID, at time.Time) (*models.TaxClass, error) {
+	query := `
+		SELECT tc.id, tc.name, COALESCE(tc.description, ''), tc.created_at, tc.updated_at,
+		       tr.id, tr.rate, tr.effective_from, tr.effective_to, tr.created_at
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		JOIN tax_classes tc ON tc.id = COALESCE(p.tax_class_id, c.tax_class_id)
+		LEFT JOIN tax_rates tr ON tr.tax_class_id = tc.id
+			AND tr.effective_from <= $2
+			AND (tr.effective_to IS NULL OR tr.effective_to > $2)
+		WHERE p.id = $1
+		ORDER BY tr.effective_from DESC
+		LIMIT 1
+	`
+
+	class := &models.TaxClass{}
+	var rateID uuid.NullUUID
+	var rate models.TaxRate
+	var effectiveFrom, createdAt sql.NullTime
+
+	err := r.db.QueryRow(query, productID, at).Scan(
+		&class.ID,
+		&class.Name,
+		&class.Description,
+		&class.CreatedAt,
+		&class.UpdatedAt,
+		&rateID,
+		&rate.Rate,
+		&effectiveFrom,
+		&rate.EffectiveTo,
+		&createdAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get product tax class: %w", err)
+	}
+
+	if rateID.Valid {
+		rate.ID = rateID.UUID
+		rate.TaxClassID = class.ID
+		rate.EffectiveFrom = effectiveFrom.Time
+		rate.CreatedAt = createdAt.Time
+		class.Rates = []models.TaxRate{rate}
+	}
+
+	return class, nil
+}
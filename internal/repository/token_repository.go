@@ -0,0 +1,503 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type TokenRepository struct {
+	db *database.DB
+}
+
+func NewTokenRepository(db *database.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+const refreshTokenColumns = `id, user_id, family_id, access_jti, access_expires_at, expires_at, used_at, revoked_at, created_at`
+
+const approvalTokenColumns = `id, approver_id, permissions, expires_at, used_at, used_by, created_at`
+
+const loginChallengeColumns = `id, user_id, attempts, expires_at, created_at`
+
+const passwordResetColumns = `id, user_id, expires_at, used_at, created_at`
+
+// WithTx runs fn inside a database transaction
+func (r *TokenRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// CreateRefreshToken stores a refresh token under the hash of its token
+func (r *TokenRepository) CreateRefreshToken(token *models.RefreshToken, tokenHash string) error {
+	return r.createRefreshToken(r.db, token, tokenHash)
+}
+
+// CreateRefreshTokenTx stores a refresh token inside tx
+func (r *TokenRepository) CreateRefreshTokenTx(tx *sql.Tx, token *models.RefreshToken, tokenHash string) error {
+	return r.createRefreshToken(tx, token, tokenHash)
+}
+
+func (r *TokenRepository) createRefreshToken(q querier, token *models.RefreshToken, tokenHash string) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, access_jti, access_expires_at, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now()
+
+	_, err := q.Exec(query,
+		token.ID,
+		token.UserID,
+		token.FamilyID,
+		tokenHash,
+		token.AccessJTI,
+		token.AccessExpiresAt,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// LockRefreshTokenByHash locks the refresh token with the given hash for the
+// rest of tx. It returns nil when there is no such token.
+func (r *TokenRepository) LockRefreshTokenByHash(tx *sql.Tx, tokenHash string) (*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`
+
+	token, err := scanRefreshToken(tx.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// MarkRefreshTokenUsedTx records that a refresh token was exchanged for its
+// successor, after which presenting it again is a reuse
+func (r *TokenRepository) MarkRefreshTokenUsedTx(tx *sql.Tx, token *models.RefreshToken) error {
+	query := `UPDATE refresh_tokens SET used_at = $1 WHERE id = $2`
+
+	now := time.Now()
+	if _, err := tx.Exec(query, now, token.ID); err != nil {
+		return fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	token.UsedAt = &now
+	return nil
+}
+
+// RevokeFamily revokes every refresh token of a session and the access
+// tokens issued with them
+func (r *TokenRepository) RevokeFamily(familyID uuid.UUID) error {
+	return r.revoke(r.db, `family_id = $1`, familyID)
+}
+
+// RevokeFamilyTx revokes a session inside tx
+func (r *TokenRepository) RevokeFamilyTx(tx *sql.Tx, familyID uuid.UUID) error {
+	return r.revoke(tx, `family_id = $1`, familyID)
+}
+
+// RevokeUser revokes every session of a user
+func (r *TokenRepository) RevokeUser(userID uuid.UUID) error {
+	return r.revoke(r.db, `user_id = $1`, userID)
+}
+
+// RevokeUserTx revokes every session of a user inside tx
+func (r *TokenRepository) RevokeUserTx(tx *sql.Tx, userID uuid.UUID) error {
+	return r.revoke(tx, `user_id = $1`, userID)
+}
+
+// revoke revokes the refresh tokens matching where, whose only parameter is
+// id, and adds the access tokens issued with them to the revoked tokens
+// until they expire
+func (r *TokenRepository) revoke(q querier, where string, id uuid.UUID) error {
+	query := `
+		WITH revoked AS (
+			UPDATE refresh_tokens SET revoked_at = $2
+			WHERE ` + where + ` AND revoked_at IS NULL
+			RETURNING access_jti, access_expires_at
+		)
+		INSERT INTO revoked_tokens (jti, expires_at, revoked_at)
+		SELECT access_jti, access_expires_at, $2 FROM revoked WHERE access_expires_at > $2
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	if _, err := q.Exec(query, id, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAccessToken revokes a single access token until it expires
+func (r *TokenRepository) RevokeAccessToken(jti uuid.UUID, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at, revoked_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	if _, err := r.db.Exec(query, jti, expiresAt, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether the access token with the given jti
+// was revoked
+func (r *TokenRepository) IsAccessTokenRevoked(jti uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	var revoked bool
+	if err := r.db.QueryRow(query, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check access token: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// CreateApprovalToken stores an approval token under the hash of its token
+func (r *TokenRepository) CreateApprovalToken(token *models.ApprovalToken, tokenHash string) error {
+	query := `
+		INSERT INTO approval_tokens (id, token_hash, approver_id, permissions, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(query,
+		token.ID,
+		tokenHash,
+		token.ApproverID,
+		pq.Array(token.Permissions),
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create approval token: %w", err)
+	}
+
+	return nil
+}
+
+// LockApprovalTokenByHash locks the approval token with the given hash for
+// the rest of tx. It returns nil when there is no such token.
+func (r *TokenRepository) LockApprovalTokenByHash(tx *sql.Tx, tokenHash string) (*models.ApprovalToken, error) {
+	query := `SELECT ` + approvalTokenColumns + ` FROM approval_tokens WHERE token_hash = $1 FOR UPDATE`
+
+	token, err := scanApprovalToken(tx.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock approval token: %w", err)
+	}
+
+	return token, nil
+}
+
+// MarkApprovalTokenUsedTx records that an approval token was presented by
+// usedBy, after which it cannot be used again
+func (r *TokenRepository) MarkApprovalTokenUsedTx(tx *sql.Tx, token *models.ApprovalToken, usedBy uuid.UUID) error {
+	query := `UPDATE approval_tokens SET used_at = $1, used_by = $2 WHERE id = $3`
+
+	now := time.Now()
+	if _, err := tx.Exec(query, now, usedBy, token.ID); err != nil {
+		return fmt.Errorf("failed to mark approval token used: %w", err)
+	}
+
+	token.UsedAt = &now
+	token.UsedBy = &usedBy
+	return nil
+}
+
+// CreateLoginChallenge stores a login challenge under the hash of its token
+func (r *TokenRepository) CreateLoginChallenge(challenge *models.LoginChallenge, tokenHash string) error {
+	query := `
+		INSERT INTO login_challenges (id, token_hash, user_id, attempts, expires_at, created_at)
+		VALUES ($1, $2, $3, 0, $4, $5)
+	`
+
+	challenge.ID = uuid.New()
+	challenge.Attempts = 0
+	challenge.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(query,
+		challenge.ID,
+		tokenHash,
+		challenge.UserID,
+		challenge.ExpiresAt,
+		challenge.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create login challenge: %w", err)
+	}
+
+	return nil
+}
+
+// LockLoginChallengeByHash locks the login challenge with the given hash for
+// the rest of tx. It returns nil when there is no such challenge.
+func (r *TokenRepository) LockLoginChallengeByHash(tx *sql.Tx, tokenHash string) (*models.LoginChallenge, error) {
+	query := `SELECT ` + loginChallengeColumns + ` FROM login_challenges WHERE token_hash = $1 FOR UPDATE`
+
+	challenge, err := scanLoginChallenge(tx.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock login challenge: %w", err)
+	}
+
+	return challenge, nil
+}
+
+// RecordChallengeFailureTx counts a wrong code given for a login challenge
+func (r *TokenRepository) RecordChallengeFailureTx(tx *sql.Tx, challenge *models.LoginChallenge) error {
+	query := `UPDATE login_challenges SET attempts = attempts + 1 WHERE id = $1`
+	if _, err := tx.Exec(query, challenge.ID); err != nil {
+		return fmt.Errorf("failed to record login challenge failure: %w", err)
+	}
+
+	challenge.Attempts++
+	return nil
+}
+
+// DeleteLoginChallengeTx removes a login challenge once it was completed
+func (r *TokenRepository) DeleteLoginChallengeTx(tx *sql.Tx, challenge *models.LoginChallenge) error {
+	if _, err := tx.Exec(`DELETE FROM login_challenges WHERE id = $1`, challenge.ID); err != nil {
+		return fmt.Errorf("failed to delete login challenge: %w", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodesTx replaces every recovery code of a user with codes
+// of the given hashes
+func (r *TokenRepository) ReplaceRecoveryCodesTx(tx *sql.Tx, userID uuid.UUID, codeHashes []string) error {
+	if err := r.DeleteRecoveryCodesTx(tx, userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, codeHash := range codeHashes {
+		_, err := tx.Exec(`
+			INSERT INTO recovery_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, uuid.New(), userID, codeHash, now)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteRecoveryCodesTx removes every recovery code of a user
+func (r *TokenRepository) DeleteRecoveryCodesTx(tx *sql.Tx, userID uuid.UUID) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCodeTx marks the unused recovery code of a user with the given
+// hash as used. It reports false when there is no such code.
+func (r *TokenRepository) UseRecoveryCodeTx(tx *sql.Tx, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE recovery_codes SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+	`
+	result, err := tx.Exec(query, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// CreatePasswordReset stores a password reset under the hash of its token,
+// replacing the earlier resets of the user, so that only the newest email
+// works
+func (r *TokenRepository) CreatePasswordReset(reset *models.PasswordResetToken, tokenHash string) error {
+	return r.db.WithTx(func(tx *sql.Tx) error {
+		if err := r.DeletePasswordResetsTx(tx, reset.UserID); err != nil {
+			return err
+		}
+
+		reset.ID = uuid.New()
+		reset.CreatedAt = time.Now()
+
+		_, err := tx.Exec(`
+			INSERT INTO password_reset_tokens (id, token_hash, user_id, expires_at, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, reset.ID, tokenHash, reset.UserID, reset.ExpiresAt, reset.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create password reset: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// LockPasswordResetByHash locks the password reset with the given hash for
+// the rest of tx. It returns nil when there is no such reset.
+func (r *TokenRepository) LockPasswordResetByHash(tx *sql.Tx, tokenHash string) (*models.PasswordResetToken, error) {
+	query := `SELECT ` + passwordResetColumns + ` FROM password_reset_tokens WHERE token_hash = $1 FOR UPDATE`
+
+	reset, err := scanPasswordReset(tx.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock password reset: %w", err)
+	}
+
+	return reset, nil
+}
+
+// MarkPasswordResetUsedTx records that a password reset was used
+func (r *TokenRepository) MarkPasswordResetUsedTx(tx *sql.Tx, reset *models.PasswordResetToken) error {
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = $1 WHERE id = $2`, now, reset.ID); err != nil {
+		return fmt.Errorf("failed to mark password reset used: %w", err)
+	}
+
+	reset.UsedAt = &now
+	return nil
+}
+
+// DeletePasswordResetsTx removes the password resets of a user that have not
+// been used
+func (r *TokenRepository) DeletePasswordResetsTx(tx *sql.Tx, userID uuid.UUID) error {
+	query := `DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`
+	if _, err := tx.Exec(query, userID); err != nil {
+		return fmt.Errorf("failed to delete password resets: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes refresh tokens, revoked access tokens, approval
+// tokens, login challenges and password resets that have expired, since none
+// of them can be used any more
+func (r *TokenRepository) DeleteExpired() error {
+	now := time.Now()
+
+	if _, err := r.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	if _, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+	if _, err := r.db.Exec(`DELETE FROM approval_tokens WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("failed to delete expired approval tokens: %w", err)
+	}
+	if _, err := r.db.Exec(`DELETE FROM login_challenges WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("failed to delete expired login challenges: %w", err)
+	}
+	if _, err := r.db.Exec(`DELETE FROM password_reset_tokens WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("failed to delete expired password resets: %w", err)
+	}
+
+	return nil
+}
+
+func scanRefreshToken(row scanner) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.AccessJTI,
+		&token.AccessExpiresAt,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func scanApprovalToken(row scanner) (*models.ApprovalToken, error) {
+	token := &models.ApprovalToken{}
+
+	err := row.Scan(
+		&token.ID,
+		&token.ApproverID,
+		pq.Array(&token.Permissions),
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.UsedBy,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func scanLoginChallenge(row scanner) (*models.LoginChallenge, error) {
+	challenge := &models.LoginChallenge{}
+
+	err := row.Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func scanPasswordReset(row scanner) (*models.PasswordResetToken, error) {
+	reset := &models.PasswordResetToken{}
+
+	err := row.Scan(
+		&reset.ID,
+		&reset.UserID,
+		&reset.ExpiresAt,
+		&reset.UsedAt,
+		&reset.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reset, nil
+}
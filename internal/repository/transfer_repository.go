@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type TransferRepository struct {
+	db *database.DB
+}
+
+func NewTransferRepository(db *database.DB) *TransferRepository {
+	return &TransferRepository{db: db}
+}
+
+const transferColumns = `id, transfer_number, from_location, to_location, status, COALESCE(notes, ''), created_by, received_by, shipped_at, received_at, cancelled_at, created_at, updated_at`
+
+// CreateTx inserts a shipped transfer and its items inside tx. The transfer
+// number is taken from its own sequence.
+func (r *TransferRepository) CreateTx(tx *sql.Tx, transfer *models.StockTransfer) error {
+	transferQuery := `
+		INSERT INTO stock_transfers (id, transfer_number, from_location, to_location, status, notes, created_by, shipped_at, created_at, updated_at)
+		VALUES ($1, 'TRF-' || nextval('transfer_number_seq'), $2, $3, 'shipped', NULLIF($4, ''), $5, $6, $6, $6)
+		RETURNING transfer_number
+	`
+
+	now := time.Now()
+	transfer.ID = uuid.New()
+	transfer.Status = "shipped"
+	transfer.ShippedAt = now
+	transfer.CreatedAt = now
+	transfer.UpdatedAt = now
+
+	err := tx.QueryRow(transferQuery,
+		transfer.ID,
+		transfer.FromLocation,
+		transfer.ToLocation,
+		transfer.Notes,
+		transfer.CreatedBy,
+		now,
+	).Scan(&transfer.TransferNumber)
+
+	if err != nil {
+		return fmt.Errorf("failed to create transfer: %w", err)
+	}
+
+	for i := range transfer.Items {
+		item := &transfer.Items[i]
+		itemQuery := `
+			INSERT INTO stock_transfer_items (id, transfer_id, product_id, quantity)
+			VALUES ($1, $2, $3, $4)
+		`
+
+		item.ID = uuid.New()
+		item.TransferID = transfer.ID
+
+		_, err = tx.Exec(itemQuery, item.ID, item.TransferID, item.ProductID, item.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to create transfer item: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Lock locks a transfer for the rest of tx and returns it with its items
+func (r *TransferRepository) Lock(tx *sql.Tx, id uuid.UUID) (*models.StockTransfer, error) {
+	query := `SELECT ` + transferColumns + ` FROM stock_transfers WHERE id = $1 FOR UPDATE`
+
+	transfer, err := scanTransfer(tx.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("transfer not found")
+		}
+		return nil, fmt.Errorf("failed to lock transfer: %w", err)
+	}
+
+	if transfer.Items, err = r.getItems(tx, transfer.ID); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// UpdateStatusTx saves the status of a transfer and when, and by whom, it
+// was received or cancelled
+func (r *TransferRepository) UpdateStatusTx(tx *sql.Tx, transfer *models.StockTransfer) error {
+	query := `
+		UPDATE stock_transfers
+		SET status = $1, received_by = $2, received_at = $3, cancelled_at = $4, updated_at = $5
+		WHERE id = $6
+	`
+
+	transfer.UpdatedAt = time.Now()
+
+	result, err := tx.Exec(query,
+		transfer.Status,
+		transfer.ReceivedBy,
+		transfer.ReceivedAt,
+		transfer.CancelledAt,
+		transfer.UpdatedAt,
+		transfer.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("transfer not found")
+	}
+
+	return nil
+}
+
+func (r *TransferRepository) GetByID(id uuid.UUID) (*models.StockTransfer, error) {
+	query := `SELECT ` + transferColumns + ` FROM stock_transfers WHERE id = $1`
+
+	transfer, err := scanTransfer(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("transfer not found")
+		}
+		return nil, fmt.Errorf("failed to get transfer: %w", err)
+	}
+
+	if transfer.Items, err = r.getItems(r.db, transfer.ID); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// GetAll returns the transfers with their items, newest first, optionally
+// only those with the given status
+func (r *TransferRepository) GetAll(status string) ([]models.StockTransfer, error) {
+	query := `
+		SELECT ` + transferColumns + `
+		FROM stock_transfers
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY shipped_at DESC
+	`
+
+	rows, err := r.db.Query(query, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := []models.StockTransfer{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer: %w", err)
+		}
+		transfers = append(transfers, *transfer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transfers: %w", err)
+	}
+
+	for i := range transfers {
+		if transfers[i].Items, err = r.getItems(r.db, transfers[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return transfers, nil
+}
+
+func (r *TransferRepository) getItems(q querier, transferID uuid.UUID) ([]models.StockTransferItem, error) {
+	query := `
+		SELECT id, transfer_id, product_id, quantity
+		FROM stock_transfer_items
+		WHERE transfer_id = $1
+		ORDER BY product_id
+	`
+
+	rows, err := q.Query(query, transferID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfer items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.StockTransferItem{}
+	for rows.Next() {
+		var item models.StockTransferItem
+		if err := rows.Scan(&item.ID, &item.TransferID, &item.ProductID, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan transfer item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func scanTransfer(row scanner) (*models.StockTransfer, error) {
+	transfer := &models.StockTransfer{}
+
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.TransferNumber,
+		&transfer.FromLocation,
+		&transfer.ToLocation,
+		&transfer.Status,
+		&transfer.Notes,
+		&transfer.CreatedBy,
+		&transfer.ReceivedBy,
+		&transfer.ShippedAt,
+		&transfer.ReceivedAt,
+		&transfer.CancelledAt,
+		&transfer.CreatedAt,
+		&transfer.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
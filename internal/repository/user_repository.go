@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"os"
 	"regexp"
 	"strconv"
@@ -15,6 +16,16 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// ErrInvalidPassword is returned for a password that does not follow the
+	// password policy
+	ErrInvalidPassword = errors.New("invalid password")
+	// ErrInvalidPIN is returned for an approval PIN that is not 4 to 8 digits
+	ErrInvalidPIN = errors.New("PIN must be 4 to 8 digits")
+)
+
+var pinPattern = regexp.MustCompile(`^[0-9]{4,8}$`)
+
 // UserRepository handles database operations for users
 type UserRepository struct {
 	db *database.DB
@@ -27,16 +38,16 @@ func NewUserRepository(db *database.DB) *UserRepository {
 
 func validatePasswordRules(password string) error {
 	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters")
+		return fmt.Errorf("%w: password must be at least 8 characters", ErrInvalidPassword)
 	}
 	if match, _ := regexp.MatchString(`[0-9]`, password); !match {
-		return errors.New("password must contain at least one numeric character")
+		return fmt.Errorf("%w: password must contain at least one numeric character", ErrInvalidPassword)
 	}
 	if match, _ := regexp.MatchString(`[A-Z]`, password); !match {
-		return errors.New("password must contain at least one uppercase letter")
+		return fmt.Errorf("%w: password must contain at least one uppercase letter", ErrInvalidPassword)
 	}
 	if match, _ := regexp.MatchString(`[^a-zA-Z0-9]`, password); !match {
-		return errors.New("password must contain at least one symbol")
+		return fmt.Errorf("%w: password must contain at least one symbol", ErrInvalidPassword)
 	}
 	return nil
 }
@@ -53,8 +64,17 @@ func getBcryptCost() int {
 	return cost
 }
 
-// CreateUser creates a new user in the database
-func (r *UserRepository) CreateUser(user *models.User) error {
+// WithTx runs fn inside a database transaction
+func (r *UserRepository) WithTx(fn func(tx *sql.Tx) error) error {
+	return r.db.WithTx(fn)
+}
+
+// CreateUserTx creates a new user inside tx
+func (r *UserRepository) CreateUserTx(tx *sql.Tx, user *models.User) error {
+	return r.createUser(tx, user)
+}
+
+func (r *UserRepository) createUser(q querier, user *models.User) error {
 	if err := validatePasswordRules(user.Password); err != nil {
 		return err
 	}
@@ -78,21 +98,62 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
-	_, err = r.db.Exec(query, user.ID, user.Username, user.Email, user.Password, user.Role, user.IsActive, user.CreatedAt, user.UpdatedAt)
+	_, err = q.Exec(query, user.ID, user.Username, user.Email, user.Password, user.Role, user.IsActive, user.CreatedAt, user.UpdatedAt)
 	return err
 }
 
+// HasAdmin reports whether any admin account exists
+func (r *UserRepository) HasAdmin() (bool, error) {
+	return r.hasAdmin(r.db)
+}
+
+// HasAdminTx reports whether any admin account exists, as seen inside tx
+func (r *UserRepository) HasAdminTx(tx *sql.Tx) (bool, error) {
+	return r.hasAdmin(tx)
+}
+
+func (r *UserRepository) hasAdmin(q querier) (bool, error) {
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE role = 'admin')`).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check for admin users: %w", err)
+	}
+	return exists, nil
+}
+
+// bootstrapLockKey is the advisory lock key taken while creating the first
+// admin account
+const bootstrapLockKey = 7238301
+
+// LockBootstrap serialises the creation of the first admin account for the
+// rest of tx, so that two setup requests cannot both find no admin
+func (r *UserRepository) LockBootstrap(tx *sql.Tx) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, bootstrapLockKey); err != nil {
+		return fmt.Errorf("failed to lock admin setup: %w", err)
+	}
+	return nil
+}
+
 // GetUserByID retrieves a user by ID
 func (r *UserRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
+	return r.getUserByID(r.db, id, "")
+}
+
+// LockUser retrieves a user by ID inside tx, locking it until tx ends
+func (r *UserRepository) LockUser(tx *sql.Tx, id uuid.UUID) (*models.User, error) {
+	return r.getUserByID(tx, id, " FOR UPDATE")
+}
+
+func (r *UserRepository) getUserByID(q querier, id uuid.UUID, lock string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, email, password, role, is_active, created_at, updated_at
-		FROM users WHERE id = $1
-	`
+		SELECT id, username, email, password, role, is_active, COALESCE(pin_hash, ''),
+			COALESCE(totp_secret, ''), totp_enabled, totp_last_step, created_at, updated_at
+		FROM users WHERE id = $1` + lock
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := q.QueryRow(query, id).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.IsActive, &user.PIN,
+		&user.TOTPSecret, &user.TwoFactorEnabled, &user.TOTPLastStep, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -102,6 +163,7 @@ func (r *UserRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
 		return nil, err
 	}
 
+	user.HasPIN = user.PIN != ""
 	return user, nil
 }
 
@@ -109,13 +171,15 @@ func (r *UserRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
 func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, email, password, role, is_active, created_at, updated_at
+		SELECT id, username, email, password, role, is_active, COALESCE(pin_hash, ''),
+			COALESCE(totp_secret, ''), totp_enabled, totp_last_step, created_at, updated_at
 		FROM users WHERE username = $1
 	`
 
 	err := r.db.QueryRow(query, username).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.IsActive, &user.PIN,
+		&user.TOTPSecret, &user.TwoFactorEnabled, &user.TOTPLastStep, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -125,6 +189,7 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 		return nil, err
 	}
 
+	user.HasPIN = user.PIN != ""
 	return user, nil
 }
 
@@ -132,13 +197,15 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, email, password, role, is_active, created_at, updated_at
+		SELECT id, username, email, password, role, is_active, COALESCE(pin_hash, ''),
+			COALESCE(totp_secret, ''), totp_enabled, totp_last_step, created_at, updated_at
 		FROM users WHERE email = $1
 	`
 
 	err := r.db.QueryRow(query, email).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.IsActive, &user.PIN,
+		&user.TOTPSecret, &user.TwoFactorEnabled, &user.TOTPLastStep, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
@@ -148,13 +215,14 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 		return nil, err
 	}
 
+	user.HasPIN = user.PIN != ""
 	return user, nil
 }
 
 // GetAllUsers retrieves all users from the database
 func (r *UserRepository) GetAllUsers() ([]models.User, error) {
 	query := `
-		SELECT id, username, email, role, is_active, created_at, updated_at
+		SELECT id, username, email, role, is_active, pin_hash IS NOT NULL, totp_enabled, created_at, updated_at
 		FROM users ORDER BY created_at DESC
 	`
 
@@ -169,7 +237,7 @@ func (r *UserRepository) GetAllUsers() ([]models.User, error) {
 		var user models.User
 		err := rows.Scan(
 			&user.ID, &user.Username, &user.Email,
-			&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+			&user.Role, &user.IsActive, &user.HasPIN, &user.TwoFactorEnabled, &user.CreatedAt, &user.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -180,8 +248,12 @@ func (r *UserRepository) GetAllUsers() ([]models.User, error) {
 	return users, nil
 }
 
-// UpdateUser updates a user in the database
-func (r *UserRepository) UpdateUser(user *models.User) error {
+// UpdateUserTx updates a user inside tx
+func (r *UserRepository) UpdateUserTx(tx *sql.Tx, user *models.User) error {
+	return r.updateUser(tx, user)
+}
+
+func (r *UserRepository) updateUser(q querier, user *models.User) error {
 	user.UpdatedAt = time.Now()
 	query := `
 		UPDATE users 
@@ -189,7 +261,7 @@ func (r *UserRepository) UpdateUser(user *models.User) error {
 		WHERE id = $6
 	`
 
-	result, err := r.db.Exec(query, user.Username, user.Email, user.Role, user.IsActive, user.UpdatedAt, user.ID)
+	result, err := q.Exec(query, user.Username, user.Email, user.Role, user.IsActive, user.UpdatedAt, user.ID)
 	if err != nil {
 		return err
 	}
@@ -206,8 +278,12 @@ func (r *UserRepository) UpdateUser(user *models.User) error {
 	return nil
 }
 
-// UpdatePassword updates a user's password
-func (r *UserRepository) UpdatePassword(userID uuid.UUID, newPassword string) error {
+// UpdatePasswordTx updates a user's password inside tx
+func (r *UserRepository) UpdatePasswordTx(tx *sql.Tx, userID uuid.UUID, newPassword string) error {
+	return r.updatePassword(tx, userID, newPassword)
+}
+
+func (r *UserRepository) updatePassword(q querier, userID uuid.UUID, newPassword string) error {
 	if err := validatePasswordRules(newPassword); err != nil {
 		return err
 	}
@@ -222,7 +298,7 @@ func (r *UserRepository) UpdatePassword(userID uuid.UUID, newPassword string) er
 	}
 
 	query := `UPDATE users SET password = $1, updated_at = $2 WHERE id = $3`
-	result, err := r.db.Exec(query, string(hashedPassword), time.Now(), userID)
+	result, err := q.Exec(query, string(hashedPassword), time.Now(), userID)
 	if err != nil {
 		return err
 	}
@@ -239,10 +315,121 @@ func (r *UserRepository) UpdatePassword(userID uuid.UUID, newPassword string) er
 	return nil
 }
 
-// DeleteUser deletes a user from the database
-func (r *UserRepository) DeleteUser(id uuid.UUID) error {
+// UpdatePINTx sets the PIN a user approves actions at the till with inside
+// tx. PINs are hashed like passwords.
+func (r *UserRepository) UpdatePINTx(tx *sql.Tx, userID uuid.UUID, pin string) error {
+	if !pinPattern.MatchString(pin) {
+		return ErrInvalidPIN
+	}
+	hashedPIN, err := bcrypt.GenerateFromPassword([]byte(os.Getenv("SALT")+pin), getBcryptCost())
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET pin_hash = $1, updated_at = $2 WHERE id = $3`
+	result, err := tx.Exec(query, string(hashedPIN), time.Now(), userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// SetTOTPSecretTx stores a new TOTP secret inside tx for a user who is
+// setting up two-factor authentication. It is not used for login until
+// EnableTOTPTx.
+func (r *UserRepository) SetTOTPSecretTx(tx *sql.Tx, userID uuid.UUID, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_enabled = false, totp_last_step = 0, updated_at = $2
+		WHERE id = $3 AND NOT totp_enabled
+	`
+	result, err := tx.Exec(query, secret, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set TOTP secret: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// EnableTOTPTx turns on two-factor authentication for a user inside tx
+func (r *UserRepository) EnableTOTPTx(tx *sql.Tx, userID uuid.UUID) error {
+	query := `UPDATE users SET totp_enabled = true, updated_at = $1 WHERE id = $2`
+	if _, err := tx.Exec(query, time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStepTx records that a code for the given time step was accepted.
+// It reports false when a code for this or a later step was accepted
+// already, so that each code works once even under concurrent logins.
+func (r *UserRepository) UseTOTPStepTx(tx *sql.Tx, userID uuid.UUID, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`
+	result, err := tx.Exec(query, step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// DisableTOTPTx turns off two-factor authentication for a user inside tx and
+// forgets their secret
+func (r *UserRepository) DisableTOTPTx(tx *sql.Tx, userID uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled = false, totp_last_step = 0, updated_at = $1
+		WHERE id = $2
+	`
+	result, err := tx.Exec(query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// DeleteUserTx deletes a user inside tx
+func (r *UserRepository) DeleteUserTx(tx *sql.Tx, id uuid.UUID) error {
+	return r.deleteUser(tx, id)
+}
+
+func (r *UserRepository) deleteUser(q querier, id uuid.UUID) error {
 	query := `DELETE FROM users WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+	result, err := q.Exec(query, id)
 	if err != nil {
 		return err
 	}
@@ -265,3 +452,12 @@ func (r *UserRepository) CheckPassword(user *models.User, password string) bool
 	passwordWithSalt := salt + password
 	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwordWithSalt)) == nil
 }
+
+// CheckPIN verifies if the provided PIN matches the user's approval PIN.
+// Users without a PIN never match.
+func (r *UserRepository) CheckPIN(user *models.User, pin string) bool {
+	if user.PIN == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PIN), []byte(os.Getenv("SALT")+pin)) == nil
+}
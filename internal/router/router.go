@@ -3,6 +3,7 @@ package router
 import (
 	"jatistore/internal/handlers"
 	"jatistore/internal/middleware"
+	"jatistore/internal/permissions"
 
 	_ "jatistore/docs" // docs is generated by Swag CLI, you have to import it.
 
@@ -35,6 +36,9 @@ func SetupRoutes(app *fiber.App, handlers *Handlers, authMiddleware *middleware.
 		})
 	})
 
+	// Public keys for verifying access tokens
+	app.Get("/.well-known/jwks.json", handlers.AuthHandler.GetJWKS)
+
 	// API routes
 	api := app.Group("/api/v1")
 
@@ -42,78 +46,221 @@ func SetupRoutes(app *fiber.App, handlers *Handlers, authMiddleware *middleware.
 	auth := api.Group("/auth")
 	auth.Post("/register", handlers.AuthHandler.Register)
 	auth.Post("/login", handlers.AuthHandler.Login)
+	auth.Post("/login/2fa", handlers.AuthHandler.LoginTwoFactor)
+	auth.Post("/login/2fa/setup", handlers.AuthHandler.SetupLoginTwoFactor)
+	auth.Post("/refresh", handlers.AuthHandler.Refresh)
+	auth.Post("/setup", handlers.AuthHandler.Setup)
+	auth.Post("/invites/accept", handlers.AuthHandler.AcceptInvite)
+	auth.Post("/password/forgot", handlers.AuthHandler.ForgotPassword)
+	auth.Post("/password/reset", handlers.AuthHandler.ResetPassword)
 
 	// Protected routes (require authentication)
 	protected := api.Group("/", authMiddleware.Authenticate())
 
-	// User profile routes
-	authProtected := protected.Group("/auth")
+	// User profile routes. Every /auth route needs a user; API keys are
+	// turned away.
+	authProtected := protected.Group("/auth", authMiddleware.RequireUser())
 	authProtected.Get("/profile", handlers.AuthHandler.GetProfile)
 	authProtected.Put("/profile", handlers.AuthHandler.UpdateProfile)
 	authProtected.Post("/change-password", handlers.AuthHandler.ChangePassword)
+	authProtected.Post("/logout", handlers.AuthHandler.Logout)
+	authProtected.Post("/logout-all", handlers.AuthHandler.LogoutAll)
+	authProtected.Put("/pin", handlers.AuthHandler.SetPIN)
+	authProtected.Post("/approvals", handlers.ApprovalHandler.CreateApprovalToken)
+	authProtected.Post("/2fa/enroll", handlers.AuthHandler.EnrollTwoFactor)
+	authProtected.Post("/2fa/confirm", handlers.AuthHandler.ConfirmTwoFactor)
+	authProtected.Post("/2fa/disable", handlers.AuthHandler.DisableTwoFactor)
+	authProtected.Post("/2fa/recovery-codes", handlers.AuthHandler.RegenerateRecoveryCodes)
+
+	// User management routes
+	users := protected.Group("/auth", authMiddleware.RequirePermission(permissions.UserManage))
+	users.Get("/users", handlers.AuthHandler.GetAllUsers)
+	users.Get("/users/:id", handlers.AuthHandler.GetUserByID)
+	users.Put("/users/:id", handlers.AuthHandler.UpdateUser)
+	users.Delete("/users/:id", handlers.AuthHandler.DeleteUser)
+	users.Delete("/users/:id/sessions", handlers.AuthHandler.RevokeUserSessions)
+	users.Delete("/users/:id/2fa", handlers.AuthHandler.ResetUserTwoFactor)
+	users.Delete("/users/:id/lockout", handlers.SecurityHandler.UnlockUser)
+	users.Get("/lockouts", handlers.SecurityHandler.GetLockouts)
+	users.Delete("/lockouts/ip/:ip", handlers.SecurityHandler.UnlockIP)
+	users.Get("/security-events", handlers.SecurityHandler.GetSecurityEvents)
+	users.Post("/api-keys", handlers.APIKeyHandler.CreateAPIKey)
+	users.Get("/api-keys", handlers.APIKeyHandler.GetAPIKeys)
+	users.Delete("/api-keys/:id", handlers.APIKeyHandler.RevokeAPIKey)
+	users.Post("/invites", handlers.AuthHandler.CreateInvite)
+	users.Get("/invites", handlers.AuthHandler.GetInvites)
+	users.Delete("/invites/:id", handlers.AuthHandler.RevokeInvite)
 
-	// Admin-only routes
-	adminRoutes := protected.Group("/auth", authMiddleware.RequireRole("admin"))
-	adminRoutes.Get("/users", handlers.AuthHandler.GetAllUsers)
-	adminRoutes.Get("/users/:id", handlers.AuthHandler.GetUserByID)
-	adminRoutes.Put("/users/:id", handlers.AuthHandler.UpdateUser)
-	adminRoutes.Delete("/users/:id", handlers.AuthHandler.DeleteUser)
+	// Role routes
+	roles := protected.Group("/roles", authMiddleware.RequirePermission(permissions.RoleManage))
+	roles.Get("/", handlers.RoleHandler.GetAllRoles)
+	roles.Get("/:name", handlers.RoleHandler.GetRole)
+	roles.Post("/", handlers.RoleHandler.CreateRole)
+	roles.Put("/:name", handlers.RoleHandler.UpdateRole)
+	roles.Put("/:name/two-factor", handlers.RoleHandler.SetTwoFactorRequirement)
+	roles.Delete("/:name", handlers.RoleHandler.DeleteRole)
+	protected.Get("/permissions", authMiddleware.RequirePermission(permissions.RoleManage), handlers.RoleHandler.GetPermissions)
 
-	// Product routes (require authentication)
+	// Audit log routes
+	audit := protected.Group("/audit", authMiddleware.RequirePermission(permissions.AuditRead))
+	audit.Get("/", handlers.AuditHandler.GetAuditLog)
+	audit.Get("/verify", handlers.AuditHandler.VerifyAuditLog)
+
+	// Product routes
 	products := protected.Group("/products")
-	products.Get("/", handlers.ProductHandler.GetAllProducts)
-	products.Get("/:id", handlers.ProductHandler.GetProductByID)
-	products.Post("/", handlers.ProductHandler.CreateProduct)
-	products.Put("/:id", handlers.ProductHandler.UpdateProduct)
-	products.Delete("/:id", handlers.ProductHandler.DeleteProduct)
+	products.Get("/", authMiddleware.RequirePermission(permissions.ProductRead), handlers.ProductHandler.GetAllProducts)
+	products.Get("/:id", authMiddleware.RequirePermission(permissions.ProductRead), handlers.ProductHandler.GetProductByID)
+	products.Post("/", authMiddleware.RequirePermission(permissions.ProductWrite), handlers.ProductHandler.CreateProduct)
+	products.Put("/:id", authMiddleware.RequirePermission(permissions.ProductWrite), handlers.ProductHandler.UpdateProduct)
+	products.Delete("/:id", authMiddleware.RequirePermission(permissions.ProductWrite), handlers.ProductHandler.DeleteProduct)
 
-	// Category routes (require authentication)
+	// Category routes
 	categories := protected.Group("/categories")
-	categories.Get("/", handlers.CategoryHandler.GetAllCategories)
-	categories.Get("/:id", handlers.CategoryHandler.GetCategoryByID)
-	categories.Post("/", handlers.CategoryHandler.CreateCategory)
-	categories.Put("/:id", handlers.CategoryHandler.UpdateCategory)
-	categories.Delete("/:id", handlers.CategoryHandler.DeleteCategory)
+	categories.Get("/", authMiddleware.RequirePermission(permissions.ProductRead), handlers.CategoryHandler.GetAllCategories)
+	categories.Get("/:id", authMiddleware.RequirePermission(permissions.ProductRead), handlers.CategoryHandler.GetCategoryByID)
+	categories.Post("/", authMiddleware.RequirePermission(permissions.ProductWrite), handlers.CategoryHandler.CreateCategory)
+	categories.Put("/:id", authMiddleware.RequirePermission(permissions.ProductWrite), handlers.CategoryHandler.UpdateCategory)
+	categories.Delete("/:id", authMiddleware.RequirePermission(permissions.ProductWrite), handlers.CategoryHandler.DeleteCategory)
+
+	// Tax class routes (reading is open to every user)
+	taxClasses := protected.Group("/tax-classes")
+	taxClasses.Get("/", handlers.TaxHandler.GetAllTaxClasses)
+	taxClasses.Get("/:id", handlers.TaxHandler.GetTaxClass)
+	taxClasses.Post("/", authMiddleware.RequirePermission(permissions.TaxManage), handlers.TaxHandler.CreateTaxClass)
+	taxClasses.Put("/:id", authMiddleware.RequirePermission(permissions.TaxManage), handlers.TaxHandler.UpdateTaxClass)
+	taxClasses.Delete("/:id", authMiddleware.RequirePermission(permissions.TaxManage), handlers.TaxHandler.DeleteTaxClass)
+	taxClasses.Post("/:id/rates", authMiddleware.RequirePermission(permissions.TaxManage), handlers.TaxHandler.AddTaxRate)
 
-	// Inventory routes (require authentication)
+	// Promotion routes (reading is open to every user)
+	promotions := protected.Group("/promotions")
+	promotions.Get("/", handlers.PromotionHandler.GetAllPromotions)
+	promotions.Get("/report", authMiddleware.RequirePermission(permissions.PromotionManage), handlers.PromotionHandler.GetPromotionReport)
+	promotions.Get("/:id", handlers.PromotionHandler.GetPromotion)
+	promotions.Post("/", authMiddleware.RequirePermission(permissions.PromotionManage), handlers.PromotionHandler.CreatePromotion)
+	promotions.Put("/:id", authMiddleware.RequirePermission(permissions.PromotionManage), handlers.PromotionHandler.UpdatePromotion)
+	promotions.Delete("/:id", authMiddleware.RequirePermission(permissions.PromotionManage), handlers.PromotionHandler.DeletePromotion)
+
+	// Coupon routes
+	coupons := protected.Group("/coupons", authMiddleware.RequirePermission(permissions.CouponManage))
+	coupons.Get("/", handlers.CouponHandler.GetAllCoupons)
+	coupons.Get("/:id", handlers.CouponHandler.GetCoupon)
+	coupons.Post("/", handlers.CouponHandler.CreateCoupon)
+	coupons.Put("/:id", handlers.CouponHandler.UpdateCoupon)
+	coupons.Delete("/:id", handlers.CouponHandler.DeleteCoupon)
+
+	// Inventory routes
 	inventory := protected.Group("/inventory")
-	inventory.Get("/", handlers.InventoryHandler.GetAllInventory)
-	inventory.Get("/:id", handlers.InventoryHandler.GetInventoryByID)
-	inventory.Post("/", handlers.InventoryHandler.CreateInventory)
-	inventory.Put("/:id", handlers.InventoryHandler.UpdateInventory)
-	inventory.Delete("/:id", handlers.InventoryHandler.DeleteInventory)
-	inventory.Post("/adjust", handlers.InventoryHandler.AdjustStock)
-
-	// Customer routes (require authentication)
+	inventory.Get("/", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.InventoryHandler.GetAllInventory)
+	inventory.Get("/transfers", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.InventoryHandler.GetAllTransfers)
+	inventory.Get("/transfers/:id", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.InventoryHandler.GetTransfer)
+	inventory.Post("/transfers", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.InventoryHandler.CreateTransfer)
+	inventory.Post("/transfers/:id/receive", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.InventoryHandler.ReceiveTransfer)
+	inventory.Post("/transfers/:id/cancel", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.InventoryHandler.CancelTransfer)
+	inventory.Get("/on-hand", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.InventoryHandler.GetStockOnHand)
+	inventory.Get("/reconcile", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.InventoryHandler.GetReconciliation)
+	inventory.Post("/reconcile", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.InventoryHandler.RebuildInventory)
+	inventory.Get("/low-stock", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.ReorderHandler.GetLowStock)
+	inventory.Get("/replenishment", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.ReorderHandler.GetReplenishment)
+	inventory.Get("/reorder-levels", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.ReorderHandler.GetReorderLevels)
+	inventory.Put("/reorder-levels/:product_id/:location", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.ReorderHandler.SetReorderLevel)
+	inventory.Delete("/reorder-levels/:product_id/:location", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.ReorderHandler.DeleteReorderLevel)
+	inventory.Get("/:id", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.InventoryHandler.GetInventoryByID)
+	inventory.Post("/", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.InventoryHandler.CreateInventory)
+	inventory.Put("/:id", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.InventoryHandler.UpdateInventory)
+	inventory.Delete("/:id", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.InventoryHandler.DeleteInventory)
+	inventory.Post("/adjust", authMiddleware.RequirePermission(permissions.InventoryAdjust), handlers.InventoryHandler.AdjustStock)
+
+	// Location routes
+	locations := protected.Group("/locations")
+	locations.Get("/", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.LocationHandler.GetAllLocations)
+	locations.Get("/:name", authMiddleware.RequirePermission(permissions.InventoryRead), handlers.LocationHandler.GetLocation)
+	locations.Post("/", authMiddleware.RequirePermission(permissions.LocationManage), handlers.LocationHandler.CreateLocation)
+	locations.Put("/:name", authMiddleware.RequirePermission(permissions.LocationManage), handlers.LocationHandler.UpdateLocation)
+	locations.Delete("/:name", authMiddleware.RequirePermission(permissions.LocationManage), handlers.LocationHandler.DeleteLocation)
+
+	// Supplier routes
+	suppliers := protected.Group("/suppliers")
+	suppliers.Get("/", authMiddleware.RequirePermission(permissions.PurchaseRead), handlers.SupplierHandler.GetAllSuppliers)
+	suppliers.Get("/:id", authMiddleware.RequirePermission(permissions.PurchaseRead), handlers.SupplierHandler.GetSupplier)
+	suppliers.Get("/:id/costs", authMiddleware.RequirePermission(permissions.PurchaseRead), handlers.SupplierHandler.GetSupplierCosts)
+	suppliers.Post("/", authMiddleware.RequirePermission(permissions.PurchaseManage), handlers.SupplierHandler.CreateSupplier)
+	suppliers.Put("/:id", authMiddleware.RequirePermission(permissions.PurchaseManage), handlers.SupplierHandler.UpdateSupplier)
+	suppliers.Delete("/:id", authMiddleware.RequirePermission(permissions.PurchaseManage), handlers.SupplierHandler.DeleteSupplier)
+
+	// Purchase order routes
+	purchaseOrders := protected.Group("/purchase-orders")
+	purchaseOrders.Get("/", authMiddleware.RequirePermission(permissions.PurchaseRead), handlers.PurchaseOrderHandler.GetAllPurchaseOrders)
+	purchaseOrders.Get("/:id", authMiddleware.RequirePermission(permissions.PurchaseRead), handlers.PurchaseOrderHandler.GetPurchaseOrder)
+	purchaseOrders.Post("/", authMiddleware.RequirePermission(permissions.PurchaseManage), handlers.PurchaseOrderHandler.CreatePurchaseOrder)
+	purchaseOrders.Put("/:id", authMiddleware.RequirePermission(permissions.PurchaseManage), handlers.PurchaseOrderHandler.UpdatePurchaseOrder)
+	purchaseOrders.Delete("/:id", authMiddleware.RequirePermission(permissions.PurchaseManage), handlers.PurchaseOrderHandler.DeletePurchaseOrder)
+	purchaseOrders.Post("/:id/send", authMiddleware.RequirePermission(permissions.PurchaseManage), handlers.PurchaseOrderHandler.SendPurchaseOrder)
+	purchaseOrders.Post("/:id/receive", authMiddleware.RequirePermission(permissions.PurchaseReceive), handlers.PurchaseOrderHandler.ReceiveGoods)
+	purchaseOrders.Post("/:id/close", authMiddleware.RequirePermission(permissions.PurchaseManage), handlers.PurchaseOrderHandler.ClosePurchaseOrder)
+
+	// Customer routes
 	customers := protected.Group("/customers")
-	customers.Get("/", handlers.CustomerHandler.GetAllCustomers)
-	customers.Get("/search", handlers.CustomerHandler.SearchCustomers)
-	customers.Get("/:id", handlers.CustomerHandler.GetCustomer)
-	customers.Post("/", handlers.CustomerHandler.CreateCustomer)
-	customers.Put("/:id", handlers.CustomerHandler.UpdateCustomer)
-	customers.Delete("/:id", handlers.CustomerHandler.DeleteCustomer)
-
-	// Order routes (require authentication)
+	customers.Get("/", authMiddleware.RequirePermission(permissions.CustomerRead), handlers.CustomerHandler.GetAllCustomers)
+	customers.Get("/search", authMiddleware.RequirePermission(permissions.CustomerRead), handlers.CustomerHandler.SearchCustomers)
+	customers.Get("/:id", authMiddleware.RequirePermission(permissions.CustomerRead), handlers.CustomerHandler.GetCustomer)
+	customers.Post("/", authMiddleware.RequirePermission(permissions.CustomerWrite), handlers.CustomerHandler.CreateCustomer)
+	customers.Put("/:id", authMiddleware.RequirePermission(permissions.CustomerWrite), handlers.CustomerHandler.UpdateCustomer)
+	customers.Delete("/:id", authMiddleware.RequirePermission(permissions.CustomerWrite), handlers.CustomerHandler.DeleteCustomer)
+
+	// Order routes (refunds above the approval threshold and tax overrides
+	// are checked by the order service)
 	orders := protected.Group("/orders")
-	orders.Get("/", handlers.OrderHandler.GetAllOrders)
-	orders.Get("/:id", handlers.OrderHandler.GetOrder)
-	orders.Post("/", handlers.OrderHandler.CreateOrder)
-	orders.Put("/:id/status", handlers.OrderHandler.UpdateOrderStatus)
-	orders.Post("/:id/payments", handlers.OrderHandler.ProcessPayment)
-	orders.Post("/:id/receipt", handlers.OrderHandler.GenerateReceipt)
-
-	// Customer orders route (require authentication)
-	protected.Get("/customers/:customerId/orders", handlers.OrderHandler.GetOrdersByCustomer)
+	orders.Get("/", authMiddleware.RequirePermission(permissions.OrderRead), handlers.OrderHandler.GetAllOrders)
+	orders.Get("/:id", authMiddleware.RequirePermission(permissions.OrderRead), handlers.OrderHandler.GetOrder)
+	orders.Post("/", authMiddleware.RequirePermission(permissions.OrderWrite), handlers.OrderHandler.CreateOrder)
+	orders.Put("/:id/status", authMiddleware.RequirePermission(permissions.OrderWrite), handlers.OrderHandler.UpdateOrderStatus)
+	orders.Get("/:id/history", authMiddleware.RequirePermission(permissions.OrderRead), handlers.OrderHandler.GetOrderStatusHistory)
+	orders.Post("/:id/payments", authMiddleware.RequirePermission(permissions.OrderWrite), handlers.OrderHandler.ProcessPayment)
+	orders.Post("/:id/receipt", authMiddleware.RequirePermission(permissions.OrderWrite), handlers.OrderHandler.GenerateReceipt)
+	orders.Post("/:id/refunds", authMiddleware.RequirePermission(permissions.OrderRefund), handlers.OrderHandler.RefundOrder)
+	orders.Get("/:id/refunds", authMiddleware.RequirePermission(permissions.OrderRead), handlers.OrderHandler.GetOrderRefunds)
+
+	// Receipt routes
+	receipts := protected.Group("/receipts", authMiddleware.RequirePermission(permissions.OrderRead))
+	receipts.Get("/:id", handlers.ReceiptHandler.GetReceipt)
+	receipts.Post("/:id/print", handlers.ReceiptHandler.PrintReceipt)
+
+	// Shift routes (other users' shifts are checked by the shift service)
+	shifts := protected.Group("/shifts", authMiddleware.RequirePermission(permissions.ShiftOperate))
+	shifts.Post("/open", handlers.ShiftHandler.OpenShift)
+	shifts.Post("/close", handlers.ShiftHandler.CloseShift)
+	shifts.Post("/cash-movements", handlers.ShiftHandler.RecordCashMovement)
+	shifts.Get("/current", handlers.ShiftHandler.GetCurrentShift)
+	shifts.Get("/", authMiddleware.RequirePermission(permissions.ShiftManage), handlers.ShiftHandler.GetAllShifts)
+	shifts.Get("/:id", handlers.ShiftHandler.GetShift)
+	shifts.Get("/:id/report", handlers.ShiftHandler.GetShiftReport)
+
+	// Customer orders route
+	protected.Get("/customers/:customerId/orders", authMiddleware.RequirePermission(permissions.OrderRead), handlers.OrderHandler.GetOrdersByCustomer)
 }
 
 // Handlers contains all the handlers for the application
 type Handlers struct {
-	AuthHandler      *handlers.AuthHandler
-	ProductHandler   *handlers.ProductHandler
-	CategoryHandler  *handlers.CategoryHandler
-	InventoryHandler *handlers.InventoryHandler
-	CustomerHandler  *handlers.CustomerHandler
-	OrderHandler     *handlers.OrderHandler
+	AuthHandler          *handlers.AuthHandler
+	ProductHandler       *handlers.ProductHandler
+	CategoryHandler      *handlers.CategoryHandler
+	InventoryHandler     *handlers.InventoryHandler
+	CustomerHandler      *handlers.CustomerHandler
+	OrderHandler         *handlers.OrderHandler
+	TaxHandler           *handlers.TaxHandler
+	PromotionHandler     *handlers.PromotionHandler
+	CouponHandler        *handlers.CouponHandler
+	ShiftHandler         *handlers.ShiftHandler
+	ReceiptHandler       *handlers.ReceiptHandler
+	RoleHandler          *handlers.RoleHandler
+	ApprovalHandler      *handlers.ApprovalHandler
+	SecurityHandler      *handlers.SecurityHandler
+	APIKeyHandler        *handlers.APIKeyHandler
+	AuditHandler         *handlers.AuditHandler
+	LocationHandler      *handlers.LocationHandler
+	ReorderHandler       *handlers.ReorderHandler
+	SupplierHandler      *handlers.SupplierHandler
+	PurchaseOrderHandler *handlers.PurchaseOrderHandler
 }
 
 // NewHandlers creates a new Handlers instance
@@ -124,13 +271,41 @@ func NewHandlers(
 	inventoryHandler *handlers.InventoryHandler,
 	customerHandler *handlers.CustomerHandler,
 	orderHandler *handlers.OrderHandler,
+	taxHandler *handlers.TaxHandler,
+	promotionHandler *handlers.PromotionHandler,
+	couponHandler *handlers.CouponHandler,
+	shiftHandler *handlers.ShiftHandler,
+	receiptHandler *handlers.ReceiptHandler,
+	roleHandler *handlers.RoleHandler,
+	approvalHandler *handlers.ApprovalHandler,
+	securityHandler *handlers.SecurityHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	auditHandler *handlers.AuditHandler,
+	locationHandler *handlers.LocationHandler,
+	reorderHandler *handlers.ReorderHandler,
+	supplierHandler *handlers.SupplierHandler,
+	purchaseOrderHandler *handlers.PurchaseOrderHandler,
 ) *Handlers {
 	return &Handlers{
-		AuthHandler:      authHandler,
-		ProductHandler:   productHandler,
-		CategoryHandler:  categoryHandler,
-		InventoryHandler: inventoryHandler,
-		CustomerHandler:  customerHandler,
-		OrderHandler:     orderHandler,
+		AuthHandler:          authHandler,
+		ProductHandler:       productHandler,
+		CategoryHandler:      categoryHandler,
+		InventoryHandler:     inventoryHandler,
+		CustomerHandler:      customerHandler,
+		OrderHandler:         orderHandler,
+		TaxHandler:           taxHandler,
+		PromotionHandler:     promotionHandler,
+		CouponHandler:        couponHandler,
+		ShiftHandler:         shiftHandler,
+		ReceiptHandler:       receiptHandler,
+		RoleHandler:          roleHandler,
+		ApprovalHandler:      approvalHandler,
+		SecurityHandler:      securityHandler,
+		APIKeyHandler:        apiKeyHandler,
+		AuditHandler:         auditHandler,
+		LocationHandler:      locationHandler,
+		ReorderHandler:       reorderHandler,
+		SupplierHandler:      supplierHandler,
+		PurchaseOrderHandler: purchaseOrderHandler,
 	}
 }
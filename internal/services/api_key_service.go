@@ -0,0 +1,205 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidAPIKey is returned for an API key that is unknown, revoked
+	// or expired
+	ErrInvalidAPIKey = errors.New("invalid or expired API key")
+	// ErrAPIKeyIPNotAllowed is returned for an API key used from an IP
+	// outside its allow-list
+	ErrAPIKeyIPNotAllowed = errors.New("API key is not allowed from this IP")
+	// ErrInvalidAPIKeyRequest is returned for an API key that cannot be
+	// created as requested
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key")
+)
+
+// apiKeyPrefix starts every API key, so that leaked keys are easy to spot
+const apiKeyPrefix = "jsk_"
+
+// Security event types
+const (
+	SecurityEventAPIKeyCreated = "api_key_created"
+	SecurityEventAPIKeyRevoked = "api_key_revoked"
+)
+
+// APIKeyService manages the API keys other systems authenticate with
+type APIKeyService struct {
+	apiKeyRepo   *repository.APIKeyRepository
+	securityRepo *repository.SecurityRepository
+}
+
+func NewAPIKeyService(apiKeyRepo *repository.APIKeyRepository, securityRepo *repository.SecurityRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:   apiKeyRepo,
+		securityRepo: securityRepo,
+	}
+}
+
+// CreateKey creates an API key with the given permissions, all of which the
+// creator must have. The key is returned once; only its hash is stored.
+func (s *APIKeyService) CreateKey(req *models.CreateAPIKeyRequest, by *models.User) (*models.APIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidAPIKeyRequest)
+	}
+
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if len(perms) == 0 {
+		return nil, fmt.Errorf("%w: at least one permission is required", ErrInvalidAPIKeyRequest)
+	}
+	for _, permission := range perms {
+		if !by.HasPermission(permission) {
+			return nil, fmt.Errorf("%w: you do not have %s", ErrInvalidAPIKeyRequest, permission)
+		}
+	}
+
+	allowedIPs, err := normalizeAllowedIPs(req.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidAPIKeyRequest)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	secret := apiKeyPrefix + token
+
+	key := &models.APIKey{
+		Name:        name,
+		Prefix:      secret[:len(apiKeyPrefix)+6],
+		Permissions: perms,
+		AllowedIPs:  allowedIPs,
+		ExpiresAt:   req.ExpiresAt,
+		CreatedBy:   optionalUserID(by.ID),
+	}
+	if err := s.apiKeyRepo.Create(key, hashToken(secret)); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordEvent(SecurityEventAPIKeyCreated, key, by); err != nil {
+		return nil, err
+	}
+
+	key.Key = secret
+	return key, nil
+}
+
+// GetKeys returns every API key, newest first
+func (s *APIKeyService) GetKeys() ([]models.APIKey, error) {
+	return s.apiKeyRepo.GetAll()
+}
+
+// RevokeKey stops an API key from being accepted
+func (s *APIKeyService) RevokeKey(id uuid.UUID, by *models.User) error {
+	key, err := s.apiKeyRepo.Revoke(id)
+	if err != nil {
+		return err
+	}
+
+	return s.recordEvent(SecurityEventAPIKeyRevoked, key, by)
+}
+
+// Authenticate checks an API key used from ip and returns the user the
+// request acts as: one without an ID, with the key's permissions and
+// APIKeyID set, so that what it does is attributed to the key
+func (s *APIKeyService) Authenticate(secret, ip string) (*models.User, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByHash(hashToken(secret))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.RevokedAt != nil || (key.ExpiresAt != nil && !time.Now().Before(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ipAllowed(key.AllowedIPs, ip) {
+		return nil, ErrAPIKeyIPNotAllowed
+	}
+
+	if err := s.apiKeyRepo.Touch(key.ID, ip); err != nil {
+		return nil, err
+	}
+
+	return &models.User{
+		Username:    "api-key:" + key.Name,
+		Role:        "api-key",
+		IsActive:    true,
+		Permissions: key.Permissions,
+		APIKeyID:    &key.ID,
+	}, nil
+}
+
+// recordEvent records the creation or revocation of an API key
+func (s *APIKeyService) recordEvent(eventType string, key *models.APIKey, by *models.User) error {
+	return s.securityRepo.CreateEvent(&models.SecurityEvent{
+		Type:      eventType,
+		Details:   fmt.Sprintf("API key %q (%s…)", key.Name, key.Prefix),
+		CreatedBy: optionalUserID(by.ID),
+	})
+}
+
+// normalizeAllowedIPs checks that each entry is an IP or a CIDR range and
+// returns them in canonical form
+func normalizeAllowedIPs(entries []string) ([]string, error) {
+	normalized := []string{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not an IP or CIDR range", ErrInvalidAPIKeyRequest, entry)
+			}
+			normalized = append(normalized, network.String())
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("%w: %q is not an IP or CIDR range", ErrInvalidAPIKeyRequest, entry)
+		}
+		normalized = append(normalized, ip.String())
+	}
+	return normalized, nil
+}
+
+// ipAllowed reports whether ip is in the allow-list. An empty list allows
+// any IP.
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
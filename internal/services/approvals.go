@@ -0,0 +1,190 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrApprovalRequired is returned when a user takes an action they lack
+	// the permission for without a supervisor's approval
+	ErrApprovalRequired = errors.New("supervisor approval required")
+	// ErrInvalidApproval is returned for a wrong supervisor PIN, an unknown,
+	// used or expired approval token, or a supervisor who lacks the
+	// permission being approved
+	ErrInvalidApproval = errors.New("invalid approval")
+	// ErrInvalidPIN is returned for a PIN that is not 4 to 8 digits
+	ErrInvalidPIN = repository.ErrInvalidPIN
+)
+
+// ApprovalPolicy controls approval tokens
+type ApprovalPolicy struct {
+	// TokenTTL is how long an approval token can be used for
+	TokenTTL time.Duration
+}
+
+// ApprovalService lets a supervisor approve an action at the till without
+// the cashier logging out: the request carries the supervisor's username
+// and PIN, or an approval token the supervisor created for it.
+type ApprovalService struct {
+	userRepo  *repository.UserRepository
+	roleRepo  *repository.RoleRepository
+	tokenRepo *repository.TokenRepository
+	guard     *LoginGuard
+	policy    ApprovalPolicy
+}
+
+func NewApprovalService(
+	userRepo *repository.UserRepository,
+	roleRepo *repository.RoleRepository,
+	tokenRepo *repository.TokenRepository,
+	guard *LoginGuard,
+	policy ApprovalPolicy,
+) *ApprovalService {
+	return &ApprovalService{
+		userRepo:  userRepo,
+		roleRepo:  roleRepo,
+		tokenRepo: tokenRepo,
+		guard:     guard,
+		policy:    policy,
+	}
+}
+
+// CreateToken creates a single-use approval token for actions needing the
+// given permissions, all of which the approver must have. The token is
+// returned once; only its hash is stored.
+func (s *ApprovalService) CreateToken(approver *models.User, req *models.CreateApprovalTokenRequest) (*models.ApprovalToken, error) {
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if len(perms) == 0 {
+		return nil, fmt.Errorf("%w: no permissions to approve", ErrInvalidApproval)
+	}
+	for _, permission := range perms {
+		if !approver.HasPermission(permission) {
+			return nil, fmt.Errorf("%w: you do not have %s", ErrInvalidApproval, permission)
+		}
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	approval := &models.ApprovalToken{
+		ApproverID:  approver.ID,
+		Permissions: perms,
+		ExpiresAt:   time.Now().Add(s.policy.TokenTTL),
+	}
+	if err := s.tokenRepo.CreateApprovalToken(approval, hashToken(token)); err != nil {
+		return nil, err
+	}
+
+	approval.Token = token
+	return approval, nil
+}
+
+// Approve checks that user may take an action needing the given permissions
+// inside tx. Permissions user has need no approval; for the rest, approval
+// must name a supervisor who has them. It returns the supervisor's ID, or
+// nil when no approval was needed. A token approval is used up when tx
+// commits.
+func (s *ApprovalService) Approve(tx *sql.Tx, user *models.User, approval *models.ApprovalRequest, needed ...string) (*uuid.UUID, error) {
+	var missing []string
+	for _, permission := range needed {
+		if !user.HasPermission(permission) {
+			missing = append(missing, permission)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	if approval == nil || (approval.Token == "" && approval.PIN == "") {
+		return nil, fmt.Errorf("%w: requires %s", ErrApprovalRequired, strings.Join(missing, ", "))
+	}
+
+	var approver *models.User
+	var token *models.ApprovalToken
+	if approval.Token != "" {
+		var err error
+		token, err = s.tokenRepo.LockApprovalTokenByHash(tx, hashToken(approval.Token))
+		if err != nil {
+			return nil, err
+		}
+		if token == nil || token.UsedAt != nil || !time.Now().Before(token.ExpiresAt) {
+			return nil, fmt.Errorf("%w: approval token is unknown, used or expired", ErrInvalidApproval)
+		}
+		for _, permission := range missing {
+			if !slices.Contains(token.Permissions, permission) {
+				return nil, fmt.Errorf("%w: approval token does not cover %s", ErrInvalidApproval, permission)
+			}
+		}
+
+		approver, err = s.userRepo.GetUserByID(token.ApproverID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: approver no longer exists", ErrInvalidApproval)
+		}
+	} else {
+		// Wrong PINs are counted per approver, as a PIN is easily guessed
+		// by replaying a request otherwise
+		attempt, err := s.guard.AttemptPIN(approval.Username)
+		if err != nil {
+			var throttled *LoginThrottledError
+			if errors.As(err, &throttled) {
+				return nil, fmt.Errorf("%w: too many wrong PINs for %s, try again in %s",
+					ErrInvalidApproval, approval.Username, throttled.RetryAfter.Round(time.Second))
+			}
+			return nil, err
+		}
+
+		approver, err = s.userRepo.GetUserByUsername(approval.Username)
+		if err != nil || !s.userRepo.CheckPIN(approver, approval.PIN) {
+			var approverID *uuid.UUID
+			if err == nil {
+				approverID = &approver.ID
+			}
+			if err := s.guard.Fail(attempt, approverID); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: wrong supervisor username or PIN", ErrInvalidApproval)
+		}
+		if err := s.guard.Succeed(attempt); err != nil {
+			return nil, err
+		}
+	}
+
+	if !approver.IsActive {
+		return nil, fmt.Errorf("%w: approver account is deactivated", ErrInvalidApproval)
+	}
+
+	// The approver's role may have changed since a token was created
+	perms, err := s.roleRepo.GetPermissions(approver.Role)
+	if err != nil {
+		return nil, err
+	}
+	approver.Permissions = perms
+	for _, permission := range missing {
+		if !approver.HasPermission(permission) {
+			return nil, fmt.Errorf("%w: %s does not have %s", ErrInvalidApproval, approver.Username, permission)
+		}
+	}
+
+	if token != nil {
+		if err := s.tokenRepo.MarkApprovalTokenUsedTx(tx, token, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &approver.ID, nil
+}
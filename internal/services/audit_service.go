@@ -0,0 +1,219 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+)
+
+// ErrInvalidAuditFilter is returned for an audit log query that cannot be run
+var ErrInvalidAuditFilter = errors.New("invalid audit log filter")
+
+// Audit log actions
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// Audited entity types
+const (
+	AuditEntityProduct       = "product"
+	AuditEntityCategory      = "category"
+	AuditEntityInventory     = "inventory"
+	AuditEntityLocation      = "location"
+	AuditEntityTransfer      = "transfer"
+	AuditEntityReorderLevel  = "reorder_level"
+	AuditEntitySupplier      = "supplier"
+	AuditEntityPurchaseOrder = "purchase_order"
+	AuditEntityCustomer      = "customer"
+	AuditEntityOrder         = "order"
+	AuditEntityPayment       = "payment"
+	AuditEntityRefund        = "refund"
+	AuditEntityUser          = "user"
+	AuditEntityRole          = "role"
+	AuditEntityShift         = "shift"
+	AuditEntityCashMovement  = "cash_movement"
+)
+
+// systemActor is the actor name of changes made without a user, such as the
+// first admin created from the command line
+const systemActor = "system"
+
+// auditPasswordChange is what the audit log records of a password change.
+// The password itself, or its hash, is never recorded.
+type auditPasswordChange struct {
+	PasswordChanged bool `json:"password_changed"`
+}
+
+// auditPINChange is what the audit log records of a supervisor PIN being set.
+// Like passwords, the PIN and its hash are never recorded.
+type auditPINChange struct {
+	PINChanged bool `json:"pin_changed"`
+}
+
+// auditTwoFactor is what the audit log records of a change to a user's
+// two-factor authentication. Secrets and recovery codes are never recorded,
+// only that they were replaced.
+type auditTwoFactor struct {
+	TwoFactorEnabled      bool `json:"two_factor_enabled"`
+	SecretReplaced        bool `json:"secret_replaced,omitempty"`
+	RecoveryCodesReplaced bool `json:"recovery_codes_replaced,omitempty"`
+}
+
+// auditShift is what the audit log records of a shift: the shift itself and,
+// once it is closed, what was expected and counted per payment method
+type auditShift struct {
+	models.Shift
+	Tenders []models.ShiftTender `json:"tenders,omitempty"`
+}
+
+// auditOrderStatus is what the audit log records of an order changing status
+type auditOrderStatus struct {
+	Status string `json:"status"`
+}
+
+// auditPaymentStatus is what the audit log records of an order being paid or
+// refunded in full
+type auditPaymentStatus struct {
+	PaymentStatus string `json:"payment_status"`
+}
+
+// AuditService records every change to the store's data in a tamper-evident
+// audit log
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// RecordTx appends a change made by actor to the audit log inside tx, so
+// that the entry is kept exactly when the change is. before is nil for a
+// create and after is nil for a delete. actor may be nil for changes the
+// system makes by itself.
+//
+// Appending takes the audit log's lock, which is held until tx ends and
+// serialises every transaction that appends. Record a change after all of
+// its other writes, stock and row locks included, so that transactions take
+// their locks in the same order and hold the audit lock as briefly as
+// possible.
+func (s *AuditService) RecordTx(tx *sql.Tx, actor *models.User, action, entityType, entityID string, before, after interface{}) error {
+	entry, err := newAuditEntry(actor, action, entityType, entityID, before, after)
+	if err != nil {
+		return err
+	}
+
+	return s.auditRepo.AppendTx(tx, entry)
+}
+
+// GetEntries returns the audit log entries matching filter, newest first
+func (s *AuditService) GetEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	switch filter.Action {
+	case "", AuditActionCreate, AuditActionUpdate, AuditActionDelete:
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidAuditFilter, filter.Action)
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidAuditFilter)
+	}
+
+	return s.auditRepo.GetEntries(filter)
+}
+
+// Verify checks the hash chain of the whole audit log
+func (s *AuditService) Verify() (*models.AuditVerification, error) {
+	return s.auditRepo.Verify()
+}
+
+// newAuditEntry builds the audit log entry of a change, with the fields an
+// update changed
+func newAuditEntry(actor *models.User, action, entityType, entityID string, before, after interface{}) (*models.AuditEntry, error) {
+	entry := &models.AuditEntry{
+		ActorName:  systemActor,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+	if actor != nil {
+		entry.ActorID = optionalUserID(actor.ID)
+		entry.APIKeyID = actor.APIKeyID
+		entry.ActorName = actor.Username
+	}
+
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = auditSnapshot(after); err != nil {
+		return nil, err
+	}
+	if action == AuditActionUpdate {
+		if entry.Changes, err = auditChanges(entry.Before, entry.After); err != nil {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+// auditSnapshot returns the JSON of an entity, or nil when there is none
+func auditSnapshot(entity interface{}) (json.RawMessage, error) {
+	if entity == nil || reflect.ValueOf(entity).Kind() == reflect.Ptr && reflect.ValueOf(entity).IsNil() {
+		return nil, nil
+	}
+
+	snapshot, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record audit snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// auditChanges returns the top-level fields that differ between two
+// snapshots, each with its value before and after
+func auditChanges(before, after json.RawMessage) (json.RawMessage, error) {
+	var from, to map[string]interface{}
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &from); err != nil {
+			return nil, fmt.Errorf("failed to compare audit snapshots: %w", err)
+		}
+	}
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &to); err != nil {
+			return nil, fmt.Errorf("failed to compare audit snapshots: %w", err)
+		}
+	}
+
+	type change struct {
+		Before interface{} `json:"before"`
+		After  interface{} `json:"after"`
+	}
+	changes := map[string]change{}
+	for field, value := range from {
+		if !reflect.DeepEqual(value, to[field]) {
+			changes[field] = change{Before: value, After: to[field]}
+		}
+	}
+	for field, value := range to {
+		if _, ok := from[field]; !ok {
+			changes[field] = change{After: value}
+		}
+	}
+
+	// Maps are encoded with sorted keys, so the same changes always give
+	// the same JSON
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record audit changes: %w", err)
+	}
+	return diff, nil
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"database/sql"
 	"fmt"
 
 	"jatistore/internal/models"
@@ -11,28 +12,47 @@ import (
 
 type CategoryService struct {
 	categoryRepo *repository.CategoryRepository
+	taxRepo      *repository.TaxRepository
+	audit        *AuditService
 }
 
-func NewCategoryService(categoryRepo *repository.CategoryRepository) *CategoryService {
+func NewCategoryService(categoryRepo *repository.CategoryRepository, taxRepo *repository.TaxRepository, audit *AuditService) *CategoryService {
 	return &CategoryService{
 		categoryRepo: categoryRepo,
+		taxRepo:      taxRepo,
+		audit:        audit,
 	}
 }
 
-func (s *CategoryService) CreateCategory(req *models.CreateCategoryRequest) (*models.Category, error) {
+func (s *CategoryService) CreateCategory(req *models.CreateCategoryRequest, actor *models.User) (*models.Category, error) {
+	taxClassID, err := parseTaxClassID(s.taxRepo, req.TaxClassID)
+	if err != nil {
+		return nil, err
+	}
+
 	category := &models.Category{
 		Name:        req.Name,
 		Description: req.Description,
+		TaxClassID:  taxClassID,
 	}
 
-	if err := s.categoryRepo.Create(category); err != nil {
-		return nil, fmt.Errorf("failed to create category: %w", err)
-	}
-
-	// Get the created category
-	createdCategory, err := s.categoryRepo.GetByID(category.ID)
+	var createdCategory *models.Category
+	err = s.categoryRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.categoryRepo.CreateTx(tx, category); err != nil {
+			return fmt.Errorf("failed to create category: %w", err)
+		}
+
+		// Get the created category
+		var err error
+		createdCategory, err = s.categoryRepo.Lock(tx, category.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get created category: %w", err)
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityCategory, createdCategory.ID.String(), nil, createdCategory)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get created category: %w", err)
+		return nil, err
 	}
 
 	return createdCategory, nil
@@ -61,44 +81,66 @@ func (s *CategoryService) GetAllCategories() ([]*models.Category, error) {
 	return categories, nil
 }
 
-func (s *CategoryService) UpdateCategory(id string, req *models.UpdateCategoryRequest) (*models.Category, error) {
+func (s *CategoryService) UpdateCategory(id string, req *models.UpdateCategoryRequest, actor *models.User) (*models.Category, error) {
 	categoryID, err := uuid.Parse(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid category ID: %w", err)
 	}
 
-	// Get existing category
-	existingCategory, err := s.categoryRepo.GetByID(categoryID)
+	taxClassID, err := parseTaxClassID(s.taxRepo, req.TaxClassID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get existing category: %w", err)
+		return nil, err
 	}
 
-	// Update category fields
-	existingCategory.Name = req.Name
-	existingCategory.Description = req.Description
-
-	if err := s.categoryRepo.Update(existingCategory); err != nil {
-		return nil, fmt.Errorf("failed to update category: %w", err)
-	}
-
-	// Get the updated category
-	updatedCategory, err := s.categoryRepo.GetByID(categoryID)
+	var updatedCategory *models.Category
+	err = s.categoryRepo.WithTx(func(tx *sql.Tx) error {
+		// Get existing category
+		existingCategory, err := s.categoryRepo.Lock(tx, categoryID)
+		if err != nil {
+			return fmt.Errorf("failed to get existing category: %w", err)
+		}
+		before := *existingCategory
+
+		// Update category fields
+		existingCategory.Name = req.Name
+		existingCategory.Description = req.Description
+		existingCategory.TaxClassID = taxClassID
+
+		if err := s.categoryRepo.UpdateTx(tx, existingCategory); err != nil {
+			return fmt.Errorf("failed to update category: %w", err)
+		}
+
+		// Get the updated category
+		updatedCategory, err = s.categoryRepo.Lock(tx, categoryID)
+		if err != nil {
+			return fmt.Errorf("failed to get updated category: %w", err)
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityCategory, categoryID.String(), &before, updatedCategory)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get updated category: %w", err)
+		return nil, err
 	}
 
 	return updatedCategory, nil
 }
 
-func (s *CategoryService) DeleteCategory(id string) error {
+func (s *CategoryService) DeleteCategory(id string, actor *models.User) error {
 	categoryID, err := uuid.Parse(id)
 	if err != nil {
 		return fmt.Errorf("invalid category ID: %w", err)
 	}
 
-	if err := s.categoryRepo.Delete(categoryID); err != nil {
-		return fmt.Errorf("failed to delete category: %w", err)
-	}
+	return s.categoryRepo.WithTx(func(tx *sql.Tx) error {
+		category, err := s.categoryRepo.Lock(tx, categoryID)
+		if err != nil {
+			return fmt.Errorf("failed to get category: %w", err)
+		}
+
+		if err := s.categoryRepo.DeleteTx(tx, categoryID); err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
 
-	return nil
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityCategory, categoryID.String(), category, nil)
+	})
 }
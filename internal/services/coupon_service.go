@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidCoupon is returned when a coupon is badly defined or cannot be
+	// used on an order
+	ErrInvalidCoupon = errors.New("invalid coupon")
+	// ErrCouponUsedUp is returned when a coupon has reached its usage limit
+	ErrCouponUsedUp = repository.ErrCouponUsedUp
+	// ErrCouponInUse is returned when deleting a coupon that has been redeemed
+	ErrCouponInUse = errors.New("coupon has been redeemed")
+)
+
+type CouponService struct {
+	couponRepo *repository.CouponRepository
+}
+
+func NewCouponService(couponRepo *repository.CouponRepository) *CouponService {
+	return &CouponService{
+		couponRepo: couponRepo,
+	}
+}
+
+func (s *CouponService) CreateCoupon(req *models.CreateCouponRequest) (*models.Coupon, error) {
+	coupon := &models.Coupon{IsActive: true}
+	applyCouponRequest(coupon, req)
+
+	if err := s.validateCoupon(coupon); err != nil {
+		return nil, err
+	}
+
+	if err := s.couponRepo.Create(coupon); err != nil {
+		return nil, fmt.Errorf("failed to create coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+func (s *CouponService) GetCoupon(id uuid.UUID) (*models.Coupon, error) {
+	coupon, err := s.couponRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+func (s *CouponService) GetAllCoupons() ([]*models.Coupon, error) {
+	coupons, err := s.couponRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupons: %w", err)
+	}
+
+	return coupons, nil
+}
+
+// UpdateCoupon changes a coupon's definition and limits. Uses already made
+// still count against the new limits.
+func (s *CouponService) UpdateCoupon(id uuid.UUID, req *models.UpdateCouponRequest) (*models.Coupon, error) {
+	coupon, err := s.couponRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing coupon: %w", err)
+	}
+
+	applyCouponRequest(coupon, (*models.CreateCouponRequest)(req))
+
+	if err := s.validateCoupon(coupon); err != nil {
+		return nil, err
+	}
+	if coupon.MaxUses != nil && *coupon.MaxUses < coupon.TimesUsed {
+		return nil, fmt.Errorf("%w: max_uses cannot be below the %d uses already made", ErrInvalidCoupon, coupon.TimesUsed)
+	}
+
+	if err := s.couponRepo.Update(coupon); err != nil {
+		return nil, fmt.Errorf("failed to update coupon: %w", err)
+	}
+
+	return coupon, nil
+}
+
+// DeleteCoupon deletes a coupon that has never been redeemed. Redeemed
+// coupons are kept with their redemptions and can only be deactivated.
+func (s *CouponService) DeleteCoupon(id uuid.UUID) error {
+	used, err := s.couponRepo.IsUsed(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete coupon: %w", err)
+	}
+	if used {
+		return fmt.Errorf("%w, deactivate it instead", ErrCouponInUse)
+	}
+
+	if err := s.couponRepo.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete coupon: %w", err)
+	}
+
+	return nil
+}
+
+// validateCoupon checks a coupon's code, limits and discount definition, and
+// that its code is not taken by another coupon
+func (s *CouponService) validateCoupon(coupon *models.Coupon) error {
+	if coupon.Code == "" || strings.ContainsAny(coupon.Code, " \t\n") {
+		return fmt.Errorf("%w: code is required and cannot contain spaces", ErrInvalidCoupon)
+	}
+	if coupon.Type == PromotionSpendThreshold {
+		return fmt.Errorf("%w: use min_basket instead of a spend_threshold coupon", ErrInvalidCoupon)
+	}
+	if coupon.MinBasket < 0 {
+		return fmt.Errorf("%w: min_basket cannot be negative", ErrInvalidCoupon)
+	}
+	if (coupon.MaxUses != nil && *coupon.MaxUses <= 0) || (coupon.MaxUsesPerCustomer != nil && *coupon.MaxUsesPerCustomer <= 0) {
+		return fmt.Errorf("%w: usage limits must be greater than 0", ErrInvalidCoupon)
+	}
+
+	if err := checkDiscount(couponPromotion(coupon)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCoupon, err)
+	}
+
+	existing, err := s.couponRepo.GetByCode(coupon.Code)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != coupon.ID {
+		return fmt.Errorf("%w: code %s already exists", ErrInvalidCoupon, coupon.Code)
+	}
+
+	return nil
+}
+
+func applyCouponRequest(coupon *models.Coupon, req *models.CreateCouponRequest) {
+	coupon.Code = normalizeCouponCode(req.Code)
+	coupon.Description = req.Description
+	coupon.Campaign = req.Campaign
+	coupon.Type = req.Type
+	coupon.Percent = req.Percent
+	coupon.Amount = req.Amount
+	coupon.BuyQuantity = req.BuyQuantity
+	coupon.GetQuantity = req.GetQuantity
+	coupon.ProductID = req.ProductID
+	coupon.CategoryID = req.CategoryID
+	coupon.MinBasket = req.MinBasket
+	coupon.MaxUses = req.MaxUses
+	coupon.MaxUsesPerCustomer = req.MaxUsesPerCustomer
+	coupon.StartsAt = req.StartsAt
+	coupon.EndsAt = req.EndsAt
+	if req.IsActive != nil {
+		coupon.IsActive = *req.IsActive
+	}
+}
+
+// normalizeCouponCode makes coupon codes case-insensitive
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// couponPromotion returns the discount a coupon gives as a promotion, so that
+// coupons are priced by the same engine as automatic promotions
+func couponPromotion(coupon *models.Coupon) *models.Promotion {
+	return &models.Promotion{
+		ID:          coupon.ID,
+		Name:        "Coupon " + coupon.Code,
+		Campaign:    coupon.Campaign,
+		Type:        coupon.Type,
+		Percent:     coupon.Percent,
+		Amount:      coupon.Amount,
+		BuyQuantity: coupon.BuyQuantity,
+		GetQuantity: coupon.GetQuantity,
+		ProductID:   coupon.ProductID,
+		CategoryID:  coupon.CategoryID,
+		StartsAt:    coupon.StartsAt,
+		EndsAt:      coupon.EndsAt,
+		IsActive:    coupon.IsActive,
+	}
+}
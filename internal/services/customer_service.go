@@ -1,6 +1,7 @@
 package services
 
 import (
+	"database/sql"
 	"fmt"
 
 	"jatistore/internal/models"
@@ -11,15 +12,17 @@ import (
 
 type CustomerService struct {
 	customerRepo *repository.CustomerRepository
+	audit        *AuditService
 }
 
-func NewCustomerService(customerRepo *repository.CustomerRepository) *CustomerService {
+func NewCustomerService(customerRepo *repository.CustomerRepository, audit *AuditService) *CustomerService {
 	return &CustomerService{
 		customerRepo: customerRepo,
+		audit:        audit,
 	}
 }
 
-func (s *CustomerService) CreateCustomer(req *models.CreateCustomerRequest) (*models.Customer, error) {
+func (s *CustomerService) CreateCustomer(req *models.CreateCustomerRequest, actor *models.User) (*models.Customer, error) {
 	// Check if customer with email already exists
 	if req.Email != "" {
 		existingCustomer, err := s.customerRepo.GetByEmail(req.Email)
@@ -35,9 +38,15 @@ func (s *CustomerService) CreateCustomer(req *models.CreateCustomerRequest) (*mo
 		Address: req.Address,
 	}
 
-	err := s.customerRepo.Create(customer)
+	err := s.customerRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.customerRepo.CreateTx(tx, customer); err != nil {
+			return fmt.Errorf("failed to create customer: %w", err)
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityCustomer, customer.ID.String(), nil, customer)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create customer: %w", err)
+		return nil, err
 	}
 
 	return customer, nil
@@ -61,42 +70,58 @@ func (s *CustomerService) GetAllCustomers() ([]models.Customer, error) {
 	return customers, nil
 }
 
-func (s *CustomerService) UpdateCustomer(id uuid.UUID, req *models.UpdateCustomerRequest) (*models.Customer, error) {
-	// Check if customer exists
-	existingCustomer, err := s.customerRepo.GetByID(id)
-	if err != nil {
-		return nil, fmt.Errorf("customer not found: %w", err)
-	}
+func (s *CustomerService) UpdateCustomer(id uuid.UUID, req *models.UpdateCustomerRequest, actor *models.User) (*models.Customer, error) {
+	var existingCustomer *models.Customer
+	err := s.customerRepo.WithTx(func(tx *sql.Tx) error {
+		// Check if customer exists
+		var err error
+		existingCustomer, err = s.customerRepo.Lock(tx, id)
+		if err != nil {
+			return fmt.Errorf("customer not found: %w", err)
+		}
 
-	// Check if email is being changed and if it already exists
-	if req.Email != existingCustomer.Email && req.Email != "" {
-		emailCustomer, err := s.customerRepo.GetByEmail(req.Email)
-		if err == nil && emailCustomer != nil {
-			return nil, fmt.Errorf("customer with email %s already exists", req.Email)
+		// Check if email is being changed and if it already exists
+		if req.Email != existingCustomer.Email && req.Email != "" {
+			emailCustomer, err := s.customerRepo.GetByEmail(req.Email)
+			if err == nil && emailCustomer != nil {
+				return fmt.Errorf("customer with email %s already exists", req.Email)
+			}
 		}
-	}
 
-	// Update customer fields
-	existingCustomer.Name = req.Name
-	existingCustomer.Email = req.Email
-	existingCustomer.Phone = req.Phone
-	existingCustomer.Address = req.Address
+		before := *existingCustomer
+
+		// Update customer fields
+		existingCustomer.Name = req.Name
+		existingCustomer.Email = req.Email
+		existingCustomer.Phone = req.Phone
+		existingCustomer.Address = req.Address
 
-	err = s.customerRepo.Update(existingCustomer)
+		if err := s.customerRepo.UpdateTx(tx, existingCustomer); err != nil {
+			return fmt.Errorf("failed to update customer: %w", err)
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityCustomer, id.String(), &before, existingCustomer)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update customer: %w", err)
+		return nil, err
 	}
 
 	return existingCustomer, nil
 }
 
-func (s *CustomerService) DeleteCustomer(id uuid.UUID) error {
-	err := s.customerRepo.Delete(id)
-	if err != nil {
-		return fmt.Errorf("failed to delete customer: %w", err)
-	}
+func (s *CustomerService) DeleteCustomer(id uuid.UUID, actor *models.User) error {
+	return s.customerRepo.WithTx(func(tx *sql.Tx) error {
+		customer, err := s.customerRepo.Lock(tx, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete customer: %w", err)
+		}
+
+		if err := s.customerRepo.DeleteTx(tx, id); err != nil {
+			return fmt.Errorf("failed to delete customer: %w", err)
+		}
 
-	return nil
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityCustomer, id.String(), customer, nil)
+	})
 }
 
 func (s *CustomerService) SearchCustomers(query string) ([]models.Customer, error) {
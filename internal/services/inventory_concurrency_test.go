@@ -0,0 +1,290 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"jatistore/internal/database"
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// These tests hammer the stock of a single product from many goroutines and
+// check that no movement is lost. They need a PostgreSQL database, given as
+// a connection URL in TEST_DATABASE_URL, and are skipped without one. Every
+// run creates its own product and locations, so the database can be reused.
+
+type stockFixture struct {
+	db            *database.DB
+	inventoryRepo *repository.InventoryRepository
+	service       *InventoryService
+	productID     string
+}
+
+func newStockFixture(t *testing.T) *stockFixture {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := database.NewConnection(url)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	suffix := uuid.New().String()[:8]
+	var categoryID, productID string
+	err = db.QueryRow(`INSERT INTO categories (name) VALUES ($1) RETURNING id`, "stock-test-"+suffix).Scan(&categoryID)
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	err = db.QueryRow(`INSERT INTO products (name, sku, category_id, price) VALUES ($1, $1, $2, 1) RETURNING id`, "stock-test-"+suffix, categoryID).Scan(&productID)
+	if err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+
+	inventoryRepo := repository.NewInventoryRepository(db)
+	return &stockFixture{
+		db:            db,
+		inventoryRepo: inventoryRepo,
+		service: NewInventoryService(
+			inventoryRepo,
+			repository.NewLocationRepository(db),
+			repository.NewTransferRepository(db),
+			NewAuditService(repository.NewAuditRepository(db)),
+		),
+		productID: productID,
+	}
+}
+
+// location creates a location unique to this run, stocked with quantity
+func (f *stockFixture) location(t *testing.T, quantity int) string {
+	t.Helper()
+
+	name := "stock-test-" + uuid.New().String()[:8]
+	if _, err := f.db.Exec(`INSERT INTO locations (name) VALUES ($1)`, name); err != nil {
+		t.Fatalf("failed to create location: %v", err)
+	}
+
+	if quantity > 0 {
+		f.adjust(t, "in", quantity, name)
+	}
+	return name
+}
+
+func (f *stockFixture) adjust(t *testing.T, kind string, quantity int, location string) {
+	t.Helper()
+
+	_, err := f.service.AdjustStock(&models.AdjustStockRequest{
+		ProductID: f.productID,
+		Type:      kind,
+		Quantity:  quantity,
+		Reason:    "Concurrency test",
+		Location:  location,
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to adjust stock: %v", err)
+	}
+}
+
+// quantity returns the stock of the product at a location
+func (f *stockFixture) quantity(t *testing.T, location string) int {
+	t.Helper()
+
+	var quantity int
+	err := f.db.QueryRow(`SELECT quantity FROM inventory WHERE product_id = $1 AND location = $2`, f.productID, location).Scan(&quantity)
+	if err != nil {
+		t.Fatalf("failed to get stock: %v", err)
+	}
+	return quantity
+}
+
+// ledger returns the stock of the product at a location worked out from the
+// stock ledger
+func (f *stockFixture) ledger(t *testing.T, location string) int {
+	t.Helper()
+
+	levels, err := f.inventoryRepo.GetLevels(nil, f.productID, location)
+	if err != nil {
+		t.Fatalf("failed to get ledger stock: %v", err)
+	}
+	if len(levels) == 0 {
+		return 0
+	}
+	return levels[0].Quantity
+}
+
+// hammer runs fn workers times each from its own goroutine and waits for
+// all of them
+func hammer(workers, times int, fn func(worker, i int)) {
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < times; i++ {
+				fn(worker, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestAdjustStockConcurrentOutNeverOversells(t *testing.T) {
+	f := newStockFixture(t)
+	const stock, workers, times = 100, 40, 5
+	location := f.location(t, stock)
+
+	var sold, refused int64
+	var unexpected sync.Map
+	hammer(workers, times, func(worker, i int) {
+		_, err := f.service.AdjustStock(&models.AdjustStockRequest{
+			ProductID: f.productID,
+			Type:      "out",
+			Quantity:  1,
+			Reason:    "Concurrency test",
+			Location:  location,
+		}, nil)
+		switch {
+		case err == nil:
+			atomic.AddInt64(&sold, 1)
+		case errors.Is(err, ErrInsufficientStock):
+			atomic.AddInt64(&refused, 1)
+		default:
+			unexpected.Store(fmt.Sprintf("%d/%d", worker, i), err)
+		}
+	})
+
+	unexpected.Range(func(key, err interface{}) bool {
+		t.Errorf("adjustment %s failed: %v", key, err)
+		return true
+	})
+	if sold != stock {
+		t.Errorf("sold %d units, want exactly the %d in stock", sold, stock)
+	}
+	if sold+refused != workers*times {
+		t.Errorf("%d adjustments sold or were refused, want %d", sold+refused, workers*times)
+	}
+	if got := f.quantity(t, location); got != 0 {
+		t.Errorf("stock is %d, want 0", got)
+	}
+	if got := f.ledger(t, location); got != 0 {
+		t.Errorf("ledger sums to %d, want 0", got)
+	}
+}
+
+func TestConcurrentAdjustmentsAndSalesLoseNothing(t *testing.T) {
+	f := newStockFixture(t)
+	const stock, workers, times = 500, 30, 20
+	location := f.location(t, stock)
+
+	// Even workers receive 3 units at a time through adjustments; odd
+	// workers sell 2 at a time the way completing an order does
+	var failures int64
+	hammer(workers, times, func(worker, i int) {
+		var err error
+		if worker%2 == 0 {
+			_, err = f.service.AdjustStock(&models.AdjustStockRequest{
+				ProductID: f.productID,
+				Type:      "in",
+				Quantity:  3,
+				Reason:    "Concurrency test",
+				Location:  location,
+			}, nil)
+		} else {
+			err = f.inventoryRepo.WithTx(func(tx *sql.Tx) error {
+				_, err := f.inventoryRepo.DeductStock(tx, f.productID, location, 2, false, "Concurrency test", "")
+				return err
+			})
+		}
+		if err != nil {
+			atomic.AddInt64(&failures, 1)
+			t.Errorf("worker %d movement %d failed: %v", worker, i, err)
+		}
+	})
+
+	if failures > 0 {
+		t.FailNow()
+	}
+
+	want := stock + (workers/2)*times*3 - (workers/2)*times*2
+	if got := f.quantity(t, location); got != want {
+		t.Errorf("stock is %d, want %d", got, want)
+	}
+	if got := f.ledger(t, location); got != want {
+		t.Errorf("ledger sums to %d, want %d", got, want)
+	}
+}
+
+func TestAdjustStockConcurrentFirstStockAtLocation(t *testing.T) {
+	f := newStockFixture(t)
+	const workers = 25
+	location := f.location(t, 0)
+
+	// Every adjustment finds no inventory row and races to create it
+	hammer(workers, 1, func(worker, i int) {
+		_, err := f.service.AdjustStock(&models.AdjustStockRequest{
+			ProductID: f.productID,
+			Type:      "in",
+			Quantity:  1,
+			Reason:    "Concurrency test",
+			Location:  location,
+		}, nil)
+		if err != nil {
+			t.Errorf("worker %d failed: %v", worker, err)
+		}
+	})
+
+	if got := f.quantity(t, location); got != workers {
+		t.Errorf("stock is %d, want %d", got, workers)
+	}
+	if got := f.ledger(t, location); got != workers {
+		t.Errorf("ledger sums to %d, want %d", got, workers)
+	}
+}
+
+func TestConcurrentTransfersConserveStock(t *testing.T) {
+	f := newStockFixture(t)
+	const stock, workers, times = 200, 20, 10
+	from, to := f.location(t, stock), f.location(t, stock)
+
+	// Half the workers move stock one way and half the other, so both
+	// locations end where they started
+	hammer(workers, times, func(worker, i int) {
+		req := &models.CreateTransferRequest{
+			FromLocation: from,
+			ToLocation:   to,
+			Receive:      true,
+			Items:        []models.TransferItemRequest{{ProductID: uuid.MustParse(f.productID), Quantity: 1}},
+		}
+		if worker%2 == 1 {
+			req.FromLocation, req.ToLocation = to, from
+		}
+		if _, err := f.service.CreateTransfer(req, nil); err != nil {
+			t.Errorf("worker %d transfer %d failed: %v", worker, i, err)
+		}
+	})
+
+	for _, location := range []string{from, to} {
+		if got := f.quantity(t, location); got != stock {
+			t.Errorf("stock at %s is %d, want %d", location, got, stock)
+		}
+		if got := f.ledger(t, location); got != stock {
+			t.Errorf("ledger at %s sums to %d, want %d", location, got, stock)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"jatistore/internal/models"
+)
+
+// GetStockOnHand works out the stock of each product at each location from
+// the ledger as it stood at the given time, or now when it is nil. productID
+// and location narrow it down unless they are empty.
+func (s *InventoryService) GetStockOnHand(at *time.Time, productID, location string) ([]models.StockLevel, error) {
+	levels, err := s.inventoryRepo.GetLevels(at, productID, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock on hand: %w", err)
+	}
+
+	return levels, nil
+}
+
+// Reconcile checks every inventory quantity against the ledger and reports
+// those that differ. With rebuild set it also replaces them by the ledger's,
+// while no stock can be moved, and records each one in the audit log.
+func (s *InventoryService) Reconcile(rebuild bool, actor *models.User) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{CheckedAt: time.Now()}
+
+	if !rebuild {
+		checked, mismatches, err := s.inventoryRepo.GetMismatches()
+		if err != nil {
+			return nil, err
+		}
+		report.Checked = checked
+		report.Mismatches = mismatches
+		return report, nil
+	}
+
+	err := s.inventoryRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.inventoryRepo.LockAll(tx); err != nil {
+			return err
+		}
+
+		checked, mismatches, err := s.inventoryRepo.GetMismatchesTx(tx)
+		if err != nil {
+			return err
+		}
+		report.Checked = checked
+		report.Mismatches = mismatches
+
+		type rebuilt struct {
+			before    *models.Inventory
+			inventory *models.Inventory
+		}
+		changes := make([]rebuilt, 0, len(mismatches))
+		for _, mismatch := range mismatches {
+			inventory, created, err := s.inventoryRepo.LockOrCreate(tx, mismatch.ProductID, mismatch.Location)
+			if err != nil {
+				return err
+			}
+
+			var before *models.Inventory
+			if !created {
+				snapshot := *inventory
+				before = &snapshot
+			}
+			if err := s.inventoryRepo.SetQuantity(tx, inventory, mismatch.LedgerQuantity); err != nil {
+				return err
+			}
+			changes = append(changes, rebuilt{before: before, inventory: inventory})
+		}
+
+		for _, change := range changes {
+			action := AuditActionUpdate
+			if change.before == nil {
+				action = AuditActionCreate
+			}
+			if err := s.audit.RecordTx(tx, actor, action, AuditEntityInventory, change.inventory.ID.String(), change.before, change.inventory); err != nil {
+				return err
+			}
+		}
+
+		report.Rebuilt = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"jatistore/internal/models"
@@ -9,29 +11,75 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrInvalidAdjustment is returned for a stock adjustment with an unknown
+	// type or a quantity it cannot have
+	ErrInvalidAdjustment = errors.New("invalid stock adjustment")
+	// ErrLocationRequired is returned for a stock adjustment that leaves out
+	// the location of a product stocked at more than one
+	ErrLocationRequired = errors.New("location is required")
+	// ErrInventoryExists is returned when creating inventory for a product
+	// at a location it is already stocked at
+	ErrInventoryExists = errors.New("product is already stocked at this location")
+	// ErrInventoryNotEmpty is returned when deleting inventory that still
+	// holds stock
+	ErrInventoryNotEmpty = errors.New("inventory still holds stock; adjust it to zero first")
+)
+
 type InventoryService struct {
 	inventoryRepo *repository.InventoryRepository
+	locationRepo  *repository.LocationRepository
+	transferRepo  *repository.TransferRepository
+	audit         *AuditService
 }
 
-func NewInventoryService(inventoryRepo *repository.InventoryRepository) *InventoryService {
+func NewInventoryService(inventoryRepo *repository.InventoryRepository, locationRepo *repository.LocationRepository, transferRepo *repository.TransferRepository, audit *AuditService) *InventoryService {
 	return &InventoryService{
 		inventoryRepo: inventoryRepo,
+		locationRepo:  locationRepo,
+		transferRepo:  transferRepo,
+		audit:         audit,
 	}
 }
 
-func (s *InventoryService) CreateInventory(req *models.CreateInventoryRequest) (*models.Inventory, error) {
-	inventory := &models.Inventory{
-		ProductID: req.ProductID,
-		Quantity:  req.Quantity,
-		Location:  req.Location,
+// CreateInventory stocks a product at a location it has not been stocked at
+// before, recording the quantity in the ledger as its opening balance
+func (s *InventoryService) CreateInventory(req *models.CreateInventoryRequest, actor *models.User) (*models.Inventory, error) {
+	if req.Quantity < 0 {
+		return nil, fmt.Errorf("%w: quantity cannot be negative", ErrInvalidAdjustment)
+	}
+	if err := activeLocation(s.locationRepo, req.Location); err != nil {
+		return nil, err
 	}
 
-	if err := s.inventoryRepo.Create(inventory); err != nil {
-		return nil, fmt.Errorf("failed to create inventory: %w", err)
+	var inventoryID uuid.UUID
+	err := s.inventoryRepo.WithTx(func(tx *sql.Tx) error {
+		inventory, created, err := s.inventoryRepo.LockOrCreate(tx, req.ProductID, req.Location)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return ErrInventoryExists
+		}
+
+		transaction := &models.InventoryTransaction{
+			Type:     "initial",
+			Quantity: req.Quantity,
+			Reason:   "Opening balance",
+		}
+		if err := s.inventoryRepo.Move(tx, inventory, req.Quantity, transaction); err != nil {
+			return err
+		}
+		inventoryID = inventory.ID
+
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityInventory, inventory.ID.String(), nil, inventory)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Get the created inventory with product information
-	createdInventory, err := s.inventoryRepo.GetByID(inventory.ID)
+	createdInventory, err := s.inventoryRepo.GetByID(inventoryID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get created inventory: %w", err)
 	}
@@ -62,7 +110,11 @@ func (s *InventoryService) GetAllInventory() ([]*models.Inventory, error) {
 	return inventories, nil
 }
 
-func (s *InventoryService) UpdateInventory(id string, req *models.UpdateInventoryRequest) (*models.Inventory, error) {
+// UpdateInventory sets the stock of an inventory row to a counted quantity.
+// It is recorded in the ledger as an adjustment, the same as one made through
+// AdjustStock. Stock cannot change location this way; it is transferred
+// instead.
+func (s *InventoryService) UpdateInventory(id string, req *models.UpdateInventoryRequest, actor *models.User) (*models.Inventory, error) {
 	inventoryID, err := uuid.Parse(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid inventory ID: %w", err)
@@ -74,12 +126,24 @@ func (s *InventoryService) UpdateInventory(id string, req *models.UpdateInventor
 		return nil, fmt.Errorf("failed to get existing inventory: %w", err)
 	}
 
-	// Update inventory fields
-	existingInventory.Quantity = req.Quantity
-	existingInventory.Location = req.Location
+	if req.Location != existingInventory.Location {
+		return nil, fmt.Errorf("%w: stock cannot change location, transfer it instead", ErrInvalidAdjustment)
+	}
 
-	if err := s.inventoryRepo.Update(existingInventory); err != nil {
-		return nil, fmt.Errorf("failed to update inventory: %w", err)
+	reason := req.Reason
+	if reason == "" {
+		reason = "Inventory updated"
+	}
+
+	_, err = s.AdjustStock(&models.AdjustStockRequest{
+		ProductID: existingInventory.ProductID,
+		Quantity:  req.Quantity,
+		Type:      "adjustment",
+		Reason:    reason,
+		Location:  existingInventory.Location,
+	}, actor)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get the updated inventory with product information
@@ -91,86 +155,145 @@ func (s *InventoryService) UpdateInventory(id string, req *models.UpdateInventor
 	return updatedInventory, nil
 }
 
-func (s *InventoryService) DeleteInventory(id string) error {
+// DeleteInventory deletes an inventory row holding no stock. Its ledger is
+// kept; stock still there is adjusted to zero first.
+func (s *InventoryService) DeleteInventory(id string, actor *models.User) error {
 	inventoryID, err := uuid.Parse(id)
 	if err != nil {
 		return fmt.Errorf("invalid inventory ID: %w", err)
 	}
 
-	if err := s.inventoryRepo.Delete(inventoryID); err != nil {
-		return fmt.Errorf("failed to delete inventory: %w", err)
+	inventory, err := s.inventoryRepo.GetByID(inventoryID)
+	if err != nil {
+		return fmt.Errorf("failed to get inventory: %w", err)
 	}
 
-	return nil
+	return s.inventoryRepo.WithTx(func(tx *sql.Tx) error {
+		locked, err := s.inventoryRepo.Lock(tx, inventory.ProductID, inventory.Location)
+		if err != nil {
+			return err
+		}
+		if locked == nil {
+			return fmt.Errorf("inventory not found")
+		}
+		if locked.Quantity != 0 {
+			return fmt.Errorf("%w: %d left at %s", ErrInventoryNotEmpty, locked.Quantity, locked.Location)
+		}
+
+		if err := s.inventoryRepo.Delete(tx, inventoryID); err != nil {
+			return fmt.Errorf("failed to delete inventory: %w", err)
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityInventory, inventoryID.String(), inventory, nil)
+	})
 }
 
-func (s *InventoryService) AdjustStock(req *models.AdjustStockRequest) (*models.InventoryTransaction, error) {
+// AdjustStock moves stock in or out of a location, or sets it to a counted
+// quantity. The location may be left out for a product stocked at a single
+// location. Stock can be brought into a location the product has not been
+// stocked at before. The inventory row is locked while the new quantity is
+// worked out, and the ledger entry is written in the same transaction, so
+// concurrent adjustments and sales cannot lose each other's changes.
+func (s *InventoryService) AdjustStock(req *models.AdjustStockRequest, actor *models.User) (*models.InventoryTransaction, error) {
 	// Use product ID as a string (no UUID parsing)
 	productID := req.ProductID
 
-	// Get current inventory for the product
-	inventories, err := s.inventoryRepo.GetByProductIDString(productID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get product inventory: %w", err)
-	}
-
-	if len(inventories) == 0 {
-		return nil, fmt.Errorf("no inventory found for product")
-	}
-
-	// For simplicity, we'll adjust the first inventory record
-	// In a real application, you might want to specify which location to adjust
-	inventory := inventories[0]
-
-	// Calculate new quantity based on transaction type
-	var newQuantity int
 	switch req.Type {
-	case "in":
-		newQuantity = inventory.Quantity + req.Quantity
-	case "out":
-		newQuantity = inventory.Quantity - req.Quantity
-		if newQuantity < 0 {
-			return nil, fmt.Errorf("insufficient stock: current quantity is %d, trying to remove %d", inventory.Quantity, req.Quantity)
+	case "in", "out":
+		if req.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidAdjustment)
 		}
 	case "adjustment":
-		newQuantity = req.Quantity
-		if newQuantity < 0 {
-			return nil, fmt.Errorf("quantity cannot be negative")
+		if req.Quantity < 0 {
+			return nil, fmt.Errorf("%w: quantity cannot be negative", ErrInvalidAdjustment)
 		}
 	default:
-		return nil, fmt.Errorf("invalid transaction type: %s", req.Type)
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidAdjustment, req.Type)
 	}
 
-	// Update inventory quantity
-	inventory.Quantity = newQuantity
-	if err := s.inventoryRepo.Update(inventory); err != nil {
-		return nil, fmt.Errorf("failed to update inventory: %w", err)
+	location, err := s.adjustmentLocation(productID, req.Location)
+	if err != nil {
+		return nil, err
+	}
+	if err := activeLocation(s.locationRepo, location); err != nil {
+		return nil, err
 	}
 
-	// Create transaction record
 	transaction := &models.InventoryTransaction{
-		ProductID: productID, // string
 		Type:      req.Type,
 		Quantity:  req.Quantity,
 		Reason:    req.Reason,
 		Reference: req.Reference,
 	}
 
-	if err := s.inventoryRepo.CreateTransactionString(transaction); err != nil {
-		return nil, fmt.Errorf("failed to create transaction: %w", err)
-	}
+	err = s.inventoryRepo.WithTx(func(tx *sql.Tx) error {
+		var inventory *models.Inventory
+		var created bool
+		var err error
+		if req.Type == "out" {
+			inventory, err = s.inventoryRepo.Lock(tx, productID, location)
+		} else {
+			inventory, created, err = s.inventoryRepo.LockOrCreate(tx, productID, location)
+		}
+		if err != nil {
+			return err
+		}
+
+		if inventory == nil {
+			return fmt.Errorf("%w: product has no stock at %s", ErrInsufficientStock, location)
+		}
+
+		// Calculate the change from the locked quantity
+		var delta int
+		switch req.Type {
+		case "in":
+			delta = req.Quantity
+		case "out":
+			if inventory.Quantity < req.Quantity {
+				return fmt.Errorf("%w: current quantity at %s is %d, trying to remove %d", ErrInsufficientStock, location, inventory.Quantity, req.Quantity)
+			}
+			delta = -req.Quantity
+		case "adjustment":
+			delta = req.Quantity - inventory.Quantity
+		}
 
-	// Get the created transaction with product information
-	createdTransaction, err := s.inventoryRepo.GetTransactionsByProductIDString(productID)
+		before := *inventory
+		if err := s.inventoryRepo.Move(tx, inventory, delta, transaction); err != nil {
+			return err
+		}
+
+		if created {
+			return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityInventory, inventory.ID.String(), nil, inventory)
+		}
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityInventory, inventory.ID.String(), &before, inventory)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get created transaction: %w", err)
+		return nil, err
 	}
 
-	if len(createdTransaction) > 0 {
-		return createdTransaction[0], nil
+	return transaction, nil
+}
+
+// adjustmentLocation returns the location a stock adjustment applies to. It
+// may be left out for a product stocked at a single location.
+func (s *InventoryService) adjustmentLocation(productID, location string) (string, error) {
+	if location != "" {
+		return location, nil
 	}
 
-	return transaction, nil
+	inventories, err := s.inventoryRepo.GetByProductIDString(productID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get product inventory: %w", err)
+	}
+
+	switch len(inventories) {
+	case 0:
+		return "", fmt.Errorf("no inventory found for product")
+	case 1:
+		return inventories[0].Location, nil
+	default:
+		return "", fmt.Errorf("%w: product is stocked at %d locations", ErrLocationRequired, len(inventories))
+	}
 }
 
 func (s *InventoryService) GetInventoryByProductID(productID string) ([]*models.Inventory, error) {
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Stock transfer statuses
+const (
+	TransferStatusShipped   = "shipped"
+	TransferStatusReceived  = "received"
+	TransferStatusCancelled = "cancelled"
+)
+
+var (
+	// ErrInvalidTransfer is returned for a transfer request that cannot be
+	// shipped as given
+	ErrInvalidTransfer = errors.New("invalid transfer")
+	// ErrTransferNotInTransit is returned when receiving or cancelling a
+	// transfer that has already been received or cancelled
+	ErrTransferNotInTransit = errors.New("transfer is not in transit")
+)
+
+// auditTransferStatus is what the audit log records of a transfer being
+// received or cancelled
+type auditTransferStatus struct {
+	Status string `json:"status"`
+}
+
+// CreateTransfer ships stock from one location to another. Each line is taken
+// out of the source location, which must hold enough stock, as an "out"
+// movement referencing the transfer number. The stock is then in transit
+// until the transfer is received, unless the request receives it at once.
+func (s *InventoryService) CreateTransfer(req *models.CreateTransferRequest, actor *models.User) (*models.StockTransfer, error) {
+	if req.FromLocation == req.ToLocation {
+		return nil, fmt.Errorf("%w: source and destination are the same location", ErrInvalidTransfer)
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: at least one item is required", ErrInvalidTransfer)
+	}
+
+	transfer := &models.StockTransfer{
+		FromLocation: req.FromLocation,
+		ToLocation:   req.ToLocation,
+		Notes:        req.Notes,
+	}
+	seen := make(map[uuid.UUID]bool, len(req.Items))
+	for _, item := range req.Items {
+		if item.ProductID == uuid.Nil {
+			return nil, fmt.Errorf("%w: product ID is required", ErrInvalidTransfer)
+		}
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidTransfer)
+		}
+		if seen[item.ProductID] {
+			return nil, fmt.Errorf("%w: product %s is listed more than once", ErrInvalidTransfer, item.ProductID)
+		}
+		seen[item.ProductID] = true
+		transfer.Items = append(transfer.Items, models.StockTransferItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	// Stock rows are locked in product order, so that concurrent transfers
+	// cannot deadlock each other
+	sort.Slice(transfer.Items, func(i, j int) bool {
+		return transfer.Items[i].ProductID.String() < transfer.Items[j].ProductID.String()
+	})
+
+	if err := activeLocation(s.locationRepo, req.FromLocation); err != nil {
+		return nil, err
+	}
+	if err := activeLocation(s.locationRepo, req.ToLocation); err != nil {
+		return nil, err
+	}
+	if actor != nil {
+		transfer.CreatedBy = optionalUserID(actor.ID)
+	}
+
+	err := s.inventoryRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.transferRepo.CreateTx(tx, transfer); err != nil {
+			return err
+		}
+
+		if req.Receive {
+			// Both ends are locked up front in the same order every time, so
+			// that transfers going opposite ways cannot deadlock each other
+			if err := s.lockTransferStock(tx, transfer); err != nil {
+				return err
+			}
+		}
+
+		reason := "Transfer to " + transfer.ToLocation
+		for _, item := range transfer.Items {
+			_, err := s.inventoryRepo.DeductStock(tx, item.ProductID.String(), transfer.FromLocation, item.Quantity, false, reason, transfer.TransferNumber)
+			if err != nil {
+				return err
+			}
+		}
+
+		if req.Receive {
+			if err := s.receiveTransfer(tx, transfer, actor); err != nil {
+				return err
+			}
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityTransfer, transfer.ID.String(), nil, transfer)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// ReceiveTransfer puts the stock of a transfer in transit into its
+// destination location, as an "in" movement per line referencing the
+// transfer number
+func (s *InventoryService) ReceiveTransfer(id uuid.UUID, actor *models.User) (*models.StockTransfer, error) {
+	var transfer *models.StockTransfer
+	err := s.inventoryRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		transfer, err = s.transferRepo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		if transfer.Status != TransferStatusShipped {
+			return fmt.Errorf("%w: transfer is %s", ErrTransferNotInTransit, transfer.Status)
+		}
+
+		if err := s.receiveTransfer(tx, transfer, actor); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityTransfer, transfer.ID.String(),
+			auditTransferStatus{Status: TransferStatusShipped}, auditTransferStatus{Status: transfer.Status})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// CancelTransfer puts the stock of a transfer in transit back into its
+// source location
+func (s *InventoryService) CancelTransfer(id uuid.UUID, actor *models.User) (*models.StockTransfer, error) {
+	var transfer *models.StockTransfer
+	err := s.inventoryRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		transfer, err = s.transferRepo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		if transfer.Status != TransferStatusShipped {
+			return fmt.Errorf("%w: transfer is %s", ErrTransferNotInTransit, transfer.Status)
+		}
+
+		reason := "Transfer cancelled"
+		for _, item := range transfer.Items {
+			_, err := s.inventoryRepo.ReturnStock(tx, item.ProductID.String(), transfer.FromLocation, item.Quantity, reason, transfer.TransferNumber)
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		transfer.Status = TransferStatusCancelled
+		transfer.CancelledAt = &now
+		if err := s.transferRepo.UpdateStatusTx(tx, transfer); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityTransfer, transfer.ID.String(),
+			auditTransferStatus{Status: TransferStatusShipped}, auditTransferStatus{Status: transfer.Status})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// GetTransfers returns the transfers, newest first, optionally only those
+// with the given status
+func (s *InventoryService) GetTransfers(status string) ([]models.StockTransfer, error) {
+	switch status {
+	case "", TransferStatusShipped, TransferStatusReceived, TransferStatusCancelled:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidTransfer, status)
+	}
+
+	transfers, err := s.transferRepo.GetAll(status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func (s *InventoryService) GetTransfer(id uuid.UUID) (*models.StockTransfer, error) {
+	return s.transferRepo.GetByID(id)
+}
+
+// lockTransferStock locks the stock rows of a transfer's products at both its
+// locations inside tx, location by location in name order and product by
+// product within each
+func (s *InventoryService) lockTransferStock(tx *sql.Tx, transfer *models.StockTransfer) error {
+	locations := []string{transfer.FromLocation, transfer.ToLocation}
+	sort.Strings(locations)
+
+	for _, location := range locations {
+		for _, item := range transfer.Items {
+			if _, err := s.inventoryRepo.Lock(tx, item.ProductID.String(), location); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// receiveTransfer moves the stock of a shipped transfer into its destination
+// inside tx and marks the transfer received
+func (s *InventoryService) receiveTransfer(tx *sql.Tx, transfer *models.StockTransfer, actor *models.User) error {
+	reason := "Transfer from " + transfer.FromLocation
+	for _, item := range transfer.Items {
+		_, err := s.inventoryRepo.ReturnStock(tx, item.ProductID.String(), transfer.ToLocation, item.Quantity, reason, transfer.TransferNumber)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	transfer.Status = TransferStatusReceived
+	transfer.ReceivedAt = &now
+	if actor != nil {
+		transfer.ReceivedBy = optionalUserID(actor.ID)
+	}
+
+	return s.transferRepo.UpdateStatusTx(tx, transfer)
+}
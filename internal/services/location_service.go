@@ -0,0 +1,158 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+)
+
+var (
+	// ErrInvalidLocationName is returned for a location name that is empty
+	// or too long
+	ErrInvalidLocationName = errors.New("location name must be 1 to 255 characters")
+	// ErrLocationInUse is returned when deleting a location that inventory,
+	// the stock ledger, a transfer or a purchase order still names. It can
+	// be deactivated instead.
+	ErrLocationInUse = errors.New("location has stock history; deactivate it instead")
+	// ErrInactiveLocation is returned for a new stock movement at a location
+	// that has been deactivated
+	ErrInactiveLocation = errors.New("location is inactive")
+)
+
+// ErrUnknownLocation is returned for a stock movement at a location that does
+// not exist
+var ErrUnknownLocation = repository.ErrUnknownLocation
+
+type LocationService struct {
+	locationRepo *repository.LocationRepository
+	audit        *AuditService
+}
+
+func NewLocationService(locationRepo *repository.LocationRepository, audit *AuditService) *LocationService {
+	return &LocationService{
+		locationRepo: locationRepo,
+		audit:        audit,
+	}
+}
+
+func (s *LocationService) GetLocations() ([]models.Location, error) {
+	locations, err := s.locationRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locations: %w", err)
+	}
+
+	return locations, nil
+}
+
+func (s *LocationService) GetLocation(name string) (*models.Location, error) {
+	return s.locationRepo.GetByName(name)
+}
+
+// CreateLocation creates an active location
+func (s *LocationService) CreateLocation(req *models.CreateLocationRequest, actor *models.User) (*models.Location, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 255 {
+		return nil, ErrInvalidLocationName
+	}
+
+	exists, err := s.locationRepo.Exists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("location already exists")
+	}
+
+	location := &models.Location{
+		Name:        name,
+		Description: req.Description,
+	}
+	err = s.locationRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.locationRepo.CreateTx(tx, location); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityLocation, location.Name, nil, location)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return location, nil
+}
+
+// UpdateLocation replaces the description of a location and whether it is
+// active. Stock at an inactive location stays where it is, but no new stock
+// can be moved in or out of it.
+func (s *LocationService) UpdateLocation(name string, req *models.UpdateLocationRequest, actor *models.User) (*models.Location, error) {
+	var location *models.Location
+	err := s.locationRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		location, err = s.locationRepo.Lock(tx, name)
+		if err != nil {
+			return err
+		}
+		before := *location
+
+		location.Description = req.Description
+		location.IsActive = req.IsActive
+
+		if err := s.locationRepo.UpdateTx(tx, location); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityLocation, location.Name, &before, location)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return location, nil
+}
+
+// DeleteLocation deletes a location that has never held stock. The location
+// is locked first, so that no stock can be put at it between counting its
+// uses and deleting it.
+func (s *LocationService) DeleteLocation(name string, actor *models.User) error {
+	return s.locationRepo.WithTx(func(tx *sql.Tx) error {
+		location, err := s.locationRepo.Lock(tx, name)
+		if err != nil {
+			return err
+		}
+
+		uses, err := s.locationRepo.CountUsesTx(tx, name)
+		if err != nil {
+			return err
+		}
+		if uses > 0 {
+			return ErrLocationInUse
+		}
+
+		if err := s.locationRepo.DeleteTx(tx, name); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityLocation, name, location, nil)
+	})
+}
+
+// activeLocation returns ErrInactiveLocation for a location that has been
+// deactivated, and ErrUnknownLocation for one that does not exist
+func activeLocation(locationRepo *repository.LocationRepository, name string) error {
+	location, err := locationRepo.GetByName(name)
+	if err != nil {
+		if err.Error() == "location not found" {
+			return fmt.Errorf("%w %q", ErrUnknownLocation, name)
+		}
+		return err
+	}
+	if !location.IsActive {
+		return fmt.Errorf("%w: %s", ErrInactiveLocation, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ErrTooManyLoginAttempts is returned for a login from a username or IP that
+// has to wait after failed logins, or is locked out
+var ErrTooManyLoginAttempts = errors.New("too many failed login attempts")
+
+// LoginThrottledError is returned instead of checking the credentials of a
+// login that is refused for now. It wraps ErrTooManyLoginAttempts.
+type LoginThrottledError struct {
+	// RetryAfter is how long until a login is accepted again
+	RetryAfter time.Duration
+	// LockedOut is set for a lockout, as opposed to the short delay
+	// after a single failure
+	LockedOut bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	wait := e.RetryAfter.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	if e.LockedOut {
+		return fmt.Sprintf("%s: locked out, try again in %s", ErrTooManyLoginAttempts, wait)
+	}
+	return fmt.Sprintf("%s: try again in %s", ErrTooManyLoginAttempts, wait)
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// Login throttle scopes
+const (
+	ThrottleScopeUsername = "username"
+	ThrottleScopeIP       = "ip"
+	// ThrottleScopePIN counts wrong supervisor PINs per approver
+	ThrottleScopePIN = "pin"
+)
+
+// Security event types
+const (
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventIPLocked        = "ip_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+	SecurityEventIPUnlocked      = "ip_unlocked"
+	SecurityEventPINLocked       = "pin_locked"
+)
+
+// LoginPolicy decides how failed logins are throttled
+type LoginPolicy struct {
+	// MaxFailures is how many failed logins for one username lock it out
+	MaxFailures int
+	// MaxIPFailures is how many failed logins from one IP, for any
+	// usernames, lock the IP out. It is higher than MaxFailures since
+	// the tills of a store usually share an IP.
+	MaxIPFailures int
+	// MaxPINFailures is how many wrong supervisor PINs for one approver
+	// lock out approvals by PIN in their name. A PIN has far fewer values
+	// than a password, so it is kept low.
+	MaxPINFailures int
+	// LockoutDuration is how long a lockout lasts
+	LockoutDuration time.Duration
+	// BackoffBase is the delay after the first failure, doubled by each
+	// further failure up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// FailureWindow is how long failures are counted for; a failure after
+	// a quiet FailureWindow starts counting from one again
+	FailureWindow time.Duration
+}
+
+// LoginGuard protects logins against password guessing. Failed logins are
+// counted per username and per client IP in the database, so it needs no
+// cache and survives restarts. Each failure makes the next attempt wait
+// twice as long, and too many failures lock the username or IP out.
+//
+// A login is counted as an attempt before its credentials are checked and
+// given back when they turn out right, so that parallel logins cannot all be
+// checked before the first failure is recorded.
+type LoginGuard struct {
+	securityRepo *repository.SecurityRepository
+	userRepo     *repository.UserRepository
+	policy       LoginPolicy
+}
+
+func NewLoginGuard(securityRepo *repository.SecurityRepository, userRepo *repository.UserRepository, policy LoginPolicy) *LoginGuard {
+	return &LoginGuard{
+		securityRepo: securityRepo,
+		userRepo:     userRepo,
+		policy:       policy,
+	}
+}
+
+// LoginAttempt is a login counted by Attempt or AttemptPIN. It is settled
+// by Fail, Succeed or Release once its credentials have been checked.
+type LoginAttempt struct {
+	username string
+	ip       string
+	scopes   []throttleScope
+	failures []int
+}
+
+// Attempt counts a login for username from ip before its credentials are
+// checked. It returns a LoginThrottledError, without counting anything, when
+// the login has to wait.
+func (g *LoginGuard) Attempt(username, ip string) (*LoginAttempt, error) {
+	return g.attempt(g.scopes(username, ip), username, ip)
+}
+
+// AttemptPIN counts an approval by the PIN of username before the PIN is
+// checked. It returns a LoginThrottledError, without counting anything,
+// when approvals by their PIN have to wait.
+func (g *LoginGuard) AttemptPIN(username string) (*LoginAttempt, error) {
+	return g.attempt(g.pinScopes(username), username, "")
+}
+
+func (g *LoginGuard) attempt(scopes []throttleScope, username, ip string) (*LoginAttempt, error) {
+	now := time.Now()
+	a := &LoginAttempt{username: username, ip: ip}
+
+	for _, scope := range scopes {
+		failures, ok, err := g.securityRepo.Attempt(scope.name, scope.key, now, now.Add(-g.policy.FailureWindow), scope.max)
+		if err == nil && !ok {
+			err = g.refusal(scope, now)
+		}
+		if err != nil {
+			// What was counted already is given back
+			if releaseErr := g.Release(a); releaseErr != nil {
+				return nil, releaseErr
+			}
+			return nil, err
+		}
+
+		a.scopes = append(a.scopes, scope)
+		a.failures = append(a.failures, failures)
+	}
+
+	return a, nil
+}
+
+// refusal returns the LoginThrottledError for a scope that refused an
+// attempt. A scope that is not blocked has as many logins being checked as
+// it allows failures, and is retried after the shortest backoff.
+func (g *LoginGuard) refusal(scope throttleScope, now time.Time) error {
+	throttle, err := g.securityRepo.GetThrottle(scope.name, scope.key)
+	if err != nil {
+		return err
+	}
+	if throttle != nil && throttle.BlockedUntil != nil && now.Before(*throttle.BlockedUntil) {
+		return &LoginThrottledError{RetryAfter: throttle.BlockedUntil.Sub(now), LockedOut: throttle.LockedOut}
+	}
+	return &LoginThrottledError{RetryAfter: g.policy.BackoffBase}
+}
+
+// Fail records that the credentials of a counted login were wrong, delaying
+// the next attempt and locking out the username, IP or PIN once it had too
+// many. userID is the account of the username, when there is one.
+func (g *LoginGuard) Fail(a *LoginAttempt, userID *uuid.UUID) error {
+	now := time.Now()
+
+	for i, scope := range a.scopes {
+		failures := a.failures[i]
+		if failures < scope.max {
+			if err := g.securityRepo.Block(scope.name, scope.key, now.Add(g.backoff(failures)), false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := g.securityRepo.Block(scope.name, scope.key, now.Add(g.policy.LockoutDuration), true); err != nil {
+			return err
+		}
+
+		event := &models.SecurityEvent{
+			Type:      SecurityEventAccountLocked,
+			UserID:    userID,
+			Username:  a.username,
+			IPAddress: a.ip,
+			Details:   fmt.Sprintf("%d failed logins, locked for %s", failures, g.policy.LockoutDuration),
+		}
+		switch scope.name {
+		case ThrottleScopeIP:
+			event.Type = SecurityEventIPLocked
+			event.UserID = nil
+		case ThrottleScopePIN:
+			event.Type = SecurityEventPINLocked
+			event.Details = fmt.Sprintf("%d wrong supervisor PINs, locked for %s", failures, g.policy.LockoutDuration)
+		}
+		if err := g.securityRepo.CreateEvent(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Succeed settles a counted login whose credentials were right. The failed
+// logins of its username, or wrong PINs of its supervisor, are forgotten.
+// Failures from the IP keep counting, so that guessing the passwords of many
+// accounts from one IP is still caught; only this attempt is given back.
+func (g *LoginGuard) Succeed(a *LoginAttempt) error {
+	for _, scope := range a.scopes {
+		var err error
+		if scope.name == ThrottleScopeIP {
+			err = g.securityRepo.Release(scope.name, scope.key)
+		} else {
+			_, err = g.securityRepo.ResetThrottle(scope.name, scope.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release gives back a counted login that was neither right nor wrong, such
+// as one for a deactivated account or one that failed for another reason
+func (g *LoginGuard) Release(a *LoginAttempt) error {
+	for _, scope := range a.scopes {
+		if err := g.securityRepo.Release(scope.name, scope.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forget forgets the failed logins of a username, and lifts its lockout
+func (g *LoginGuard) Forget(username string) error {
+	_, err := g.securityRepo.ResetThrottle(ThrottleScopeUsername, username)
+	return err
+}
+
+// UnlockUser lifts the lockout of a user's username and PIN and forgets
+// their failed logins and wrong PINs
+func (g *LoginGuard) UnlockUser(userID uuid.UUID, by *models.User) error {
+	user, err := g.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	locked, err := g.securityRepo.ResetThrottle(ThrottleScopeUsername, user.Username)
+	if err != nil {
+		return err
+	}
+	pinLocked, err := g.securityRepo.ResetThrottle(ThrottleScopePIN, user.Username)
+	if err != nil || !(locked || pinLocked) {
+		return err
+	}
+
+	return g.securityRepo.CreateEvent(&models.SecurityEvent{
+		Type:      SecurityEventAccountUnlocked,
+		UserID:    &user.ID,
+		Username:  user.Username,
+		CreatedBy: &by.ID,
+	})
+}
+
+// UnlockIP lifts the lockout of an IP and forgets its failed logins
+func (g *LoginGuard) UnlockIP(ip string, by *models.User) error {
+	locked, err := g.securityRepo.ResetThrottle(ThrottleScopeIP, ip)
+	if err != nil || !locked {
+		return err
+	}
+
+	return g.securityRepo.CreateEvent(&models.SecurityEvent{
+		Type:      SecurityEventIPUnlocked,
+		IPAddress: ip,
+		CreatedBy: &by.ID,
+	})
+}
+
+// GetLockouts returns the usernames and IPs that are locked out now
+func (g *LoginGuard) GetLockouts() ([]models.LoginThrottle, error) {
+	return g.securityRepo.GetLockouts()
+}
+
+// GetSecurityEvents returns the latest security events, optionally of one
+// user only
+func (g *LoginGuard) GetSecurityEvents(userID *uuid.UUID, limit int) ([]models.SecurityEvent, error) {
+	return g.securityRepo.GetEvents(userID, limit)
+}
+
+// Purge removes the throttles of usernames and IPs that have had no failed
+// logins for a while
+func (g *LoginGuard) Purge() error {
+	return g.securityRepo.DeleteStaleThrottles(time.Now().Add(-g.policy.FailureWindow))
+}
+
+// throttleScope is one of the keys failed logins are counted under
+type throttleScope struct {
+	name string
+	key  string
+	max  int
+}
+
+// scopes returns the keys failed logins for username from ip are counted
+// under. Logins without a known IP are only counted per username.
+func (g *LoginGuard) scopes(username, ip string) []throttleScope {
+	scopes := []throttleScope{{name: ThrottleScopeUsername, key: username, max: g.policy.MaxFailures}}
+	if ip != "" {
+		scopes = append(scopes, throttleScope{name: ThrottleScopeIP, key: ip, max: g.policy.MaxIPFailures})
+	}
+	return scopes
+}
+
+// pinScopes returns the key wrong supervisor PINs for username are counted
+// under
+func (g *LoginGuard) pinScopes(username string) []throttleScope {
+	return []throttleScope{{name: ThrottleScopePIN, key: username, max: g.policy.MaxPINFailures}}
+}
+
+// backoff returns how long to wait after the given number of failures
+func (g *LoginGuard) backoff(failures int) time.Duration {
+	delay := float64(g.policy.BackoffBase) * math.Pow(2, float64(failures-1))
+	if delay > float64(g.policy.BackoffMax) {
+		return g.policy.BackoffMax
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+)
+
+// orderCoupon is a coupon entered on an order with the discount it gave
+type orderCoupon struct {
+	coupon *models.Coupon
+	amount money.Amount
+}
+
+// applyCoupons looks up the coupon codes entered on an order, checks that
+// each of them can be used and applies their discounts on top of whatever
+// the order has been given already. The minimum basket of every coupon is
+// checked against the order before any coupon is applied.
+func (s *OrderService) applyCoupons(order *models.Order, codes []string, at time.Time) ([]orderCoupon, error) {
+	basket := orderRemaining(order)
+	seen := make(map[string]bool)
+
+	var coupons []orderCoupon
+	for _, entered := range codes {
+		code := normalizeCouponCode(entered)
+		if seen[code] {
+			return nil, fmt.Errorf("%w: %s is entered more than once", ErrInvalidCoupon, code)
+		}
+		seen[code] = true
+
+		coupon, err := s.couponRepo.GetByCode(code)
+		if err != nil {
+			return nil, err
+		}
+		if coupon == nil {
+			return nil, fmt.Errorf("%w: %s does not exist", ErrInvalidCoupon, code)
+		}
+
+		if err := checkCoupon(coupon, order, at); err != nil {
+			return nil, err
+		}
+		if basket < coupon.MinBasket {
+			return nil, fmt.Errorf("%w: %s needs a basket of at least %s", ErrInvalidCoupon, code, coupon.MinBasket)
+		}
+
+		var amount money.Amount
+		for _, applied := range s.pricer.ApplyPromotions(order, []*models.Promotion{couponPromotion(coupon)}) {
+			amount += applied.Amount
+		}
+		if amount == 0 {
+			return nil, fmt.Errorf("%w: %s does not apply to this order", ErrInvalidCoupon, code)
+		}
+
+		coupons = append(coupons, orderCoupon{coupon: coupon, amount: amount})
+	}
+
+	return coupons, nil
+}
+
+// redeemCoupons redeems the coupons applied to an order inside tx. Each
+// coupon row is locked and its limits checked again, so that concurrent
+// orders cannot take more uses than the coupon has left. Coupons are locked
+// in ID order to keep orders entering the same codes from deadlocking.
+func (s *OrderService) redeemCoupons(tx *sql.Tx, order *models.Order, coupons []orderCoupon, at time.Time) error {
+	byID := make([]orderCoupon, len(coupons))
+	copy(byID, coupons)
+	sort.Slice(byID, func(i, j int) bool {
+		return byID[i].coupon.ID.String() < byID[j].coupon.ID.String()
+	})
+
+	for _, c := range byID {
+		locked, err := s.couponRepo.Lock(tx, c.coupon.ID)
+		if err != nil {
+			return err
+		}
+		if !locked.UpdatedAt.Equal(c.coupon.UpdatedAt) {
+			return fmt.Errorf("%w: %s was changed while the order was being placed, please try again", ErrInvalidCoupon, locked.Code)
+		}
+		if err := checkCoupon(locked, order, at); err != nil {
+			return err
+		}
+
+		if locked.MaxUsesPerCustomer != nil {
+			used, err := s.couponRepo.CountCustomerRedemptionsTx(tx, locked.ID, *order.CustomerID)
+			if err != nil {
+				return err
+			}
+			if used >= *locked.MaxUsesPerCustomer {
+				return fmt.Errorf("%w: %s can only be used %d times per customer", ErrCouponUsedUp, locked.Code, *locked.MaxUsesPerCustomer)
+			}
+		}
+	}
+
+	for _, c := range coupons {
+		redemption := models.CouponRedemption{
+			CouponID:   c.coupon.ID,
+			OrderID:    order.ID,
+			CustomerID: order.CustomerID,
+			Code:       c.coupon.Code,
+			Amount:     c.amount,
+		}
+		if err := s.couponRepo.RedeemTx(tx, &redemption); err != nil {
+			return err
+		}
+		order.Coupons = append(order.Coupons, redemption)
+	}
+
+	return nil
+}
+
+// checkCoupon checks that a coupon is active, valid at the given time, has
+// uses left and can be used on the order
+func checkCoupon(coupon *models.Coupon, order *models.Order, at time.Time) error {
+	if !coupon.IsActive {
+		return fmt.Errorf("%w: %s is not active", ErrInvalidCoupon, coupon.Code)
+	}
+	if coupon.StartsAt != nil && at.Before(*coupon.StartsAt) {
+		return fmt.Errorf("%w: %s is not valid yet", ErrInvalidCoupon, coupon.Code)
+	}
+	if coupon.EndsAt != nil && !at.Before(*coupon.EndsAt) {
+		return fmt.Errorf("%w: %s has expired", ErrInvalidCoupon, coupon.Code)
+	}
+	if coupon.MaxUses != nil && coupon.TimesUsed >= *coupon.MaxUses {
+		return fmt.Errorf("%w: %s", ErrCouponUsedUp, coupon.Code)
+	}
+	if coupon.MaxUsesPerCustomer != nil && order.CustomerID == nil {
+		return fmt.Errorf("%w: %s can only be used on orders with a customer", ErrInvalidCoupon, coupon.Code)
+	}
+
+	return nil
+}
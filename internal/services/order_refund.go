@@ -0,0 +1,371 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+	"jatistore/internal/permissions"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidRefund is returned when the requested refund lines do not match the order
+	ErrInvalidRefund = errors.New("invalid refund")
+	// ErrRefundExceedsPaid is returned when a refund would return more than was paid
+	ErrRefundExceedsPaid = errors.New("refund exceeds amount paid")
+	// ErrRefundApprovalRequired is returned when a cashier refunds more than
+	// the approval threshold without a supervisor's approval
+	ErrRefundApprovalRequired = errors.New("refund requires manager approval")
+)
+
+// RefundPolicy controls who may issue refunds
+type RefundPolicy struct {
+	// ApprovalThreshold is the largest refund a cashier may issue on their
+	// own. Larger refunds need the order.refund_approve permission, which
+	// managers and admins have, or the approval of someone who has it.
+	ApprovalThreshold money.Amount
+}
+
+// RefundOrder refunds a completed order, either fully or per line. Refunded
+// money is recorded as negative payments linked to the payments being
+// refunded, refunded items are returned to stock and a credit note receipt is
+// issued, all in a single transaction. Items go back to the restock location
+// when one is given; otherwise only a refund that leaves the order fully
+// refunded returns its items, to the locations they were taken from.
+func (s *OrderService) RefundOrder(orderID uuid.UUID, req *models.CreateRefundRequest, user *models.User) (*models.Refund, error) {
+	order, err := s.orderRepo.GetByID(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund order: %w", err)
+	}
+
+	// Like any other stock movement, refunded items cannot go to a
+	// location that has been deactivated
+	if req.RestockLocation != "" {
+		if err := activeLocation(s.locationRepo, req.RestockLocation); err != nil {
+			return nil, fmt.Errorf("failed to refund order: %w", err)
+		}
+	}
+
+	var refund *models.Refund
+	err = s.orderRepo.WithTx(func(tx *sql.Tx) error {
+		// Money refunded by a user with an open shift comes out of their
+		// drawer, and cash always does. The shift is taken before the
+		// order, as payments do.
+		shift, err := s.shiftRepo.GetOpenByUserIDTx(tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		locked, err := s.orderRepo.Lock(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if locked.Status != OrderStatusCompleted && locked.Status != OrderStatusPartiallyRefunded {
+			return fmt.Errorf("%w: only completed orders can be refunded, order is %s", ErrInvalidTransition, locked.Status)
+		}
+
+		refunded, err := s.refundRepo.GetRefundedByOrderID(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		items, fullyRefunded, err := s.buildRefundItems(order, req.Items, refunded)
+		if err != nil {
+			return err
+		}
+
+		var amount money.Amount
+		for _, item := range items {
+			amount += item.Amount
+		}
+
+		if amount <= 0 {
+			return fmt.Errorf("%w: nothing left to refund", ErrInvalidRefund)
+		}
+
+		var approvedBy *uuid.UUID
+		if amount > s.refundPolicy.ApprovalThreshold {
+			approvedBy, err = s.approvals.Approve(tx, user, req.Approval, permissions.OrderRefundApprove)
+			if errors.Is(err, ErrApprovalRequired) {
+				return fmt.Errorf("%w: %s is above the cashier limit of %s", ErrRefundApprovalRequired, amount, s.refundPolicy.ApprovalThreshold)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		refund = &models.Refund{
+			OrderID:         orderID,
+			Amount:          amount,
+			Reason:          req.Reason,
+			RestockLocation: req.RestockLocation,
+			CreatedBy:       optionalUserID(user.ID),
+			ApprovedBy:      approvedBy,
+			Items:           items,
+		}
+
+		if err := s.refundRepo.CreateTx(tx, refund); err != nil {
+			return err
+		}
+
+		if refund.Payments, err = s.refundPayments(tx, refund, shift); err != nil {
+			return err
+		}
+
+		if req.RestockLocation != "" {
+			for _, item := range items {
+				_, err := s.inventoryRepo.ReturnStock(tx, item.ProductID.String(), req.RestockLocation, item.Quantity, stockReasonRefund, order.OrderNumber)
+				if err != nil {
+					return err
+				}
+			}
+		} else if fullyRefunded {
+			// Refunding the whole order reverses its completion, so the
+			// stock goes back where it came from
+			taken, err := s.inventoryRepo.GetDeductionsTx(tx, stockReasonOrderCompleted, order.OrderNumber)
+			if err != nil {
+				return err
+			}
+			for _, back := range returnLocations(taken, items) {
+				if err := activeLocation(s.locationRepo, back.Location); err != nil {
+					return fmt.Errorf("%w; give a restock_location to return the items to", err)
+				}
+				_, err := s.inventoryRepo.ReturnStock(tx, back.ProductID, back.Location, back.Quantity, stockReasonRefund, order.OrderNumber)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		refund.CreditNote = &models.Receipt{
+			OrderID:     orderID,
+			Type:        "credit_note",
+			RefundID:    &refund.ID,
+			TotalAmount: amount,
+			CashierID:   optionalUserID(user.ID),
+		}
+		if refund.CreditNote.Taxes, err = s.nameTaxes(s.pricer.RefundTaxes(order, items)); err != nil {
+			return err
+		}
+		for _, tax := range refund.CreditNote.Taxes {
+			refund.CreditNote.TaxAmount += tax.TaxAmount
+		}
+		if err := s.receiptRepo.CreateTx(tx, refund.CreditNote); err != nil {
+			return err
+		}
+
+		for i := range refund.Payments {
+			payment := &refund.Payments[i]
+			if err := s.audit.RecordTx(tx, user, AuditActionCreate, AuditEntityPayment, payment.ID.String(), nil, payment); err != nil {
+				return err
+			}
+		}
+		if err := s.audit.RecordTx(tx, user, AuditActionCreate, AuditEntityRefund, refund.ID.String(), nil, refund); err != nil {
+			return err
+		}
+
+		status := OrderStatusPartiallyRefunded
+		if fullyRefunded {
+			status = OrderStatusRefunded
+			if err := s.orderRepo.UpdatePaymentStatusTx(tx, orderID, PaymentStatusRefunded); err != nil {
+				return err
+			}
+			err = s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityOrder, orderID.String(),
+				auditPaymentStatus{PaymentStatus: locked.PaymentStatus}, auditPaymentStatus{PaymentStatus: PaymentStatusRefunded})
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.transitionOrder(tx, order, status, req.Reason, user, approvedBy, true)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund order: %w", err)
+	}
+
+	return refund, nil
+}
+
+// GetOrderRefunds returns the refunds made against an order
+func (s *OrderService) GetOrderRefunds(orderID uuid.UUID) ([]models.Refund, error) {
+	if _, err := s.orderRepo.GetByID(orderID); err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	refunds, err := s.refundRepo.GetByOrderID(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order refunds: %w", err)
+	}
+
+	return refunds, nil
+}
+
+// refundPayments spreads the refund over the order's payments, newest first,
+// recording a negative payment against each payment it draws from. The
+// payments are recorded on shift when it is not nil; cash can only be refunded
+// from a drawer, so cash payments need one.
+func (s *OrderService) refundPayments(tx *sql.Tx, refund *models.Refund, shift *models.Shift) ([]models.Payment, error) {
+	refundable, err := s.paymentRepo.GetRefundableByOrderID(tx, refund.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var payments []models.Payment
+	remaining := refund.Amount
+	for _, original := range refundable {
+		if remaining <= 0 {
+			break
+		}
+
+		part := money.Min(original.Amount, remaining)
+		payment := models.Payment{
+			OrderID:       refund.OrderID,
+			Amount:        -part,
+			PaymentMethod: original.PaymentMethod,
+			Reference:     original.Reference,
+			Status:        "refunded",
+			RefundOf:      &original.ID,
+			RefundID:      &refund.ID,
+			CreatedBy:     refund.CreatedBy,
+			ApprovedBy:    refund.ApprovedBy,
+		}
+		if shift != nil {
+			payment.ShiftID = &shift.ID
+		} else if original.PaymentMethod == PaymentMethodCash {
+			return nil, fmt.Errorf("%w: open a shift before refunding cash", ErrNoOpenShift)
+		}
+
+		if err := s.paymentRepo.CreateTx(tx, &payment); err != nil {
+			return nil, err
+		}
+
+		payments = append(payments, payment)
+		remaining -= part
+	}
+
+	if remaining > 0 {
+		return nil, fmt.Errorf("%w: %s could not be matched to a payment", ErrRefundExceedsPaid, remaining)
+	}
+
+	return payments, nil
+}
+
+// stockReturn is a quantity of a product going back to a location
+type stockReturn struct {
+	ProductID string
+	Location  string
+	Quantity  int
+}
+
+// returnLocations spreads refunded items over the locations their stock was
+// taken from, in the order it was taken. Units with nothing on record, such
+// as those of orders completed before stock had locations, are left out.
+func returnLocations(taken []models.InventoryTransaction, items []models.RefundItem) []stockReturn {
+	sources := make(map[string][]stockReturn)
+	for _, transaction := range taken {
+		if transaction.Location == "" {
+			continue
+		}
+		sources[transaction.ProductID] = append(sources[transaction.ProductID], stockReturn{
+			ProductID: transaction.ProductID,
+			Location:  transaction.Location,
+			Quantity:  transaction.Quantity,
+		})
+	}
+
+	var returns []stockReturn
+	for _, item := range items {
+		remaining := item.Quantity
+		from := sources[item.ProductID.String()]
+		for i := range from {
+			if remaining == 0 {
+				break
+			}
+			quantity := min(remaining, from[i].Quantity)
+			if quantity == 0 {
+				continue
+			}
+			returns = append(returns, stockReturn{
+				ProductID: from[i].ProductID,
+				Location:  from[i].Location,
+				Quantity:  quantity,
+			})
+			from[i].Quantity -= quantity
+			remaining -= quantity
+		}
+	}
+
+	return returns
+}
+
+// buildRefundItems turns the requested lines into refund items priced at what
+// was paid for them. An empty request refunds everything still refundable.
+// It also reports whether the order is fully refunded afterwards.
+func (s *OrderService) buildRefundItems(order *models.Order, requested []models.RefundItemRequest, refunded map[uuid.UUID]models.RefundItem) ([]models.RefundItem, bool, error) {
+	quantities := make(map[uuid.UUID]int)
+	if len(requested) == 0 {
+		for _, item := range order.Items {
+			quantities[item.ID] = item.Quantity - refunded[item.ID].Quantity
+		}
+	} else {
+		for _, line := range requested {
+			if line.Quantity <= 0 {
+				return nil, false, fmt.Errorf("%w: refund quantity must be greater than 0", ErrInvalidRefund)
+			}
+			quantities[line.OrderItemID] += line.Quantity
+		}
+	}
+
+	found := 0
+	fullyRefunded := true
+	paidAmounts := s.pricer.LinePaidAmounts(order)
+	var items []models.RefundItem
+	for i, item := range order.Items {
+		quantity, ok := quantities[item.ID]
+		if ok {
+			found++
+		}
+
+		already := refunded[item.ID]
+		remaining := item.Quantity - already.Quantity
+		if quantity > remaining {
+			return nil, false, fmt.Errorf("%w: only %d of order item %s can still be refunded", ErrInvalidRefund, remaining, item.ID)
+		}
+		if quantity < remaining {
+			fullyRefunded = false
+		}
+		if quantity == 0 {
+			continue
+		}
+
+		paid := paidAmounts[i]
+		var amount money.Amount
+		if quantity == remaining {
+			// The last units take whatever is left so rounding never leaks
+			amount = paid - already.Amount
+		} else {
+			amount = s.pricer.Share(paid, quantity, item.Quantity)
+		}
+
+		if already.Amount+amount > paid {
+			return nil, false, fmt.Errorf("%w: order item %s", ErrRefundExceedsPaid, item.ID)
+		}
+
+		items = append(items, models.RefundItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			Quantity:    quantity,
+			Amount:      amount,
+		})
+	}
+
+	if found != len(quantities) {
+		return nil, false, fmt.Errorf("%w: refund contains items that are not on the order", ErrInvalidRefund)
+	}
+
+	return items, fullyRefunded, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestReturnLocations(t *testing.T) {
+	coffee := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	tea := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+
+	out := func(product uuid.UUID, location string, quantity int) models.InventoryTransaction {
+		return models.InventoryTransaction{ProductID: product.String(), Type: "out", Location: location, Quantity: quantity}
+	}
+	back := func(product uuid.UUID, location string, quantity int) stockReturn {
+		return stockReturn{ProductID: product.String(), Location: location, Quantity: quantity}
+	}
+
+	tests := []struct {
+		name  string
+		taken []models.InventoryTransaction
+		items []models.RefundItem
+		want  []stockReturn
+	}{
+		{
+			name:  "whole order back where it came from",
+			taken: []models.InventoryTransaction{out(coffee, "store", 2), out(tea, "warehouse", 3)},
+			items: []models.RefundItem{{ProductID: coffee, Quantity: 2}, {ProductID: tea, Quantity: 3}},
+			want:  []stockReturn{back(coffee, "store", 2), back(tea, "warehouse", 3)},
+		},
+		{
+			name:  "product taken from two locations",
+			taken: []models.InventoryTransaction{out(coffee, "store", 2), out(coffee, "warehouse", 3)},
+			items: []models.RefundItem{{ProductID: coffee, Quantity: 2}, {ProductID: coffee, Quantity: 3}},
+			want:  []stockReturn{back(coffee, "store", 2), back(coffee, "warehouse", 3)},
+		},
+		{
+			name:  "last units of a partly refunded order",
+			taken: []models.InventoryTransaction{out(coffee, "store", 5)},
+			items: []models.RefundItem{{ProductID: coffee, Quantity: 2}},
+			want:  []stockReturn{back(coffee, "store", 2)},
+		},
+		{
+			name:  "a refund spanning locations",
+			taken: []models.InventoryTransaction{out(coffee, "store", 1), out(coffee, "warehouse", 4)},
+			items: []models.RefundItem{{ProductID: coffee, Quantity: 3}},
+			want:  []stockReturn{back(coffee, "store", 1), back(coffee, "warehouse", 2)},
+		},
+		{
+			name:  "stock taken before locations is left out",
+			taken: []models.InventoryTransaction{out(coffee, "", 2), out(tea, "store", 1)},
+			items: []models.RefundItem{{ProductID: coffee, Quantity: 2}, {ProductID: tea, Quantity: 1}},
+			want:  []stockReturn{back(tea, "store", 1)},
+		},
+		{
+			name:  "nothing on record",
+			items: []models.RefundItem{{ProductID: coffee, Quantity: 2}},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := returnLocations(tt.taken, tt.items)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("returnLocations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
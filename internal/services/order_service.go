@@ -1,20 +1,69 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"jatistore/internal/models"
+	"jatistore/internal/money"
+	"jatistore/internal/permissions"
 	"jatistore/internal/repository"
 
 	"github.com/google/uuid"
 )
 
+// ErrInsufficientStock is returned when an order cannot be completed because
+// there is not enough stock and backorders are disabled
+var ErrInsufficientStock = repository.ErrInsufficientStock
+
+// ErrInvalidPayment is returned when the tenders of a payment do not fit what
+// is due on the order
+var ErrInvalidPayment = errors.New("invalid payment")
+
+// Reasons recorded on the stock movements of orders and refunds
+const (
+	stockReasonOrderCompleted = "Order completed"
+	stockReasonRefund         = "Refund"
+)
+
+// StockPolicy controls how completing an order affects inventory
+type StockPolicy struct {
+	// Location is the inventory location stock is drawn from. When empty,
+	// the location holding the most stock for each product is used.
+	Location string
+	// AllowBackorder lets stock go below zero instead of rejecting the order
+	AllowBackorder bool
+}
+
+// DiscountPolicy controls who may give manual discounts
+type DiscountPolicy struct {
+	// ApprovalThreshold is the largest manual discount, order and line
+	// discounts together, a cashier may give on their own. Larger discounts
+	// need the order.discount_approve permission or a supervisor's approval.
+	ApprovalThreshold money.Amount
+}
+
 type OrderService struct {
-	orderRepo    *repository.OrderRepository
-	productRepo  *repository.ProductRepository
-	customerRepo *repository.CustomerRepository
-	paymentRepo  *repository.PaymentRepository
-	receiptRepo  *repository.ReceiptRepository
+	orderRepo      *repository.OrderRepository
+	productRepo    *repository.ProductRepository
+	customerRepo   *repository.CustomerRepository
+	paymentRepo    *repository.PaymentRepository
+	receiptRepo    *repository.ReceiptRepository
+	inventoryRepo  *repository.InventoryRepository
+	locationRepo   *repository.LocationRepository
+	refundRepo     *repository.RefundRepository
+	taxRepo        *repository.TaxRepository
+	promotionRepo  *repository.PromotionRepository
+	couponRepo     *repository.CouponRepository
+	shiftRepo      *repository.ShiftRepository
+	approvals      *ApprovalService
+	audit          *AuditService
+	pricer         *Pricer
+	stockPolicy    StockPolicy
+	refundPolicy   RefundPolicy
+	discountPolicy DiscountPolicy
 }
 
 func NewOrderService(
@@ -23,17 +72,50 @@ func NewOrderService(
 	customerRepo *repository.CustomerRepository,
 	paymentRepo *repository.PaymentRepository,
 	receiptRepo *repository.ReceiptRepository,
+	inventoryRepo *repository.InventoryRepository,
+	locationRepo *repository.LocationRepository,
+	refundRepo *repository.RefundRepository,
+	taxRepo *repository.TaxRepository,
+	promotionRepo *repository.PromotionRepository,
+	couponRepo *repository.CouponRepository,
+	shiftRepo *repository.ShiftRepository,
+	approvals *ApprovalService,
+	audit *AuditService,
+	pricer *Pricer,
+	stockPolicy StockPolicy,
+	refundPolicy RefundPolicy,
+	discountPolicy DiscountPolicy,
 ) *OrderService {
 	return &OrderService{
-		orderRepo:    orderRepo,
-		productRepo:  productRepo,
-		customerRepo: customerRepo,
-		paymentRepo:  paymentRepo,
-		receiptRepo:  receiptRepo,
+		orderRepo:      orderRepo,
+		productRepo:    productRepo,
+		customerRepo:   customerRepo,
+		paymentRepo:    paymentRepo,
+		receiptRepo:    receiptRepo,
+		inventoryRepo:  inventoryRepo,
+		locationRepo:   locationRepo,
+		refundRepo:     refundRepo,
+		taxRepo:        taxRepo,
+		promotionRepo:  promotionRepo,
+		couponRepo:     couponRepo,
+		shiftRepo:      shiftRepo,
+		approvals:      approvals,
+		audit:          audit,
+		pricer:         pricer,
+		stockPolicy:    stockPolicy,
+		refundPolicy:   refundPolicy,
+		discountPolicy: discountPolicy,
 	}
 }
 
-func (s *OrderService) CreateOrder(req *models.CreateOrderRequest) (*models.Order, error) {
+// CreateOrder prices and stores a new order. Active promotions the order
+// matches are applied first, then the coupons entered on it, and both are
+// recorded on the order; coupons are redeemed in the same transaction. Tax
+// is then worked out per line from each product's tax class; a tax amount in
+// the request replaces it. Overriding tax or prices and manual discounts above
+// the discount threshold need the matching permission or a supervisor's
+// approval in the request.
+func (s *OrderService) CreateOrder(req *models.CreateOrderRequest, user *models.User) (*models.Order, error) {
 	// Validate customer if provided
 	var customerID *uuid.UUID
 	if req.CustomerID != nil {
@@ -51,7 +133,9 @@ func (s *OrderService) CreateOrder(req *models.CreateOrderRequest) (*models.Orde
 
 	// Process order items and calculate totals
 	var orderItems []models.OrderItem
-	var subtotal float64
+	now := time.Now()
+	manualDiscount := req.DiscountAmount
+	priceOverridden := false
 
 	for _, itemReq := range req.Items {
 		// Get product details
@@ -60,43 +144,119 @@ func (s *OrderService) CreateOrder(req *models.CreateOrderRequest) (*models.Orde
 			return nil, fmt.Errorf("product not found: %w", err)
 		}
 
-		// Calculate item total
-		itemTotal := (product.Price * float64(itemReq.Quantity)) - itemReq.Discount
-		if itemTotal < 0 {
-			itemTotal = 0
+		// A price entered at the till replaces the product price
+		unitPrice := product.Price
+		if itemReq.UnitPrice != nil && *itemReq.UnitPrice != product.Price {
+			unitPrice = *itemReq.UnitPrice
+			priceOverridden = true
 		}
+		manualDiscount += itemReq.Discount
+
+		// Calculate item total
+		itemTotal := s.pricer.LineTotal(unitPrice, itemReq.Quantity, itemReq.Discount)
 
 		orderItem := models.OrderItem{
-			ProductID:  itemReq.ProductID,
-			Quantity:   itemReq.Quantity,
-			UnitPrice:  product.Price,
-			Discount:   itemReq.Discount,
-			TotalPrice: itemTotal,
+			ProductID:       itemReq.ProductID,
+			Quantity:        itemReq.Quantity,
+			UnitPrice:       unitPrice,
+			Discount:        itemReq.Discount,
+			TotalPrice:      itemTotal,
+			PriceOverridden: unitPrice != product.Price,
+			Product:         product,
+		}
+
+		// Look up the tax rate in effect for the product right now
+		taxClass, err := s.taxRepo.GetProductTaxClass(product.ID, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tax class: %w", err)
+		}
+		if taxClass != nil {
+			if len(taxClass.Rates) == 0 {
+				return nil, fmt.Errorf("%w for tax class %s of product %s", ErrNoTaxRate, taxClass.Name, product.Name)
+			}
+			orderItem.TaxClassID = &taxClass.ID
+			orderItem.TaxRate = taxClass.Rates[0].Rate
 		}
 
 		orderItems = append(orderItems, orderItem)
-		subtotal += itemTotal
 	}
 
-	// Calculate total amount
-	totalAmount := subtotal + req.TaxAmount - req.DiscountAmount
-	if totalAmount < 0 {
-		totalAmount = 0
+	status := OrderStatusPending
+	if req.Draft {
+		status = OrderStatusDraft
 	}
 
 	order := &models.Order{
 		CustomerID:     customerID,
-		Status:         "pending",
-		Subtotal:       subtotal,
-		TaxAmount:      req.TaxAmount,
+		Status:         status,
 		DiscountAmount: req.DiscountAmount,
-		TotalAmount:    totalAmount,
-		PaymentStatus:  "pending",
+		PaymentStatus:  PaymentStatusPending,
 		Notes:          req.Notes,
+		CreatedBy:      optionalUserID(user.ID),
 		Items:          orderItems,
 	}
 
-	err := s.orderRepo.Create(order)
+	// Work out which of the order's overrides need approval
+	var needed []string
+	if req.TaxAmount != nil {
+		needed = append(needed, permissions.OrderOverrideTax)
+	}
+	if priceOverridden {
+		needed = append(needed, permissions.OrderOverridePrice)
+	}
+	if manualDiscount > s.discountPolicy.ApprovalThreshold {
+		needed = append(needed, permissions.OrderDiscountApprove)
+	}
+
+	promotions, err := s.promotionRepo.GetActive(now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotions: %w", err)
+	}
+	applied := s.pricer.ApplyPromotions(order, promotions)
+
+	coupons, err := s.applyCoupons(order, req.CouponCodes, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	// Calculate tax and total amount
+	s.pricer.PriceOrder(order)
+	if req.TaxAmount != nil {
+		s.pricer.OverrideTax(order, *req.TaxAmount)
+	}
+
+	err = s.orderRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		if order.ApprovedBy, err = s.approvals.Approve(tx, user, req.Approval, needed...); err != nil {
+			return err
+		}
+
+		if err := s.orderRepo.CreateTx(tx, order); err != nil {
+			return err
+		}
+
+		order.Promotions = orderPromotions(order, applied)
+		if err := s.promotionRepo.CreateOrderPromotionsTx(tx, order.Promotions); err != nil {
+			return err
+		}
+
+		if err := s.redeemCoupons(tx, order, coupons, now); err != nil {
+			return err
+		}
+
+		err = s.orderRepo.AddStatusHistory(tx, &models.OrderStatusHistory{
+			OrderID:    order.ID,
+			ToStatus:   order.Status,
+			ChangedBy:  optionalUserID(user.ID),
+			ApprovedBy: order.ApprovedBy,
+			Reason:     "Order created",
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionCreate, AuditEntityOrder, order.ID.String(), nil, order)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
@@ -110,6 +270,16 @@ func (s *OrderService) GetOrder(id uuid.UUID) (*models.Order, error) {
 		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
+	order.Promotions, err = s.promotionRepo.GetByOrderID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order promotions: %w", err)
+	}
+
+	order.Coupons, err = s.couponRepo.GetByOrderID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order coupons: %w", err)
+	}
+
 	return order, nil
 }
 
@@ -122,19 +292,32 @@ func (s *OrderService) GetAllOrders() ([]models.Order, error) {
 	return orders, nil
 }
 
-func (s *OrderService) UpdateOrderStatus(id uuid.UUID, status string) error {
-	// Validate status
-	validStatuses := map[string]bool{
-		"pending":   true,
-		"completed": true,
-		"cancelled": true,
+// UpdateOrderStatus moves an order to another status through the order state
+// machine, recording the transition in the order's status history. Cancelling
+// (voiding) an order other than a draft needs the order.void permission or a
+// supervisor's approval.
+func (s *OrderService) UpdateOrderStatus(id uuid.UUID, req *models.UpdateOrderStatusRequest, user *models.User) error {
+	if !IsValidOrderStatus(req.Status) {
+		return fmt.Errorf("invalid status: %s", req.Status)
 	}
 
-	if !validStatuses[status] {
-		return fmt.Errorf("invalid status: %s", status)
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
-	err := s.orderRepo.UpdateStatus(id, status)
+	// The status change and the stock movements it causes are committed together
+	err = s.orderRepo.WithTx(func(tx *sql.Tx) error {
+		var approvedBy *uuid.UUID
+		if req.Status == OrderStatusCancelled && order.Status != OrderStatusDraft {
+			var err error
+			if approvedBy, err = s.approvals.Approve(tx, user, req.Approval, permissions.OrderVoid); err != nil {
+				return err
+			}
+		}
+
+		return s.transitionOrder(tx, order, req.Status, req.Reason, user, approvedBy, false)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
@@ -142,54 +325,234 @@ func (s *OrderService) UpdateOrderStatus(id uuid.UUID, status string) error {
 	return nil
 }
 
-func (s *OrderService) ProcessPayment(orderID uuid.UUID, req *models.CreatePaymentRequest) (*models.Payment, error) {
-	// Validate order exists
-	order, err := s.orderRepo.GetByID(orderID)
-	if err != nil {
-		return nil, fmt.Errorf("order not found: %w", err)
+// GetOrderStatusHistory returns every status transition of an order
+func (s *OrderService) GetOrderStatusHistory(id uuid.UUID) ([]models.OrderStatusHistory, error) {
+	if _, err := s.orderRepo.GetByID(id); err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	// Check if payment amount is valid
-	if req.Amount <= 0 {
-		return nil, fmt.Errorf("payment amount must be greater than 0")
+	history, err := s.orderRepo.GetStatusHistory(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order status history: %w", err)
 	}
 
-	// Get total paid so far
-	totalPaid, err := s.paymentRepo.GetTotalPaidByOrderID(orderID)
+	return history, nil
+}
+
+// transitionOrder locks the order, checks the transition against the state
+// machine, applies it together with its inventory side effects and records it
+// in the status history together with whoever approved it, and in the audit
+// log. order must have its items loaded.
+func (s *OrderService) transitionOrder(tx *sql.Tx, order *models.Order, status, reason string, user *models.User, approvedBy *uuid.UUID, internal bool) error {
+	locked, err := s.orderRepo.Lock(tx, order.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total paid: %w", err)
+		return err
+	}
+	order.Status = locked.Status
+	order.PaymentStatus = locked.PaymentStatus
+
+	if err := checkOrderTransition(order, status, internal); err != nil {
+		return err
 	}
 
-	// Check if payment exceeds order total
-	if totalPaid+req.Amount > order.TotalAmount {
-		return nil, fmt.Errorf("payment amount exceeds order total")
+	from := order.Status
+	if err := s.orderRepo.UpdateStatusTx(tx, order.ID, status); err != nil {
+		return err
 	}
 
-	payment := &models.Payment{
-		OrderID:       orderID,
-		Amount:        req.Amount,
-		PaymentMethod: req.PaymentMethod,
-		Reference:     req.Reference,
-		Status:        "completed",
+	if status == OrderStatusCompleted {
+		if err := s.deductOrderStock(tx, order); err != nil {
+			return err
+		}
+	}
+
+	// Coupons used on orders that end up cancelled or refunded can be used again
+	if status == OrderStatusCancelled || status == OrderStatusRefunded {
+		if err := s.couponRepo.ReverseByOrderIDTx(tx, order.ID); err != nil {
+			return err
+		}
 	}
 
-	err = s.paymentRepo.Create(payment)
+	order.Status = status
+
+	err = s.orderRepo.AddStatusHistory(tx, &models.OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: from,
+		ToStatus:   status,
+		ChangedBy:  optionalUserID(user.ID),
+		ApprovedBy: approvedBy,
+		Reason:     reason,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create payment: %w", err)
+		return err
+	}
+
+	return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityOrder, order.ID.String(), auditOrderStatus{Status: from}, auditOrderStatus{Status: status})
+}
+
+// deductOrderStock takes every order line out of inventory inside tx
+func (s *OrderService) deductOrderStock(tx *sql.Tx, order *models.Order) error {
+	for _, item := range order.Items {
+		_, err := s.inventoryRepo.DeductStock(
+			tx,
+			item.ProductID.String(),
+			s.stockPolicy.Location,
+			item.Quantity,
+			s.stockPolicy.AllowBackorder,
+			stockReasonOrderCompleted,
+			order.OrderNumber,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ProcessPayment pays for an order with one or more tenders. Card, transfer
+// and digital wallet tenders are applied in full and cannot exceed what is
+// due. Cash tenders cover the rest, and cash handed over beyond it is given
+// back as change. All tenders are recorded together or not at all, on the
+// open shift of the user taking them. Only pending orders can be paid.
+func (s *OrderService) ProcessPayment(orderID uuid.UUID, req *models.CreatePaymentRequest, user *models.User) (*models.PaymentResult, error) {
+	tenders := req.Tenders
+	if len(tenders) == 0 {
+		tenders = []models.TenderRequest{{
+			Amount:        req.Amount,
+			PaymentMethod: req.PaymentMethod,
+			Reference:     req.Reference,
+		}}
 	}
 
-	// Update order payment status if fully paid
-	if totalPaid+req.Amount >= order.TotalAmount {
-		err = s.orderRepo.UpdatePaymentStatus(orderID, "paid")
+	for _, tender := range tenders {
+		if tender.Amount <= 0 {
+			return nil, fmt.Errorf("%w: payment amount must be greater than 0", ErrInvalidPayment)
+		}
+	}
+
+	result := &models.PaymentResult{OrderID: orderID}
+	err := s.orderRepo.WithTx(func(tx *sql.Tx) error {
+		shift, err := s.shiftRepo.GetOpenByUserIDTx(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if shift == nil {
+			return fmt.Errorf("%w: open a shift before taking payments", ErrNoOpenShift)
+		}
+
+		// Lock the order so concurrent payments cannot both see it unpaid
+		order, err := s.orderRepo.Lock(tx, orderID)
+		if err != nil {
+			return err
+		}
+		// Only a pending order is payable: a draft is still being put
+		// together, an order on hold is parked and the rest are settled
+		if order.Status != OrderStatusPending {
+			return fmt.Errorf("%w: only pending orders can be paid, order is %s", ErrInvalidTransition, order.Status)
+		}
+
+		totalPaid, err := s.paymentRepo.GetTotalPaidByOrderIDTx(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		result.AmountDue = order.TotalAmount - totalPaid
+		if result.AmountDue <= 0 {
+			return fmt.Errorf("%w: order is already paid", ErrInvalidPayment)
+		}
+
+		result.Payments, err = applyTenders(orderID, tenders, result.AmountDue)
 		if err != nil {
-			return nil, fmt.Errorf("failed to update payment status: %w", err)
+			return err
+		}
+
+		for i := range result.Payments {
+			payment := &result.Payments[i]
+			payment.ShiftID = &shift.ID
+			payment.CreatedBy = optionalUserID(user.ID)
+			if err := s.paymentRepo.CreateTx(tx, payment); err != nil {
+				return err
+			}
+
+			result.TenderedAmount += payment.TenderedAmount
+			result.AppliedAmount += payment.Amount
+			result.ChangeAmount += payment.ChangeAmount
+		}
+
+		result.Balance = result.AmountDue - result.AppliedAmount
+		result.PaymentStatus = order.PaymentStatus
+		if result.Balance == 0 {
+			result.PaymentStatus = PaymentStatusPaid
+			if err := s.orderRepo.UpdatePaymentStatusTx(tx, orderID, PaymentStatusPaid); err != nil {
+				return err
+			}
+		}
+
+		for i := range result.Payments {
+			payment := &result.Payments[i]
+			if err := s.audit.RecordTx(tx, user, AuditActionCreate, AuditEntityPayment, payment.ID.String(), nil, payment); err != nil {
+				return err
+			}
+		}
+		if result.PaymentStatus != order.PaymentStatus {
+			return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityOrder, orderID.String(),
+				auditPaymentStatus{PaymentStatus: order.PaymentStatus}, auditPaymentStatus{PaymentStatus: result.PaymentStatus})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to process payment: %w", err)
+	}
+
+	return result, nil
+}
+
+// applyTenders works out what each tender applies to an amount due. Tenders
+// other than cash are applied first and in full; cash covers what they leave
+// and the rest of it is change.
+func applyTenders(orderID uuid.UUID, tenders []models.TenderRequest, due money.Amount) ([]models.Payment, error) {
+	var exact money.Amount
+	for _, tender := range tenders {
+		if tender.PaymentMethod != PaymentMethodCash {
+			exact += tender.Amount
+		}
+	}
+	if exact > due {
+		return nil, fmt.Errorf("%w: card, transfer and digital wallet payments of %s exceed the %s due", ErrInvalidPayment, exact, due)
+	}
+
+	cashDue := due - exact
+	payments := make([]models.Payment, 0, len(tenders))
+	for _, tender := range tenders {
+		payment := models.Payment{
+			OrderID:        orderID,
+			Amount:         tender.Amount,
+			TenderedAmount: tender.Amount,
+			PaymentMethod:  tender.PaymentMethod,
+			Reference:      tender.Reference,
+			Status:         "completed",
+		}
+
+		if tender.PaymentMethod == PaymentMethodCash {
+			if cashDue == 0 {
+				return nil, fmt.Errorf("%w: cash tender of %s is not needed, the order is already covered", ErrInvalidPayment, tender.Amount)
+			}
+			payment.Amount = money.Min(tender.Amount, cashDue)
+			payment.ChangeAmount = tender.Amount - payment.Amount
+			cashDue -= payment.Amount
 		}
+
+		payments = append(payments, payment)
 	}
 
-	return payment, nil
+	return payments, nil
 }
 
-func (s *OrderService) GenerateReceipt(orderID uuid.UUID) (*models.Receipt, error) {
+// GenerateReceipt stores the receipt of a paid order, with user as its
+// cashier. The existing receipt is returned when there is one already.
+func (s *OrderService) GenerateReceipt(orderID uuid.UUID, user *models.User) (*models.Receipt, error) {
 	// Get order details
 	order, err := s.orderRepo.GetByID(orderID)
 	if err != nil {
@@ -197,13 +560,16 @@ func (s *OrderService) GenerateReceipt(orderID uuid.UUID) (*models.Receipt, erro
 	}
 
 	// Check if order is paid
-	if order.PaymentStatus != "paid" {
+	if order.PaymentStatus != PaymentStatusPaid {
 		return nil, fmt.Errorf("cannot generate receipt for unpaid order")
 	}
 
 	// Check if receipt already exists
 	existingReceipt, err := s.receiptRepo.GetByOrderID(orderID)
 	if err == nil && existingReceipt != nil {
+		if err := s.attachTenders(existingReceipt); err != nil {
+			return nil, err
+		}
 		return existingReceipt, nil
 	}
 
@@ -211,6 +577,12 @@ func (s *OrderService) GenerateReceipt(orderID uuid.UUID) (*models.Receipt, erro
 		OrderID:     orderID,
 		TotalAmount: order.TotalAmount,
 		TaxAmount:   order.TaxAmount,
+		CashierID:   optionalUserID(user.ID),
+	}
+
+	receipt.Taxes, err = s.nameTaxes(s.pricer.SaleTaxes(order))
+	if err != nil {
+		return nil, err
 	}
 
 	err = s.receiptRepo.Create(receipt)
@@ -218,9 +590,25 @@ func (s *OrderService) GenerateReceipt(orderID uuid.UUID) (*models.Receipt, erro
 		return nil, fmt.Errorf("failed to create receipt: %w", err)
 	}
 
+	if err := s.attachTenders(receipt); err != nil {
+		return nil, err
+	}
+
 	return receipt, nil
 }
 
+// attachTenders adds the payments made for a sale to its receipt, oldest
+// first, together with the change given on them
+func (s *OrderService) attachTenders(receipt *models.Receipt) error {
+	payments, err := s.paymentRepo.GetByOrderID(receipt.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to get receipt tenders: %w", err)
+	}
+
+	receipt.Tenders, receipt.ChangeAmount = saleTenders(payments)
+	return nil
+}
+
 func (s *OrderService) GetOrdersByCustomer(customerID uuid.UUID) ([]models.Order, error) {
 	orders, err := s.orderRepo.GetByCustomerID(customerID)
 	if err != nil {
@@ -229,3 +617,57 @@ func (s *OrderService) GetOrdersByCustomer(customerID uuid.UUID) ([]models.Order
 
 	return orders, nil
 }
+
+// orderPromotions turns the promotions applied while pricing an order into
+// records of the stored order and its items
+func orderPromotions(order *models.Order, applied []AppliedPromotion) []models.OrderPromotion {
+	records := make([]models.OrderPromotion, 0, len(applied))
+	for _, a := range applied {
+		record := models.OrderPromotion{
+			OrderID:     order.ID,
+			PromotionID: a.Promotion.ID,
+			Name:        a.Promotion.Name,
+			Campaign:    a.Promotion.Campaign,
+			Amount:      a.Amount,
+		}
+		if a.Line >= 0 {
+			record.OrderItemID = &order.Items[a.Line].ID
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// nameTaxes fills in the tax class names of a tax breakdown
+func (s *OrderService) nameTaxes(taxes []models.ReceiptTax) ([]models.ReceiptTax, error) {
+	if len(taxes) == 0 {
+		return taxes, nil
+	}
+
+	classes, err := s.taxRepo.GetAllClasses()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tax classes: %w", err)
+	}
+
+	names := make(map[uuid.UUID]string, len(classes))
+	for _, class := range classes {
+		names[class.ID] = class.Name
+	}
+
+	for i := range taxes {
+		taxes[i].Name = "No tax class"
+		if taxes[i].TaxClassID != nil {
+			taxes[i].Name = names[*taxes[i].TaxClassID]
+		}
+	}
+
+	return taxes, nil
+}
+
+// optionalUserID turns uuid.Nil into nil so that actions without a user are stored as NULL
+func optionalUserID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
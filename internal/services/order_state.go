@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"jatistore/internal/models"
+)
+
+// Order statuses
+const (
+	OrderStatusDraft             = "draft"
+	OrderStatusPending           = "pending"
+	OrderStatusOnHold            = "on_hold"
+	OrderStatusCompleted         = "completed"
+	OrderStatusCancelled         = "cancelled"
+	OrderStatusPartiallyRefunded = "partially_refunded"
+	OrderStatusRefunded          = "refunded"
+)
+
+// Order payment statuses
+const (
+	PaymentStatusPending  = "pending"
+	PaymentStatusPaid     = "paid"
+	PaymentStatusRefunded = "refunded"
+)
+
+// PaymentMethodCash is the only payment method that can be overpaid, the
+// difference being given back as change
+const PaymentMethodCash = "cash"
+
+// paymentMethods are the ways an order can be paid
+var paymentMethods = map[string]bool{
+	PaymentMethodCash: true,
+	"card":            true,
+	"transfer":        true,
+	"digital_wallet":  true,
+}
+
+// IsPaymentMethod reports whether method is a way an order can be paid
+func IsPaymentMethod(method string) bool {
+	return paymentMethods[method]
+}
+
+// ErrInvalidTransition is returned when an order cannot move to the requested status
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+// orderTransition describes an allowed move between two order statuses
+type orderTransition struct {
+	// guard rejects the transition when the order is not in a suitable state
+	guard func(order *models.Order) error
+	// internal transitions are only made by the service itself (for example
+	// by the refund process) and cannot be requested through the API
+	internal bool
+}
+
+// orderTransitions lists, for every status, the statuses it may move to
+var orderTransitions = map[string]map[string]orderTransition{
+	OrderStatusDraft: {
+		OrderStatusPending:   {},
+		OrderStatusCancelled: {guard: requireUnpaid},
+	},
+	OrderStatusPending: {
+		OrderStatusOnHold:    {},
+		OrderStatusCompleted: {guard: requirePaid},
+		OrderStatusCancelled: {guard: requireUnpaid},
+	},
+	OrderStatusOnHold: {
+		OrderStatusPending:   {},
+		OrderStatusCompleted: {guard: requirePaid},
+		OrderStatusCancelled: {guard: requireUnpaid},
+	},
+	// A completed order is paid, so it is refunded rather than cancelled;
+	// its stock goes back through the refund's restock_location
+	OrderStatusCompleted: {
+		OrderStatusPartiallyRefunded: {internal: true},
+		OrderStatusRefunded:          {internal: true},
+	},
+	OrderStatusPartiallyRefunded: {
+		OrderStatusPartiallyRefunded: {internal: true},
+		OrderStatusRefunded:          {internal: true},
+	},
+	OrderStatusCancelled: {},
+	OrderStatusRefunded:  {},
+}
+
+// IsValidOrderStatus reports whether status is a known order status
+func IsValidOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// checkOrderTransition verifies that order may move to status. Internal
+// transitions are only permitted when internal is true.
+func checkOrderTransition(order *models.Order, status string, internal bool) error {
+	transition, ok := orderTransitions[order.Status][status]
+	if !ok {
+		return fmt.Errorf("%w: cannot move order from %s to %s", ErrInvalidTransition, order.Status, status)
+	}
+
+	if transition.internal && !internal {
+		return fmt.Errorf("%w: status %s is set by the refund process", ErrInvalidTransition, status)
+	}
+
+	if transition.guard != nil {
+		if err := transition.guard(order); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTransition, err)
+		}
+	}
+
+	return nil
+}
+
+func requirePaid(order *models.Order) error {
+	if order.PaymentStatus != PaymentStatusPaid {
+		return errors.New("order can only be completed once it is paid")
+	}
+	return nil
+}
+
+func requireUnpaid(order *models.Order) error {
+	if order.PaymentStatus == PaymentStatusPaid {
+		return errors.New("a paid order must be refunded instead of cancelled")
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"jatistore/internal/mailer"
+	"jatistore/internal/models"
+)
+
+// ErrInvalidPasswordReset is returned for a password reset token that is
+// unknown, expired or already used
+var ErrInvalidPasswordReset = errors.New("invalid or expired password reset token")
+
+// PasswordResetPolicy decides how forgotten passwords are reset
+type PasswordResetPolicy struct {
+	// TokenTTL is how long an emailed reset token can be used for
+	TokenTTL time.Duration
+	// URL is the page of the front end that sets the new password. The
+	// token is added to it as the token query parameter. Without it the
+	// email only contains the token.
+	URL string
+}
+
+// ForgotPassword emails a password reset token to the account with the
+// given email. It does the same whether or not there is such an account, so
+// that it cannot be used to find out which emails have one. A new email
+// replaces the tokens sent before.
+func (s *UserService) ForgotPassword(req *models.ForgotPasswordRequest) error {
+	user, err := s.userRepo.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil || !user.IsActive {
+		return nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	reset := &models.PasswordResetToken{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.resets.TokenTTL),
+	}
+	if err := s.tokenRepo.CreatePasswordReset(reset, hashToken(token)); err != nil {
+		return err
+	}
+
+	// Sending takes long enough to tell existing accounts apart, so the
+	// email goes out in the background
+	msg := s.passwordResetEmail(user, token)
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			log.Printf("Error sending password reset email to user %s: %v", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword sets a new password with an emailed reset token. The token
+// works once, and every session of the user is logged out.
+func (s *UserService) ResetPassword(req *models.ResetPasswordRequest) error {
+	var user *models.User
+
+	err := s.tokenRepo.WithTx(func(tx *sql.Tx) error {
+		reset, err := s.tokenRepo.LockPasswordResetByHash(tx, hashToken(req.Token))
+		if err != nil {
+			return err
+		}
+		if reset == nil || reset.UsedAt != nil || !time.Now().Before(reset.ExpiresAt) {
+			return ErrInvalidPasswordReset
+		}
+
+		if user, err = s.userRepo.GetUserByID(reset.UserID); err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return ErrInvalidPasswordReset
+		}
+
+		if err := s.userRepo.UpdatePasswordTx(tx, user.ID, req.NewPassword); err != nil {
+			return err
+		}
+		if err := s.tokenRepo.MarkPasswordResetUsedTx(tx, reset); err != nil {
+			return err
+		}
+		if err := s.tokenRepo.DeletePasswordResetsTx(tx, user.ID); err != nil {
+			return err
+		}
+		if err := s.tokenRepo.RevokeUserTx(tx, user.ID); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityUser, user.ID.String(),
+			auditPasswordChange{PasswordChanged: false}, auditPasswordChange{PasswordChanged: true})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Whoever reset the password owns the email, so failed logins of the
+	// username, and a lockout, no longer count against it
+	return s.guard.Forget(user.Username)
+}
+
+// passwordResetEmail returns the email that sends user a reset token
+func (s *UserService) passwordResetEmail(user *models.User, token string) mailer.Message {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hello %s,\n\n", user.Username)
+	b.WriteString("Someone asked to reset the password of your account.\n\n")
+	if link := s.passwordResetLink(token); link != "" {
+		fmt.Fprintf(&b, "Choose a new password here:\n%s\n\n", link)
+	} else {
+		fmt.Fprintf(&b, "Your password reset token is:\n%s\n\n", token)
+	}
+	fmt.Fprintf(&b, "It works once and expires in %s. ", s.resets.TokenTTL)
+	b.WriteString("If you did not ask for this, ignore this email and your password stays the same.\n")
+
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    b.String(),
+	}
+}
+
+// passwordResetLink returns the reset page URL with token added, or "" when
+// there is no reset page
+func (s *UserService) passwordResetLink(token string) string {
+	if s.resets.URL == "" {
+		return ""
+	}
+	link, err := url.Parse(s.resets.URL)
+	if err != nil {
+		return ""
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
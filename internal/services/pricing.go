@@ -0,0 +1,212 @@
+package services
+
+import (
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// Pricer is the single place where line totals, order totals, tax and
+// discounts are computed. Every computed amount is rounded to the store
+// currency.
+type Pricer struct {
+	Currency money.Currency
+	// PricesIncludeTax is true when product prices already contain tax, as
+	// is usual for PPN in Indonesian retail, and false when tax is added on
+	// top of them
+	PricesIncludeTax bool
+}
+
+// NewPricer creates a Pricer for the given store currency and pricing mode
+func NewPricer(currency money.Currency, pricesIncludeTax bool) *Pricer {
+	return &Pricer{Currency: currency, PricesIncludeTax: pricesIncludeTax}
+}
+
+// LineTotal returns unit price times quantity less the line discount, never
+// below zero
+func (p *Pricer) LineTotal(unitPrice money.Amount, quantity int, discount money.Amount) money.Amount {
+	total := p.Currency.Round(unitPrice.Mul(quantity) - discount)
+	return money.Max(total, money.Zero)
+}
+
+// OrderTotal returns subtotal plus tax less the order discount, never below zero
+func (p *Pricer) OrderTotal(subtotal, tax, discount money.Amount) money.Amount {
+	total := p.Currency.Round(subtotal + tax - discount)
+	return money.Max(total, money.Zero)
+}
+
+// PriceOrder works out the subtotal, the tax of every line, the tax and the
+// total of an order from its line totals, its order discount and the tax
+// rate of each line. The order discount is shared over the lines first so
+// that tax is charged on what the customer actually pays.
+func (p *Pricer) PriceOrder(order *models.Order) {
+	order.PricesIncludeTax = p.PricesIncludeTax
+	order.TaxOverridden = false
+
+	order.Subtotal = money.Zero
+	for _, item := range order.Items {
+		order.Subtotal += item.TotalPrice
+	}
+
+	discounts := p.lineDiscounts(order)
+	order.TaxAmount = money.Zero
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.TaxAmount = p.lineTax(item.TotalPrice-discounts[i], item.TaxRate, order.PricesIncludeTax)
+		order.TaxAmount += item.TaxAmount
+	}
+
+	p.total(order)
+}
+
+// OverrideTax replaces the computed tax of a priced order with tax, sharing
+// it over the lines in proportion to their totals
+func (p *Pricer) OverrideTax(order *models.Order, tax money.Amount) {
+	parts := p.Currency.Allocate(tax, lineWeights(order))
+	for i := range order.Items {
+		order.Items[i].TaxAmount = parts[i]
+	}
+
+	order.TaxAmount = tax
+	order.TaxOverridden = true
+	p.total(order)
+}
+
+// LinePaidAmounts returns what the customer paid for each order line: the
+// line total less its share of the order discount plus its own tax when tax
+// is added on top. The amounts always add up to the order total; orders
+// whose lines do not, such as orders placed before tax was stored per line,
+// have their total spread over the lines in proportion instead.
+func (p *Pricer) LinePaidAmounts(order *models.Order) []money.Amount {
+	discounts := p.lineDiscounts(order)
+	paid := make([]money.Amount, len(order.Items))
+	var sum money.Amount
+	for i, item := range order.Items {
+		paid[i] = item.TotalPrice - discounts[i]
+		if !order.PricesIncludeTax {
+			paid[i] += item.TaxAmount
+		}
+		sum += paid[i]
+	}
+
+	if sum != order.TotalAmount {
+		return p.Currency.Allocate(order.TotalAmount, paid)
+	}
+	return paid
+}
+
+// SaleTaxes returns the tax breakdown of an order, one entry per tax class
+// and rate. Names are left for the caller to fill in.
+func (p *Pricer) SaleTaxes(order *models.Order) []models.ReceiptTax {
+	discounts := p.lineDiscounts(order)
+
+	var taxes []models.ReceiptTax
+	for i, item := range order.Items {
+		taxable := item.TotalPrice - discounts[i]
+		if order.PricesIncludeTax {
+			taxable -= item.TaxAmount
+		}
+		taxes = addReceiptTax(taxes, item.TaxClassID, item.TaxRate, taxable, item.TaxAmount)
+	}
+
+	return taxes
+}
+
+// RefundTaxes returns the tax breakdown of a refund. Each refunded line
+// gives back the share of the line's tax that its amount is of what was paid
+// for the line.
+func (p *Pricer) RefundTaxes(order *models.Order, items []models.RefundItem) []models.ReceiptTax {
+	paid := p.LinePaidAmounts(order)
+	lines := make(map[uuid.UUID]int, len(order.Items))
+	for i, item := range order.Items {
+		lines[item.ID] = i
+	}
+
+	var taxes []models.ReceiptTax
+	for _, refunded := range items {
+		i := lines[refunded.OrderItemID]
+		line := order.Items[i]
+
+		var tax money.Amount
+		if paid[i] > 0 {
+			tax = p.Prorate(refunded.Amount, line.TaxAmount, paid[i])
+		}
+		taxes = addReceiptTax(taxes, line.TaxClassID, line.TaxRate, refunded.Amount-tax, tax)
+	}
+
+	return taxes
+}
+
+// Share returns quantity/of of amount, rounded to the store currency
+func (p *Pricer) Share(amount money.Amount, quantity, of int) money.Amount {
+	return p.Currency.Round(amount.MulRatio(int64(quantity), int64(of)))
+}
+
+// Prorate returns the share of amount that part is of whole, rounded to the
+// store currency
+func (p *Pricer) Prorate(amount, part, whole money.Amount) money.Amount {
+	return p.Currency.Round(amount.MulRatio(int64(part), int64(whole)))
+}
+
+// lineTax returns the tax on a line amount, which either contains the tax
+// already or has it added on top
+func (p *Pricer) lineTax(amount money.Amount, rate money.Rate, inclusive bool) money.Amount {
+	if inclusive {
+		return p.Currency.Round(rate.Within(amount))
+	}
+	return p.Currency.Round(rate.Of(amount))
+}
+
+// lineDiscounts shares the order discount over the lines in proportion to
+// their totals. A discount larger than the subtotal is capped at it.
+func (p *Pricer) lineDiscounts(order *models.Order) []money.Amount {
+	var subtotal money.Amount
+	for _, item := range order.Items {
+		subtotal += item.TotalPrice
+	}
+	return p.Currency.Allocate(money.Min(order.DiscountAmount, subtotal), lineWeights(order))
+}
+
+// total sets the order total from its subtotal, tax and discount. Tax is only
+// added when prices do not include it already.
+func (p *Pricer) total(order *models.Order) {
+	tax := order.TaxAmount
+	if order.PricesIncludeTax {
+		tax = money.Zero
+	}
+	order.TotalAmount = p.OrderTotal(order.Subtotal, tax, order.DiscountAmount)
+}
+
+func lineWeights(order *models.Order) []money.Amount {
+	weights := make([]money.Amount, len(order.Items))
+	for i, item := range order.Items {
+		weights[i] = item.TotalPrice
+	}
+	return weights
+}
+
+// addReceiptTax adds a line to the breakdown entry of its tax class and rate
+func addReceiptTax(taxes []models.ReceiptTax, classID *uuid.UUID, rate money.Rate, taxable, tax money.Amount) []models.ReceiptTax {
+	for i := range taxes {
+		if sameTaxClass(taxes[i].TaxClassID, classID) && taxes[i].Rate == rate {
+			taxes[i].TaxableAmount += taxable
+			taxes[i].TaxAmount += tax
+			return taxes
+		}
+	}
+
+	return append(taxes, models.ReceiptTax{
+		TaxClassID:    classID,
+		Rate:          rate,
+		TaxableAmount: taxable,
+		TaxAmount:     tax,
+	})
+}
+
+func sameTaxClass(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
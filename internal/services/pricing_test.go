@@ -0,0 +1,283 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// ppn is the 11% rate of Indonesian VAT
+const ppn = 11 * money.RateScale
+
+// pricedOrder returns an order with a line of 100.00 at 11% tax, a line of
+// 50.00 without tax and a 15.00 order discount
+func pricedOrder() *models.Order {
+	return &models.Order{
+		Items: []models.OrderItem{
+			{ID: uuid.New(), Quantity: 2, UnitPrice: 5000, TotalPrice: 10000, TaxRate: ppn},
+			{ID: uuid.New(), Quantity: 1, UnitPrice: 5000, TotalPrice: 5000},
+		},
+		DiscountAmount: 1500,
+	}
+}
+
+func TestLineTotal(t *testing.T) {
+	tests := []struct {
+		currency  money.Currency
+		unitPrice money.Amount
+		quantity  int
+		discount  money.Amount
+		want      money.Amount
+	}{
+		{money.USD, 1000, 3, 500, 2500},
+		{money.USD, 1000, 3, 0, 3000},
+		{money.USD, 1000, 1, 1500, 0},
+		{money.IDR, 1050, 1, 0, 1100},
+		{money.IDR, 333300, 3, 49, 999900},
+	}
+
+	for _, tt := range tests {
+		p := NewPricer(tt.currency, false)
+		if got := p.LineTotal(tt.unitPrice, tt.quantity, tt.discount); got != tt.want {
+			t.Errorf("%s LineTotal(%d, %d, %d) = %d, want %d", tt.currency.Code, int64(tt.unitPrice), tt.quantity, int64(tt.discount), got, tt.want)
+		}
+	}
+}
+
+func TestPriceOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		currency  money.Currency
+		inclusive bool
+		lineTax   []money.Amount
+		tax       money.Amount
+		total     money.Amount
+	}{
+		// The discount takes 10.00 off the taxed line, which pays tax on 90.00
+		{"tax added on top", money.USD, false, []money.Amount{990, 0}, 990, 14490},
+		// 90.00 contains 90.00 * 11/111 of tax
+		{"tax included", money.USD, true, []money.Amount{892, 0}, 892, 13500},
+		{"tax added on top in whole rupiah", money.IDR, false, []money.Amount{1000, 0}, 1000, 14500},
+		{"tax included in whole rupiah", money.IDR, true, []money.Amount{900, 0}, 900, 13500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := pricedOrder()
+			NewPricer(tt.currency, tt.inclusive).PriceOrder(order)
+
+			if order.Subtotal != 15000 {
+				t.Errorf("Subtotal = %d, want 15000", order.Subtotal)
+			}
+			for i, want := range tt.lineTax {
+				if order.Items[i].TaxAmount != want {
+					t.Errorf("line %d TaxAmount = %d, want %d", i, order.Items[i].TaxAmount, want)
+				}
+			}
+			if order.TaxAmount != tt.tax {
+				t.Errorf("TaxAmount = %d, want %d", order.TaxAmount, tt.tax)
+			}
+			if order.TotalAmount != tt.total {
+				t.Errorf("TotalAmount = %d, want %d", order.TotalAmount, tt.total)
+			}
+			if order.PricesIncludeTax != tt.inclusive {
+				t.Errorf("PricesIncludeTax = %v, want %v", order.PricesIncludeTax, tt.inclusive)
+			}
+		})
+	}
+}
+
+func TestPriceOrderDiscountAboveSubtotal(t *testing.T) {
+	order := pricedOrder()
+	order.DiscountAmount = 20000
+
+	NewPricer(money.USD, false).PriceOrder(order)
+
+	if order.TaxAmount != 0 {
+		t.Errorf("TaxAmount = %d, want 0", order.TaxAmount)
+	}
+	if order.TotalAmount != 0 {
+		t.Errorf("TotalAmount = %d, want 0", order.TotalAmount)
+	}
+}
+
+func TestOverrideTax(t *testing.T) {
+	order := pricedOrder()
+	order.DiscountAmount = 0
+	p := NewPricer(money.USD, false)
+	p.PriceOrder(order)
+
+	p.OverrideTax(order, 300)
+
+	if order.Items[0].TaxAmount != 200 || order.Items[1].TaxAmount != 100 {
+		t.Errorf("line taxes = %d, %d, want 200, 100", order.Items[0].TaxAmount, order.Items[1].TaxAmount)
+	}
+	if order.TaxAmount != 300 || !order.TaxOverridden {
+		t.Errorf("TaxAmount = %d, TaxOverridden = %v, want 300, true", order.TaxAmount, order.TaxOverridden)
+	}
+	if order.TotalAmount != 15300 {
+		t.Errorf("TotalAmount = %d, want 15300", order.TotalAmount)
+	}
+
+	// Pricing the order again drops the override
+	p.PriceOrder(order)
+	if order.TaxAmount != 1100 || order.TaxOverridden {
+		t.Errorf("after PriceOrder TaxAmount = %d, TaxOverridden = %v, want 1100, false", order.TaxAmount, order.TaxOverridden)
+	}
+}
+
+func TestLinePaidAmounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		inclusive bool
+		want      []money.Amount
+	}{
+		{"tax added on top", false, []money.Amount{9990, 4500}},
+		{"tax included", true, []money.Amount{9000, 4500}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPricer(money.USD, tt.inclusive)
+			order := pricedOrder()
+			p.PriceOrder(order)
+
+			got := p.LinePaidAmounts(order)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LinePaidAmounts = %v, want %v", got, tt.want)
+			}
+
+			var sum money.Amount
+			for _, paid := range got {
+				sum += paid
+			}
+			if sum != order.TotalAmount {
+				t.Errorf("LinePaidAmounts sum to %d, want the order total %d", sum, order.TotalAmount)
+			}
+		})
+	}
+}
+
+func TestLinePaidAmountsWithoutLineTax(t *testing.T) {
+	// Orders placed before tax was stored per line only have it in the total
+	order := pricedOrder()
+	order.DiscountAmount = 0
+	order.TotalAmount = 16500
+
+	got := NewPricer(money.USD, false).LinePaidAmounts(order)
+	want := []money.Amount{11000, 5500}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LinePaidAmounts = %v, want %v", got, want)
+	}
+}
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		currency     money.Currency
+		amount       money.Amount
+		quantity, of int
+		want         money.Amount
+	}{
+		{money.USD, 1000, 1, 3, 333},
+		{money.USD, 1000, 2, 3, 667},
+		{money.USD, 1000, 3, 3, 1000},
+		{money.IDR, 100000, 1, 3, 33300},
+		{money.IDR, 100000, 2, 3, 66700},
+	}
+
+	for _, tt := range tests {
+		p := NewPricer(tt.currency, false)
+		if got := p.Share(tt.amount, tt.quantity, tt.of); got != tt.want {
+			t.Errorf("%s Share(%d, %d, %d) = %d, want %d", tt.currency.Code, int64(tt.amount), tt.quantity, tt.of, got, tt.want)
+		}
+	}
+}
+
+func TestProrate(t *testing.T) {
+	p := NewPricer(money.USD, false)
+	if got := p.Prorate(990, 3330, 9990); got != 330 {
+		t.Errorf("Prorate(990, 3330, 9990) = %d, want 330", got)
+	}
+}
+
+func TestSaleTaxes(t *testing.T) {
+	tests := []struct {
+		name      string
+		inclusive bool
+		want      []models.ReceiptTax
+	}{
+		{"tax added on top", false, []models.ReceiptTax{
+			{Rate: ppn, TaxableAmount: 9000, TaxAmount: 990},
+			{Rate: 0, TaxableAmount: 4500, TaxAmount: 0},
+		}},
+		{"tax included", true, []models.ReceiptTax{
+			{Rate: ppn, TaxableAmount: 8108, TaxAmount: 892},
+			{Rate: 0, TaxableAmount: 4500, TaxAmount: 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPricer(money.USD, tt.inclusive)
+			order := pricedOrder()
+			p.PriceOrder(order)
+
+			if got := p.SaleTaxes(order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SaleTaxes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefundTaxes(t *testing.T) {
+	p := NewPricer(money.USD, false)
+	order := pricedOrder()
+	p.PriceOrder(order)
+
+	// Half of the taxed line, as paid, gives back half of its tax
+	items := []models.RefundItem{{OrderItemID: order.Items[0].ID, Quantity: 1, Amount: 4995}}
+	want := []models.ReceiptTax{{Rate: ppn, TaxableAmount: 4500, TaxAmount: 495}}
+
+	if got := p.RefundTaxes(order, items); !reflect.DeepEqual(got, want) {
+		t.Errorf("RefundTaxes = %+v, want %+v", got, want)
+	}
+}
+
+func TestRefundsAddUpToPaid(t *testing.T) {
+	p := NewPricer(money.USD, false)
+	s := &OrderService{pricer: p}
+
+	order := &models.Order{
+		Items: []models.OrderItem{{ID: uuid.New(), Quantity: 3, UnitPrice: 400, Discount: 200, TotalPrice: 1000}},
+	}
+	p.PriceOrder(order)
+
+	// Refunding one unit at a time gives 3.33, 3.33 and what is left
+	want := []money.Amount{333, 333, 334}
+	refunded := make(map[uuid.UUID]models.RefundItem)
+	for n, amount := range want {
+		items, full, err := s.buildRefundItems(order, []models.RefundItemRequest{{OrderItemID: order.Items[0].ID, Quantity: 1}}, refunded)
+		if err != nil {
+			t.Fatalf("refund %d: %v", n+1, err)
+		}
+		if items[0].Amount != amount {
+			t.Errorf("refund %d = %d, want %d", n+1, items[0].Amount, amount)
+		}
+		if full != (n == len(want)-1) {
+			t.Errorf("refund %d fully refunded = %v", n+1, full)
+		}
+
+		already := refunded[order.Items[0].ID]
+		already.Quantity += items[0].Quantity
+		already.Amount += items[0].Amount
+		refunded[order.Items[0].ID] = already
+	}
+
+	if _, _, err := s.buildRefundItems(order, []models.RefundItemRequest{{OrderItemID: order.Items[0].ID, Quantity: 1}}, refunded); err == nil {
+		t.Error("refunding more than was sold succeeded, want an error")
+	}
+}
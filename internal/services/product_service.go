@@ -1,6 +1,7 @@
 package services
 
 import (
+	"database/sql"
 	"fmt"
 
 	"jatistore/internal/models"
@@ -11,21 +12,30 @@ import (
 
 type ProductService struct {
 	productRepo *repository.ProductRepository
+	taxRepo     *repository.TaxRepository
+	audit       *AuditService
 }
 
-func NewProductService(productRepo *repository.ProductRepository) *ProductService {
+func NewProductService(productRepo *repository.ProductRepository, taxRepo *repository.TaxRepository, audit *AuditService) *ProductService {
 	return &ProductService{
 		productRepo: productRepo,
+		taxRepo:     taxRepo,
+		audit:       audit,
 	}
 }
 
-func (s *ProductService) CreateProduct(req *models.CreateProductRequest) (*models.Product, error) {
+func (s *ProductService) CreateProduct(req *models.CreateProductRequest, actor *models.User) (*models.Product, error) {
 	// Parse category ID
 	categoryID, err := uuid.Parse(req.CategoryID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid category ID: %w", err)
 	}
 
+	taxClassID, err := parseTaxClassID(s.taxRepo, req.TaxClassID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate SKU if not provided
 	sku := req.SKU
 	if sku == "" {
@@ -53,16 +63,26 @@ func (s *ProductService) CreateProduct(req *models.CreateProductRequest) (*model
 		BarcodeNumber: barcodeNumber,
 		CategoryID:    categoryID,
 		Price:         req.Price,
+		TaxClassID:    taxClassID,
 	}
 
-	if err := s.productRepo.Create(product); err != nil {
-		return nil, fmt.Errorf("failed to create product: %w", err)
-	}
+	var createdProduct *models.Product
+	err = s.productRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.productRepo.CreateTx(tx, product); err != nil {
+			return fmt.Errorf("failed to create product: %w", err)
+		}
+
+		// Get the created product with category information
+		var err error
+		createdProduct, err = s.productRepo.Lock(tx, product.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get created product: %w", err)
+		}
 
-	// Get the created product with category information
-	createdProduct, err := s.productRepo.GetByID(product.ID)
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityProduct, createdProduct.ID.String(), nil, createdProduct)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get created product: %w", err)
+		return nil, err
 	}
 
 	return createdProduct, nil
@@ -91,7 +111,7 @@ func (s *ProductService) GetAllProducts() ([]*models.Product, error) {
 	return products, nil
 }
 
-func (s *ProductService) UpdateProduct(id string, req *models.UpdateProductRequest) (*models.Product, error) {
+func (s *ProductService) UpdateProduct(id string, req *models.UpdateProductRequest, actor *models.User) (*models.Product, error) {
 	productID, err := uuid.Parse(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid product ID: %w", err)
@@ -103,65 +123,87 @@ func (s *ProductService) UpdateProduct(id string, req *models.UpdateProductReque
 		return nil, fmt.Errorf("invalid category ID: %w", err)
 	}
 
-	// Get existing product
-	existingProduct, err := s.productRepo.GetByID(productID)
+	taxClassID, err := parseTaxClassID(s.taxRepo, req.TaxClassID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get existing product: %w", err)
+		return nil, err
 	}
 
-	// Handle SKU update
-	sku := req.SKU
-	if sku == "" {
-		sku = fmt.Sprintf("SKU-%s", uuid.New().String()[:8])
-	}
+	var updatedProduct *models.Product
+	err = s.productRepo.WithTx(func(tx *sql.Tx) error {
+		// Get existing product
+		existingProduct, err := s.productRepo.Lock(tx, productID)
+		if err != nil {
+			return fmt.Errorf("failed to get existing product: %w", err)
+		}
+		before := *existingProduct
 
-	// Check if SKU is being changed and if it already exists
-	if existingProduct.SKU != sku {
-		productWithSKU, _ := s.productRepo.GetBySKU(sku)
-		if productWithSKU != nil && productWithSKU.ID != productID {
-			return nil, fmt.Errorf("product with SKU %s already exists", sku)
+		// Handle SKU update
+		sku := req.SKU
+		if sku == "" {
+			sku = fmt.Sprintf("SKU-%s", uuid.New().String()[:8])
 		}
-	}
 
-	// Update product fields
-	existingProduct.Name = req.Name
-	existingProduct.Description = req.Description
-	existingProduct.SKU = sku
-	
-	var barcodeNumber *string
-	if req.BarcodeNumber != "" {
-		barcodeNumber = &req.BarcodeNumber
-	} else {
-		uniqueBarcode := fmt.Sprintf("BC-%s", uuid.New().String()[:8])
-		barcodeNumber = &uniqueBarcode
-	}
-	existingProduct.BarcodeNumber = barcodeNumber
-	
-	existingProduct.CategoryID = categoryID
-	existingProduct.Price = req.Price
+		// Check if SKU is being changed and if it already exists
+		if existingProduct.SKU != sku {
+			productWithSKU, _ := s.productRepo.GetBySKU(sku)
+			if productWithSKU != nil && productWithSKU.ID != productID {
+				return fmt.Errorf("product with SKU %s already exists", sku)
+			}
+		}
 
-	if err := s.productRepo.Update(existingProduct); err != nil {
-		return nil, fmt.Errorf("failed to update product: %w", err)
-	}
+		// Update product fields
+		existingProduct.Name = req.Name
+		existingProduct.Description = req.Description
+		existingProduct.SKU = sku
+		
+		var barcodeNumber *string
+		if req.BarcodeNumber != "" {
+			barcodeNumber = &req.BarcodeNumber
+		} else {
+			uniqueBarcode := fmt.Sprintf("BC-%s", uuid.New().String()[:8])
+			barcodeNumber = &uniqueBarcode
+		}
+		existingProduct.BarcodeNumber = barcodeNumber
+		
+		existingProduct.CategoryID = categoryID
+		existingProduct.Price = req.Price
+		existingProduct.TaxClassID = taxClassID
+
+		if err := s.productRepo.UpdateTx(tx, existingProduct); err != nil {
+			return fmt.Errorf("failed to update product: %w", err)
+		}
+
+		// Get the updated product with category information
+		updatedProduct, err = s.productRepo.Lock(tx, productID)
+		if err != nil {
+			return fmt.Errorf("failed to get updated product: %w", err)
+		}
 
-	// Get the updated product with category information
-	updatedProduct, err := s.productRepo.GetByID(productID)
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityProduct, productID.String(), &before, updatedProduct)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get updated product: %w", err)
+		return nil, err
 	}
 
 	return updatedProduct, nil
 }
 
-func (s *ProductService) DeleteProduct(id string) error {
+func (s *ProductService) DeleteProduct(id string, actor *models.User) error {
 	productID, err := uuid.Parse(id)
 	if err != nil {
 		return fmt.Errorf("invalid product ID: %w", err)
 	}
 
-	if err := s.productRepo.Delete(productID); err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
-	}
+	return s.productRepo.WithTx(func(tx *sql.Tx) error {
+		product, err := s.productRepo.Lock(tx, productID)
+		if err != nil {
+			return fmt.Errorf("failed to get product: %w", err)
+		}
+
+		if err := s.productRepo.DeleteTx(tx, productID); err != nil {
+			return fmt.Errorf("failed to delete product: %w", err)
+		}
 
-	return nil
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityProduct, productID.String(), product, nil)
+	})
 }
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidPromotion is returned when a promotion is missing the fields its type needs
+	ErrInvalidPromotion = errors.New("invalid promotion")
+	// ErrPromotionInUse is returned when deleting a promotion that has been applied to orders
+	ErrPromotionInUse = errors.New("promotion has been applied to orders")
+)
+
+type PromotionService struct {
+	promotionRepo *repository.PromotionRepository
+}
+
+func NewPromotionService(promotionRepo *repository.PromotionRepository) *PromotionService {
+	return &PromotionService{
+		promotionRepo: promotionRepo,
+	}
+}
+
+func (s *PromotionService) CreatePromotion(req *models.CreatePromotionRequest) (*models.Promotion, error) {
+	promotion := &models.Promotion{IsActive: true}
+	applyPromotionRequest(promotion, req)
+
+	if err := validatePromotion(promotion); err != nil {
+		return nil, err
+	}
+
+	if err := s.promotionRepo.Create(promotion); err != nil {
+		return nil, fmt.Errorf("failed to create promotion: %w", err)
+	}
+
+	return promotion, nil
+}
+
+func (s *PromotionService) GetPromotion(id uuid.UUID) (*models.Promotion, error) {
+	promotion, err := s.promotionRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion: %w", err)
+	}
+
+	return promotion, nil
+}
+
+func (s *PromotionService) GetAllPromotions() ([]*models.Promotion, error) {
+	promotions, err := s.promotionRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotions: %w", err)
+	}
+
+	return promotions, nil
+}
+
+func (s *PromotionService) UpdatePromotion(id uuid.UUID, req *models.UpdatePromotionRequest) (*models.Promotion, error) {
+	promotion, err := s.promotionRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing promotion: %w", err)
+	}
+
+	applyPromotionRequest(promotion, (*models.CreatePromotionRequest)(req))
+
+	if err := validatePromotion(promotion); err != nil {
+		return nil, err
+	}
+
+	if err := s.promotionRepo.Update(promotion); err != nil {
+		return nil, fmt.Errorf("failed to update promotion: %w", err)
+	}
+
+	return promotion, nil
+}
+
+// DeletePromotion deletes a promotion that has never been applied. Applied
+// promotions are kept for reporting and can only be deactivated.
+func (s *PromotionService) DeletePromotion(id uuid.UUID) error {
+	used, err := s.promotionRepo.IsUsed(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete promotion: %w", err)
+	}
+	if used {
+		return fmt.Errorf("%w, deactivate it instead", ErrPromotionInUse)
+	}
+
+	if err := s.promotionRepo.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete promotion: %w", err)
+	}
+
+	return nil
+}
+
+// GetPromotionReport returns the discount given per promotion on orders
+// placed between from and to
+func (s *PromotionService) GetPromotionReport(from, to time.Time) ([]models.PromotionReport, error) {
+	report, err := s.promotionRepo.GetReport(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion report: %w", err)
+	}
+
+	return report, nil
+}
+
+func applyPromotionRequest(promotion *models.Promotion, req *models.CreatePromotionRequest) {
+	promotion.Name = req.Name
+	promotion.Description = req.Description
+	promotion.Campaign = req.Campaign
+	promotion.Type = req.Type
+	promotion.Percent = req.Percent
+	promotion.Amount = req.Amount
+	promotion.BuyQuantity = req.BuyQuantity
+	promotion.GetQuantity = req.GetQuantity
+	promotion.MinSpend = req.MinSpend
+	promotion.ProductID = req.ProductID
+	promotion.CategoryID = req.CategoryID
+	promotion.CustomerID = req.CustomerID
+	promotion.StartsAt = req.StartsAt
+	promotion.EndsAt = req.EndsAt
+	promotion.Priority = req.Priority
+	if req.IsActive != nil {
+		promotion.IsActive = *req.IsActive
+	}
+}
+
+// validatePromotion checks that a promotion has the fields its type needs
+func validatePromotion(promotion *models.Promotion) error {
+	if err := checkDiscount(promotion); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromotion, err)
+	}
+	return nil
+}
+
+// checkDiscount checks the discount definition shared by promotions and
+// coupons
+func checkDiscount(promotion *models.Promotion) error {
+	if promotion.Percent < 0 || promotion.Percent > 100*money.RateScale {
+		return errors.New("percent must be between 0 and 100")
+	}
+	if promotion.Amount < 0 || promotion.MinSpend < 0 || promotion.BuyQuantity < 0 || promotion.GetQuantity < 0 {
+		return errors.New("amounts and quantities cannot be negative")
+	}
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	switch promotion.Type {
+	case PromotionPercentage:
+		if promotion.Percent == 0 {
+			return errors.New("percentage discounts need a percent")
+		}
+	case PromotionFixed:
+		if promotion.Amount == 0 {
+			return errors.New("fixed discounts need an amount")
+		}
+	case PromotionBuyXGetY:
+		if promotion.BuyQuantity == 0 || promotion.GetQuantity == 0 {
+			return errors.New("buy_x_get_y discounts need buy_quantity and get_quantity")
+		}
+	case PromotionBundle:
+		if promotion.BuyQuantity < 2 || promotion.Amount == 0 {
+			return errors.New("bundle discounts need a buy_quantity of at least 2 and a bundle amount")
+		}
+	case PromotionSpendThreshold:
+		if promotion.MinSpend == 0 || (promotion.Amount == 0 && promotion.Percent == 0) {
+			return errors.New("spend_threshold discounts need min_spend and an amount or percent")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", promotion.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"sort"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+)
+
+// Promotion types
+const (
+	PromotionPercentage     = "percentage"
+	PromotionFixed          = "fixed"
+	PromotionBuyXGetY       = "buy_x_get_y"
+	PromotionBundle         = "bundle"
+	PromotionSpendThreshold = "spend_threshold"
+)
+
+// AppliedPromotion is the discount a promotion gave on one order line, or on
+// the order as a whole when Line is -1
+type AppliedPromotion struct {
+	Promotion *models.Promotion
+	Line      int
+	Amount    money.Amount
+}
+
+// ApplyPromotions applies promotions to an order in the given order. Line
+// discounts are added to the lines' Discount and taken off their TotalPrice;
+// order discounts are added to the order's DiscountAmount. Each promotion
+// works on what is left after the ones before it, and no line or order is
+// discounted below zero. order.Items must have their Product loaded for
+// category scoped promotions to match.
+func (p *Pricer) ApplyPromotions(order *models.Order, promotions []*models.Promotion) []AppliedPromotion {
+	var applied []AppliedPromotion
+	for _, promotion := range promotions {
+		if promotion.CustomerID != nil && (order.CustomerID == nil || *order.CustomerID != *promotion.CustomerID) {
+			continue
+		}
+
+		lines := matchingLines(order, promotion)
+		if len(lines) == 0 {
+			continue
+		}
+
+		var lineDiscounts map[int]money.Amount
+		var orderDiscount money.Amount
+
+		switch promotion.Type {
+		case PromotionPercentage:
+			if isScoped(promotion) {
+				lineDiscounts = make(map[int]money.Amount)
+				for _, i := range lines {
+					lineDiscounts[i] = p.Currency.Round(promotion.Percent.Of(order.Items[i].TotalPrice))
+				}
+			} else {
+				orderDiscount = p.Currency.Round(promotion.Percent.Of(orderRemaining(order)))
+			}
+		case PromotionFixed:
+			if isScoped(promotion) {
+				lineDiscounts = make(map[int]money.Amount)
+				for _, i := range lines {
+					lineDiscounts[i] = promotion.Amount.Mul(order.Items[i].Quantity)
+				}
+			} else {
+				orderDiscount = promotion.Amount
+			}
+		case PromotionBuyXGetY:
+			lineDiscounts = p.buyXGetY(order, lines, promotion)
+		case PromotionBundle:
+			lineDiscounts = p.bundle(order, lines, promotion)
+		case PromotionSpendThreshold:
+			var spend money.Amount
+			for _, i := range lines {
+				spend += order.Items[i].TotalPrice
+			}
+			if spend >= promotion.MinSpend {
+				orderDiscount = promotion.Amount
+				if promotion.Percent > 0 {
+					orderDiscount = p.Currency.Round(promotion.Percent.Of(spend))
+				}
+			}
+		}
+
+		for _, i := range lines {
+			item := &order.Items[i]
+			amount := money.Min(lineDiscounts[i], item.TotalPrice)
+			if amount <= 0 {
+				continue
+			}
+			item.Discount += amount
+			item.TotalPrice -= amount
+			applied = append(applied, AppliedPromotion{Promotion: promotion, Line: i, Amount: amount})
+		}
+
+		if amount := money.Min(orderDiscount, orderRemaining(order)); amount > 0 {
+			order.DiscountAmount += amount
+			applied = append(applied, AppliedPromotion{Promotion: promotion, Line: -1, Amount: amount})
+		}
+	}
+
+	return applied
+}
+
+// promotionUnit is a single unit on an order line
+type promotionUnit struct {
+	line  int
+	price money.Amount
+}
+
+// buyXGetY discounts GetQuantity units for every BuyQuantity+GetQuantity
+// matching units, choosing the cheapest units
+func (p *Pricer) buyXGetY(order *models.Order, lines []int, promotion *models.Promotion) map[int]money.Amount {
+	group := promotion.BuyQuantity + promotion.GetQuantity
+	if promotion.BuyQuantity <= 0 || promotion.GetQuantity <= 0 {
+		return nil
+	}
+
+	units := expandUnits(order, lines)
+	sort.SliceStable(units, func(a, b int) bool { return units[a].price < units[b].price })
+
+	percent := promotion.Percent
+	if percent == 0 {
+		percent = 100 * money.RateScale
+	}
+
+	discounts := make(map[int]money.Amount)
+	free := len(units) / group * promotion.GetQuantity
+	for _, unit := range units[:free] {
+		discounts[unit.line] += p.Currency.Round(percent.Of(unit.price))
+	}
+
+	return discounts
+}
+
+// bundle prices every BuyQuantity matching units at Amount together, starting
+// with the most expensive units. Each bundle's discount is shared over its
+// units in proportion to their prices.
+func (p *Pricer) bundle(order *models.Order, lines []int, promotion *models.Promotion) map[int]money.Amount {
+	size := promotion.BuyQuantity
+	if size <= 0 {
+		return nil
+	}
+
+	units := expandUnits(order, lines)
+	sort.SliceStable(units, func(a, b int) bool { return units[a].price > units[b].price })
+
+	discounts := make(map[int]money.Amount)
+	for start := 0; start+size <= len(units); start += size {
+		bundle := units[start : start+size]
+
+		var regular money.Amount
+		prices := make([]money.Amount, len(bundle))
+		for i, unit := range bundle {
+			prices[i] = unit.price
+			regular += unit.price
+		}
+		if regular <= promotion.Amount {
+			continue
+		}
+
+		for i, share := range p.Currency.Allocate(regular-promotion.Amount, prices) {
+			discounts[bundle[i].line] += share
+		}
+	}
+
+	return discounts
+}
+
+// expandUnits lists every unit on the given lines at its list price
+func expandUnits(order *models.Order, lines []int) []promotionUnit {
+	var units []promotionUnit
+	for _, i := range lines {
+		for n := 0; n < order.Items[i].Quantity; n++ {
+			units = append(units, promotionUnit{line: i, price: order.Items[i].UnitPrice})
+		}
+	}
+	return units
+}
+
+// matchingLines returns the indexes of the order lines a promotion applies to
+func matchingLines(order *models.Order, promotion *models.Promotion) []int {
+	var lines []int
+	for i, item := range order.Items {
+		if promotion.ProductID != nil && item.ProductID != *promotion.ProductID {
+			continue
+		}
+		if promotion.CategoryID != nil && (item.Product == nil || item.Product.CategoryID != *promotion.CategoryID) {
+			continue
+		}
+		lines = append(lines, i)
+	}
+	return lines
+}
+
+// isScoped reports whether a promotion is limited to a product or category
+func isScoped(promotion *models.Promotion) bool {
+	return promotion.ProductID != nil || promotion.CategoryID != nil
+}
+
+// orderRemaining is what is left of the order after line and order discounts
+func orderRemaining(order *models.Order) money.Amount {
+	var subtotal money.Amount
+	for _, item := range order.Items {
+		subtotal += item.TotalPrice
+	}
+	return money.Max(subtotal-order.DiscountAmount, money.Zero)
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+
+	"github.com/google/uuid"
+)
+
+var (
+	coffeeID  = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	teaID     = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	drinksID  = uuid.MustParse("33333333-3333-3333-3333-333333333333")
+	regularID = uuid.MustParse("44444444-4444-4444-4444-444444444444")
+)
+
+// promotionOrder returns an order of two coffees at 5.00 and a tea at 3.00,
+// all in the drinks category
+func promotionOrder() *models.Order {
+	line := func(product uuid.UUID, price money.Amount, quantity int) models.OrderItem {
+		return models.OrderItem{
+			ProductID:  product,
+			Quantity:   quantity,
+			UnitPrice:  price,
+			TotalPrice: price.Mul(quantity),
+			Product:    &models.Product{ID: product, CategoryID: drinksID},
+		}
+	}
+
+	return &models.Order{
+		Items: []models.OrderItem{
+			line(coffeeID, 500, 2),
+			line(teaID, 300, 1),
+		},
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	coffee, drinks, regular := &coffeeID, &drinksID, &regularID
+
+	tests := []struct {
+		name       string
+		promotions []*models.Promotion
+		lines      []money.Amount
+		order      money.Amount
+	}{
+		{
+			name:       "percentage off the order",
+			promotions: []*models.Promotion{{Type: PromotionPercentage, Percent: 10 * money.RateScale}},
+			lines:      []money.Amount{0, 0},
+			order:      130,
+		},
+		{
+			name:       "percentage off a product",
+			promotions: []*models.Promotion{{Type: PromotionPercentage, Percent: 10 * money.RateScale, ProductID: coffee}},
+			lines:      []money.Amount{100, 0},
+		},
+		{
+			name:       "percentage off a category",
+			promotions: []*models.Promotion{{Type: PromotionPercentage, Percent: 10 * money.RateScale, CategoryID: drinks}},
+			lines:      []money.Amount{100, 30},
+		},
+		{
+			name:       "fixed amount off the order",
+			promotions: []*models.Promotion{{Type: PromotionFixed, Amount: 200}},
+			lines:      []money.Amount{0, 0},
+			order:      200,
+		},
+		{
+			name:       "fixed amount off every unit of a product",
+			promotions: []*models.Promotion{{Type: PromotionFixed, Amount: 50, ProductID: coffee}},
+			lines:      []money.Amount{100, 0},
+		},
+		{
+			name:       "fixed amount never takes a line below zero",
+			promotions: []*models.Promotion{{Type: PromotionFixed, Amount: 400, CategoryID: drinks}},
+			lines:      []money.Amount{800, 300},
+		},
+		{
+			name:       "fixed amount never takes the order below zero",
+			promotions: []*models.Promotion{{Type: PromotionFixed, Amount: 5000}},
+			lines:      []money.Amount{0, 0},
+			order:      1300,
+		},
+		{
+			name:       "buy two get one free gives the cheapest unit",
+			promotions: []*models.Promotion{{Type: PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, CategoryID: drinks}},
+			lines:      []money.Amount{0, 300},
+		},
+		{
+			name:       "buy two get one at half price",
+			promotions: []*models.Promotion{{Type: PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Percent: 50 * money.RateScale, CategoryID: drinks}},
+			lines:      []money.Amount{0, 150},
+		},
+		{
+			name:       "buy one get one needs two units",
+			promotions: []*models.Promotion{{Type: PromotionBuyXGetY, BuyQuantity: 1, GetQuantity: 1, ProductID: coffee}},
+			lines:      []money.Amount{500, 0},
+		},
+		{
+			name:       "bundle of the two dearest units",
+			promotions: []*models.Promotion{{Type: PromotionBundle, BuyQuantity: 2, Amount: 800, CategoryID: drinks}},
+			lines:      []money.Amount{200, 0},
+		},
+		{
+			name:       "bundle dearer than the units is ignored",
+			promotions: []*models.Promotion{{Type: PromotionBundle, BuyQuantity: 3, Amount: 1500, CategoryID: drinks}},
+			lines:      []money.Amount{0, 0},
+		},
+		{
+			name:       "bundle discount shared by price",
+			promotions: []*models.Promotion{{Type: PromotionBundle, BuyQuantity: 3, Amount: 1040, CategoryID: drinks}},
+			lines:      []money.Amount{200, 60},
+		},
+		{
+			name:       "spend threshold reached",
+			promotions: []*models.Promotion{{Type: PromotionSpendThreshold, MinSpend: 1000, Amount: 100}},
+			lines:      []money.Amount{0, 0},
+			order:      100,
+		},
+		{
+			name:       "spend threshold as a percentage",
+			promotions: []*models.Promotion{{Type: PromotionSpendThreshold, MinSpend: 1000, Percent: 5 * money.RateScale}},
+			lines:      []money.Amount{0, 0},
+			order:      65,
+		},
+		{
+			name:       "spend threshold not reached by the matching lines",
+			promotions: []*models.Promotion{{Type: PromotionSpendThreshold, MinSpend: 1100, Amount: 100, ProductID: coffee}},
+			lines:      []money.Amount{0, 0},
+		},
+		{
+			name:       "promotion for another customer",
+			promotions: []*models.Promotion{{Type: PromotionFixed, Amount: 200, CustomerID: regular}},
+			lines:      []money.Amount{0, 0},
+		},
+		{
+			name:       "promotion for a product not on the order",
+			promotions: []*models.Promotion{{Type: PromotionFixed, Amount: 200, ProductID: regular}},
+			lines:      []money.Amount{0, 0},
+		},
+		{
+			name: "order percentage after a line discount",
+			promotions: []*models.Promotion{
+				{Type: PromotionPercentage, Percent: 10 * money.RateScale, ProductID: coffee},
+				{Type: PromotionPercentage, Percent: 10 * money.RateScale},
+			},
+			lines: []money.Amount{100, 0},
+			order: 120,
+		},
+		{
+			name: "order discounts stack on what is left",
+			promotions: []*models.Promotion{
+				{Type: PromotionPercentage, Percent: 10 * money.RateScale},
+				{Type: PromotionPercentage, Percent: 10 * money.RateScale},
+			},
+			lines: []money.Amount{0, 0},
+			order: 247,
+		},
+		{
+			name: "line discounts stack on what is left",
+			promotions: []*models.Promotion{
+				{Type: PromotionFixed, Amount: 300, ProductID: coffee},
+				{Type: PromotionPercentage, Percent: 50 * money.RateScale, ProductID: coffee},
+			},
+			lines: []money.Amount{800, 0},
+		},
+		{
+			name: "a fixed order discount after line discounts is capped",
+			promotions: []*models.Promotion{
+				{Type: PromotionBuyXGetY, BuyQuantity: 1, GetQuantity: 1, ProductID: coffee},
+				{Type: PromotionFixed, Amount: 1000},
+			},
+			lines: []money.Amount{500, 0},
+			order: 800,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := promotionOrder()
+			applied := NewPricer(money.USD, false).ApplyPromotions(order, tt.promotions)
+
+			lines := make([]money.Amount, len(order.Items))
+			for i, item := range order.Items {
+				lines[i] = item.Discount
+				if item.TotalPrice != item.UnitPrice.Mul(item.Quantity)-item.Discount {
+					t.Errorf("line %d TotalPrice = %d, want %d less its discount %d", i, item.TotalPrice, item.UnitPrice.Mul(item.Quantity), item.Discount)
+				}
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("line discounts = %v, want %v", lines, tt.lines)
+			}
+			if order.DiscountAmount != tt.order {
+				t.Errorf("order discount = %d, want %d", order.DiscountAmount, tt.order)
+			}
+
+			// Every discount given is reported against its line or the order
+			var reported, given money.Amount
+			for _, a := range applied {
+				reported += a.Amount
+			}
+			for _, discount := range lines {
+				given += discount
+			}
+			if reported != given+order.DiscountAmount {
+				t.Errorf("applied promotions add up to %d, want %d", reported, given+order.DiscountAmount)
+			}
+		})
+	}
+}
+
+func TestApplyPromotionsForCustomer(t *testing.T) {
+	order := promotionOrder()
+	order.CustomerID = &regularID
+
+	promotion := &models.Promotion{Type: PromotionFixed, Amount: 200, CustomerID: &regularID}
+	applied := NewPricer(money.USD, false).ApplyPromotions(order, []*models.Promotion{promotion})
+
+	if len(applied) != 1 || applied[0].Promotion != promotion || applied[0].Line != -1 || applied[0].Amount != 200 {
+		t.Errorf("applied = %+v, want 2.00 off the order", applied)
+	}
+	if order.DiscountAmount != 200 {
+		t.Errorf("order discount = %d, want 200", order.DiscountAmount)
+	}
+}
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Purchase order statuses
+const (
+	PurchaseOrderStatusDraft             = "draft"
+	PurchaseOrderStatusSent              = "sent"
+	PurchaseOrderStatusPartiallyReceived = "partially_received"
+	PurchaseOrderStatusReceived          = "received"
+	PurchaseOrderStatusClosed            = "closed"
+)
+
+var (
+	// ErrInvalidPurchaseOrder is returned for a purchase order or goods
+	// receipt request that cannot be carried out as given
+	ErrInvalidPurchaseOrder = errors.New("invalid purchase order")
+	// ErrPurchaseOrderStatus is returned for a change a purchase order does
+	// not allow in its current status, such as editing one that has been
+	// sent or receiving one that is closed
+	ErrPurchaseOrderStatus = errors.New("purchase order status does not allow this")
+	// ErrOverDelivery is returned when goods received would bring a line
+	// above the quantity ordered and the request did not accept that
+	ErrOverDelivery = errors.New("more delivered than ordered")
+	// ErrInactiveSupplier is returned for a new purchase order from a
+	// supplier that has been deactivated
+	ErrInactiveSupplier = errors.New("supplier is inactive")
+)
+
+type PurchaseOrderService struct {
+	purchaseOrderRepo *repository.PurchaseOrderRepository
+	supplierRepo      *repository.SupplierRepository
+	inventoryRepo     *repository.InventoryRepository
+	locationRepo      *repository.LocationRepository
+	audit             *AuditService
+}
+
+func NewPurchaseOrderService(purchaseOrderRepo *repository.PurchaseOrderRepository, supplierRepo *repository.SupplierRepository, inventoryRepo *repository.InventoryRepository, locationRepo *repository.LocationRepository, audit *AuditService) *PurchaseOrderService {
+	return &PurchaseOrderService{
+		purchaseOrderRepo: purchaseOrderRepo,
+		supplierRepo:      supplierRepo,
+		inventoryRepo:     inventoryRepo,
+		locationRepo:      locationRepo,
+		audit:             audit,
+	}
+}
+
+// GetPurchaseOrders returns the purchase orders, newest first, optionally
+// only those with the given status or from the given supplier
+func (s *PurchaseOrderService) GetPurchaseOrders(status string, supplierID *uuid.UUID) ([]models.PurchaseOrder, error) {
+	switch status {
+	case "", PurchaseOrderStatusDraft, PurchaseOrderStatusSent, PurchaseOrderStatusPartiallyReceived,
+		PurchaseOrderStatusReceived, PurchaseOrderStatusClosed:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidPurchaseOrder, status)
+	}
+
+	orders, err := s.purchaseOrderRepo.GetAll(status, supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get purchase orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+func (s *PurchaseOrderService) GetPurchaseOrder(id uuid.UUID) (*models.PurchaseOrder, error) {
+	return s.purchaseOrderRepo.GetByID(id)
+}
+
+// CreatePurchaseOrder drafts a purchase order. Lines without a unit cost are
+// expected to cost what the supplier charged for the product last time.
+func (s *PurchaseOrderService) CreatePurchaseOrder(req *models.PurchaseOrderRequest, actor *models.User) (*models.PurchaseOrder, error) {
+	order := &models.PurchaseOrder{}
+	if err := s.applyRequest(order, req); err != nil {
+		return nil, err
+	}
+	if actor != nil {
+		order.CreatedBy = optionalUserID(actor.ID)
+	}
+
+	err := s.purchaseOrderRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.purchaseOrderRepo.CreateTx(tx, order); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityPurchaseOrder, order.ID.String(), nil, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// UpdatePurchaseOrder replaces the supplier, location, dates, notes and
+// lines of a purchase order that has not been sent yet
+func (s *PurchaseOrderService) UpdatePurchaseOrder(id uuid.UUID, req *models.PurchaseOrderRequest, actor *models.User) (*models.PurchaseOrder, error) {
+	var order *models.PurchaseOrder
+	err := s.purchaseOrderRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		order, err = s.purchaseOrderRepo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != PurchaseOrderStatusDraft {
+			return fmt.Errorf("%w: only draft purchase orders can be changed, this one is %s", ErrPurchaseOrderStatus, order.Status)
+		}
+		before := copyPurchaseOrder(order)
+
+		if err := s.applyRequest(order, req); err != nil {
+			return err
+		}
+
+		if err := s.purchaseOrderRepo.UpdateTx(tx, order); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityPurchaseOrder, order.ID.String(), before, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// DeletePurchaseOrder deletes a purchase order that has not been sent yet
+func (s *PurchaseOrderService) DeletePurchaseOrder(id uuid.UUID, actor *models.User) error {
+	return s.purchaseOrderRepo.WithTx(func(tx *sql.Tx) error {
+		order, err := s.purchaseOrderRepo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != PurchaseOrderStatusDraft {
+			return fmt.Errorf("%w: only draft purchase orders can be deleted, close this one instead", ErrPurchaseOrderStatus)
+		}
+
+		if err := s.purchaseOrderRepo.DeleteTx(tx, id); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityPurchaseOrder, id.String(), order, nil)
+	})
+}
+
+// SendPurchaseOrder marks a draft purchase order as sent to its supplier.
+// From then on its outstanding quantities count as on order.
+func (s *PurchaseOrderService) SendPurchaseOrder(id uuid.UUID, actor *models.User) (*models.PurchaseOrder, error) {
+	return s.changeStatus(id, actor, func(order *models.PurchaseOrder) error {
+		if order.Status != PurchaseOrderStatusDraft {
+			return fmt.Errorf("%w: purchase order is %s", ErrPurchaseOrderStatus, order.Status)
+		}
+
+		now := time.Now()
+		order.Status = PurchaseOrderStatusSent
+		order.SentAt = &now
+		return nil
+	})
+}
+
+// ClosePurchaseOrder closes a sent purchase order, whether or not all of it
+// has been received. Nothing more is expected against it after that.
+func (s *PurchaseOrderService) ClosePurchaseOrder(id uuid.UUID, actor *models.User) (*models.PurchaseOrder, error) {
+	return s.changeStatus(id, actor, func(order *models.PurchaseOrder) error {
+		switch order.Status {
+		case PurchaseOrderStatusSent, PurchaseOrderStatusPartiallyReceived, PurchaseOrderStatusReceived:
+		case PurchaseOrderStatusDraft:
+			return fmt.Errorf("%w: a draft purchase order is deleted instead of closed", ErrPurchaseOrderStatus)
+		default:
+			return fmt.Errorf("%w: purchase order is %s", ErrPurchaseOrderStatus, order.Status)
+		}
+
+		now := time.Now()
+		order.Status = PurchaseOrderStatusClosed
+		order.ClosedAt = &now
+		return nil
+	})
+}
+
+// ReceiveGoods puts a delivery against a sent purchase order into stock at
+// its location. Each line delivered is recorded as an "in" movement that
+// references the purchase order line, and what the supplier charged for it
+// is added to the supplier's cost history. A delivery may be partial; more
+// than is outstanding on a line is refused unless the request accepts it.
+// The purchase order is received once every line is, and partially received
+// until then.
+func (s *PurchaseOrderService) ReceiveGoods(id uuid.UUID, req *models.ReceiveGoodsRequest, actor *models.User) (*models.PurchaseOrder, error) {
+	if len(req.Lines) == 0 {
+		return nil, fmt.Errorf("%w: at least one line is required", ErrInvalidPurchaseOrder)
+	}
+	seen := make(map[uuid.UUID]bool, len(req.Lines))
+	for _, line := range req.Lines {
+		if line.LineID == uuid.Nil {
+			return nil, fmt.Errorf("%w: line ID is required", ErrInvalidPurchaseOrder)
+		}
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidPurchaseOrder)
+		}
+		if line.UnitCost != nil && line.UnitCost.IsNegative() {
+			return nil, fmt.Errorf("%w: unit cost cannot be negative", ErrInvalidPurchaseOrder)
+		}
+		if seen[line.LineID] {
+			return nil, fmt.Errorf("%w: line %s is listed more than once", ErrInvalidPurchaseOrder, line.LineID)
+		}
+		seen[line.LineID] = true
+	}
+
+	var order *models.PurchaseOrder
+	err := s.purchaseOrderRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		order, err = s.purchaseOrderRepo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != PurchaseOrderStatusSent && order.Status != PurchaseOrderStatusPartiallyReceived {
+			return fmt.Errorf("%w: only sent purchase orders can be received, this one is %s", ErrPurchaseOrderStatus, order.Status)
+		}
+		if err := activeLocation(s.locationRepo, order.Location); err != nil {
+			return err
+		}
+		supplier, err := s.supplierRepo.GetByID(order.SupplierID)
+		if err != nil {
+			return err
+		}
+		before := copyPurchaseOrder(order)
+
+		lines := make(map[uuid.UUID]*models.PurchaseOrderLine, len(order.Lines))
+		for i := range order.Lines {
+			lines[order.Lines[i].ID] = &order.Lines[i]
+		}
+
+		type delivery struct {
+			line *models.PurchaseOrderLine
+			req  models.ReceiveGoodsLineRequest
+		}
+		deliveries := make([]delivery, 0, len(req.Lines))
+		for _, lineReq := range req.Lines {
+			line, ok := lines[lineReq.LineID]
+			if !ok {
+				return fmt.Errorf("%w: line %s is not on purchase order %s", ErrInvalidPurchaseOrder, lineReq.LineID, order.PONumber)
+			}
+			if outstanding := line.Quantity - line.ReceivedQuantity; lineReq.Quantity > outstanding && !req.AcceptOverDelivery {
+				return fmt.Errorf("%w: product %s has %d outstanding, %d delivered", ErrOverDelivery, line.ProductID, max(outstanding, 0), lineReq.Quantity)
+			}
+			deliveries = append(deliveries, delivery{line: line, req: lineReq})
+		}
+
+		// Stock rows are locked in product order, so that concurrent
+		// movements cannot deadlock each other
+		sort.Slice(deliveries, func(i, j int) bool {
+			return deliveries[i].line.ProductID.String() < deliveries[j].line.ProductID.String()
+		})
+
+		now := time.Now()
+		var receivedBy *uuid.UUID
+		if actor != nil {
+			receivedBy = optionalUserID(actor.ID)
+		}
+		reason := "Received from " + supplier.Name
+		for _, d := range deliveries {
+			inventory, _, err := s.inventoryRepo.LockOrCreate(tx, d.line.ProductID.String(), order.Location)
+			if err != nil {
+				return err
+			}
+
+			lineID := d.line.ID
+			err = s.inventoryRepo.Move(tx, inventory, d.req.Quantity, &models.InventoryTransaction{
+				Type:                "in",
+				Quantity:            d.req.Quantity,
+				Reason:              reason,
+				Reference:           order.PONumber,
+				PurchaseOrderLineID: &lineID,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := s.purchaseOrderRepo.AddReceivedTx(tx, d.line, d.req.Quantity); err != nil {
+				return err
+			}
+
+			cost := d.line.UnitCost
+			if d.req.UnitCost != nil {
+				cost = *d.req.UnitCost
+			}
+			err = s.supplierRepo.AddCostTx(tx, &models.SupplierCost{
+				SupplierID:          order.SupplierID,
+				ProductID:           d.line.ProductID,
+				PurchaseOrderLineID: &lineID,
+				Quantity:            d.req.Quantity,
+				UnitCost:            cost,
+				ReceivedBy:          receivedBy,
+				ReceivedAt:          now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		order.Status = PurchaseOrderStatusReceived
+		for _, line := range order.Lines {
+			if line.ReceivedQuantity < line.Quantity {
+				order.Status = PurchaseOrderStatusPartiallyReceived
+				break
+			}
+		}
+		if order.Status == PurchaseOrderStatusReceived {
+			order.ReceivedAt = &now
+		}
+		if err := s.purchaseOrderRepo.UpdateStatusTx(tx, order); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityPurchaseOrder, order.ID.String(), before, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// changeStatus locks a purchase order, lets change move it to another
+// status and saves and audits the result
+func (s *PurchaseOrderService) changeStatus(id uuid.UUID, actor *models.User, change func(order *models.PurchaseOrder) error) (*models.PurchaseOrder, error) {
+	var order *models.PurchaseOrder
+	err := s.purchaseOrderRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		order, err = s.purchaseOrderRepo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		before := copyPurchaseOrder(order)
+
+		if err := change(order); err != nil {
+			return err
+		}
+		if err := s.purchaseOrderRepo.UpdateStatusTx(tx, order); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityPurchaseOrder, order.ID.String(), before, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// applyRequest checks a purchase order request and copies it into order.
+// The supplier must be active and the location active, and each product may
+// only be ordered once.
+func (s *PurchaseOrderService) applyRequest(order *models.PurchaseOrder, req *models.PurchaseOrderRequest) error {
+	if req.SupplierID == uuid.Nil {
+		return fmt.Errorf("%w: supplier ID is required", ErrInvalidPurchaseOrder)
+	}
+	if req.Location == "" {
+		return fmt.Errorf("%w: location is required", ErrInvalidPurchaseOrder)
+	}
+	if len(req.Lines) == 0 {
+		return fmt.Errorf("%w: at least one line is required", ErrInvalidPurchaseOrder)
+	}
+
+	supplier, err := s.supplierRepo.GetByID(req.SupplierID)
+	if err != nil {
+		if err.Error() == "supplier not found" {
+			return fmt.Errorf("%w: supplier %s does not exist", ErrInvalidPurchaseOrder, req.SupplierID)
+		}
+		return err
+	}
+	if !supplier.IsActive {
+		return fmt.Errorf("%w: %s", ErrInactiveSupplier, supplier.Name)
+	}
+	if err := activeLocation(s.locationRepo, req.Location); err != nil {
+		return err
+	}
+
+	lines := make([]models.PurchaseOrderLine, 0, len(req.Lines))
+	seen := make(map[uuid.UUID]bool, len(req.Lines))
+	for _, lineReq := range req.Lines {
+		if lineReq.ProductID == uuid.Nil {
+			return fmt.Errorf("%w: product ID is required", ErrInvalidPurchaseOrder)
+		}
+		if lineReq.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidPurchaseOrder)
+		}
+		if seen[lineReq.ProductID] {
+			return fmt.Errorf("%w: product %s is listed more than once", ErrInvalidPurchaseOrder, lineReq.ProductID)
+		}
+		seen[lineReq.ProductID] = true
+
+		line := models.PurchaseOrderLine{
+			ProductID:  lineReq.ProductID,
+			Quantity:   lineReq.Quantity,
+			ExpectedAt: lineReq.ExpectedAt,
+		}
+		if lineReq.UnitCost != nil {
+			if lineReq.UnitCost.IsNegative() {
+				return fmt.Errorf("%w: unit cost cannot be negative", ErrInvalidPurchaseOrder)
+			}
+			line.UnitCost = *lineReq.UnitCost
+		} else {
+			cost, ok, err := s.supplierRepo.GetLastCost(req.SupplierID, lineReq.ProductID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%w: unit cost of product %s is required, %s has not supplied it before", ErrInvalidPurchaseOrder, lineReq.ProductID, supplier.Name)
+			}
+			line.UnitCost = cost
+		}
+		lines = append(lines, line)
+	}
+
+	order.SupplierID = req.SupplierID
+	order.Location = req.Location
+	order.ExpectedAt = req.ExpectedAt
+	order.Notes = req.Notes
+	order.Lines = lines
+	return nil
+}
+
+// copyPurchaseOrder copies a purchase order and its lines, so that the
+// audit log can record it as it was before a change
+func copyPurchaseOrder(order *models.PurchaseOrder) *models.PurchaseOrder {
+	snapshot := *order
+	snapshot.Lines = append([]models.PurchaseOrderLine(nil), order.Lines...)
+	return &snapshot
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"fmt"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+	"jatistore/internal/printer"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnsupportedReceiptFormat is returned for a format receipts cannot be printed in
+	ErrUnsupportedReceiptFormat = printer.ErrUnsupportedFormat
+	// ErrUnsupportedPaperWidth is returned for a paper width receipts cannot be printed on
+	ErrUnsupportedPaperWidth = printer.ErrUnsupportedWidth
+)
+
+type ReceiptService struct {
+	receiptRepo   *repository.ReceiptRepository
+	orderRepo     *repository.OrderRepository
+	paymentRepo   *repository.PaymentRepository
+	refundRepo    *repository.RefundRepository
+	promotionRepo *repository.PromotionRepository
+	couponRepo    *repository.CouponRepository
+	userRepo      *repository.UserRepository
+	renderer      *printer.Renderer
+}
+
+func NewReceiptService(
+	receiptRepo *repository.ReceiptRepository,
+	orderRepo *repository.OrderRepository,
+	paymentRepo *repository.PaymentRepository,
+	refundRepo *repository.RefundRepository,
+	promotionRepo *repository.PromotionRepository,
+	couponRepo *repository.CouponRepository,
+	userRepo *repository.UserRepository,
+	renderer *printer.Renderer,
+) *ReceiptService {
+	return &ReceiptService{
+		receiptRepo:   receiptRepo,
+		orderRepo:     orderRepo,
+		paymentRepo:   paymentRepo,
+		refundRepo:    refundRepo,
+		promotionRepo: promotionRepo,
+		couponRepo:    couponRepo,
+		userRepo:      userRepo,
+		renderer:      renderer,
+	}
+}
+
+// GetReceipt returns a receipt with everything printed on it: its order and
+// customer, line items, discounts, tax breakdown, tenders and cashier
+func (s *ReceiptService) GetReceipt(id uuid.UUID) (*models.Receipt, error) {
+	receipt, err := s.receiptRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt.Order, err = s.orderRepo.GetByID(receipt.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt order: %w", err)
+	}
+
+	if receipt.CashierID != nil {
+		cashier, err := s.userRepo.GetUserByID(*receipt.CashierID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get receipt cashier: %w", err)
+		}
+		receipt.Cashier = cashier.Username
+	}
+
+	payments, err := s.paymentRepo.GetByOrderID(receipt.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt tenders: %w", err)
+	}
+
+	if receipt.Type == "credit_note" {
+		if err := s.loadCreditNote(receipt, payments); err != nil {
+			return nil, err
+		}
+		return receipt, nil
+	}
+
+	receipt.Tenders, receipt.ChangeAmount = saleTenders(payments)
+	for _, item := range receipt.Order.Items {
+		line := receiptLine(item, item.Quantity, item.TotalPrice)
+		line.Discount = item.Discount
+		receipt.Lines = append(receipt.Lines, line)
+	}
+
+	if receipt.Discounts, err = s.saleDiscounts(receipt.OrderID); err != nil {
+		return nil, err
+	}
+
+	return receipt, nil
+}
+
+// RenderReceipt renders a receipt in one of the printer formats without
+// counting it as a print, e.g. to show it on screen. A receipt that has been
+// printed before renders as a copy. width is the paper width in millimetres,
+// or zero for the store's default.
+func (s *ReceiptService) RenderReceipt(id uuid.UUID, format string, width int) ([]byte, *models.Receipt, error) {
+	if err := s.renderer.Validate(format, width); err != nil {
+		return nil, nil, err
+	}
+
+	receipt, err := s.GetReceipt(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output, err := s.renderer.Render(receipt, format, printer.Options{
+		Width: width,
+		Copy:  receipt.PrintCount > 0,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return output, receipt, nil
+}
+
+// PrintReceipt renders a receipt in one of the printer formats and counts
+// the print. Every print after the first is marked as a copy. width is the
+// paper width in millimetres, or zero for the store's default.
+func (s *ReceiptService) PrintReceipt(id uuid.UUID, format string, width int) ([]byte, *models.Receipt, error) {
+	if err := s.renderer.Validate(format, width); err != nil {
+		return nil, nil, err
+	}
+
+	receipt, err := s.GetReceipt(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Counting the print before rendering it means two receipts printed at
+	// the same time can never both come out as the original
+	receipt.PrintCount, err = s.receiptRepo.MarkPrinted(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output, err := s.renderer.Render(receipt, format, printer.Options{
+		Width: width,
+		Copy:  receipt.PrintCount > 1,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return output, receipt, nil
+}
+
+// loadCreditNote fills in the returned lines of a credit note and the
+// refund payments it was paid out with
+func (s *ReceiptService) loadCreditNote(receipt *models.Receipt, payments []models.Payment) error {
+	if receipt.RefundID == nil {
+		return nil
+	}
+
+	refunds, err := s.refundRepo.GetByOrderID(receipt.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to get credit note refund: %w", err)
+	}
+
+	items := make(map[uuid.UUID]models.OrderItem, len(receipt.Order.Items))
+	for _, item := range receipt.Order.Items {
+		items[item.ID] = item
+	}
+
+	for _, refund := range refunds {
+		if refund.ID != *receipt.RefundID {
+			continue
+		}
+		for _, returned := range refund.Items {
+			receipt.Lines = append(receipt.Lines, receiptLine(items[returned.OrderItemID], returned.Quantity, returned.Amount))
+		}
+	}
+
+	for i := len(payments) - 1; i >= 0; i-- {
+		if payments[i].RefundID != nil && *payments[i].RefundID == *receipt.RefundID {
+			receipt.Tenders = append(receipt.Tenders, payments[i])
+		}
+	}
+
+	return nil
+}
+
+// saleDiscounts lists the promotions and coupons that took money off an
+// order, one entry per promotion or coupon
+func (s *ReceiptService) saleDiscounts(orderID uuid.UUID) ([]models.ReceiptDiscount, error) {
+	promotions, err := s.promotionRepo.GetByOrderID(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt promotions: %w", err)
+	}
+
+	var discounts []models.ReceiptDiscount
+	index := make(map[uuid.UUID]int)
+	for _, promotion := range promotions {
+		i, ok := index[promotion.PromotionID]
+		if !ok {
+			i = len(discounts)
+			index[promotion.PromotionID] = i
+			discounts = append(discounts, models.ReceiptDiscount{Name: promotion.Name})
+		}
+		discounts[i].Amount += promotion.Amount
+	}
+
+	coupons, err := s.couponRepo.GetByOrderID(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt coupons: %w", err)
+	}
+	for _, coupon := range coupons {
+		if coupon.ReversedAt != nil {
+			continue
+		}
+		discounts = append(discounts, models.ReceiptDiscount{Name: "Coupon " + coupon.Code, Amount: coupon.Amount})
+	}
+
+	return discounts, nil
+}
+
+// receiptLine prints quantity units of an order line that came to amount
+func receiptLine(item models.OrderItem, quantity int, amount money.Amount) models.ReceiptLine {
+	line := models.ReceiptLine{
+		Quantity:  quantity,
+		UnitPrice: item.UnitPrice,
+		Amount:    amount,
+	}
+	if item.Product != nil {
+		line.Name = item.Product.Name
+		line.SKU = item.Product.SKU
+	}
+	return line
+}
+
+// saleTenders picks the payments made for a sale out of all payments of an
+// order, oldest first, and adds up the change given on them. payments are
+// ordered newest first, as the payment repository returns them.
+func saleTenders(payments []models.Payment) ([]models.Payment, money.Amount) {
+	var tenders []models.Payment
+	change := money.Zero
+	for i := len(payments) - 1; i >= 0; i-- {
+		payment := payments[i]
+		if payment.RefundOf != nil || payment.Status != "completed" {
+			continue
+		}
+		tenders = append(tenders, payment)
+		change += payment.ChangeAmount
+	}
+	return tenders, change
+}
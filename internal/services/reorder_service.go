@@ -0,0 +1,312 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"jatistore/internal/mailer"
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Stock states of a product at a location against its reorder level
+const (
+	StockStateOK   = "ok"
+	StockStateLow  = "low"
+	StockStateOut  = "out"
+	StockStateOver = "over"
+)
+
+var (
+	// ErrInvalidReorderLevel is returned for a reorder level with quantities
+	// it cannot have
+	ErrInvalidReorderLevel = errors.New("invalid reorder level")
+	// ErrReorderLevelNotFound is returned when a product has no reorder level
+	// at a location
+	ErrReorderLevelNotFound = repository.ErrReorderLevelNotFound
+)
+
+// ReorderPolicy decides who is alerted about stock levels and how
+// replenishment is worked out
+type ReorderPolicy struct {
+	// AlertEmails are emailed when stock crosses a reorder level. Without
+	// any, alerts are only logged.
+	AlertEmails []string
+	// SalesDays is how many days of sales the daily sales of a product are
+	// averaged over, unless a request asks for another period
+	SalesDays int
+	// CoverDays is how many days of sales replenished stock should last,
+	// unless a request asks for another period
+	CoverDays int
+}
+
+type ReorderService struct {
+	reorderRepo  *repository.ReorderRepository
+	locationRepo *repository.LocationRepository
+	audit        *AuditService
+	mailer       mailer.Mailer
+	policy       ReorderPolicy
+}
+
+func NewReorderService(reorderRepo *repository.ReorderRepository, locationRepo *repository.LocationRepository, audit *AuditService, mail mailer.Mailer, policy ReorderPolicy) *ReorderService {
+	return &ReorderService{
+		reorderRepo:  reorderRepo,
+		locationRepo: locationRepo,
+		audit:        audit,
+		mailer:       mail,
+		policy:       policy,
+	}
+}
+
+// GetReorderLevels returns the reorder levels, optionally only those of a
+// product or at a location
+func (s *ReorderService) GetReorderLevels(productID *uuid.UUID, location string) ([]models.ReorderLevel, error) {
+	levels, err := s.reorderRepo.GetAll(productID, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reorder levels: %w", err)
+	}
+
+	return levels, nil
+}
+
+// SetReorderLevel creates or replaces the reorder level of a product at a
+// location
+func (s *ReorderService) SetReorderLevel(productID uuid.UUID, location string, req *models.SetReorderLevelRequest, actor *models.User) (*models.ReorderLevel, error) {
+	if req.MinQuantity < 0 || req.ReorderQuantity < 0 {
+		return nil, fmt.Errorf("%w: quantities cannot be negative", ErrInvalidReorderLevel)
+	}
+	if req.MaxQuantity < req.MinQuantity {
+		return nil, fmt.Errorf("%w: maximum cannot be below minimum", ErrInvalidReorderLevel)
+	}
+
+	exists, err := s.locationRepo.Exists(location)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w %q", ErrUnknownLocation, location)
+	}
+
+	level := &models.ReorderLevel{
+		ProductID:       productID,
+		Location:        location,
+		MinQuantity:     req.MinQuantity,
+		MaxQuantity:     req.MaxQuantity,
+		ReorderQuantity: req.ReorderQuantity,
+	}
+	err = s.reorderRepo.WithTx(func(tx *sql.Tx) error {
+		before, err := s.reorderRepo.GetTx(tx, productID, location)
+		if err != nil && !errors.Is(err, ErrReorderLevelNotFound) {
+			return err
+		}
+
+		if err := s.reorderRepo.SetTx(tx, level); err != nil {
+			return err
+		}
+
+		action := AuditActionUpdate
+		if before == nil {
+			action = AuditActionCreate
+		}
+		return s.audit.RecordTx(tx, actor, action, AuditEntityReorderLevel, reorderLevelID(productID, location), before, level)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return level, nil
+}
+
+// DeleteReorderLevel stops tracking the stock of a product at a location
+func (s *ReorderService) DeleteReorderLevel(productID uuid.UUID, location string, actor *models.User) error {
+	return s.reorderRepo.WithTx(func(tx *sql.Tx) error {
+		level, err := s.reorderRepo.GetTx(tx, productID, location)
+		if err != nil {
+			return err
+		}
+
+		if err := s.reorderRepo.DeleteTx(tx, productID, location); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityReorderLevel, reorderLevelID(productID, location), level, nil)
+	})
+}
+
+// GetLowStock returns the products with a reorder level whose stock is at
+// or below its minimum, optionally only at a location
+func (s *ReorderService) GetLowStock(location string) ([]models.ReorderStatus, error) {
+	statuses, err := s.reorderRepo.GetStatuses(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get low stock: %w", err)
+	}
+
+	low := []models.ReorderStatus{}
+	for _, status := range statuses {
+		if status.State == StockStateOut || status.State == StockStateLow {
+			low = append(low, status)
+		}
+	}
+
+	return low, nil
+}
+
+// GetReplenishment suggests how much of each product with a reorder level to
+// bring into its location, optionally only at one location. Sales are
+// averaged over salesDays and replenished stock should last coverDays; the
+// policy's periods are used when they are not positive. Daily sales are per
+// product, as orders do not record the location they sold from.
+func (s *ReorderService) GetReplenishment(location string, salesDays, coverDays int) ([]models.ReplenishmentSuggestion, error) {
+	if salesDays <= 0 {
+		salesDays = s.policy.SalesDays
+	}
+	if coverDays <= 0 {
+		coverDays = s.policy.CoverDays
+	}
+	if salesDays <= 0 || coverDays <= 0 {
+		return nil, errors.New("replenishment sales and cover periods must be positive")
+	}
+
+	statuses, err := s.reorderRepo.GetStatuses(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reorder levels: %w", err)
+	}
+
+	sold, err := s.reorderRepo.GetUnitsSold(time.Now().AddDate(0, 0, -salesDays))
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []models.ReplenishmentSuggestion{}
+	for _, status := range statuses {
+		if suggestion, ok := suggestReplenishment(status, sold[status.ProductID], salesDays, coverDays); ok {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	return suggestions, nil
+}
+
+// EvaluateAlerts notifies every product at a location whose stock has
+// crossed a reorder level since it was last notified. Each change is
+// notified once, even with several servers evaluating at the same time.
+func (s *ReorderService) EvaluateAlerts() error {
+	changes, err := s.reorderRepo.GetAlertChanges()
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		claimed, err := s.reorderRepo.SetAlertState(change.ProductID, change.Location, change.AlertState, change.State)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		s.notify(change)
+	}
+
+	return nil
+}
+
+// notify logs a stock alert and emails it to the policy's addresses
+func (s *ReorderService) notify(status models.ReorderStatus) {
+	subject := stockAlertSubject(status)
+	log.Printf("Stock alert: %s (%d on hand, minimum %d, maximum %d)", subject, status.Quantity, status.MinQuantity, status.MaxQuantity)
+
+	if len(s.policy.AlertEmails) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", subject)
+	fmt.Fprintf(&b, "Product:   %s", status.ProductName)
+	if status.SKU != "" {
+		fmt.Fprintf(&b, " (%s)", status.SKU)
+	}
+	fmt.Fprintf(&b, "\nLocation:  %s\n", status.Location)
+	fmt.Fprintf(&b, "On hand:   %d\n", status.Quantity)
+	fmt.Fprintf(&b, "Minimum:   %d\n", status.MinQuantity)
+	fmt.Fprintf(&b, "Maximum:   %d\n", status.MaxQuantity)
+	if status.State == StockStateOut || status.State == StockStateLow {
+		b.WriteString("\nSee GET /api/v1/inventory/replenishment for how much to reorder.\n")
+	}
+
+	for _, to := range s.policy.AlertEmails {
+		msg := mailer.Message{To: to, Subject: subject, Body: b.String()}
+		if err := s.mailer.Send(msg); err != nil {
+			log.Printf("Error sending stock alert to %s: %v", to, err)
+		}
+	}
+}
+
+// stockAlertSubject describes the state a product's stock has crossed into
+func stockAlertSubject(status models.ReorderStatus) string {
+	switch status.State {
+	case StockStateOut:
+		return fmt.Sprintf("Out of stock: %s at %s", status.ProductName, status.Location)
+	case StockStateLow:
+		return fmt.Sprintf("Low stock: %s at %s", status.ProductName, status.Location)
+	case StockStateOver:
+		return fmt.Sprintf("Overstocked: %s at %s", status.ProductName, status.Location)
+	default:
+		return fmt.Sprintf("Stock back to normal: %s at %s", status.ProductName, status.Location)
+	}
+}
+
+// suggestReplenishment works out how much of a product to bring into a
+// location. Stock still on order from suppliers counts as available. It is
+// needed when the available stock is at or below the minimum, or would not
+// last coverDays at the product's daily sales. The suggestion brings it up
+// to the maximum, or to coverDays of sales when that is more, rounded up to
+// a multiple of the reorder quantity.
+func suggestReplenishment(status models.ReorderStatus, unitsSold, salesDays, coverDays int) (models.ReplenishmentSuggestion, bool) {
+	daily := float64(unitsSold) / float64(salesDays)
+	cover := int(math.Ceil(daily * float64(coverDays)))
+
+	suggestion := models.ReplenishmentSuggestion{
+		ReorderStatus: status,
+		UnitsSold:     unitsSold,
+		DailySales:    math.Round(daily*100) / 100,
+	}
+
+	available := status.Quantity + status.OnOrder
+	if available > status.MinQuantity && available >= cover {
+		return suggestion, false
+	}
+
+	target := status.MaxQuantity
+	if cover > target {
+		target = cover
+	}
+
+	quantity := target - available
+	if lot := status.ReorderQuantity; lot > 0 {
+		if quantity < lot {
+			quantity = lot
+		} else if quantity%lot != 0 {
+			quantity += lot - quantity%lot
+		}
+	}
+	if quantity <= 0 {
+		return suggestion, false
+	}
+
+	suggestion.SuggestedQuantity = quantity
+	return suggestion, true
+}
+
+// reorderLevelID identifies the reorder level of a product at a location in
+// the audit log
+func reorderLevelID(productID uuid.UUID, location string) string {
+	return productID.String() + "@" + location
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"jatistore/internal/models"
+	"jatistore/internal/permissions"
+	"jatistore/internal/repository"
+)
+
+var (
+	// ErrInvalidRoleName is returned for a role name that is not lowercase
+	// letters, digits, underscores and hyphens
+	ErrInvalidRoleName = errors.New("role name must be 1 to 50 lowercase letters, digits, underscores or hyphens")
+	// ErrUnknownPermission is returned for a permission that does not exist
+	ErrUnknownPermission = errors.New("unknown permission")
+	// ErrSystemRole is returned when changing the admin role or deleting a
+	// system role
+	ErrSystemRole = errors.New("system role cannot be changed")
+	// ErrRoleInUse is returned when deleting a role that users or pending
+	// invites still have
+	ErrRoleInUse = errors.New("role is still assigned to users or invites")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+type RoleService struct {
+	roleRepo *repository.RoleRepository
+	audit    *AuditService
+}
+
+func NewRoleService(roleRepo *repository.RoleRepository, audit *AuditService) *RoleService {
+	return &RoleService{
+		roleRepo: roleRepo,
+		audit:    audit,
+	}
+}
+
+// SeedSystemRoles creates the system roles that do not exist yet, and gives
+// the admin role every permission, including ones added since it was made
+func (s *RoleService) SeedSystemRoles() error {
+	for _, role := range permissions.SystemRoles {
+		if err := s.roleRepo.SeedSystemRole(role, role.Name == permissions.Admin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPermissions returns every permission a role can have
+func (s *RoleService) GetPermissions() []models.Permission {
+	return permissions.All
+}
+
+func (s *RoleService) GetRoles() ([]models.Role, error) {
+	roles, err := s.roleRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (s *RoleService) GetRole(name string) (*models.Role, error) {
+	return s.roleRepo.GetByName(name)
+}
+
+// CreateRole creates a custom role with a set of permissions, all of which
+// actor must have
+func (s *RoleService) CreateRole(req *models.CreateRoleRequest, actor *models.User) (*models.Role, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, ErrInvalidRoleName
+	}
+
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGrantable(perms, actor); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.roleRepo.Exists(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("role already exists")
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: perms,
+	}
+	err = s.roleRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.roleRepo.CreateTx(tx, role); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntityRole, role.Name, nil, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// UpdateRole replaces the description and permissions of a role. The admin
+// role always has every permission and cannot be changed. Like accounts,
+// only someone with every permission the role has, before and after, can
+// change it.
+func (s *RoleService) UpdateRole(name string, req *models.UpdateRoleRequest, actor *models.User) (*models.Role, error) {
+	if name == permissions.Admin {
+		return nil, ErrSystemRole
+	}
+
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGrantable(perms, actor); err != nil {
+		return nil, err
+	}
+
+	var role *models.Role
+	err = s.roleRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		role, err = s.roleRepo.Lock(tx, name)
+		if err != nil {
+			return err
+		}
+		if err := checkGrantable(role.Permissions, actor); err != nil {
+			return err
+		}
+		before := *role
+
+		role.Permissions = perms
+		role.Description = req.Description
+
+		if err := s.roleRepo.UpdateTx(tx, role); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityRole, name, &before, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// SetTwoFactorRequirement sets whether users with a role must log in with a
+// second factor. Unlike its permissions, this can be set for the admin role
+// too.
+func (s *RoleService) SetTwoFactorRequirement(name string, req *models.SetTwoFactorRequirementRequest, actor *models.User) (*models.Role, error) {
+	var role *models.Role
+	err := s.roleRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		role, err = s.roleRepo.Lock(tx, name)
+		if err != nil {
+			return err
+		}
+		before := *role
+
+		if err := s.roleRepo.SetRequireTwoFactorTx(tx, role, req.Required); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityRole, name, &before, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// DeleteRole deletes a custom role that no user or pending invite has
+func (s *RoleService) DeleteRole(name string, actor *models.User) error {
+	return s.roleRepo.WithTx(func(tx *sql.Tx) error {
+		role, err := s.roleRepo.Lock(tx, name)
+		if err != nil {
+			return err
+		}
+		if role.IsSystem {
+			return ErrSystemRole
+		}
+
+		members, err := s.roleRepo.CountMembersTx(tx, name)
+		if err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrRoleInUse
+		}
+
+		if err := s.roleRepo.DeleteTx(tx, name); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityRole, name, role, nil)
+	})
+}
+
+// checkGrantable checks that by has every one of perms, so that nobody can
+// hand out more than they have themselves
+func checkGrantable(perms []string, by *models.User) error {
+	for _, permission := range perms {
+		if by == nil || !by.HasPermission(permission) {
+			return fmt.Errorf("%w: %s", ErrRoleNotGrantable, permission)
+		}
+	}
+	return nil
+}
+
+// normalizePermissions checks that every permission exists and returns them
+// sorted and without duplicates
+func normalizePermissions(perms []string) ([]string, error) {
+	seen := make(map[string]bool, len(perms))
+	normalized := []string{}
+	for _, permission := range perms {
+		if !permissions.Valid(permission) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownPermission, permission)
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			normalized = append(normalized, permission)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"jatistore/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for a refresh token that is unknown,
+	// expired or revoked, or whose account was deactivated
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token is presented
+	// after it was exchanged already. The session it belongs to is revoked,
+	// since either its owner or whoever stole it holds a newer token.
+	ErrRefreshTokenReused = errors.New("refresh token was already used, the session has been revoked")
+)
+
+// SessionPolicy decides how long the tokens of a login stay valid
+type SessionPolicy struct {
+	// AccessTTL is how long an access token is accepted
+	AccessTTL time.Duration
+	// RefreshTTL is how long a refresh token can be exchanged for a new
+	// access token. Every refresh starts a new refresh token, so a session
+	// ends after RefreshTTL without use.
+	RefreshTTL time.Duration
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token in the same session. Each refresh token works once; presenting one
+// again revokes the whole session.
+func (s *UserService) Refresh(req *models.RefreshRequest) (*models.LoginResponse, error) {
+	var response *models.LoginResponse
+	reused := false
+
+	err := s.tokenRepo.WithTx(func(tx *sql.Tx) error {
+		token, err := s.tokenRepo.LockRefreshTokenByHash(tx, hashToken(req.RefreshToken))
+		if err != nil {
+			return err
+		}
+		if token == nil || token.RevokedAt != nil || !time.Now().Before(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if token.UsedAt != nil {
+			reused = true
+			return s.tokenRepo.RevokeFamilyTx(tx, token.FamilyID)
+		}
+
+		user, err := s.userRepo.GetUserByID(token.UserID)
+		if err != nil || !user.IsActive {
+			return ErrInvalidRefreshToken
+		}
+
+		// A session from before the user's role required two-factor
+		// authentication ends until it is set up
+		if !user.TwoFactorEnabled {
+			required, err := s.roleRepo.RequiresTwoFactor(user.Role)
+			if err != nil {
+				return err
+			}
+			if required {
+				return ErrTwoFactorRequired
+			}
+		}
+
+		if err := s.tokenRepo.MarkRefreshTokenUsedTx(tx, token); err != nil {
+			return err
+		}
+
+		response, err = s.issueTokens(tx, user, token.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The revocation has to be committed, so reuse is reported only now
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+
+	return response, nil
+}
+
+// Logout ends the session the access token in claims belongs to, revoking
+// the token itself and the session's refresh tokens
+func (s *UserService) Logout(claims *models.Claims) error {
+	if err := s.tokenRepo.RevokeFamily(claims.SessionID); err != nil {
+		return err
+	}
+
+	// Revoking the session revokes its access tokens unless it was revoked
+	// before, so the current token is revoked on its own as well
+	jti, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return err
+	}
+	return s.tokenRepo.RevokeAccessToken(jti, claims.ExpiresAt.Time)
+}
+
+// LogoutAll ends every session of a user, on every device
+func (s *UserService) LogoutAll(userID uuid.UUID) error {
+	return s.tokenRepo.RevokeUser(userID)
+}
+
+// IsTokenRevoked reports whether the access token in claims was revoked
+func (s *UserService) IsTokenRevoked(claims *models.Claims) (bool, error) {
+	jti, err := uuid.Parse(claims.ID)
+	if err != nil {
+		// Every access token is issued with a jti, so one without it
+		// cannot be checked and is not accepted
+		return true, nil
+	}
+	return s.tokenRepo.IsAccessTokenRevoked(jti)
+}
+
+// PurgeExpiredTokens removes refresh tokens and revoked access tokens that
+// have expired, and the failed logins of usernames and IPs that stopped
+// failing
+func (s *UserService) PurgeExpiredTokens() error {
+	if err := s.tokenRepo.DeleteExpired(); err != nil {
+		return err
+	}
+	return s.guard.Purge()
+}
+
+// startSession issues the tokens of a new session for user
+func (s *UserService) startSession(user *models.User) (*models.LoginResponse, error) {
+	var response *models.LoginResponse
+
+	err := s.tokenRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		response, err = s.issueTokens(tx, user, uuid.New())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// issueTokens issues an access token and the refresh token that replaces it
+// for a session
+func (s *UserService) issueTokens(tx *sql.Tx, user *models.User, sessionID uuid.UUID) (*models.LoginResponse, error) {
+	accessToken, claims, err := s.generateJWTToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &models.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        sessionID,
+		AccessJTI:       uuid.MustParse(claims.ID),
+		AccessExpiresAt: claims.ExpiresAt.Time,
+		ExpiresAt:       time.Now().Add(s.sessions.RefreshTTL),
+	}
+	if err := s.tokenRepo.CreateRefreshTokenTx(tx, token, hashToken(refreshToken)); err != nil {
+		return nil, err
+	}
+
+	// Don't return the password
+	user.Password = ""
+
+	return &models.LoginResponse{
+		Token:            accessToken,
+		ExpiresAt:        claims.ExpiresAt.Time,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: token.ExpiresAt,
+		User:             *user,
+	}, nil
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"jatistore/internal/models"
+	"jatistore/internal/money"
+	"jatistore/internal/permissions"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Shift statuses
+const (
+	ShiftStatusOpen   = "open"
+	ShiftStatusClosed = "closed"
+)
+
+// Cash movement types
+const (
+	CashMovementIn  = "cash_in"
+	CashMovementOut = "cash_out"
+)
+
+var (
+	// ErrNoOpenShift is returned when taking money without an open shift
+	ErrNoOpenShift = errors.New("no open shift")
+	// ErrShiftAlreadyOpen is returned when opening a second shift
+	ErrShiftAlreadyOpen = errors.New("shift already open")
+	// ErrShiftAccessDenied is returned when a cashier looks at another cashier's shift
+	ErrShiftAccessDenied = errors.New("shift belongs to another user")
+	// ErrUnknownPaymentMethod is returned when closing a shift with an
+	// amount counted for something that is not a payment method
+	ErrUnknownPaymentMethod = errors.New("unknown payment method")
+)
+
+type ShiftService struct {
+	shiftRepo *repository.ShiftRepository
+	audit     *AuditService
+}
+
+func NewShiftService(shiftRepo *repository.ShiftRepository, audit *AuditService) *ShiftService {
+	return &ShiftService{
+		shiftRepo: shiftRepo,
+		audit:     audit,
+	}
+}
+
+// OpenShift opens a shift for user with the float put in the drawer
+func (s *ShiftService) OpenShift(user *models.User, req *models.OpenShiftRequest) (*models.Shift, error) {
+	shift := &models.Shift{
+		UserID:       user.ID,
+		Register:     req.Register,
+		Status:       ShiftStatusOpen,
+		OpeningFloat: req.OpeningFloat,
+		Notes:        req.Notes,
+	}
+
+	err := s.shiftRepo.WithTx(func(tx *sql.Tx) error {
+		open, err := s.shiftRepo.GetOpenByUserIDTx(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if open != nil {
+			return fmt.Errorf("%w: close shift %s first", ErrShiftAlreadyOpen, open.ID)
+		}
+
+		if err := s.shiftRepo.CreateTx(tx, shift); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionCreate, AuditEntityShift, shift.ID.String(), nil, auditShift{Shift: *shift})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shift: %w", err)
+	}
+
+	return shift, nil
+}
+
+// GetCurrentShift returns the open shift of user
+func (s *ShiftService) GetCurrentShift(user *models.User) (*models.Shift, error) {
+	shift, err := s.shiftRepo.GetOpenByUserID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shift: %w", err)
+	}
+	if shift == nil {
+		return nil, ErrNoOpenShift
+	}
+
+	return shift, nil
+}
+
+func (s *ShiftService) GetAllShifts() ([]*models.Shift, error) {
+	shifts, err := s.shiftRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shifts: %w", err)
+	}
+
+	return shifts, nil
+}
+
+// GetShift returns a shift. Cashiers can only see their own shifts.
+func (s *ShiftService) GetShift(id uuid.UUID, user *models.User) (*models.Shift, error) {
+	shift, err := s.shiftRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shift: %w", err)
+	}
+
+	if shift.UserID != user.ID && !canManageShifts(user) {
+		return nil, ErrShiftAccessDenied
+	}
+
+	return shift, nil
+}
+
+// RecordCashMovement records cash put into or taken out of the drawer of
+// user's open shift, such as petty cash or a safe drop
+func (s *ShiftService) RecordCashMovement(user *models.User, req *models.CreateCashMovementRequest) (*models.CashMovement, error) {
+	movement := &models.CashMovement{
+		Type:      req.Type,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+		CreatedBy: optionalUserID(user.ID),
+	}
+
+	err := s.shiftRepo.WithTx(func(tx *sql.Tx) error {
+		shift, err := s.shiftRepo.GetOpenByUserIDTx(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if shift == nil {
+			return ErrNoOpenShift
+		}
+
+		movement.ShiftID = shift.ID
+		if err := s.shiftRepo.CreateMovementTx(tx, movement); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionCreate, AuditEntityCashMovement, movement.ID.String(), nil, movement)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record cash movement: %w", err)
+	}
+
+	return movement, nil
+}
+
+// CloseShift closes user's open shift against the cash counted in the drawer
+// and returns its Z report. The shift is locked first, so payments still in
+// progress on it are included and no more can be taken on it.
+func (s *ShiftService) CloseShift(user *models.User, req *models.CloseShiftRequest) (*models.ShiftReport, error) {
+	// A misspelt method would be reported as a tender of its own, leaving
+	// the one meant looking short
+	for method := range req.CountedTenders {
+		if !IsPaymentMethod(method) {
+			return nil, fmt.Errorf("%w %q in counted tenders", ErrUnknownPaymentMethod, method)
+		}
+	}
+
+	var report *models.ShiftReport
+	err := s.shiftRepo.WithTx(func(tx *sql.Tx) error {
+		shift, err := s.shiftRepo.LockOpenByUserID(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if shift == nil {
+			return ErrNoOpenShift
+		}
+		before := auditShift{Shift: *shift}
+
+		report, err = s.buildReport(tx, shift)
+		if err != nil {
+			return err
+		}
+
+		counted := map[string]money.Amount{PaymentMethodCash: req.CountedCash}
+		for method, amount := range req.CountedTenders {
+			if method != PaymentMethodCash {
+				counted[method] = amount
+			}
+		}
+		countTenders(report, counted)
+
+		shift.ExpectedCash = &report.ExpectedCash
+		shift.CountedCash = report.CountedCash
+		shift.Variance = report.Variance
+		if req.Notes != "" {
+			shift.Notes = req.Notes
+		}
+
+		if err := s.shiftRepo.CloseTx(tx, shift); err != nil {
+			return err
+		}
+		report.Type = "Z"
+		report.Shift = *shift
+
+		if err := s.shiftRepo.CreateTenderCountsTx(tx, shift.ID, report.Tenders); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityShift, shift.ID.String(), before, auditShift{Shift: *shift, Tenders: report.Tenders})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to close shift: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetShiftReport returns the X report of an open shift or the Z report of a
+// closed one. Cashiers can only see reports of their own shifts.
+func (s *ShiftService) GetShiftReport(id uuid.UUID, user *models.User) (*models.ShiftReport, error) {
+	shift, err := s.GetShift(id, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *models.ShiftReport
+	err = s.shiftRepo.WithTx(func(tx *sql.Tx) error {
+		report, err = s.buildReport(tx, shift)
+		if err != nil {
+			return err
+		}
+
+		if shift.Status != ShiftStatusClosed {
+			report.Type = "X"
+			return nil
+		}
+
+		counted, err := s.shiftRepo.GetTenderCounts(tx, shift.ID)
+		if err != nil {
+			return err
+		}
+		countTenders(report, counted)
+		report.Type = "Z"
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shift report: %w", err)
+	}
+
+	return report, nil
+}
+
+// buildReport works out the takings of a shift and the cash that should be
+// in its drawer: the opening float, plus cash sales less the change given,
+// less cash refunds, plus cash put in, less cash taken out
+func (s *ShiftService) buildReport(tx *sql.Tx, shift *models.Shift) (*models.ShiftReport, error) {
+	report := &models.ShiftReport{Shift: *shift}
+
+	tenders, change, err := s.shiftRepo.GetTenderTotals(tx, shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	report.ChangeGiven = change
+
+	if report.Movements, err = s.shiftRepo.GetMovements(tx, shift.ID); err != nil {
+		return nil, err
+	}
+	for _, movement := range report.Movements {
+		if movement.Type == CashMovementIn {
+			report.CashIn += movement.Amount
+		} else {
+			report.CashOut += movement.Amount
+		}
+	}
+
+	// There is always a cash line, since the drawer holds the float
+	hasCash := false
+	for _, tender := range tenders {
+		if tender.PaymentMethod == PaymentMethodCash {
+			hasCash = true
+		}
+	}
+	if !hasCash {
+		tenders = append(tenders, models.ShiftTender{PaymentMethod: PaymentMethodCash})
+	}
+
+	for i := range tenders {
+		tender := &tenders[i]
+		tender.Expected = tender.Sales - tender.Refunds
+		if tender.PaymentMethod == PaymentMethodCash {
+			tender.Expected += shift.OpeningFloat + report.CashIn - report.CashOut
+			report.ExpectedCash = tender.Expected
+		}
+	}
+	report.Tenders = tenders
+
+	if report.RefundCount, report.RefundAmount, err = s.shiftRepo.GetRefundTotals(tx, shift.ID); err != nil {
+		return nil, err
+	}
+	if report.VoidCount, report.VoidAmount, err = s.shiftRepo.GetVoidTotals(tx, shift); err != nil {
+		return nil, err
+	}
+	if report.DiscountAmount, err = s.shiftRepo.GetDiscountTotal(tx, shift.ID); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// countTenders sets what was counted per payment method on a report and the
+// variance against what was expected. Counted methods that took nothing are
+// added.
+func countTenders(r *models.ShiftReport, counted map[string]money.Amount) {
+	for method := range counted {
+		found := false
+		for _, tender := range r.Tenders {
+			if tender.PaymentMethod == method {
+				found = true
+			}
+		}
+		if !found {
+			r.Tenders = append(r.Tenders, models.ShiftTender{PaymentMethod: method})
+		}
+	}
+	sort.Slice(r.Tenders, func(i, j int) bool {
+		return r.Tenders[i].PaymentMethod < r.Tenders[j].PaymentMethod
+	})
+
+	for i := range r.Tenders {
+		tender := &r.Tenders[i]
+		amount, ok := counted[tender.PaymentMethod]
+		if !ok {
+			continue
+		}
+
+		variance := amount - tender.Expected
+		tender.Counted = &amount
+		tender.Variance = &variance
+
+		if tender.PaymentMethod == PaymentMethodCash {
+			r.CountedCash = tender.Counted
+			r.Variance = tender.Variance
+		}
+	}
+}
+
+// canManageShifts reports whether user may see and report on every shift
+func canManageShifts(user *models.User) bool {
+	return user.HasPermission(permissions.ShiftManage)
+}
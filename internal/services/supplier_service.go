@@ -0,0 +1,175 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidSupplierName is returned for a supplier name that is empty
+	// or too long
+	ErrInvalidSupplierName = errors.New("supplier name must be 1 to 255 characters")
+	// ErrSupplierInUse is returned when deleting a supplier that has
+	// purchase orders. It can be deactivated instead.
+	ErrSupplierInUse = errors.New("supplier has purchase orders; deactivate it instead")
+)
+
+type SupplierService struct {
+	supplierRepo *repository.SupplierRepository
+	audit        *AuditService
+}
+
+func NewSupplierService(supplierRepo *repository.SupplierRepository, audit *AuditService) *SupplierService {
+	return &SupplierService{
+		supplierRepo: supplierRepo,
+		audit:        audit,
+	}
+}
+
+func (s *SupplierService) GetSuppliers() ([]models.Supplier, error) {
+	suppliers, err := s.supplierRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get suppliers: %w", err)
+	}
+
+	return suppliers, nil
+}
+
+func (s *SupplierService) GetSupplier(id uuid.UUID) (*models.Supplier, error) {
+	return s.supplierRepo.GetByID(id)
+}
+
+// CreateSupplier creates an active supplier
+func (s *SupplierService) CreateSupplier(req *models.CreateSupplierRequest, actor *models.User) (*models.Supplier, error) {
+	name, err := s.supplierName(req.Name, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	supplier := &models.Supplier{
+		Name:        name,
+		ContactName: req.ContactName,
+		Email:       req.Email,
+		Phone:       req.Phone,
+		Address:     req.Address,
+		Notes:       req.Notes,
+	}
+	err = s.supplierRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.supplierRepo.CreateTx(tx, supplier); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionCreate, AuditEntitySupplier, supplier.ID.String(), nil, supplier)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return supplier, nil
+}
+
+// UpdateSupplier replaces the details of a supplier and whether it is
+// active. An inactive supplier's purchase orders can still be received and
+// closed, but no new ones can be raised.
+func (s *SupplierService) UpdateSupplier(id uuid.UUID, req *models.UpdateSupplierRequest, actor *models.User) (*models.Supplier, error) {
+	name, err := s.supplierName(req.Name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var supplier *models.Supplier
+	err = s.supplierRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		supplier, err = s.supplierRepo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		before := *supplier
+
+		supplier.Name = name
+		supplier.ContactName = req.ContactName
+		supplier.Email = req.Email
+		supplier.Phone = req.Phone
+		supplier.Address = req.Address
+		supplier.Notes = req.Notes
+		supplier.IsActive = req.IsActive
+
+		if err := s.supplierRepo.UpdateTx(tx, supplier); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntitySupplier, supplier.ID.String(), &before, supplier)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return supplier, nil
+}
+
+// DeleteSupplier deletes a supplier that has no purchase orders. The
+// supplier is locked first, so that no purchase order can be raised with it
+// between counting them and deleting it.
+func (s *SupplierService) DeleteSupplier(id uuid.UUID, actor *models.User) error {
+	return s.supplierRepo.WithTx(func(tx *sql.Tx) error {
+		supplier, err := s.supplierRepo.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+
+		count, err := s.supplierRepo.CountPurchaseOrdersTx(tx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrSupplierInUse
+		}
+
+		if err := s.supplierRepo.DeleteTx(tx, id); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntitySupplier, id.String(), supplier, nil)
+	})
+}
+
+// GetSupplierCosts returns what a supplier charged for the goods received
+// from it, newest first, optionally only for one product
+func (s *SupplierService) GetSupplierCosts(id uuid.UUID, productID *uuid.UUID) ([]models.SupplierCost, error) {
+	if _, err := s.supplierRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+
+	costs, err := s.supplierRepo.GetCosts(id, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supplier costs: %w", err)
+	}
+
+	return costs, nil
+}
+
+// supplierName trims a supplier name and checks that it is valid and not
+// taken by a supplier other than exceptID
+func (s *SupplierService) supplierName(name string, exceptID uuid.UUID) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return "", ErrInvalidSupplierName
+	}
+
+	exists, err := s.supplierRepo.ExistsByName(name, exceptID)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", errors.New("supplier already exists")
+	}
+
+	return name, nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTaxRateOverlap is returned when a new rate would not start after the
+	// class's latest rate
+	ErrTaxRateOverlap = errors.New("tax rate overlaps an existing rate")
+	// ErrNoTaxRate is returned when a product's tax class has no rate in effect
+	ErrNoTaxRate = errors.New("no tax rate in effect")
+)
+
+type TaxService struct {
+	taxRepo *repository.TaxRepository
+}
+
+func NewTaxService(taxRepo *repository.TaxRepository) *TaxService {
+	return &TaxService{
+		taxRepo: taxRepo,
+	}
+}
+
+func (s *TaxService) CreateTaxClass(req *models.CreateTaxClassRequest) (*models.TaxClass, error) {
+	class := &models.TaxClass{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	if err := s.taxRepo.CreateClass(class); err != nil {
+		return nil, fmt.Errorf("failed to create tax class: %w", err)
+	}
+
+	return class, nil
+}
+
+func (s *TaxService) GetTaxClass(id uuid.UUID) (*models.TaxClass, error) {
+	class, err := s.taxRepo.GetClassByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tax class: %w", err)
+	}
+
+	return class, nil
+}
+
+func (s *TaxService) GetAllTaxClasses() ([]*models.TaxClass, error) {
+	classes, err := s.taxRepo.GetAllClasses()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tax classes: %w", err)
+	}
+
+	return classes, nil
+}
+
+func (s *TaxService) UpdateTaxClass(id uuid.UUID, req *models.UpdateTaxClassRequest) (*models.TaxClass, error) {
+	class, err := s.taxRepo.GetClassByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing tax class: %w", err)
+	}
+
+	class.Name = req.Name
+	class.Description = req.Description
+
+	if err := s.taxRepo.UpdateClass(class); err != nil {
+		return nil, fmt.Errorf("failed to update tax class: %w", err)
+	}
+
+	return class, nil
+}
+
+func (s *TaxService) DeleteTaxClass(id uuid.UUID) error {
+	if err := s.taxRepo.DeleteClass(id); err != nil {
+		return fmt.Errorf("failed to delete tax class: %w", err)
+	}
+
+	return nil
+}
+
+// AddTaxRate schedules a new rate for a tax class. The class's latest rate
+// stops being in effect when the new one starts, so rates never overlap and
+// orders placed before the change keep the rate they were taxed at.
+func (s *TaxService) AddTaxRate(classID uuid.UUID, req *models.CreateTaxRateRequest) (*models.TaxRate, error) {
+	if _, err := s.taxRepo.GetClassByID(classID); err != nil {
+		return nil, fmt.Errorf("failed to get tax class: %w", err)
+	}
+
+	rate := &models.TaxRate{
+		TaxClassID:    classID,
+		Rate:          req.Rate,
+		EffectiveFrom: time.Now(),
+	}
+	if req.EffectiveFrom != nil {
+		rate.EffectiveFrom = *req.EffectiveFrom
+	}
+
+	err := s.taxRepo.WithTx(func(tx *sql.Tx) error {
+		latest, err := s.taxRepo.GetLatestRateTx(tx, classID)
+		if err != nil {
+			return err
+		}
+
+		if latest != nil {
+			if !rate.EffectiveFrom.After(latest.EffectiveFrom) {
+				return fmt.Errorf("%w: the new rate must start after %s", ErrTaxRateOverlap, latest.EffectiveFrom.Format(time.RFC3339))
+			}
+			if latest.EffectiveTo == nil || latest.EffectiveTo.After(rate.EffectiveFrom) {
+				if err := s.taxRepo.EndRateTx(tx, latest.ID, rate.EffectiveFrom); err != nil {
+					return err
+				}
+			}
+		}
+
+		return s.taxRepo.CreateRateTx(tx, rate)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add tax rate: %w", err)
+	}
+
+	return rate, nil
+}
+
+// parseTaxClassID parses an optional tax class ID from a request and checks
+// that the class exists. An empty ID means no tax class.
+func parseTaxClassID(taxRepo *repository.TaxRepository, id string) (*uuid.UUID, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	classID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tax class ID: %w", err)
+	}
+
+	if _, err := taxRepo.GetClassByID(classID); err != nil {
+		return nil, err
+	}
+
+	return &classID, nil
+}
@@ -0,0 +1,476 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/totp"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTwoFactorRequired is returned when the user's role requires
+	// two-factor authentication, for refreshing a session without it or
+	// turning it off
+	ErrTwoFactorRequired = errors.New("two-factor authentication is required for this role")
+	// ErrTwoFactorEnabled is returned when setting up two-factor
+	// authentication for a user who has it already
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorNotEnrolled is returned when confirming, using or turning
+	// off two-factor authentication that was not set up
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not set up")
+	// ErrInvalidTwoFactorCode is returned for a wrong, reused or missing
+	// authenticator or recovery code
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	// ErrInvalidLoginChallenge is returned for a two-factor login token that
+	// is unknown, expired or has had too many wrong codes
+	ErrInvalidLoginChallenge = errors.New("invalid or expired two-factor login token, log in again")
+)
+
+const (
+	// maxTwoFactorAttempts is how many wrong codes a login challenge
+	// accepts before the login has to start over
+	maxTwoFactorAttempts = 5
+	// recoveryCodeCount is how many recovery codes a user gets
+	recoveryCodeCount = 10
+)
+
+// TwoFactorPolicy configures two-factor authentication
+type TwoFactorPolicy struct {
+	// Issuer names the account in authenticator apps
+	Issuer string
+	// ChallengeTTL is how long the second login step can be completed for
+	ChallengeTTL time.Duration
+}
+
+// EnrollTwoFactor starts setting up two-factor authentication for a user,
+// returning a new TOTP secret and its provisioning URI. It is turned on by
+// ConfirmTwoFactor with the first code from the authenticator app.
+func (s *UserService) EnrollTwoFactor(userID uuid.UUID, req *models.EnrollTwoFactorRequest) (*models.TwoFactorEnrollment, error) {
+	var enrollment *models.TwoFactorEnrollment
+
+	err := s.userRepo.WithTx(func(tx *sql.Tx) error {
+		user, err := s.userRepo.LockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if !s.userRepo.CheckPassword(user, req.CurrentPassword) {
+			return errors.New("current password is incorrect")
+		}
+
+		enrollment, err = s.enrollTwoFactor(tx, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return enrollment, nil
+}
+
+// ConfirmTwoFactor turns on two-factor authentication with a code from the
+// authenticator app and returns the user's recovery codes
+func (s *UserService) ConfirmTwoFactor(userID uuid.UUID, req *models.TwoFactorCodeRequest) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	var codes []string
+	err = s.userRepo.WithTx(func(tx *sql.Tx) error {
+		ok, err := s.checkTOTP(tx, user, req.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		if err := s.userRepo.EnableTOTPTx(tx, user.ID); err != nil {
+			return err
+		}
+
+		if codes, err = s.replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityUser, user.ID.String(),
+			auditTwoFactor{TwoFactorEnabled: false}, auditTwoFactor{TwoFactorEnabled: true, RecoveryCodesReplaced: true})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns off two-factor authentication for a user, given
+// their password and a code. Users whose role requires it cannot.
+func (s *UserService) DisableTwoFactor(userID uuid.UUID, req *models.DisableTwoFactorRequest) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if !s.userRepo.CheckPassword(user, req.CurrentPassword) {
+		return errors.New("current password is incorrect")
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnrolled
+	}
+
+	required, err := s.roleRepo.RequiresTwoFactor(user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+
+	return s.userRepo.WithTx(func(tx *sql.Tx) error {
+		ok, err := s.checkSecondFactor(tx, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		if err := s.tokenRepo.DeleteRecoveryCodesTx(tx, user.ID); err != nil {
+			return err
+		}
+		if err := s.userRepo.DisableTOTPTx(tx, user.ID); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityUser, user.ID.String(),
+			auditTwoFactor{TwoFactorEnabled: true}, auditTwoFactor{TwoFactorEnabled: false})
+	})
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes, given a code
+// from their authenticator app
+func (s *UserService) RegenerateRecoveryCodes(userID uuid.UUID, req *models.TwoFactorCodeRequest) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	var codes []string
+	err = s.userRepo.WithTx(func(tx *sql.Tx) error {
+		ok, err := s.checkTOTP(tx, user, req.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		if codes, err = s.replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityUser, user.ID.String(),
+			auditTwoFactor{TwoFactorEnabled: true}, auditTwoFactor{TwoFactorEnabled: true, RecoveryCodesReplaced: true})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// ResetTwoFactor turns off two-factor authentication for a user who lost
+// their authenticator and recovery codes, and ends their sessions. If their
+// role requires it, they set it up again at their next login. Like other
+// changes to an account, actor must have every permission of its role.
+func (s *UserService) ResetTwoFactor(userID uuid.UUID, actor *models.User) error {
+	return s.userRepo.WithTx(func(tx *sql.Tx) error {
+		user, err := s.userRepo.LockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkRole(user.Role, actor); err != nil {
+			return err
+		}
+
+		if err := s.tokenRepo.DeleteRecoveryCodesTx(tx, userID); err != nil {
+			return err
+		}
+		if err := s.userRepo.DisableTOTPTx(tx, userID); err != nil {
+			return err
+		}
+		if err := s.tokenRepo.RevokeUserTx(tx, userID); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityUser, userID.String(),
+			auditTwoFactor{TwoFactorEnabled: user.TwoFactorEnabled}, auditTwoFactor{TwoFactorEnabled: false})
+	})
+}
+
+// SetupLoginTwoFactor sets up two-factor authentication during a login that
+// requires it, for a user who has not set it up yet. The login is completed
+// by CompleteLogin with the first code from the authenticator app.
+func (s *UserService) SetupLoginTwoFactor(req *models.TwoFactorSetupRequest) (*models.TwoFactorEnrollment, error) {
+	var enrollment *models.TwoFactorEnrollment
+
+	err := s.tokenRepo.WithTx(func(tx *sql.Tx) error {
+		user, _, err := s.lockLoginChallenge(tx, req.TwoFactorToken)
+		if err != nil {
+			return err
+		}
+		if user.TwoFactorEnabled {
+			return ErrTwoFactorEnabled
+		}
+
+		enrollment, err = s.enrollTwoFactor(tx, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return enrollment, nil
+}
+
+// CompleteLogin is the second login step: it checks the code for the login
+// challenge and starts the session. A user setting up two-factor
+// authentication during the login has it turned on and gets their recovery
+// codes in the response. Wrong codes count as failed logins of the user, and
+// a user or IP that has to wait after failed logins cannot try a code
+// either.
+func (s *UserService) CompleteLogin(req *models.TwoFactorLoginRequest, ip string) (*models.LoginResponse, error) {
+	var response *models.LoginResponse
+	var failed *models.User
+	var attempt *LoginAttempt
+
+	err := s.tokenRepo.WithTx(func(tx *sql.Tx) error {
+		user, challenge, err := s.lockLoginChallenge(tx, req.TwoFactorToken)
+		if err != nil {
+			return err
+		}
+		if user.TOTPSecret == "" {
+			return ErrTwoFactorNotEnrolled
+		}
+
+		if attempt, err = s.guard.Attempt(user.Username, ip); err != nil {
+			return err
+		}
+
+		// Recovery codes only exist once two-factor authentication is on
+		enrolling := !user.TwoFactorEnabled
+		recoveryCode := req.RecoveryCode
+		if enrolling {
+			recoveryCode = ""
+		}
+
+		ok, err := s.checkSecondFactor(tx, user, req.Code, recoveryCode)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failed = user
+			return s.tokenRepo.RecordChallengeFailureTx(tx, challenge)
+		}
+
+		if err := s.tokenRepo.DeleteLoginChallengeTx(tx, challenge); err != nil {
+			return err
+		}
+
+		var codes []string
+		if enrolling {
+			if err := s.userRepo.EnableTOTPTx(tx, user.ID); err != nil {
+				return err
+			}
+			user.TwoFactorEnabled = true
+			if codes, err = s.replaceRecoveryCodes(tx, user.ID); err != nil {
+				return err
+			}
+			err = s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityUser, user.ID.String(),
+				auditTwoFactor{TwoFactorEnabled: false}, auditTwoFactor{TwoFactorEnabled: true, RecoveryCodesReplaced: true})
+			if err != nil {
+				return err
+			}
+		}
+
+		if response, err = s.issueTokens(tx, user, uuid.New()); err != nil {
+			return err
+		}
+		response.RecoveryCodes = codes
+		return nil
+	})
+	if err != nil {
+		if attempt != nil {
+			if err := s.guard.Release(attempt); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	// The failed attempt has to be committed, so it is reported only now
+	if failed != nil {
+		if err := s.guard.Fail(attempt, &failed.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	if err := s.guard.Succeed(attempt); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// loginChallenge returns the second login step for a user whose password
+// was checked, or nil when the user can log in without one
+func (s *UserService) loginChallenge(user *models.User) (*models.TwoFactorChallenge, error) {
+	required, err := s.roleRepo.RequiresTwoFactor(user.Role)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled && !required {
+		return nil, nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &models.LoginChallenge{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.twoFactor.ChallengeTTL),
+	}
+	if err := s.tokenRepo.CreateLoginChallenge(challenge, hashToken(token)); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorChallenge{
+		TwoFactorRequired: true,
+		SetupRequired:     !user.TwoFactorEnabled,
+		TwoFactorToken:    token,
+		ExpiresAt:         challenge.ExpiresAt,
+	}, nil
+}
+
+// lockLoginChallenge locks the login challenge of a two-factor login token
+// for the rest of tx and returns it with its user
+func (s *UserService) lockLoginChallenge(tx *sql.Tx, token string) (*models.User, *models.LoginChallenge, error) {
+	challenge, err := s.tokenRepo.LockLoginChallengeByHash(tx, hashToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge == nil || !time.Now().Before(challenge.ExpiresAt) || challenge.Attempts >= maxTwoFactorAttempts {
+		return nil, nil, ErrInvalidLoginChallenge
+	}
+
+	user, err := s.userRepo.GetUserByID(challenge.UserID)
+	if err != nil || !user.IsActive {
+		return nil, nil, ErrInvalidLoginChallenge
+	}
+
+	return user, challenge, nil
+}
+
+// enrollTwoFactor gives user a new TOTP secret inside tx, unless two-factor
+// authentication is on already
+func (s *UserService) enrollTwoFactor(tx *sql.Tx, user *models.User) (*models.TwoFactorEnrollment, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetTOTPSecretTx(tx, user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	err = s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityUser, user.ID.String(),
+		auditTwoFactor{TwoFactorEnabled: false}, auditTwoFactor{TwoFactorEnabled: false, SecretReplaced: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.URI(s.twoFactor.Issuer, user.Username, secret),
+	}, nil
+}
+
+// checkSecondFactor checks an authenticator code, or a recovery code when
+// one is given, using it up inside tx
+func (s *UserService) checkSecondFactor(tx *sql.Tx, user *models.User, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return s.tokenRepo.UseRecoveryCodeTx(tx, user.ID, hashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+	return s.checkTOTP(tx, user, code)
+}
+
+// checkTOTP checks an authenticator code inside tx. Each code is accepted
+// once.
+func (s *UserService) checkTOTP(tx *sql.Tx, user *models.User, code string) (bool, error) {
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+	return s.userRepo.UseTOTPStepTx(tx, user.ID, step)
+}
+
+// replaceRecoveryCodes gives a user new recovery codes inside tx and returns
+// them; only their hashes are stored
+func (s *UserService) replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+
+	if err := s.tokenRepo.ReplaceRecoveryCodesTx(tx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// newRecoveryCode returns a random recovery code such as "k3v9q-7mzrp"
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in a recovery code
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"jatistore/internal/models"
+	"jatistore/internal/permissions"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSetupUnavailable is returned by Setup once an admin exists or when
+	// there is no setup token
+	ErrSetupUnavailable = errors.New("setup is not available")
+	// ErrInvalidSetupToken is returned by Setup for a wrong setup token
+	ErrInvalidSetupToken = errors.New("invalid setup token")
+	// ErrInvalidInvite is returned for an invite token that is unknown,
+	// expired or already used
+	ErrInvalidInvite = errors.New("invalid or expired invite")
+	// ErrInvalidRole is returned for a role that does not exist
+	ErrInvalidRole = errors.New("role does not exist")
+	// ErrRoleNotGrantable is returned when giving an account a role with
+	// permissions that the user giving it does not have
+	ErrRoleNotGrantable = errors.New("role has permissions you do not have")
+)
+
+// SetupToken returns the token that creates the first admin account through
+// Setup, generating one when none is configured. It returns "" once an admin
+// exists. It is meant to be called once at startup, before requests are
+// served.
+func (s *UserService) SetupToken() (string, error) {
+	hasAdmin, err := s.userRepo.HasAdmin()
+	if err != nil {
+		return "", err
+	}
+	if hasAdmin {
+		return "", nil
+	}
+
+	if s.policy.SetupToken == "" {
+		if s.policy.SetupToken, err = newToken(); err != nil {
+			return "", err
+		}
+	}
+
+	return s.policy.SetupToken, nil
+}
+
+// Setup creates the first admin account. It needs the setup token and only
+// works while no admin exists, so the token cannot be used again.
+func (s *UserService) Setup(req *models.SetupRequest) (*models.User, error) {
+	if s.policy.SetupToken == "" {
+		return nil, ErrSetupUnavailable
+	}
+	if subtle.ConstantTimeCompare([]byte(req.SetupToken), []byte(s.policy.SetupToken)) != 1 {
+		return nil, ErrInvalidSetupToken
+	}
+
+	return s.createAdmin(req.Username, req.Email, req.Password, true)
+}
+
+// CreateAdmin creates an admin account without a setup token. It is used by
+// the create-admin command, which needs access to the server and database
+// anyway, and also works when admins exist already.
+func (s *UserService) CreateAdmin(req *models.RegisterRequest) (*models.User, error) {
+	return s.createAdmin(req.Username, req.Email, req.Password, false)
+}
+
+// createAdmin creates an admin account, refusing when onlyFirst is set
+// and an admin exists already
+func (s *UserService) createAdmin(username, email, password string, onlyFirst bool) (*models.User, error) {
+	user := &models.User{
+		Username: username,
+		Email:    email,
+		Password: password,
+		Role:     permissions.Admin,
+		IsActive: true,
+	}
+
+	err := s.userRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.userRepo.LockBootstrap(tx); err != nil {
+			return err
+		}
+
+		if onlyFirst {
+			hasAdmin, err := s.userRepo.HasAdminTx(tx)
+			if err != nil {
+				return err
+			}
+			if hasAdmin {
+				return fmt.Errorf("%w: an admin account exists already", ErrSetupUnavailable)
+			}
+		}
+
+		if err := s.checkAvailable(username, email); err != nil {
+			return err
+		}
+
+		if err := s.userRepo.CreateUserTx(tx, user); err != nil {
+			return err
+		}
+
+		// There is no user yet to make the change, so it is the system's
+		return s.audit.RecordTx(tx, nil, AuditActionCreate, AuditEntityUser, user.ID.String(), nil, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// CreateInvite invites a staff member by email. The invite replaces any
+// earlier invite for the same email that has not been accepted. The token
+// is only returned here.
+func (s *UserService) CreateInvite(req *models.CreateInviteRequest, invitedBy *models.User) (*models.Invite, error) {
+	if err := s.checkRole(req.Role, invitedBy); err != nil {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if existing, _ := s.userRepo.GetUserByEmail(email); existing != nil {
+		return nil, errors.New("email already exists")
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.inviteRepo.DeletePendingByEmail(email); err != nil {
+		return nil, err
+	}
+
+	invite := &models.Invite{
+		Email:     email,
+		Role:      req.Role,
+		InvitedBy: optionalUserID(invitedBy.ID),
+		ExpiresAt: time.Now().Add(s.policy.InviteTTL),
+	}
+	if err := s.inviteRepo.Create(invite, hashToken(token)); err != nil {
+		return nil, err
+	}
+
+	invite.Token = token
+	return invite, nil
+}
+
+// GetInvites returns every invite, newest first
+func (s *UserService) GetInvites() ([]models.Invite, error) {
+	return s.inviteRepo.GetAll()
+}
+
+// RevokeInvite deletes an invite that has not been accepted
+func (s *UserService) RevokeInvite(id uuid.UUID) error {
+	return s.inviteRepo.Delete(id)
+}
+
+// AcceptInvite redeems an invite token, creating the invited account with
+// the chosen username and password. Each invite can be redeemed once.
+func (s *UserService) AcceptInvite(req *models.AcceptInviteRequest) (*models.User, error) {
+	var user *models.User
+
+	err := s.inviteRepo.WithTx(func(tx *sql.Tx) error {
+		invite, err := s.inviteRepo.LockByTokenHash(tx, hashToken(req.Token))
+		if err != nil {
+			return err
+		}
+		if invite == nil || invite.AcceptedAt != nil || !time.Now().Before(invite.ExpiresAt) {
+			return ErrInvalidInvite
+		}
+
+		if err := s.checkAvailable(req.Username, invite.Email); err != nil {
+			return err
+		}
+
+		user = &models.User{
+			Username: req.Username,
+			Email:    invite.Email,
+			Password: req.Password,
+			Role:     invite.Role,
+			IsActive: true,
+		}
+		if err := s.userRepo.CreateUserTx(tx, user); err != nil {
+			return err
+		}
+
+		if err := s.inviteRepo.AcceptTx(tx, invite, user.ID); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionCreate, AuditEntityUser, user.ID.String(), nil, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// checkAvailable checks that no account uses the username or email
+func (s *UserService) checkAvailable(username, email string) error {
+	if existing, _ := s.userRepo.GetUserByUsername(username); existing != nil {
+		return errors.New("username already exists")
+	}
+	if existing, _ := s.userRepo.GetUserByEmail(email); existing != nil {
+		return errors.New("email already exists")
+	}
+	return nil
+}
+
+// checkRole checks that role exists and that by has every one of its
+// permissions, so that nobody can give an account more than they have
+// themselves
+func (s *UserService) checkRole(role string, by *models.User) error {
+	exists, err := s.roleRepo.Exists(role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrInvalidRole
+	}
+
+	perms, err := s.roleRepo.GetPermissions(role)
+	if err != nil {
+		return err
+	}
+	return checkGrantable(perms, by)
+}
@@ -1,29 +1,98 @@
 package services
 
 import (
+	"database/sql"
 	"errors"
-	"os"
 	"time"
 
+	"jatistore/internal/mailer"
 	"jatistore/internal/models"
 	"jatistore/internal/repository"
+	"jatistore/internal/signing"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrRegistrationDisabled is returned by Register when public
+	// registration is turned off
+	ErrRegistrationDisabled = errors.New("public registration is disabled")
+	// ErrRoleNotAllowed is returned when public registration asks for a
+	// staff role
+	ErrRoleNotAllowed = errors.New("public registration can only create user accounts")
+	// ErrInvalidPassword is returned for a password that does not follow the
+	// password policy
+	ErrInvalidPassword = repository.ErrInvalidPassword
+)
+
+// RegistrationPolicy decides how accounts can be created
+type RegistrationPolicy struct {
+	// AllowPublic lets anyone register an account with the "user" role.
+	// Staff accounts are only created through invites.
+	AllowPublic bool
+	// SetupToken creates the first admin account while there is none
+	SetupToken string
+	// InviteTTL is how long an invite can be redeemed for
+	InviteTTL time.Duration
+}
+
 // UserService handles business logic for user operations
 type UserService struct {
-	userRepo *repository.UserRepository
+	userRepo   *repository.UserRepository
+	inviteRepo *repository.InviteRepository
+	tokenRepo  *repository.TokenRepository
+	roleRepo   *repository.RoleRepository
+	keys       *signing.KeySet
+	guard      *LoginGuard
+	mailer     mailer.Mailer
+	audit      *AuditService
+	policy     RegistrationPolicy
+	sessions   SessionPolicy
+	twoFactor  TwoFactorPolicy
+	resets     PasswordResetPolicy
 }
 
 // NewUserService creates a new UserService instance
-func NewUserService(userRepo *repository.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+func NewUserService(
+	userRepo *repository.UserRepository,
+	inviteRepo *repository.InviteRepository,
+	tokenRepo *repository.TokenRepository,
+	roleRepo *repository.RoleRepository,
+	keys *signing.KeySet,
+	guard *LoginGuard,
+	mailer mailer.Mailer,
+	audit *AuditService,
+	policy RegistrationPolicy,
+	sessions SessionPolicy,
+	twoFactor TwoFactorPolicy,
+	resets PasswordResetPolicy,
+) *UserService {
+	return &UserService{
+		userRepo:   userRepo,
+		inviteRepo: inviteRepo,
+		tokenRepo:  tokenRepo,
+		roleRepo:   roleRepo,
+		keys:       keys,
+		guard:      guard,
+		mailer:     mailer,
+		audit:      audit,
+		policy:     policy,
+		sessions:   sessions,
+		twoFactor:  twoFactor,
+		resets:     resets,
+	}
 }
 
-// Register creates a new user account
+// Register creates a new user account through public registration
 func (s *UserService) Register(req *models.RegisterRequest) (*models.User, error) {
+	if !s.policy.AllowPublic {
+		return nil, ErrRegistrationDisabled
+	}
+	if req.Role != "" && req.Role != "user" {
+		return nil, ErrRoleNotAllowed
+	}
+
 	// Check if username already exists
 	existingUser, _ := s.userRepo.GetUserByUsername(req.Username)
 	if existingUser != nil {
@@ -40,51 +109,74 @@ func (s *UserService) Register(req *models.RegisterRequest) (*models.User, error
 		Username: req.Username,
 		Email:    req.Email,
 		Password: req.Password,
-		Role:     req.Role,
+		Role:     "user",
 		IsActive: true,
 	}
 
-	err := s.userRepo.CreateUser(user)
+	err := s.userRepo.WithTx(func(tx *sql.Tx) error {
+		if err := s.userRepo.CreateUserTx(tx, user); err != nil {
+			return err
+		}
+
+		// Don't return the password
+		user.Password = ""
+
+		return s.audit.RecordTx(tx, user, AuditActionCreate, AuditEntityUser, user.ID.String(), nil, user)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Don't return the password
-	user.Password = ""
 	return user, nil
 }
 
-// Login authenticates a user and returns a JWT token
-func (s *UserService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
+// Login authenticates a user and starts a session, returning a short-lived
+// JWT access token and a refresh token. Users with two-factor authentication,
+// or whose role requires it, get a challenge instead, which CompleteLogin
+// turns into a session. Failed logins are throttled per username and per
+// client IP.
+func (s *UserService) Login(req *models.LoginRequest, ip string) (*models.LoginResponse, *models.TwoFactorChallenge, error) {
+	attempt, err := s.guard.Attempt(req.Username, ip)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Get user by username
 	user, err := s.userRepo.GetUserByUsername(req.Username)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		if err := s.guard.Fail(attempt, nil); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("invalid credentials")
 	}
 
 	// Check if user is active
 	if !user.IsActive {
-		return nil, errors.New("account is deactivated")
+		if err := s.guard.Release(attempt); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("account is deactivated")
 	}
 
 	// Verify password
 	if !s.userRepo.CheckPassword(user, req.Password) {
-		return nil, errors.New("invalid credentials")
+		if err := s.guard.Fail(attempt, &user.ID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("invalid credentials")
 	}
 
-	// Generate JWT token
-	token, err := s.generateJWTToken(user)
-	if err != nil {
-		return nil, err
+	if err := s.guard.Succeed(attempt); err != nil {
+		return nil, nil, err
 	}
 
-	// Don't return the password
-	user.Password = ""
+	challenge, err := s.loginChallenge(user)
+	if err != nil || challenge != nil {
+		return nil, challenge, err
+	}
 
-	return &models.LoginResponse{
-		Token: token,
-		User:  *user,
-	}, nil
+	response, err := s.startSession(user)
+	return response, nil, err
 }
 
 // GetUserByID retrieves a user by ID
@@ -99,40 +191,74 @@ func (s *UserService) GetUserByID(id uuid.UUID) (*models.User, error) {
 	return user, nil
 }
 
+// GetPermissions returns the permissions granted by a role
+func (s *UserService) GetPermissions(role string) ([]string, error) {
+	return s.roleRepo.GetPermissions(role)
+}
+
 // GetAllUsers retrieves all users
 func (s *UserService) GetAllUsers() ([]models.User, error) {
 	return s.userRepo.GetAllUsers()
 }
 
 // UpdateUser updates a user
-func (s *UserService) UpdateUser(id uuid.UUID, req *models.UpdateUserRequest) (*models.User, error) {
-	user, err := s.userRepo.GetUserByID(id)
-	if err != nil {
-		return nil, err
-	}
+func (s *UserService) UpdateUser(id uuid.UUID, req *models.UpdateUserRequest, actor *models.User) (*models.User, error) {
+	var user *models.User
+	err := s.userRepo.WithTx(func(tx *sql.Tx) error {
+		var err error
+		user, err = s.userRepo.LockUser(tx, id)
+		if err != nil {
+			return err
+		}
+		before := *user
+
+		// Check if username is being changed and if it already exists
+		if req.Username != user.Username {
+			existingUser, _ := s.userRepo.GetUserByUsername(req.Username)
+			if existingUser != nil {
+				return errors.New("username already exists")
+			}
+		}
 
-	// Check if username is being changed and if it already exists
-	if req.Username != user.Username {
-		existingUser, _ := s.userRepo.GetUserByUsername(req.Username)
-		if existingUser != nil {
-			return nil, errors.New("username already exists")
+		// Check if email is being changed and if it already exists
+		if req.Email != user.Email {
+			existingUser, _ := s.userRepo.GetUserByEmail(req.Email)
+			if existingUser != nil {
+				return errors.New("email already exists")
+			}
 		}
-	}
 
-	// Check if email is being changed and if it already exists
-	if req.Email != user.Email {
-		existingUser, _ := s.userRepo.GetUserByEmail(req.Email)
-		if existingUser != nil {
-			return nil, errors.New("email already exists")
+		// Nobody can change an account that can do more than they can, or
+		// give it a role that can
+		if actor == nil || actor.ID != user.ID {
+			if err := s.checkRole(user.Role, actor); err != nil {
+				return err
+			}
 		}
-	}
+		if req.Role != user.Role {
+			if err := s.checkRole(req.Role, actor); err != nil {
+				return err
+			}
+		}
+
+		user.Username = req.Username
+		user.Email = req.Email
+		user.Role = req.Role
+		user.IsActive = req.IsActive
 
-	user.Username = req.Username
-	user.Email = req.Email
-	user.Role = req.Role
-	user.IsActive = req.IsActive
+		if err := s.userRepo.UpdateUserTx(tx, user); err != nil {
+			return err
+		}
+
+		// A deactivated account is logged out everywhere
+		if !user.IsActive {
+			if err := s.tokenRepo.RevokeUserTx(tx, user.ID); err != nil {
+				return err
+			}
+		}
 
-	err = s.userRepo.UpdateUser(user)
+		return s.audit.RecordTx(tx, actor, AuditActionUpdate, AuditEntityUser, id.String(), &before, user)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -144,71 +270,103 @@ func (s *UserService) UpdateUser(id uuid.UUID, req *models.UpdateUserRequest) (*
 
 // ChangePassword changes a user's password
 func (s *UserService) ChangePassword(userID uuid.UUID, req *models.ChangePasswordRequest) error {
-	user, err := s.userRepo.GetUserByID(userID)
-	if err != nil {
-		return err
-	}
+	return s.userRepo.WithTx(func(tx *sql.Tx) error {
+		user, err := s.userRepo.LockUser(tx, userID)
+		if err != nil {
+			return err
+		}
 
-	// Verify current password
-	if !s.userRepo.CheckPassword(user, req.CurrentPassword) {
-		return errors.New("current password is incorrect")
-	}
+		// Verify current password
+		if !s.userRepo.CheckPassword(user, req.CurrentPassword) {
+			return errors.New("current password is incorrect")
+		}
 
-	// Update password
-	return s.userRepo.UpdatePassword(userID, req.NewPassword)
+		// Update password
+		if err := s.userRepo.UpdatePasswordTx(tx, userID, req.NewPassword); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityUser, userID.String(),
+			auditPasswordChange{PasswordChanged: false}, auditPasswordChange{PasswordChanged: true})
+	})
 }
 
-// DeleteUser deletes a user
-func (s *UserService) DeleteUser(id uuid.UUID) error {
-	return s.userRepo.DeleteUser(id)
+// SetPIN sets the PIN the user approves actions at the till with. The
+// current password is required, as for changing the password.
+func (s *UserService) SetPIN(userID uuid.UUID, req *models.SetPINRequest) error {
+	return s.userRepo.WithTx(func(tx *sql.Tx) error {
+		user, err := s.userRepo.LockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if !s.userRepo.CheckPassword(user, req.CurrentPassword) {
+			return errors.New("current password is incorrect")
+		}
+
+		if err := s.userRepo.UpdatePINTx(tx, userID, req.PIN); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, user, AuditActionUpdate, AuditEntityUser, userID.String(),
+			auditPINChange{PINChanged: false}, auditPINChange{PINChanged: true})
+	})
 }
 
-// generateJWTToken generates a JWT token for the user
-func (s *UserService) generateJWTToken(user *models.User) (string, error) {
-	// Get JWT secret from environment variable
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-secret-key" // Default secret for development
-	}
+// DeleteUser deletes a user
+func (s *UserService) DeleteUser(id uuid.UUID, actor *models.User) error {
+	return s.userRepo.WithTx(func(tx *sql.Tx) error {
+		user, err := s.userRepo.LockUser(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Nobody can delete an account that can do more than they can
+		if err := s.checkRole(user.Role, actor); err != nil {
+			return err
+		}
+
+		if err := s.userRepo.DeleteUserTx(tx, id); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, actor, AuditActionDelete, AuditEntityUser, id.String(), user, nil)
+	})
+}
 
+// generateJWTToken generates a JWT access token for the user in a session.
+// Each token gets its own ID (jti) so that it can be revoked.
+func (s *UserService) generateJWTToken(user *models.User, sessionID uuid.UUID) (string, *models.Claims, error) {
 	// Create claims
+	now := time.Now()
 	claims := &models.Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires in 24 hours
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(),
+			Issuer:    s.keys.Issuer(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.sessions.AccessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign token
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	// Sign token with the active key
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return tokenString, nil
+	return tokenString, claims, nil
 }
 
 // ValidateToken validates a JWT token and returns the claims
 func (s *UserService) ValidateToken(tokenString string) (*models.Claims, error) {
-	// Get JWT secret from environment variable
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-secret-key" // Default secret for development
-	}
-
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
-
+	// Parse token with the key named by its kid
+	token, err := s.keys.Parse(tokenString, &models.Claims{})
 	if err != nil {
 		return nil, err
 	}
@@ -220,3 +378,8 @@ func (s *UserService) ValidateToken(tokenString string) (*models.Claims, error)
 
 	return nil, errors.New("invalid token")
 }
+
+// JWKS returns the public keys tokens can be verified with
+func (s *UserService) JWKS() signing.JWKS {
+	return s.keys.JWKS()
+}
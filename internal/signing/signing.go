@@ -0,0 +1,262 @@
+// Package signing signs and verifies JWTs with a set of keys identified by
+// kid. Tokens are signed with RS256 or EdDSA by the active key and verified
+// with any key of the set, so that keys can be rotated without logging
+// everyone out. The public keys are published as a JWK Set for other services.
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms of asymmetric keys
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 3072
+
+var (
+	ErrNoKeys           = errors.New("no signing keys found")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrUnsupportedKey   = errors.New("unsupported key type, keys must be RSA or Ed25519")
+	ErrUnsupportedAlg   = errors.New("unsupported algorithm, must be RS256 or EdDSA")
+	ErrAmbiguousSigning = errors.New("more than one signing key, choose the active one with JWT_SIGNING_KEY")
+)
+
+// defaultSecrets are the example secrets that shipped with jatistore and
+// must never sign tokens in production
+var defaultSecrets = []string{"", "your-secret-key", "your-secret-key-here"}
+
+// Key is a key tokens are signed or verified with
+type Key struct {
+	ID     string
+	method jwt.SigningMethod
+	// private signs tokens; it is an RSA or Ed25519 private key, or the
+	// secret of an HS256 key
+	private any
+	// public verifies tokens
+	public any
+}
+
+// KeySet holds the keys tokens are verified with and the one they are
+// signed with
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+	issuer string
+}
+
+// LoadDir loads the PEM private keys in dir, one key per file named
+// "<kid>.pem". activeID picks the key that signs tokens and may be empty
+// when dir holds a single key; the other keys only verify tokens.
+func LoadDir(dir, activeID, issuer string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	set := &KeySet{keys: make(map[string]*Key), issuer: issuer}
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return nil, err
+		}
+		set.keys[key.ID] = key
+	}
+
+	switch {
+	case len(set.keys) == 0:
+		return nil, fmt.Errorf("%w in %s", ErrNoKeys, dir)
+	case activeID != "":
+		if set.active = set.keys[activeID]; set.active == nil {
+			return nil, fmt.Errorf("%w %q in %s", ErrUnknownKey, activeID, dir)
+		}
+	case len(set.keys) == 1:
+		for _, key := range set.keys {
+			set.active = key
+		}
+	default:
+		return nil, ErrAmbiguousSigning
+	}
+
+	return set, nil
+}
+
+// NewSecret returns a key set that signs tokens with HS256 and a shared
+// secret. Such tokens can only be verified by holders of the secret, so
+// nothing is published in the JWK Set.
+func NewSecret(secret, issuer string) *KeySet {
+	key := &Key{ID: "", method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)}
+	return &KeySet{active: key, keys: map[string]*Key{"": key}, issuer: issuer}
+}
+
+// NewEphemeral returns a key set with a fresh Ed25519 key that lives as long
+// as the process. Tokens stop verifying when the server restarts.
+func NewEphemeral(issuer string) (*KeySet, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	key := &Key{ID: "ephemeral", method: jwt.SigningMethodEdDSA, private: private, public: public}
+	return &KeySet{active: key, keys: map[string]*Key{key.ID: key}, issuer: issuer}, nil
+}
+
+// IsDefaultSecret reports whether secret is empty or one of the example
+// secrets
+func IsDefaultSecret(secret string) bool {
+	for _, s := range defaultSecrets {
+		if secret == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveKeyID returns the kid of the key that signs tokens
+func (s *KeySet) ActiveKeyID() string {
+	return s.active.ID
+}
+
+// Issuer returns the iss claim of the tokens signed with the set
+func (s *KeySet) Issuer() string {
+	return s.issuer
+}
+
+// Sign signs claims with the active key, naming it in the kid header
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.active.method, claims)
+	if s.active.ID != "" {
+		token.Header["kid"] = s.active.ID
+	}
+	return token.SignedString(s.active.private)
+}
+
+// Parse verifies a token with the key its kid header names and decodes it
+// into claims. Only the algorithm of that key is accepted.
+func (s *KeySet) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	options := []jwt.ParserOption{}
+	if s.issuer != "" {
+		options = append(options, jwt.WithIssuer(s.issuer))
+	}
+
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := s.keys[kid]
+		if key == nil {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.public, nil
+	}, options...)
+}
+
+// GenerateKeyFile writes a new private key for alg to dir as "<kid>.pem" and
+// returns its path. The key only signs tokens once it is made the active key.
+func GenerateKeyFile(dir, alg, kid string) (string, error) {
+	var private any
+	switch alg {
+	case AlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		private = key
+	case AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return "", err
+		}
+		private = key
+	default:
+		return "", ErrUnsupportedAlg
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// loadKey reads a PKCS #8 or PKCS #1 PEM private key, taking its kid from
+// the file name
+func loadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM key found", path)
+	}
+
+	var private any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem"), private: private}
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key.method = jwt.SigningMethodRS256
+		key.public = &private.PublicKey
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.public = private.Public()
+	default:
+		return nil, fmt.Errorf("%s: %w", path, ErrUnsupportedKey)
+	}
+
+	return key, nil
+}
+
+// publicKey returns the public key of an asymmetric key
+func (k *Key) publicKey() (crypto.PublicKey, bool) {
+	switch k.public.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return k.public, true
+	}
+	return nil, false
+}
@@ -14,19 +14,29 @@ package main
 // @in header
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key of another system, created by an admin with POST /auth/api-keys.
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"jatistore/internal/config"
 	"jatistore/internal/database"
 	"jatistore/internal/handlers"
+	"jatistore/internal/mailer"
 	"jatistore/internal/middleware"
+	"jatistore/internal/money"
+	"jatistore/internal/printer"
 	"jatistore/internal/repository"
 	"jatistore/internal/router"
 	"jatistore/internal/services"
+	"jatistore/internal/signing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/joho/godotenv"
@@ -43,6 +53,19 @@ func main() {
 	// Initialize configuration
 	cfg := config.New()
 
+	// Commands that need no database run before connecting to it
+	if len(os.Args) > 1 && os.Args[1] == "generate-key" {
+		if err := generateKey(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	keys, err := loadSigningKeys(cfg)
+	if err != nil {
+		log.Fatal("Invalid JWT signing configuration: ", err)
+	}
+
 	// Dynamically set Swagger host
 	setSwaggerHost(cfg)
 
@@ -60,23 +83,177 @@ func main() {
 		log.Fatal("Failed to create database tables:", err)
 	}
 
+	// Only backorder-enabled stores may store negative stock
+	if err := db.SetStockFloor(cfg.AllowBackorder); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("Error closing database connection: %v", closeErr)
+		}
+		log.Fatal("Failed to apply the stock floor:", err)
+	}
+
+	currency, err := money.LookupCurrency(cfg.Currency)
+	if err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("Error closing database connection: %v", closeErr)
+		}
+		log.Fatal("Invalid currency configuration:", err)
+	}
+
+	renderer, err := printer.NewRenderer(printer.Profile{
+		Name:    cfg.StoreName,
+		Address: cfg.StoreAddress,
+		Phone:   cfg.StorePhone,
+		TaxID:   cfg.StoreTaxID,
+		Footer:  cfg.ReceiptFooter,
+		Width:   cfg.ReceiptWidth,
+	}, currency, cfg.ReceiptTemplate)
+	if err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("Error closing database connection: %v", closeErr)
+		}
+		log.Fatal("Invalid receipt configuration:", err)
+	}
+
+	mail, err := newMailer(cfg)
+	if err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("Error closing database connection: %v", closeErr)
+		}
+		log.Fatal("Invalid mail configuration:", err)
+	}
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
+	inviteRepo := repository.NewInviteRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
 	productRepo := repository.NewProductRepository(db)
 	categoryRepo := repository.NewCategoryRepository(db)
 	inventoryRepo := repository.NewInventoryRepository(db)
+	locationRepo := repository.NewLocationRepository(db)
+	transferRepo := repository.NewTransferRepository(db)
+	reorderRepo := repository.NewReorderRepository(db)
+	supplierRepo := repository.NewSupplierRepository(db)
+	purchaseOrderRepo := repository.NewPurchaseOrderRepository(db)
 	customerRepo := repository.NewCustomerRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
 	paymentRepo := repository.NewPaymentRepository(db)
 	receiptRepo := repository.NewReceiptRepository(db)
+	refundRepo := repository.NewRefundRepository(db)
+	taxRepo := repository.NewTaxRepository(db)
+	promotionRepo := repository.NewPromotionRepository(db)
+	couponRepo := repository.NewCouponRepository(db)
+	shiftRepo := repository.NewShiftRepository(db)
+	securityRepo := repository.NewSecurityRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
 
 	// Initialize services
-	userService := services.NewUserService(userRepo)
-	productService := services.NewProductService(productRepo)
-	categoryService := services.NewCategoryService(categoryRepo)
-	inventoryService := services.NewInventoryService(inventoryRepo)
-	customerService := services.NewCustomerService(customerRepo)
-	orderService := services.NewOrderService(orderRepo, productRepo, customerRepo, paymentRepo, receiptRepo)
+	auditService := services.NewAuditService(auditRepo)
+	loginGuard := services.NewLoginGuard(securityRepo, userRepo, services.LoginPolicy{
+		MaxFailures:     cfg.LoginMaxFailures,
+		MaxIPFailures:   cfg.LoginIPMaxFailures,
+		MaxPINFailures:  cfg.PINMaxFailures,
+		LockoutDuration: cfg.LoginLockoutDuration,
+		BackoffBase:     cfg.LoginBackoffBase,
+		BackoffMax:      cfg.LoginBackoffMax,
+		FailureWindow:   cfg.LoginFailureWindow,
+	})
+	userService := services.NewUserService(userRepo, inviteRepo, tokenRepo, roleRepo, keys, loginGuard, mail, auditService,
+		services.RegistrationPolicy{
+			AllowPublic: cfg.AllowPublicRegistration,
+			SetupToken:  cfg.SetupToken,
+			InviteTTL:   cfg.InviteTTL,
+		},
+		services.SessionPolicy{
+			AccessTTL:  cfg.AccessTokenTTL,
+			RefreshTTL: cfg.RefreshTokenTTL,
+		},
+		services.TwoFactorPolicy{
+			Issuer:       cfg.TOTPIssuer,
+			ChallengeTTL: cfg.TwoFactorChallengeTTL,
+		},
+		services.PasswordResetPolicy{
+			TokenTTL: cfg.PasswordResetTTL,
+			URL:      cfg.PasswordResetURL,
+		},
+	)
+	productService := services.NewProductService(productRepo, taxRepo, auditService)
+	categoryService := services.NewCategoryService(categoryRepo, taxRepo, auditService)
+	inventoryService := services.NewInventoryService(inventoryRepo, locationRepo, transferRepo, auditService)
+	locationService := services.NewLocationService(locationRepo, auditService)
+	reorderService := services.NewReorderService(reorderRepo, locationRepo, auditService, mail, services.ReorderPolicy{
+		AlertEmails: cfg.StockAlertEmails,
+		SalesDays:   cfg.ReplenishmentSalesDays,
+		CoverDays:   cfg.ReplenishmentCoverDays,
+	})
+	supplierService := services.NewSupplierService(supplierRepo, auditService)
+	purchaseOrderService := services.NewPurchaseOrderService(purchaseOrderRepo, supplierRepo, inventoryRepo, locationRepo, auditService)
+	customerService := services.NewCustomerService(customerRepo, auditService)
+	taxService := services.NewTaxService(taxRepo)
+	promotionService := services.NewPromotionService(promotionRepo)
+	couponService := services.NewCouponService(couponRepo)
+	shiftService := services.NewShiftService(shiftRepo, auditService)
+	roleService := services.NewRoleService(roleRepo, auditService)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, securityRepo)
+	approvalService := services.NewApprovalService(userRepo, roleRepo, tokenRepo, loginGuard, services.ApprovalPolicy{
+		TokenTTL: cfg.ApprovalTokenTTL,
+	})
+	receiptService := services.NewReceiptService(receiptRepo, orderRepo, paymentRepo, refundRepo, promotionRepo, couponRepo, userRepo, renderer)
+	orderService := services.NewOrderService(orderRepo, productRepo, customerRepo, paymentRepo, receiptRepo, inventoryRepo, locationRepo, refundRepo, taxRepo, promotionRepo, couponRepo, shiftRepo, approvalService, auditService,
+		services.NewPricer(currency, cfg.PricesIncludeTax),
+		services.StockPolicy{
+			Location:       cfg.SalesLocation,
+			AllowBackorder: cfg.AllowBackorder,
+		},
+		services.RefundPolicy{
+			ApprovalThreshold: cfg.RefundApprovalThreshold,
+		},
+		services.DiscountPolicy{
+			ApprovalThreshold: cfg.DiscountApprovalThreshold,
+		},
+	)
+
+	// Create the system roles on first start and give admin every permission
+	if err := roleService.SeedSystemRoles(); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("Error closing database connection: %v", closeErr)
+		}
+		log.Fatal("Failed to seed roles:", err)
+	}
+
+	// Run a command such as create-admin instead of the server
+	if len(os.Args) > 1 {
+		err := runCommand(userService, inventoryService, os.Args[1:])
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("Error closing database connection: %v", closeErr)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Until there is an admin, the first one is created with the setup token
+	setupToken, err := userService.SetupToken()
+	if err != nil {
+		log.Printf("Error checking for admin accounts: %v", err)
+	} else if setupToken != "" {
+		log.Printf("No admin account exists. Create one with POST /api/v1/auth/setup using setup token %s, or run `%s create-admin`", setupToken, os.Args[0])
+	}
+
+	// Expired refresh tokens, revoked access tokens and stale login
+	// throttles are cleaned up hourly
+	go purgeExpiredTokens(userService)
+
+	// Stock alerts go out when stock crosses a reorder level. The database
+	// announces every stock change; reorder levels are also checked at
+	// STOCK_ALERT_INTERVAL in case an announcement was missed.
+	stockChanges, err := database.Listen(cfg.DatabaseURL, "stock_changed")
+	if err != nil {
+		log.Printf("Error listening for stock changes, checking stock alerts every %s only: %v", cfg.StockAlertInterval, err)
+	}
+	go watchStockAlerts(reorderService, stockChanges, cfg.StockAlertInterval)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userService)
@@ -85,16 +262,37 @@ func main() {
 	inventoryHandler := handlers.NewInventoryHandler(inventoryService)
 	customerHandler := handlers.NewCustomerHandler(customerService)
 	orderHandler := handlers.NewOrderHandler(orderService)
+	taxHandler := handlers.NewTaxHandler(taxService)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
+	couponHandler := handlers.NewCouponHandler(couponService)
+	shiftHandler := handlers.NewShiftHandler(shiftService)
+	receiptHandler := handlers.NewReceiptHandler(receiptService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	approvalHandler := handlers.NewApprovalHandler(approvalService)
+	securityHandler := handlers.NewSecurityHandler(loginGuard)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	locationHandler := handlers.NewLocationHandler(locationService)
+	reorderHandler := handlers.NewReorderHandler(reorderService)
+	supplierHandler := handlers.NewSupplierHandler(supplierService)
+	purchaseOrderHandler := handlers.NewPurchaseOrderHandler(purchaseOrderService)
 
 	// Initialize authentication middleware
-	authMiddleware := middleware.NewAuthMiddleware(userService)
+	authMiddleware := middleware.NewAuthMiddleware(userService, apiKeyService)
 
 	// Create handlers instance
-	handlers := router.NewHandlers(authHandler, productHandler, categoryHandler, inventoryHandler, customerHandler, orderHandler)
+	handlers := router.NewHandlers(authHandler, productHandler, categoryHandler, inventoryHandler, customerHandler, orderHandler, taxHandler, promotionHandler, couponHandler, shiftHandler, receiptHandler, roleHandler, approvalHandler, securityHandler, apiKeyHandler, auditHandler, locationHandler, reorderHandler, supplierHandler, purchaseOrderHandler)
 
 	// Create Fiber app
+	// Behind a reverse proxy, client IPs for login throttling come from
+	// PROXY_HEADER, but only on requests from TRUSTED_PROXIES, so that
+	// clients cannot pick their own IP
 	app := fiber.New(fiber.Config{
-		ErrorHandler: middleware.ErrorHandler,
+		ErrorHandler:            middleware.ErrorHandler,
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      true,
 	})
 
 	// Setup routes
@@ -116,6 +314,86 @@ func main() {
 	}
 }
 
+// loadSigningKeys returns the keys that sign and verify tokens: the keys in
+// JWT_KEYS_DIR, or else JWT_SECRET. Without either, development servers sign
+// with a key that is lost on restart, and production servers do not start.
+func loadSigningKeys(cfg *config.Config) (*signing.KeySet, error) {
+	if cfg.JWTKeysDir != "" {
+		keys, err := signing.LoadDir(cfg.JWTKeysDir, cfg.JWTSigningKey, cfg.JWTIssuer)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Signing tokens with key %s", keys.ActiveKeyID())
+		return keys, nil
+	}
+
+	if !signing.IsDefaultSecret(cfg.JWTSecret) {
+		log.Printf("Signing tokens with JWT_SECRET (HS256); set JWT_KEYS_DIR to sign with published keys instead")
+		return signing.NewSecret(cfg.JWTSecret, cfg.JWTIssuer), nil
+	}
+
+	if cfg.Environment == "production" {
+		return nil, fmt.Errorf("JWT_SECRET is empty or the example secret; set JWT_KEYS_DIR (see `%s generate-key`) or a strong JWT_SECRET", os.Args[0])
+	}
+
+	log.Printf("No JWT_KEYS_DIR or JWT_SECRET set, signing tokens with a temporary key; tokens stop working when the server restarts")
+	return signing.NewEphemeral(cfg.JWTIssuer)
+}
+
+// newMailer returns the mailer chosen by MAILER. The log and file mailers
+// only write emails out, which is meant for development.
+func newMailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case "smtp":
+		return mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		})
+	case "log", "file":
+		if cfg.Environment == "production" {
+			log.Printf("MAILER=%s: emails, including password reset tokens, are not sent; set MAILER=smtp to send them", cfg.Mailer)
+		}
+		if cfg.Mailer == "file" {
+			return mailer.NewFile(cfg.MailFile, cfg.MailFrom)
+		}
+		return mailer.NewLog(cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q; use smtp, log or file", cfg.Mailer)
+	}
+}
+
+// purgeExpiredTokens removes expired refresh tokens, revoked access tokens and
+// stale login throttles now and every hour after
+func purgeExpiredTokens(userService *services.UserService) {
+	for {
+		if err := userService.PurgeExpiredTokens(); err != nil {
+			log.Printf("Error purging expired tokens: %v", err)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// watchStockAlerts evaluates stock alerts whenever stock changes and at
+// every interval
+func watchStockAlerts(reorderService *services.ReorderService, changes <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := reorderService.EvaluateAlerts(); err != nil {
+			log.Printf("Error evaluating stock alerts: %v", err)
+		}
+
+		select {
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
 func setSwaggerHost(cfg *config.Config) {
 	host := ""
 	if cfg.BaseURL != "" {
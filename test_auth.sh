@@ -6,19 +6,21 @@ BASE_URL="http://localhost:8080/api/v1"
 echo "🧪 Testing JatiStore Authentication API"
 echo "========================================"
 
-# Test 1: Register a new user
+# Test 1: Create the first admin with the setup token
+# The server logs the setup token at startup while no admin exists, or set
+# SETUP_TOKEN in the server's environment
 echo ""
-echo "1. Testing user registration..."
-REGISTER_RESPONSE=$(curl -s -X POST "$BASE_URL/auth/register" \
+echo "1. Testing admin setup..."
+REGISTER_RESPONSE=$(curl -s -X POST "$BASE_URL/auth/setup" \
   -H "Content-Type: application/json" \
   -d '{
+    "setup_token": "'"$SETUP_TOKEN"'",
     "username": "admin",
     "email": "admin@jatistore.com",
-    "password": "admin123",
-    "role": "admin"
+    "password": "admin123"
   }')
 
-echo "Register Response: $REGISTER_RESPONSE"
+echo "Setup Response: $REGISTER_RESPONSE"
 
 # Test 2: Login
 echo ""
@@ -70,5 +72,18 @@ else
     echo "❌ Login failed! Cannot proceed with protected route tests."
 fi
 
+# Test 6: Repeated failed logins are throttled (should end with 429)
+echo ""
+echo "6. Testing login throttling (should end with 429)..."
+for i in 1 2 3; do
+    THROTTLE_STATUS=$(curl -s -o /dev/null -w "%{http_code}" -X POST "$BASE_URL/auth/login" \
+      -H "Content-Type: application/json" \
+      -d '{
+        "username": "throttle-test",
+        "password": "wrong-password"
+      }')
+    echo "Attempt $i: HTTP $THROTTLE_STATUS"
+done
+
 echo ""
 echo "🏁 Authentication tests completed!" 
\ No newline at end of file